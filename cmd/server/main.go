@@ -12,6 +12,7 @@ import (
 	"github.com/zhuangbiaowei/LocalAIStack/internal/config"
 	"github.com/zhuangbiaowei/LocalAIStack/internal/control"
 	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/runtime"
 	"github.com/zhuangbiaowei/LocalAIStack/pkg/logging"
 )
 
@@ -45,13 +46,27 @@ func main() {
 		log.Fatal().Err(err).Msg(i18n.T("Failed to start control layer"))
 	}
 
+	// The runtime manager outlives CLI invocations; `las service` reaches it
+	// through the supervisor socket.
+	runtimeManager := runtime.NewManager(cfg.Runtime)
+	if restored, err := runtimeManager.Restore(ctx); err != nil {
+		log.Error().Err(err).Msg(i18n.T("Failed to restore runtime state"))
+	} else if len(restored) > 0 {
+		log.Info().Int("modules", len(restored)).Msg(i18n.T("Restored runtime state"))
+	}
+
 	// Initialize API server
-	apiServer := api.NewServer(cfg, controlLayer)
+	apiServer := api.NewServer(cfg, controlLayer, runtimeManager)
 	go func() {
 		if err := apiServer.Start(); err != nil {
 			log.Error().Err(err).Msg(i18n.T("API server error"))
 		}
 	}()
+	go func() {
+		if err := apiServer.StartSupervisor(); err != nil {
+			log.Error().Err(err).Msg(i18n.T("Runtime supervisor error"))
+		}
+	}()
 
 	// Wait for interrupt signal
 	sigCh := make(chan os.Signal, 1)
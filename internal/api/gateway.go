@@ -0,0 +1,348 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/config"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/runtime"
+)
+
+// Labels that mark a runtime service as a model instance started by
+// `las model serve`. The gateway routes requests for the model, the
+// instance name or the served name to the recorded host and port.
+// On-demand instances are started by the gateway when first requested;
+// ModelVRAMLabel holds the bytes of VRAM they are estimated to use, and
+// ModelAdviceLabel the smart-run parameters that the gateway writes to
+// ModelAdvicePathLabel once a launch is healthy.
+const (
+	ModelLabel           = "io.localaistack.model"
+	ModelRuntimeLabel    = "io.localaistack.model.runtime"
+	ModelHostLabel       = "io.localaistack.model.host"
+	ModelPortLabel       = "io.localaistack.model.port"
+	ModelServedNameLabel = "io.localaistack.model.served-name"
+	ModelOnDemandLabel   = "io.localaistack.model.on-demand"
+	ModelVRAMLabel       = "io.localaistack.model.vram-bytes"
+	ModelAdviceLabel     = "io.localaistack.model.smart-run-advice"
+	ModelAdvicePathLabel = "io.localaistack.model.smart-run-path"
+)
+
+const (
+	gatewayMaxRequestBytes = 64 << 20
+	gatewayOllamaTimeout   = 2 * time.Second
+)
+
+// LocalServerAddress is the address at which this machine reaches a server
+// bound to host and port.
+func LocalServerAddress(host, port string) string {
+	switch host = strings.Trim(strings.TrimSpace(host), "[]"); host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// modelInstances is the part of runtime.Manager the gateway relies on.
+type modelInstances interface {
+	List() []runtime.Status
+	Status(name string) (runtime.Status, bool)
+	Spec(name string) (runtime.ModuleSpec, bool)
+	Start(ctx context.Context, spec runtime.ModuleSpec) (*runtime.Status, error)
+	Stop(ctx context.Context, name string) error
+}
+
+// gateway serves the OpenAI API of every local model behind one endpoint.
+type gateway struct {
+	instances modelInstances
+	loader    *modelLoader
+	ollamaURL *url.URL
+	client    *http.Client
+}
+
+// modelBackend is a server that answers for one or more model names.
+type modelBackend struct {
+	id      string
+	ownedBy string
+	created int64
+	names   []string
+	target  *url.URL
+	// model replaces the model of forwarded requests when set; vLLM rejects
+	// names other than the one it serves.
+	model  string
+	health runtime.HealthState
+	// instance is set for local instances; onDemand ones may be stopped.
+	instance string
+	state    runtime.ProcessState
+	onDemand bool
+}
+
+type gatewayError struct {
+	Error gatewayErrorBody `json:"error"`
+}
+
+type gatewayErrorBody struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+}
+
+type gatewayModel struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+type gatewayModelList struct {
+	Object string         `json:"object"`
+	Data   []gatewayModel `json:"data"`
+}
+
+func newGateway(cfg config.GatewayConfig, instances modelInstances) *gateway {
+	g := &gateway{
+		instances: instances,
+		loader:    newModelLoader(cfg, instances),
+		client:    &http.Client{Timeout: gatewayOllamaTimeout},
+	}
+	if raw := strings.TrimSpace(cfg.OllamaURL); raw != "" {
+		if parsed, err := url.Parse(raw); err == nil && parsed.Host != "" {
+			g.ollamaURL = parsed
+		} else {
+			log.Warn().Str("url", raw).Msg(i18n.T("ignoring invalid gateway.ollama_url"))
+		}
+	}
+	return g
+}
+
+func (g *gateway) register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /v1/models", g.models)
+	mux.HandleFunc("POST /v1/chat/completions", g.proxy)
+	mux.HandleFunc("POST /v1/completions", g.proxy)
+	mux.HandleFunc("POST /v1/embeddings", g.proxy)
+}
+
+func (g *gateway) models(w http.ResponseWriter, r *http.Request) {
+	list := gatewayModelList{Object: "list", Data: []gatewayModel{}}
+	seen := make(map[string]bool)
+	for _, backend := range append(g.instanceBackends(), g.ollamaBackends(r.Context())...) {
+		if seen[backend.id] {
+			continue
+		}
+		seen[backend.id] = true
+		list.Data = append(list.Data, gatewayModel{ID: backend.id, Object: "model", Created: backend.created, OwnedBy: backend.ownedBy})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// proxy forwards a request to the backend serving its model. Responses,
+// including server-sent event streams, are copied through unbuffered.
+func (g *gateway) proxy(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, gatewayMaxRequestBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeGatewayError(w, http.StatusRequestEntityTooLarge, "invalid_request_error", "", i18n.T("request body is too large"))
+			return
+		}
+		writeGatewayError(w, http.StatusBadRequest, "invalid_request_error", "", i18n.T("failed to read request body: %v", err))
+		return
+	}
+	var request struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil || strings.TrimSpace(request.Model) == "" {
+		writeGatewayError(w, http.StatusBadRequest, "invalid_request_error", "", i18n.T("request body must be a JSON object with a model field"))
+		return
+	}
+
+	backend, ok := g.route(r.Context(), request.Model)
+	if !ok {
+		writeGatewayError(w, http.StatusNotFound, "invalid_request_error", "model_not_found",
+			i18n.T("model %q is not served by LocalAIStack; see GET /v1/models", request.Model))
+		return
+	}
+	if backend.model != "" && backend.model != request.Model {
+		if body, err = replaceRequestModel(body, backend.model); err != nil {
+			writeGatewayError(w, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+			return
+		}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+
+	// Generations, and waiting for a model to load, take longer than the
+	// server's write timeout.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	if backend.instance != "" {
+		release, err := g.loader.acquire(r.Context(), backend)
+		if err != nil {
+			if r.Context().Err() == nil {
+				log.Warn().Err(err).Str("model", request.Model).Str("instance", backend.instance).Msg(i18n.T("failed to load model instance"))
+			}
+			writeGatewayError(w, http.StatusServiceUnavailable, "api_error", "model_not_loaded", err.Error())
+			return
+		}
+		defer release()
+	}
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(backend.target)
+		},
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Warn().Err(err).Str("model", request.Model).Str("backend", backend.id).Msg(i18n.T("gateway request failed"))
+			writeGatewayError(w, http.StatusBadGateway, "api_error", "", i18n.T("model %s is not reachable: %v", backend.id, err))
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// route picks the backend for model. Local instances win over Ollama, a
+// healthy instance over one that is still loading, and a running instance
+// over an on-demand one that has to be started.
+func (g *gateway) route(ctx context.Context, model string) (modelBackend, bool) {
+	var best *modelBackend
+	instances := g.instanceBackends()
+	for i := range instances {
+		if instances[i].serves(model) && (best == nil || instances[i].rank() > best.rank()) {
+			best = &instances[i]
+		}
+	}
+	if best != nil {
+		return *best, true
+	}
+	for _, backend := range g.ollamaBackends(ctx) {
+		if backend.serves(model) {
+			return backend, true
+		}
+	}
+	return modelBackend{}, false
+}
+
+func (g *gateway) instanceBackends() []modelBackend {
+	if g.instances == nil {
+		return nil
+	}
+	var backends []modelBackend
+	for _, status := range g.instances.List() {
+		port := status.Labels[ModelPortLabel]
+		onDemand := isOnDemandInstance(status)
+		if (status.State != runtime.StateRunning && !onDemand) || status.Labels[ModelLabel] == "" || port == "" {
+			continue
+		}
+		backends = append(backends, modelBackend{
+			id:       status.Name,
+			ownedBy:  status.Labels[ModelRuntimeLabel],
+			created:  status.StartedAt.Unix(),
+			names:    []string{status.Name, status.Labels[ModelLabel], status.Labels[ModelServedNameLabel]},
+			target:   &url.URL{Scheme: "http", Host: LocalServerAddress(status.Labels[ModelHostLabel], port)},
+			model:    status.Labels[ModelServedNameLabel],
+			health:   status.Health,
+			instance: status.Name,
+			state:    status.State,
+			onDemand: onDemand,
+		})
+	}
+	sort.Slice(backends, func(i, j int) bool { return backends[i].id < backends[j].id })
+	return backends
+}
+
+// ollamaBackends lists the models pulled into the Ollama daemon, or nothing
+// when it is not running.
+func (g *gateway) ollamaBackends(ctx context.Context) []modelBackend {
+	if g.ollamaURL == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, gatewayOllamaTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.ollamaURL.JoinPath("api", "tags").String(), nil)
+	if err != nil {
+		return nil
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	var tags struct {
+		Models []struct {
+			Name       string    `json:"name"`
+			ModifiedAt time.Time `json:"modified_at"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil
+	}
+	backends := make([]modelBackend, 0, len(tags.Models))
+	for _, model := range tags.Models {
+		backends = append(backends, modelBackend{
+			id:      model.Name,
+			ownedBy: "ollama",
+			created: model.ModifiedAt.Unix(),
+			names:   []string{model.Name, strings.TrimSuffix(model.Name, ":latest")},
+			target:  g.ollamaURL,
+			health:  runtime.HealthHealthy,
+		})
+	}
+	return backends
+}
+
+func (b modelBackend) serves(model string) bool {
+	for _, name := range b.names {
+		if name != "" && name == model {
+			return true
+		}
+	}
+	return false
+}
+
+func (b modelBackend) rank() int {
+	if b.state != runtime.StateRunning {
+		return 2
+	}
+	switch b.health {
+	case runtime.HealthHealthy:
+		return 4
+	case runtime.HealthUnknown:
+		return 3
+	}
+	return 1
+}
+
+func isOnDemandInstance(status runtime.Status) bool {
+	return status.Labels[ModelOnDemandLabel] == "true"
+}
+
+func replaceRequestModel(body []byte, model string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+	fields["model"] = encoded
+	return json.Marshal(fields)
+}
+
+func writeGatewayError(w http.ResponseWriter, code int, errorType, errorCode, message string) {
+	writeJSON(w, code, gatewayError{Error: gatewayErrorBody{Message: message, Type: errorType, Code: errorCode}})
+}
@@ -0,0 +1,414 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/config"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/runtime"
+	"github.com/zhuangbiaowei/LocalAIStack/pkg/hardware"
+)
+
+const (
+	modelLoadPollInterval = 500 * time.Millisecond
+	modelProbeTimeout     = 2 * time.Second
+)
+
+// modelLoader starts on-demand instances for the requests that need them
+// and stops them again when they sit idle or their VRAM is needed for
+// another model. Instances started by hand are never stopped.
+type modelLoader struct {
+	instances    modelInstances
+	idleTTL      time.Duration
+	loadTimeout  time.Duration
+	pollInterval time.Duration
+	probe        *http.Client
+
+	budgetOnce sync.Once
+	budget     uint64
+	detectVRAM func() uint64
+
+	// admitMu serializes eviction and start so two loads cannot both claim
+	// the same free VRAM.
+	admitMu  sync.Mutex
+	mu       sync.Mutex
+	lastUsed map[string]time.Time
+	inFlight map[string]int
+	loads    map[string]*modelLoad
+	// stopping holds the instances being evicted or reaped; the channel is
+	// closed once they are stopped.
+	stopping map[string]chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// modelLoad is a start in progress; requests for the instance wait on done.
+type modelLoad struct {
+	done chan struct{}
+	err  error
+}
+
+func newModelLoader(cfg config.GatewayConfig, instances modelInstances) *modelLoader {
+	l := &modelLoader{
+		instances:    instances,
+		idleTTL:      time.Duration(cfg.IdleTTLSeconds) * time.Second,
+		loadTimeout:  time.Duration(cfg.LoadTimeoutSeconds) * time.Second,
+		pollInterval: modelLoadPollInterval,
+		probe:        &http.Client{Timeout: modelProbeTimeout},
+		detectVRAM:   detectVRAMBudget,
+		lastUsed:     make(map[string]time.Time),
+		inFlight:     make(map[string]int),
+		loads:        make(map[string]*modelLoad),
+		stopping:     make(map[string]chan struct{}),
+		stop:         make(chan struct{}),
+	}
+	if cfg.VRAMBudgetMB > 0 {
+		l.budget = uint64(cfg.VRAMBudgetMB) << 20
+	}
+	if l.loadTimeout <= 0 {
+		l.loadTimeout = 15 * time.Minute
+	}
+	return l
+}
+
+// acquire marks backend in use for one request, first starting it and
+// waiting until it answers if it is an on-demand instance that is not
+// ready or is being stopped. The returned func ends the request.
+func (l *modelLoader) acquire(ctx context.Context, backend modelBackend) (func(), error) {
+	name := backend.instance
+	l.mu.Lock()
+	l.inFlight[name]++
+	l.lastUsed[name] = time.Now()
+	stopping := l.stopping[name]
+	l.mu.Unlock()
+	release := func() {
+		l.mu.Lock()
+		l.inFlight[name]--
+		l.lastUsed[name] = time.Now()
+		l.mu.Unlock()
+	}
+	if stopping == nil && (!backend.onDemand || (backend.state == runtime.StateRunning && backend.health == runtime.HealthHealthy)) {
+		return release, nil
+	}
+	if stopping != nil {
+		select {
+		case <-stopping:
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	if err := l.load(ctx, name); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// load starts name unless a start is already under way and waits for it to
+// finish. The start outlives ctx so other queued requests still get it.
+func (l *modelLoader) load(ctx context.Context, name string) error {
+	l.mu.Lock()
+	current, ok := l.loads[name]
+	if !ok {
+		current = &modelLoad{done: make(chan struct{})}
+		l.loads[name] = current
+		go func() {
+			current.err = l.start(name)
+			l.mu.Lock()
+			delete(l.loads, name)
+			l.mu.Unlock()
+			close(current.done)
+		}()
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-current.done:
+		return current.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *modelLoader) start(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), l.loadTimeout)
+	defer cancel()
+
+	admitted := false
+	if status, ok := l.instances.Status(name); !ok || !modelInstanceActive(status) {
+		if err := l.admit(ctx, name); err != nil {
+			return err
+		}
+		admitted = true
+	}
+	if err := l.waitReady(ctx, name); err != nil {
+		return err
+	}
+	if admitted {
+		l.saveAdvice(name)
+	}
+	return nil
+}
+
+// saveAdvice records the smart-run parameters of an instance the gateway
+// launched successfully, so later `las model run` and serve launches start
+// from them.
+func (l *modelLoader) saveAdvice(name string) {
+	spec, ok := l.instances.Spec(name)
+	if !ok {
+		return
+	}
+	path, payload := spec.Labels[ModelAdvicePathLabel], spec.Labels[ModelAdviceLabel]
+	if path == "" || payload == "" {
+		return
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err == nil {
+		err = os.WriteFile(path, []byte(payload), 0o600)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("instance", name).Msg(i18n.T("failed to save smart-run parameters"))
+	}
+}
+
+// admit starts name with its recorded spec after evicting idle on-demand
+// instances, least recently used first, until its VRAM fits the budget.
+func (l *modelLoader) admit(ctx context.Context, name string) error {
+	l.admitMu.Lock()
+	defer l.admitMu.Unlock()
+
+	spec, ok := l.instances.Spec(name)
+	if !ok {
+		return i18n.Errorf("model instance %s is no longer registered", name)
+	}
+	if err := l.evictFor(ctx, name, modelVRAM(spec.Labels)); err != nil {
+		return err
+	}
+	log.Info().Str("instance", name).Msg(i18n.T("starting on-demand model instance"))
+	if _, err := l.instances.Start(ctx, spec); err != nil {
+		return i18n.Errorf("start model instance %s: %w", name, err)
+	}
+	return nil
+}
+
+func (l *modelLoader) evictFor(ctx context.Context, name string, need uint64) error {
+	if need == 0 {
+		return nil
+	}
+	budget := l.vramBudget()
+	if budget == 0 {
+		return nil
+	}
+
+	var used uint64
+	var idle []runtime.Status
+	l.mu.Lock()
+	for _, status := range l.instances.List() {
+		if status.Name == name || !modelInstanceActive(status) {
+			continue
+		}
+		used += modelVRAM(status.Labels)
+		if isOnDemandInstance(status) && l.inFlight[status.Name] == 0 && l.loads[status.Name] == nil {
+			idle = append(idle, status)
+		}
+	}
+	lastUsed := make(map[string]time.Time, len(idle))
+	for _, status := range idle {
+		lastUsed[status.Name] = l.lastUsed[status.Name]
+	}
+	l.mu.Unlock()
+	sort.Slice(idle, func(i, j int) bool { return lastUsed[idle[i].Name].Before(lastUsed[idle[j].Name]) })
+
+	for _, status := range idle {
+		if used+need <= budget {
+			break
+		}
+		l.mu.Lock()
+		claimed := l.claimIdle(status.Name)
+		l.mu.Unlock()
+		if !claimed {
+			continue
+		}
+		log.Info().Str("instance", status.Name).Str("for", name).Msg(i18n.T("evicting least recently used model instance"))
+		if err := l.stopInstance(ctx, status.Name); err != nil {
+			return err
+		}
+		used -= modelVRAM(status.Labels)
+	}
+	if used+need > budget {
+		return i18n.Errorf("not enough VRAM to load %s: it needs %d MiB and %d of %d MiB are held by busy instances",
+			name, need>>20, used>>20, budget>>20)
+	}
+	return nil
+}
+
+// waitReady waits until name answers its health check. The runtime probes
+// on a long interval during loading, so the check is also run directly.
+func (l *modelLoader) waitReady(ctx context.Context, name string) error {
+	ticker := time.NewTicker(l.pollInterval)
+	defer ticker.Stop()
+	for {
+		status, ok := l.instances.Status(name)
+		if !ok {
+			return i18n.Errorf("model instance %s is no longer registered", name)
+		}
+		if !modelInstanceActive(status) {
+			return i18n.Errorf("model instance %s is %s instead of loading; see `las model logs %s`", name, status.State, name)
+		}
+		if status.State == runtime.StateRunning && (status.Health == runtime.HealthHealthy || l.probeHealth(ctx, name)) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return i18n.Errorf("model instance %s did not become ready within %s", name, l.loadTimeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+func (l *modelLoader) probeHealth(ctx context.Context, name string) bool {
+	spec, ok := l.instances.Spec(name)
+	if !ok {
+		return false
+	}
+	if spec.HealthCheck.HTTP == nil {
+		return spec.HealthCheck.TCP == nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, spec.HealthCheck.HTTP.URL, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := l.probe.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+// reapIdle stops on-demand instances that served no request for the idle
+// TTL until close is called. Instances found running are given a full TTL.
+func (l *modelLoader) reapIdle() {
+	if l.idleTTL <= 0 {
+		return
+	}
+	ticker := time.NewTicker(min(l.idleTTL/2, time.Minute))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case now := <-ticker.C:
+			for _, name := range l.idleInstances(now) {
+				log.Info().Str("instance", name).Dur("idle_ttl", l.idleTTL).Msg(i18n.T("stopping idle model instance"))
+				ctx, cancel := context.WithTimeout(context.Background(), supervisorStopTimeout)
+				if err := l.stopInstance(ctx, name); err != nil {
+					log.Warn().Err(err).Str("instance", name).Msg(i18n.T("failed to stop idle model instance"))
+				}
+				cancel()
+			}
+		}
+	}
+}
+
+func (l *modelLoader) idleInstances(now time.Time) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var idle []string
+	for _, status := range l.instances.List() {
+		if !isOnDemandInstance(status) || status.State != runtime.StateRunning {
+			continue
+		}
+		last, seen := l.lastUsed[status.Name]
+		if !seen {
+			l.lastUsed[status.Name] = now
+			continue
+		}
+		if now.Sub(last) >= l.idleTTL && l.claimIdle(status.Name) {
+			idle = append(idle, status.Name)
+		}
+	}
+	return idle
+}
+
+// claimIdle marks name as stopping unless a request is using or loading
+// it; requests that arrive later wait for the stop and load it again. The
+// caller must hold l.mu.
+func (l *modelLoader) claimIdle(name string) bool {
+	if l.inFlight[name] > 0 || l.loads[name] != nil || l.stopping[name] != nil {
+		return false
+	}
+	l.stopping[name] = make(chan struct{})
+	return true
+}
+
+// stopInstance stops an instance claimed with claimIdle.
+func (l *modelLoader) stopInstance(ctx context.Context, name string) error {
+	err := l.instances.Stop(ctx, name)
+	l.mu.Lock()
+	if err == nil {
+		delete(l.lastUsed, name)
+	}
+	if stopping := l.stopping[name]; stopping != nil {
+		close(stopping)
+		delete(l.stopping, name)
+	}
+	l.mu.Unlock()
+	if err != nil {
+		return i18n.Errorf("stop model instance %s: %w", name, err)
+	}
+	return nil
+}
+
+func (l *modelLoader) close() {
+	l.stopOnce.Do(func() { close(l.stop) })
+}
+
+func (l *modelLoader) vramBudget() uint64 {
+	l.budgetOnce.Do(func() {
+		if l.budget == 0 {
+			l.budget = l.detectVRAM()
+			log.Info().Uint64("vram_mib", l.budget>>20).Msg(i18n.T("detected VRAM budget for on-demand models"))
+		}
+	})
+	return l.budget
+}
+
+func detectVRAMBudget() uint64 {
+	gpus, err := hardware.NewNativeDetector().DetectGPUs()
+	if err != nil {
+		log.Warn().Err(err).Msg(i18n.T("failed to detect GPUs; on-demand models are not evicted for VRAM"))
+		return 0
+	}
+	var total uint64
+	for _, gpu := range gpus {
+		total += gpu.VRAMTotal
+	}
+	return total
+}
+
+// modelVRAM is the estimated VRAM of an instance, 0 when unknown.
+func modelVRAM(labels map[string]string) uint64 {
+	value, err := strconv.ParseUint(labels[ModelVRAMLabel], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+func modelInstanceActive(status runtime.Status) bool {
+	switch status.State {
+	case runtime.StateStarting, runtime.StateRunning, runtime.StateRestarting:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,527 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/config"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/runtime"
+)
+
+// fakeModelServer stands in for llama-server, vLLM or Ollama and records the
+// requests it receives.
+type fakeModelServer struct {
+	*httptest.Server
+	requests chan recordedRequest
+}
+
+type recordedRequest struct {
+	path  string
+	model string
+}
+
+func newFakeModelServer(t *testing.T, name string, mux *http.ServeMux) *fakeModelServer {
+	t.Helper()
+	fake := &fakeModelServer{requests: make(chan recordedRequest, 10)}
+	if mux == nil {
+		mux = http.NewServeMux()
+	}
+	mux.HandleFunc("POST /v1/", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		fake.requests <- recordedRequest{path: r.URL.Path, model: body.Model}
+		writeJSON(w, http.StatusOK, map[string]string{"backend": name, "model": body.Model})
+	})
+	fake.Server = httptest.NewServer(mux)
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func modelInstanceStatus(t *testing.T, name, model, runtimeName string, backend *httptest.Server, health runtime.HealthState) runtime.Status {
+	t.Helper()
+	parsed, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatalf("parse backend url: %v", err)
+	}
+	return runtime.Status{
+		Name:      name,
+		State:     runtime.StateRunning,
+		Health:    health,
+		StartedAt: time.Unix(1700000000, 0),
+		Labels: map[string]string{
+			ModelLabel:        model,
+			ModelRuntimeLabel: runtimeName,
+			ModelHostLabel:    "0.0.0.0",
+			ModelPortLabel:    parsed.Port(),
+		},
+	}
+}
+
+// fakeInstances stands in for the runtime manager. Started instances run
+// immediately; whether they answer is up to their backend.
+type fakeInstances struct {
+	mu       sync.Mutex
+	statuses map[string]runtime.Status
+	specs    map[string]runtime.ModuleSpec
+	started  []string
+	stopped  []string
+}
+
+func newFakeInstances(statuses ...runtime.Status) *fakeInstances {
+	f := &fakeInstances{statuses: make(map[string]runtime.Status), specs: make(map[string]runtime.ModuleSpec)}
+	for _, status := range statuses {
+		f.statuses[status.Name] = status
+		f.specs[status.Name] = runtime.ModuleSpec{Name: status.Name, Labels: status.Labels}
+	}
+	return f
+}
+
+func (f *fakeInstances) List() []runtime.Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	statuses := make([]runtime.Status, 0, len(f.statuses))
+	for _, status := range f.statuses {
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func (f *fakeInstances) Status(name string) (runtime.Status, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status, ok := f.statuses[name]
+	return status, ok
+}
+
+func (f *fakeInstances) Spec(name string) (runtime.ModuleSpec, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	spec, ok := f.specs[name]
+	return spec, ok
+}
+
+func (f *fakeInstances) Start(ctx context.Context, spec runtime.ModuleSpec) (*runtime.Status, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status := f.statuses[spec.Name]
+	status.State = runtime.StateRunning
+	status.Health = runtime.HealthUnknown
+	f.statuses[spec.Name] = status
+	f.started = append(f.started, spec.Name)
+	return &status, nil
+}
+
+func (f *fakeInstances) Stop(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status := f.statuses[name]
+	status.State = runtime.StateStopped
+	f.statuses[name] = status
+	f.stopped = append(f.stopped, name)
+	return nil
+}
+
+func (f *fakeInstances) calls() (started, stopped []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.started...), append([]string(nil), f.stopped...)
+}
+
+func newTestGateway(t *testing.T, ollamaURL string, statuses ...runtime.Status) *httptest.Server {
+	t.Helper()
+	return serveGateway(t, newGateway(config.GatewayConfig{OllamaURL: ollamaURL}, newFakeInstances(statuses...)))
+}
+
+func serveGateway(t *testing.T, g *gateway) *httptest.Server {
+	t.Helper()
+	g.loader.pollInterval = 5 * time.Millisecond
+	mux := http.NewServeMux()
+	g.register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func postJSON(t *testing.T, srv *httptest.Server, path, body string) (int, map[string]any) {
+	t.Helper()
+	resp, err := http.Post(srv.URL+path, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	var payload map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode response of %s: %v", path, err)
+	}
+	return resp.StatusCode, payload
+}
+
+func TestGatewayRoutesByModel(t *testing.T) {
+	llama := newFakeModelServer(t, "llama", nil)
+	vllm := newFakeModelServer(t, "vllm", nil)
+	vllmStatus := modelInstanceStatus(t, "qwen", "Qwen/Qwen3-8B", "vllm", vllm.Server, runtime.HealthHealthy)
+	vllmStatus.Labels[ModelServedNameLabel] = "qwen3-8b"
+	stopped := modelInstanceStatus(t, "old", "old/model", "llama.cpp", llama.Server, runtime.HealthHealthy)
+	stopped.State = runtime.StateStopped
+	srv := newTestGateway(t, "",
+		modelInstanceStatus(t, "coder", "unsloth/Qwen3-Coder-Next-GGUF", "llama.cpp", llama.Server, runtime.HealthHealthy),
+		vllmStatus,
+		stopped,
+	)
+
+	tests := []struct {
+		path      string
+		model     string
+		backend   *fakeModelServer
+		wantModel string
+	}{
+		{"/v1/chat/completions", "coder", llama, "coder"},
+		{"/v1/completions", "unsloth/Qwen3-Coder-Next-GGUF", llama, "unsloth/Qwen3-Coder-Next-GGUF"},
+		{"/v1/embeddings", "qwen", vllm, "qwen3-8b"},
+		{"/v1/chat/completions", "Qwen/Qwen3-8B", vllm, "qwen3-8b"},
+	}
+	for _, tt := range tests {
+		code, payload := postJSON(t, srv, tt.path, `{"model":"`+tt.model+`","messages":[{"role":"user","content":"hi"}]}`)
+		if code != http.StatusOK {
+			t.Fatalf("%s %s: expected 200, got %d %v", tt.path, tt.model, code, payload)
+		}
+		select {
+		case got := <-tt.backend.requests:
+			if got.path != tt.path || got.model != tt.wantModel {
+				t.Fatalf("%s %s: backend received %+v, want model %q", tt.path, tt.model, got, tt.wantModel)
+			}
+		default:
+			t.Fatalf("%s %s: expected the request at the other backend, got %v", tt.path, tt.model, payload)
+		}
+	}
+
+	code, payload := postJSON(t, srv, "/v1/chat/completions", `{"model":"old"}`)
+	if errBody, _ := payload["error"].(map[string]any); code != http.StatusNotFound || errBody["code"] != "model_not_found" {
+		t.Fatalf("expected a stopped instance to be unroutable, got %d %v", code, payload)
+	}
+	if code, _ := postJSON(t, srv, "/v1/chat/completions", `{"messages":[]}`); code != http.StatusBadRequest {
+		t.Fatalf("expected a request without model to be rejected, got %d", code)
+	}
+}
+
+func TestGatewayPrefersHealthyInstance(t *testing.T) {
+	loading := newFakeModelServer(t, "loading", nil)
+	ready := newFakeModelServer(t, "ready", nil)
+	srv := newTestGateway(t, "",
+		modelInstanceStatus(t, "a", "llama3", "llama.cpp", loading.Server, runtime.HealthUnknown),
+		modelInstanceStatus(t, "b", "llama3", "llama.cpp", ready.Server, runtime.HealthHealthy),
+	)
+	if _, payload := postJSON(t, srv, "/v1/chat/completions", `{"model":"llama3"}`); payload["backend"] != "ready" {
+		t.Fatalf("expected the healthy instance to answer, got %v", payload)
+	}
+}
+
+func TestGatewayStreamsServerSentEvents(t *testing.T) {
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		io.WriteString(w, "data: {\"delta\":\"Hel\"}\n\n")
+		flusher.Flush()
+		<-release
+		io.WriteString(w, "data: {\"delta\":\"lo\"}\n\ndata: [DONE]\n\n")
+	})
+	backend := httptest.NewServer(mux)
+	t.Cleanup(backend.Close)
+	srv := newTestGateway(t, "", modelInstanceStatus(t, "chat", "llama3", "llama.cpp", backend, runtime.HealthHealthy))
+
+	resp, err := http.Post(srv.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"chat","stream":true}`))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", resp.Header.Get("Content-Type"))
+	}
+
+	// The first event must arrive while the backend is still generating.
+	reader := bufio.NewReader(resp.Body)
+	first := make(chan string, 1)
+	go func() {
+		line, _ := reader.ReadString('\n')
+		first <- line
+	}()
+	select {
+	case line := <-first:
+		if line != "data: {\"delta\":\"Hel\"}\n" {
+			t.Fatalf("unexpected first event %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the first event was buffered by the gateway")
+	}
+	close(release)
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read stream: %v", err)
+	}
+	if !strings.HasSuffix(string(rest), "data: [DONE]\n\n") {
+		t.Fatalf("unexpected end of stream %q", rest)
+	}
+}
+
+func TestGatewayFallsBackToOllama(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"models":[{"name":"qwen3:latest","modified_at":"2025-01-02T03:04:05Z"},{"name":"llama3.2:3b"}]}`)
+	})
+	ollama := newFakeModelServer(t, "ollama", mux)
+	llama := newFakeModelServer(t, "llama", nil)
+	srv := newTestGateway(t, ollama.URL, modelInstanceStatus(t, "coder", "unsloth/Qwen3-Coder-Next-GGUF", "llama.cpp", llama.Server, runtime.HealthHealthy))
+
+	if _, payload := postJSON(t, srv, "/v1/chat/completions", `{"model":"qwen3"}`); payload["backend"] != "ollama" || payload["model"] != "qwen3" {
+		t.Fatalf("expected Ollama to serve qwen3, got %v", payload)
+	}
+	if got := <-ollama.requests; got.path != "/v1/chat/completions" {
+		t.Fatalf("unexpected Ollama request %+v", got)
+	}
+
+	resp, err := http.Get(srv.URL + "/v1/models")
+	if err != nil {
+		t.Fatalf("GET /v1/models: %v", err)
+	}
+	defer resp.Body.Close()
+	var list gatewayModelList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("decode model list: %v", err)
+	}
+	var ids []string
+	for _, model := range list.Data {
+		ids = append(ids, model.ID+"/"+model.OwnedBy)
+	}
+	if list.Object != "list" || strings.Join(ids, ",") != "coder/llama.cpp,qwen3:latest/ollama,llama3.2:3b/ollama" {
+		t.Fatalf("unexpected model list %+v", list)
+	}
+}
+
+func TestGatewayReportsUnreachableBackend(t *testing.T) {
+	backend := httptest.NewServer(http.NotFoundHandler())
+	status := modelInstanceStatus(t, "gone", "llama3", "llama.cpp", backend, runtime.HealthHealthy)
+	backend.Close()
+	srv := newTestGateway(t, "", status)
+	if code, payload := postJSON(t, srv, "/v1/chat/completions", `{"model":"gone"}`); code != http.StatusBadGateway || payload["error"] == nil {
+		t.Fatalf("expected 502 with an error body, got %d %v", code, payload)
+	}
+}
+
+func onDemandStatus(t *testing.T, name string, backend *httptest.Server, vramMiB int) runtime.Status {
+	t.Helper()
+	status := modelInstanceStatus(t, name, "org/"+name, "llama.cpp", backend, runtime.HealthUnhealthy)
+	status.State = runtime.StateStopped
+	status.Labels[ModelOnDemandLabel] = "true"
+	if vramMiB > 0 {
+		status.Labels[ModelVRAMLabel] = strconv.Itoa(vramMiB << 20)
+	}
+	return status
+}
+
+func TestGatewayStartsOnDemandInstanceAndQueuesRequests(t *testing.T) {
+	var ready atomic.Bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			http.Error(w, "loading model", http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "ok")
+	})
+	backend := newFakeModelServer(t, "lazy", mux)
+	instances := newFakeInstances(onDemandStatus(t, "lazy", backend.Server, 0))
+	advicePath := filepath.Join(t.TempDir(), "smart-run", "llama.cpp__org_lazy.json")
+	instances.statuses["lazy"].Labels[ModelAdvicePathLabel] = advicePath
+	instances.statuses["lazy"].Labels[ModelAdviceLabel] = `{"schema_version":3}`
+	instances.specs["lazy"] = runtime.ModuleSpec{
+		Name:        "lazy",
+		Labels:      instances.statuses["lazy"].Labels,
+		HealthCheck: runtime.HealthCheck{HTTP: &runtime.HTTPHealthCheck{URL: backend.URL + "/health"}},
+	}
+	srv := serveGateway(t, newGateway(config.GatewayConfig{}, instances))
+
+	if resp, err := http.Get(srv.URL + "/v1/models"); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /v1/models: %v", err)
+	} else {
+		var list gatewayModelList
+		json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if len(list.Data) != 1 || list.Data[0].ID != "lazy" {
+			t.Fatalf("expected the stopped on-demand instance to be listed, got %+v", list)
+		}
+	}
+
+	const requests = 3
+	codes := make(chan int, requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			code, _ := postJSON(t, srv, "/v1/chat/completions", `{"model":"org/lazy"}`)
+			codes <- code
+		}()
+	}
+	select {
+	case code := <-codes:
+		t.Fatalf("a request was answered with %d before the model finished loading", code)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, err := os.Stat(advicePath); !os.IsNotExist(err) {
+		t.Fatalf("smart-run parameters must not be saved before the instance is healthy")
+	}
+	ready.Store(true)
+	for i := 0; i < requests; i++ {
+		if code := <-codes; code != http.StatusOK {
+			t.Fatalf("expected queued requests to succeed once loaded, got %d", code)
+		}
+	}
+	if started, _ := instances.calls(); strings.Join(started, ",") != "lazy" {
+		t.Fatalf("expected a single start of the instance, got %v", started)
+	}
+	if len(backend.requests) != requests {
+		t.Fatalf("expected %d requests at the backend, got %d", requests, len(backend.requests))
+	}
+	if saved, err := os.ReadFile(advicePath); err != nil || string(saved) != `{"schema_version":3}` {
+		t.Fatalf("expected the smart-run parameters of the launch to be saved, got %q (%v)", saved, err)
+	}
+}
+
+func TestGatewayEvictsLeastRecentlyUsedInstances(t *testing.T) {
+	backend := newFakeModelServer(t, "model", nil)
+	running := func(status runtime.Status) runtime.Status {
+		status.State = runtime.StateRunning
+		status.Health = runtime.HealthHealthy
+		return status
+	}
+	manual := modelInstanceStatus(t, "manual", "org/manual", "vllm", backend.Server, runtime.HealthHealthy)
+	manual.Labels[ModelVRAMLabel] = strconv.Itoa(2000 << 20)
+	instances := newFakeInstances(
+		manual,
+		running(onDemandStatus(t, "old", backend.Server, 4000)),
+		running(onDemandStatus(t, "recent", backend.Server, 3000)),
+		running(onDemandStatus(t, "busy", backend.Server, 500)),
+		onDemandStatus(t, "next", backend.Server, 5000),
+		onDemandStatus(t, "huge", backend.Server, 9000),
+	)
+	g := newGateway(config.GatewayConfig{VRAMBudgetMB: 10000}, instances)
+	g.loader.detectVRAM = func() uint64 {
+		t.Fatalf("the configured budget must not be replaced by detection")
+		return 0
+	}
+	now := time.Now()
+	g.loader.lastUsed["old"] = now.Add(-2 * time.Minute)
+	g.loader.lastUsed["recent"] = now.Add(-time.Minute)
+	g.loader.inFlight["busy"] = 1
+	srv := serveGateway(t, g)
+
+	// 2000 manual + 4000 + 3000 + 500 busy leaves room for 5000 only once
+	// both idle instances are gone.
+	if code, payload := postJSON(t, srv, "/v1/completions", `{"model":"next"}`); code != http.StatusOK {
+		t.Fatalf("expected next to load, got %d %v", code, payload)
+	}
+	started, stopped := instances.calls()
+	if strings.Join(stopped, ",") != "old,recent" || strings.Join(started, ",") != "next" {
+		t.Fatalf("expected old then recent to be evicted for next, got stopped %v started %v", stopped, started)
+	}
+
+	code, payload := postJSON(t, srv, "/v1/completions", `{"model":"huge"}`)
+	if errBody, _ := payload["error"].(map[string]any); code != http.StatusServiceUnavailable || !strings.Contains(errBody["message"].(string), "not enough VRAM") {
+		t.Fatalf("expected huge to be refused while busy instances hold the VRAM, got %d %v", code, payload)
+	}
+	if status, _ := instances.Status("manual"); status.State != runtime.StateRunning {
+		t.Fatalf("an instance started by hand must not be evicted")
+	}
+}
+
+func TestModelLoaderFindsIdleInstances(t *testing.T) {
+	backend := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(backend.Close)
+	running := func(status runtime.Status) runtime.Status {
+		status.State = runtime.StateRunning
+		return status
+	}
+	instances := newFakeInstances(
+		running(onDemandStatus(t, "idle", backend, 0)),
+		running(onDemandStatus(t, "busy", backend, 0)),
+		onDemandStatus(t, "stopped", backend, 0),
+		modelInstanceStatus(t, "manual", "org/manual", "llama.cpp", backend, runtime.HealthHealthy),
+	)
+	loader := newModelLoader(config.GatewayConfig{IdleTTLSeconds: 60}, instances)
+	now := time.Now()
+	if idle := loader.idleInstances(now); len(idle) != 0 {
+		t.Fatalf("instances seen for the first time get a full TTL, got %v", idle)
+	}
+	loader.inFlight["busy"] = 1
+	if idle := loader.idleInstances(now.Add(59 * time.Second)); len(idle) != 0 {
+		t.Fatalf("nothing is idle before the TTL, got %v", idle)
+	}
+	if idle := loader.idleInstances(now.Add(time.Minute)); strings.Join(idle, ",") != "idle" {
+		t.Fatalf("expected only the unused on-demand instance to be idle, got %v", idle)
+	}
+	if idle := loader.idleInstances(now.Add(2 * time.Minute)); len(idle) != 0 {
+		t.Fatalf("an instance being stopped must not be claimed twice, got %v", idle)
+	}
+
+	// A request arriving while the instance is being stopped waits for the
+	// stop instead of being sent to it.
+	backendOf := modelBackend{instance: "idle", onDemand: true, state: runtime.StateRunning, health: runtime.HealthHealthy}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if release, err := loader.acquire(ctx, backendOf); err == nil {
+		release()
+		t.Fatalf("expected the request to wait for the stopping instance")
+	}
+	loader.pollInterval = time.Millisecond
+	acquired := make(chan error, 1)
+	go func() {
+		release, err := loader.acquire(context.Background(), backendOf)
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+	for waiting := false; !waiting; {
+		loader.mu.Lock()
+		waiting = loader.inFlight["idle"] > 0
+		loader.mu.Unlock()
+	}
+	if err := loader.stopInstance(context.Background(), "idle"); err != nil {
+		t.Fatalf("stopInstance returned error: %v", err)
+	}
+	if err := <-acquired; err != nil {
+		t.Fatalf("expected the stopped instance to be loaded again, got %v", err)
+	}
+	if started, _ := instances.calls(); strings.Join(started, ",") != "idle" {
+		t.Fatalf("expected the instance to be started again, got %v", started)
+	}
+}
+
+func TestLocalServerAddress(t *testing.T) {
+	cases := map[string]string{
+		"0.0.0.0":  "127.0.0.1:8080",
+		"":         "127.0.0.1:8080",
+		"::":       "[::1]:8080",
+		"10.0.0.5": "10.0.0.5:8080",
+		"[::1]":    "[::1]:8080",
+	}
+	for host, want := range cases {
+		if got := LocalServerAddress(host, "8080"); got != want {
+			t.Fatalf("LocalServerAddress(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
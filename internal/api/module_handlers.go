@@ -15,6 +15,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/rs/zerolog/log"
 	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
 	"github.com/zhuangbiaowei/LocalAIStack/internal/module"
 )
@@ -231,9 +232,12 @@ func listModules() ([]moduleInfo, error) {
 		return nil, err
 	}
 	registry, err := module.LoadRegistryFromDir(modulesRoot)
-	if err != nil {
+	if registry == nil {
 		return nil, err
 	}
+	if err != nil {
+		log.Warn().Err(err).Msg(i18n.T("some modules were skipped"))
+	}
 
 	all := registry.All()
 	names := make([]string, 0, len(all))
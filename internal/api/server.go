@@ -12,15 +12,19 @@ import (
 	"github.com/zhuangbiaowei/LocalAIStack/internal/control"
 	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
 	"github.com/zhuangbiaowei/LocalAIStack/internal/llm"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/runtime"
 )
 
 type Server struct {
 	cfg          *config.Config
 	controlLayer *control.ControlLayer
+	runtime      *runtime.Manager
 	server       *http.Server
+	supervisor   *http.Server
+	gateway      *gateway
 }
 
-func NewServer(cfg *config.Config, controlLayer *control.ControlLayer) *Server {
+func NewServer(cfg *config.Config, controlLayer *control.ControlLayer, runtimeManager *runtime.Manager) *Server {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", healthHandler)
 	mux.HandleFunc("/api/v1/status", statusHandler)
@@ -28,6 +32,7 @@ func NewServer(cfg *config.Config, controlLayer *control.ControlLayer) *Server {
 	server := &Server{
 		cfg:          cfg,
 		controlLayer: controlLayer,
+		runtime:      runtimeManager,
 		server: &http.Server{
 			Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
 			Handler:      mux,
@@ -44,11 +49,21 @@ func NewServer(cfg *config.Config, controlLayer *control.ControlLayer) *Server {
 	mux.HandleFunc("/api/v1/module/uninstall", server.moduleUninstallHandler)
 	mux.HandleFunc("/api/v1/module/check", server.moduleCheckHandler)
 
+	var instances modelInstances
+	if runtimeManager != nil {
+		instances = runtimeManager
+	}
+	server.gateway = newGateway(cfg.Gateway, instances)
+	server.gateway.register(mux)
+
 	return server
 }
 
 func (s *Server) Start() error {
 	log.Info().Str("addr", s.server.Addr).Msg(i18n.T("Starting API server"))
+	if s.runtime != nil {
+		go s.gateway.loader.reapIdle()
+	}
 	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		return err
 	}
@@ -59,6 +74,12 @@ func (s *Server) Stop() error {
 	log.Info().Msg(i18n.T("Stopping API server"))
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+	s.gateway.loader.close()
+	if s.supervisor != nil {
+		if err := s.supervisor.Shutdown(ctx); err != nil {
+			log.Warn().Err(err).Msg(i18n.T("Error stopping runtime supervisor"))
+		}
+	}
 	return s.server.Shutdown(ctx)
 }
 
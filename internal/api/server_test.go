@@ -18,7 +18,7 @@ func TestProvidersHandler(t *testing.T) {
 	cfg := config.DefaultConfig()
 	cfg.LLM.Provider = "eino"
 
-	server := NewServer(cfg, nil)
+	server := NewServer(cfg, nil, nil)
 	request := httptest.NewRequest(http.MethodGet, "/api/v1/providers", nil)
 	recorder := httptest.NewRecorder()
 
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/config"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/runtime"
+)
+
+const supervisorStopTimeout = 30 * time.Second
+
+// SupervisorSocketPath returns the unix socket the runtime supervisor listens on.
+func SupervisorSocketPath(cfg config.RuntimeConfig) string {
+	if path := strings.TrimSpace(cfg.SocketPath); path != "" {
+		return path
+	}
+	logDir := cfg.LogDir
+	if logDir == "" {
+		logDir = "/var/lib/localaistack/runtime"
+	}
+	return filepath.Join(logDir, "supervisor.sock")
+}
+
+type supervisorHandler struct {
+	manager *runtime.Manager
+}
+
+type supervisorError struct {
+	Error string `json:"error"`
+}
+
+// NewSupervisorHandler exposes a runtime.Manager to `las service` over HTTP.
+func NewSupervisorHandler(manager *runtime.Manager) http.Handler {
+	h := &supervisorHandler{manager: manager}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/services", h.list)
+	mux.HandleFunc("POST /v1/services", h.start)
+	mux.HandleFunc("GET /v1/services/{name}", h.status)
+	mux.HandleFunc("PUT /v1/services/{name}", h.register)
+	mux.HandleFunc("DELETE /v1/services/{name}", h.remove)
+	mux.HandleFunc("POST /v1/services/{name}/start", h.restart)
+	mux.HandleFunc("POST /v1/services/{name}/stop", h.stop)
+	return mux
+}
+
+func (h *supervisorHandler) list(w http.ResponseWriter, r *http.Request) {
+	statuses := h.manager.List()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (h *supervisorHandler) start(w http.ResponseWriter, r *http.Request) {
+	var spec runtime.ModuleSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		writeJSON(w, http.StatusBadRequest, supervisorError{Error: i18n.T("invalid service spec: %v", err)})
+		return
+	}
+	status, err := h.manager.Start(r.Context(), spec)
+	if err != nil {
+		writeJSON(w, http.StatusConflict, supervisorError{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusCreated, status)
+}
+
+// restart starts a known service again with the spec it last ran with.
+func (h *supervisorHandler) restart(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	spec, ok := h.manager.Spec(name)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, supervisorError{Error: i18n.T("service %q not found", name)})
+		return
+	}
+	status, err := h.manager.Start(r.Context(), spec)
+	if err != nil {
+		writeJSON(w, http.StatusConflict, supervisorError{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusCreated, status)
+}
+
+// register records a service spec without starting it.
+func (h *supervisorHandler) register(w http.ResponseWriter, r *http.Request) {
+	var spec runtime.ModuleSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		writeJSON(w, http.StatusBadRequest, supervisorError{Error: i18n.T("invalid service spec: %v", err)})
+		return
+	}
+	if name := r.PathValue("name"); spec.Name != name {
+		writeJSON(w, http.StatusBadRequest, supervisorError{Error: i18n.T("service spec is named %q, not %q", spec.Name, name)})
+		return
+	}
+	status, err := h.manager.Register(spec)
+	if err != nil {
+		writeJSON(w, http.StatusConflict, supervisorError{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (h *supervisorHandler) remove(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if _, ok := h.manager.Status(name); !ok {
+		writeJSON(w, http.StatusNotFound, supervisorError{Error: i18n.T("service %q not found", name)})
+		return
+	}
+	if err := h.manager.Remove(name); err != nil {
+		writeJSON(w, http.StatusConflict, supervisorError{Error: err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *supervisorHandler) status(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	status, ok := h.manager.Status(name)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, supervisorError{Error: i18n.T("service %q not found", name)})
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (h *supervisorHandler) stop(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if _, ok := h.manager.Status(name); !ok {
+		writeJSON(w, http.StatusNotFound, supervisorError{Error: i18n.T("service %q not found", name)})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), supervisorStopTimeout)
+	defer cancel()
+	if err := h.manager.Stop(ctx, name); err != nil {
+		writeJSON(w, http.StatusInternalServerError, supervisorError{Error: err.Error()})
+		return
+	}
+	status, _ := h.manager.Status(name)
+	writeJSON(w, http.StatusOK, status)
+}
+
+func writeJSON(w http.ResponseWriter, code int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		log.Warn().Err(err).Msg(i18n.T("failed to encode response"))
+	}
+}
+
+// StartSupervisor serves the runtime supervisor API on the configured unix
+// socket. Only the owner and group of the socket may manage services.
+func (s *Server) StartSupervisor() error {
+	if s.runtime == nil {
+		return i18n.Errorf("runtime manager is not configured")
+	}
+	path := SupervisorSocketPath(s.cfg.Runtime)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return i18n.Errorf("create supervisor socket dir: %w", err)
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return i18n.Errorf("listen on supervisor socket: %w", err)
+	}
+	if err := os.Chmod(path, 0o660); err != nil {
+		listener.Close()
+		return i18n.Errorf("chmod supervisor socket: %w", err)
+	}
+
+	s.supervisor = &http.Server{Handler: NewSupervisorHandler(s.runtime)}
+	log.Info().Str("socket", path).Msg(i18n.T("Starting runtime supervisor"))
+	if err := s.supervisor.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func removeStaleSocket(path string) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return i18n.Errorf("runtime supervisor already running on %s", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return i18n.Errorf("remove stale supervisor socket: %w", err)
+	}
+	return nil
+}
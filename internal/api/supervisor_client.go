@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/runtime"
+)
+
+// ErrServiceNotFound is returned when the supervisor does not know a service.
+var ErrServiceNotFound = errors.New("service not found")
+
+// SupervisorClient talks to the runtime supervisor hosted by las-server.
+type SupervisorClient struct {
+	socketPath string
+	baseURL    string
+	http       *http.Client
+}
+
+func NewSupervisorClient(socketPath string) *SupervisorClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	return &SupervisorClient{
+		socketPath: socketPath,
+		baseURL:    "http://supervisor",
+		http:       &http.Client{Transport: transport, Timeout: 2 * supervisorStopTimeout},
+	}
+}
+
+// newSupervisorClientForURL is used by tests to target an httptest server.
+func newSupervisorClientForURL(baseURL string, client *http.Client) *SupervisorClient {
+	return &SupervisorClient{socketPath: baseURL, baseURL: strings.TrimRight(baseURL, "/"), http: client}
+}
+
+func (c *SupervisorClient) Start(ctx context.Context, spec runtime.ModuleSpec) (runtime.Status, error) {
+	var status runtime.Status
+	err := c.do(ctx, http.MethodPost, "/v1/services", spec, &status)
+	return status, err
+}
+
+// Restart starts a previously registered service with its recorded spec.
+func (c *SupervisorClient) Restart(ctx context.Context, name string) (runtime.Status, error) {
+	var status runtime.Status
+	err := c.do(ctx, http.MethodPost, "/v1/services/"+url.PathEscape(name)+"/start", nil, &status)
+	return status, err
+}
+
+// Register records spec without starting it; Restart starts it later.
+func (c *SupervisorClient) Register(ctx context.Context, spec runtime.ModuleSpec) (runtime.Status, error) {
+	var status runtime.Status
+	err := c.do(ctx, http.MethodPut, "/v1/services/"+url.PathEscape(spec.Name), spec, &status)
+	return status, err
+}
+
+// Remove forgets a stopped service.
+func (c *SupervisorClient) Remove(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/services/"+url.PathEscape(name), nil, nil)
+}
+
+func (c *SupervisorClient) Stop(ctx context.Context, name string) (runtime.Status, error) {
+	var status runtime.Status
+	err := c.do(ctx, http.MethodPost, "/v1/services/"+url.PathEscape(name)+"/stop", nil, &status)
+	return status, err
+}
+
+func (c *SupervisorClient) Status(ctx context.Context, name string) (runtime.Status, error) {
+	var status runtime.Status
+	err := c.do(ctx, http.MethodGet, "/v1/services/"+url.PathEscape(name), nil, &status)
+	return status, err
+}
+
+func (c *SupervisorClient) List(ctx context.Context) ([]runtime.Status, error) {
+	var statuses []runtime.Status
+	err := c.do(ctx, http.MethodGet, "/v1/services", nil, &statuses)
+	return statuses, err
+}
+
+func (c *SupervisorClient) do(ctx context.Context, method, path string, body any, out any) error {
+	var payload []byte
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = encoded
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return i18n.Errorf("runtime supervisor is not reachable at %s (is las-server running?): %w", c.socketPath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var payload supervisorError
+		_ = json.NewDecoder(resp.Body).Decode(&payload)
+		message := strings.TrimSpace(payload.Error)
+		if message == "" {
+			message = resp.Status
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: %s", ErrServiceNotFound, message)
+		}
+		return errors.New(message)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
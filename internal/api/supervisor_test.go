@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"testing"
+	"time"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/config"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/runtime"
+)
+
+func newTestRuntimeManager(t *testing.T) *runtime.Manager {
+	t.Helper()
+	return runtime.NewManager(config.RuntimeConfig{
+		NativeEnabled: true,
+		DefaultMode:   string(runtime.ModeNative),
+		LogDir:        t.TempDir(),
+	})
+}
+
+func TestSupervisorStartStatusStop(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("requires a POSIX sleep binary")
+	}
+	manager := newTestRuntimeManager(t)
+	srv := httptest.NewServer(NewSupervisorHandler(manager))
+	defer srv.Close()
+	client := newSupervisorClientForURL(srv.URL, srv.Client())
+	ctx := context.Background()
+
+	started, err := client.Start(ctx, runtime.ModuleSpec{Name: "sleeper", Command: []string{"sleep", "30"}})
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	if started.State != runtime.StateRunning || started.PID <= 0 || started.LogPath == "" {
+		t.Fatalf("unexpected start status: %+v", started)
+	}
+	if _, err := os.Stat(started.LogPath); err != nil {
+		t.Fatalf("expected log file: %v", err)
+	}
+
+	if _, err := client.Start(ctx, runtime.ModuleSpec{Name: "sleeper", Command: []string{"sleep", "30"}}); err == nil {
+		t.Fatalf("expected duplicate start to fail")
+	}
+
+	statuses, err := client.List(ctx)
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].PID != started.PID {
+		t.Fatalf("unexpected list: %+v", statuses)
+	}
+
+	stopped, err := client.Stop(ctx, "sleeper")
+	if err != nil {
+		t.Fatalf("Stop returned error: %v", err)
+	}
+	if stopped.State != runtime.StateStopped || stopped.FinishedAt == nil {
+		t.Fatalf("unexpected stop status: %+v", stopped)
+	}
+
+	restarted, err := client.Restart(ctx, "sleeper")
+	if err != nil {
+		t.Fatalf("Restart returned error: %v", err)
+	}
+	if restarted.State != runtime.StateRunning || restarted.PID == started.PID {
+		t.Fatalf("unexpected restart status: %+v", restarted)
+	}
+	if _, err := client.Stop(ctx, "sleeper"); err != nil {
+		t.Fatalf("Stop after restart returned error: %v", err)
+	}
+
+	if _, err := client.Status(ctx, "missing"); !errors.Is(err, ErrServiceNotFound) {
+		t.Fatalf("expected ErrServiceNotFound, got %v", err)
+	}
+	if _, err := client.Restart(ctx, "missing"); !errors.Is(err, ErrServiceNotFound) {
+		t.Fatalf("expected ErrServiceNotFound on restart, got %v", err)
+	}
+
+	registered, err := client.Register(ctx, runtime.ModuleSpec{Name: "lazy", Command: []string{"sleep", "30"}})
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	if registered.State != runtime.StateStopped || registered.PID != 0 {
+		t.Fatalf("unexpected register status: %+v", registered)
+	}
+	if err := client.Remove(ctx, "lazy"); err != nil {
+		t.Fatalf("Remove returned error: %v", err)
+	}
+	if err := client.Remove(ctx, "lazy"); !errors.Is(err, ErrServiceNotFound) {
+		t.Fatalf("expected ErrServiceNotFound on remove, got %v", err)
+	}
+}
+
+func TestStartSupervisorServesUnixSocket(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("unix sockets are exercised on POSIX hosts")
+	}
+	dir, err := os.MkdirTemp("", "las-sup")
+	if err != nil {
+		t.Fatalf("mkdir temp: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.DefaultConfig()
+	cfg.Runtime.SocketPath = filepath.Join(dir, "supervisor.sock")
+	server := NewServer(cfg, nil, newTestRuntimeManager(t))
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.StartSupervisor() }()
+	defer server.Stop()
+
+	client := NewSupervisorClient(cfg.Runtime.SocketPath)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		statuses, err := client.List(context.Background())
+		if err == nil {
+			if len(statuses) != 0 {
+				t.Fatalf("expected no services, got %+v", statuses)
+			}
+			break
+		}
+		select {
+		case serveErr := <-errCh:
+			t.Fatalf("StartSupervisor returned early: %v", serveErr)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("supervisor socket never became reachable: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	info, err := os.Stat(cfg.Runtime.SocketPath)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if info.Mode().Perm() != 0o660 {
+		t.Fatalf("expected socket mode 0660, got %v", info.Mode().Perm())
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/zhuangbiaowei/LocalAIStack/internal/failure"
 	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
 	"github.com/zhuangbiaowei/LocalAIStack/internal/llm"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/memestimate"
 	"github.com/zhuangbiaowei/LocalAIStack/internal/modelmanager"
 	"github.com/zhuangbiaowei/LocalAIStack/internal/module"
 	"github.com/zhuangbiaowei/LocalAIStack/internal/system"
@@ -64,8 +65,26 @@ func RegisterModuleCommands(rootCmd *cobra.Command) {
 		Short: "Install a module",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			rebuildValue, _ := cmd.Flags().GetString("rebuild")
+			rebuild, err := module.ParseRebuildMode(rebuildValue)
+			if err != nil {
+				return err
+			}
+			assumeYes, _ := cmd.Flags().GetBool("yes")
+			resume, _ := cmd.Flags().GetBool("resume")
+			noRollback, _ := cmd.Flags().GetBool("no-rollback")
+			opts := module.InstallOptions{Rebuild: rebuild, Resume: resume, NoRollback: noRollback}
+			if !assumeYes {
+				opts.Confirm = func(detected []string) bool {
+					return confirmModuleRebuild(cmd, args[0], rebuild, detected)
+				}
+			}
+			lifecycle, err := openModuleLifecycle()
+			if err != nil {
+				return err
+			}
 			cmd.Printf("%s\n", i18n.T("Installing module: %s", args[0]))
-			if err := module.Install(args[0]); err != nil {
+			if err := installModulePlan(cmd, lifecycle, args[0], opts); err != nil {
 				cmd.Printf("%s\n", i18n.T("Module install failed: %s", err))
 				return err
 			}
@@ -74,13 +93,22 @@ func RegisterModuleCommands(rootCmd *cobra.Command) {
 		},
 	}
 
+	installCmd.Flags().String("rebuild", "none", "Clean up an existing installation before installing: none|soft|full")
+	installCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation before a rebuild cleanup")
+	installCmd.Flags().Bool("resume", false, "Continue a failed install from the last completed step")
+	installCmd.Flags().Bool("no-rollback", false, "Keep the changes of a failed install instead of rolling them back")
+
 	updateCmd := &cobra.Command{
 		Use:   "update [module-name]",
 		Short: "Update a module",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			lifecycle, err := openModuleLifecycle()
+			if err != nil {
+				return err
+			}
 			cmd.Printf("%s\n", i18n.T("Updating module: %s", args[0]))
-			if err := module.Update(args[0]); err != nil {
+			if err := lifecycle.Update(args[0]); err != nil {
 				cmd.Printf("%s\n", i18n.T("Module update failed: %s", err))
 				return err
 			}
@@ -94,8 +122,16 @@ func RegisterModuleCommands(rootCmd *cobra.Command) {
 		Short: "Uninstall a module",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cmd.Printf("%s\n", i18n.T("Uninstalling module: %s", args[0]))
-			if err := module.Uninstall(args[0]); err != nil {
+			lifecycle, err := openModuleLifecycle()
+			if err != nil {
+				return err
+			}
+			cascade, _ := cmd.Flags().GetBool("cascade")
+			order, err := lifecycle.RemovalOrder(loadModuleRegistry(), args[0], cascade)
+			if err != nil {
+				return err
+			}
+			if err := removeModules(cmd, order, "Uninstalling module: %s", lifecycle.Uninstall); err != nil {
 				cmd.Printf("%s\n", i18n.T("Module uninstall failed: %s", err))
 				return err
 			}
@@ -104,13 +140,23 @@ func RegisterModuleCommands(rootCmd *cobra.Command) {
 		},
 	}
 
+	uninstallCmd.Flags().Bool("cascade", false, "Also uninstall installed modules that depend on this module")
+
 	purgeCmd := &cobra.Command{
 		Use:   "purge [module-name]",
 		Short: "Purge a module",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cmd.Printf("%s\n", i18n.T("Purging module: %s", args[0]))
-			if err := module.Purge(args[0]); err != nil {
+			lifecycle, err := openModuleLifecycle()
+			if err != nil {
+				return err
+			}
+			cascade, _ := cmd.Flags().GetBool("cascade")
+			order, err := lifecycle.RemovalOrder(loadModuleRegistry(), args[0], cascade)
+			if err != nil {
+				return err
+			}
+			if err := removeModules(cmd, order, "Purging module: %s", lifecycle.Purge); err != nil {
 				cmd.Printf("%s\n", i18n.T("Module purge failed: %s", err))
 				return err
 			}
@@ -119,19 +165,15 @@ func RegisterModuleCommands(rootCmd *cobra.Command) {
 		},
 	}
 
+	purgeCmd.Flags().Bool("cascade", false, "Also purge installed modules that depend on this module")
+
 	listCmd := &cobra.Command{
 		Use:   "list",
 		Short: "List all available modules",
 		Run: func(cmd *cobra.Command, args []string) {
-			modulesRoot, err := module.FindModulesRoot()
-			if err != nil {
-				cmd.Printf("%s\n", i18n.T("Failed to locate modules directory: %v", err))
-				return
-			}
-			registry, err := module.LoadRegistryFromDir(modulesRoot)
+			registry, err := module.LoadRegistry()
 			if err != nil {
-				cmd.Printf("%s\n", i18n.T("Failed to load modules from %s: %v", modulesRoot, err))
-				return
+				cmd.Printf("%s\n", i18n.T("Some module sources could not be loaded: %v", err))
 			}
 
 			all := registry.All()
@@ -145,17 +187,37 @@ func RegisterModuleCommands(rootCmd *cobra.Command) {
 			if len(names) == 0 {
 				cmd.Println(i18n.T("- none"))
 			}
+			lifecycle, err := openModuleLifecycle()
+			if err != nil {
+				cmd.Printf("%s\n", i18n.T("Failed to open module state: %v", err))
+				return
+			}
+			probe, _ := cmd.Flags().GetBool("probe")
 			writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
 			for _, name := range names {
-				status := i18n.T("Not installed")
-				if err := module.Check(name); err == nil {
-					status = i18n.T("Installed")
+				if probe {
+					if _, err := lifecycle.Probe(name); err != nil {
+						cmd.Printf("%s\n", i18n.T("Failed to record state of module %s: %v", name, err))
+					}
 				}
-				_, _ = fmt.Fprintf(writer, "%s\n", i18n.T("- %s\t%s", name, status))
+				state, version := lifecycle.State(name)
+				line := i18n.T("- %s\t%s\t%s", name, moduleStateLabel(state), version)
+				record := all[name][0]
+				if record.Source != module.BuiltinSource {
+					line += "\t" + i18n.T("(from %s)", record.Source)
+				}
+				switch {
+				case record.SignedBy != "":
+					line += "\t" + i18n.T("signed by %s", record.SignedBy)
+				case record.SignatureWarning != "":
+					line += "\t" + i18n.T("unverified: %s", record.SignatureWarning)
+				}
+				_, _ = fmt.Fprintf(writer, "%s\n", line)
 			}
 			_ = writer.Flush()
 		},
 	}
+	listCmd.Flags().Bool("probe", false, "Re-run module check scripts and update the recorded state")
 
 	checkCmd := &cobra.Command{
 		Use:   "check [module-name]",
@@ -226,9 +288,9 @@ func RegisterModuleCommands(rootCmd *cobra.Command) {
 			}()
 
 			baseInfoPath := configplanner.ResolveBaseInfoPath()
-			baseInfo, err := system.LoadBaseInfoSummary(baseInfoPath)
+			baseInfo, err := system.LoadHardwareSummary(baseInfoPath)
 			if err != nil {
-				return fmt.Errorf("failed to read base info at %s (try `./build/las system init`): %w", baseInfoPath, err)
+				return fmt.Errorf("failed to read base info at %s (try `./build/las system detect`): %w", baseInfoPath, err)
 			}
 
 			plan, err := configplanner.BuildStaticPlan(moduleName, modelID, baseInfo)
@@ -315,48 +377,13 @@ func RegisterModuleCommands(rootCmd *cobra.Command) {
 	moduleCmd.AddCommand(checkCmd)
 	moduleCmd.AddCommand(settingCmd)
 	moduleCmd.AddCommand(configPlanCmd)
+	moduleCmd.AddCommand(newModuleHistoryCommand())
+	moduleCmd.AddCommand(newModuleRepoCommand())
+	moduleCmd.AddCommand(newModuleDigestCommand())
+	moduleCmd.AddCommand(newModuleLintCommand())
 	rootCmd.AddCommand(moduleCmd)
 }
 
-func RegisterServiceCommands(rootCmd *cobra.Command) {
-	serviceCmd := &cobra.Command{
-		Use:   "service",
-		Short: "Manage services",
-	}
-
-	startCmd := &cobra.Command{
-		Use:   "start [service-name]",
-		Short: "Start a service",
-		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			cmd.Printf("%s\n", i18n.T("Starting service: %s", args[0]))
-		},
-	}
-
-	stopCmd := &cobra.Command{
-		Use:   "stop [service-name]",
-		Short: "Stop a service",
-		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			cmd.Printf("%s\n", i18n.T("Stopping service: %s", args[0]))
-		},
-	}
-
-	statusCmd := &cobra.Command{
-		Use:   "status [service-name]",
-		Short: "Get service status",
-		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			cmd.Printf("%s\n", i18n.T("Service status: %s", args[0]))
-		},
-	}
-
-	serviceCmd.AddCommand(startCmd)
-	serviceCmd.AddCommand(stopCmd)
-	serviceCmd.AddCommand(statusCmd)
-	rootCmd.AddCommand(serviceCmd)
-}
-
 func RegisterModelCommands(rootCmd *cobra.Command) {
 	modelCmd := &cobra.Command{
 		Use:   "model",
@@ -503,13 +530,14 @@ func RegisterModelCommands(rootCmd *cobra.Command) {
 			}
 
 			writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
-			fmt.Fprintln(writer, "NAME\tSOURCE\tFORMAT\tSIZE\tDOWNLOADED")
+			fmt.Fprintln(writer, "NAME\tSOURCE\tFORMAT\tPARAMS\tQUANT\tSIZE\tDOWNLOADED")
 
 			for _, model := range models {
 				size, _ := mgr.GetModelSize(model.ID)
 				downloadTime := time.Unix(model.DownloadedAt, 0).Format("2006-01-02 15:04")
-				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n",
-					model.ID, model.Source, model.Format,
+				params, quant := describeGGUFModel(model.LocalPath)
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					model.ID, model.Source, model.Format, params, quant,
 					modelmanager.FormatBytes(size), downloadTime)
 			}
 
@@ -540,6 +568,12 @@ func RegisterModelCommands(rootCmd *cobra.Command) {
 			smartRunRefresh, _ := cmd.Flags().GetBool("smart-run-refresh")
 			smartRunStrict, _ := cmd.Flags().GetBool("smart-run-strict")
 			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			explain, _ := cmd.Flags().GetBool("explain")
+			cacheType, _ := cmd.Flags().GetString("cache-type")
+			cacheType, err := llamaCacheType(cacheType)
+			if err != nil {
+				return err
+			}
 			host, _ := cmd.Flags().GetString("host")
 			port, _ := cmd.Flags().GetInt("port")
 			temperature, _ := cmd.Flags().GetFloat64("temperature")
@@ -569,6 +603,7 @@ func RegisterModelCommands(rootCmd *cobra.Command) {
 			vllmMaxModelLenChanged := cmd.Flags().Changed("vllm-max-model-len")
 			vllmGpuMemUtilChanged := cmd.Flags().Changed("vllm-gpu-memory-utilization")
 			vllmTrustRemoteCodeChanged := cmd.Flags().Changed("vllm-trust-remote-code")
+			serving := cmd.Name() == "serve"
 			plannerProvider := ""
 			plannerModel := ""
 			defer func() {
@@ -642,6 +677,9 @@ func RegisterModelCommands(rootCmd *cobra.Command) {
 			}
 
 			if src == modelmanager.SourceOllama {
+				if serving {
+					return fmt.Errorf("ollama models are served by the ollama daemon; use `las model run` instead")
+				}
 				ollamaPath, err := exec.LookPath("ollama")
 				if err != nil {
 					return fmt.Errorf("ollama not found in PATH (install the ollama module first)")
@@ -677,9 +715,17 @@ func RegisterModelCommands(rootCmd *cobra.Command) {
 			}
 
 			baseInfoPath := resolveBaseInfoPath()
-			baseInfo, err := system.LoadBaseInfoSummary(baseInfoPath)
+			baseInfo, err := system.LoadHardwareSummary(baseInfoPath)
 			if err != nil {
-				return fmt.Errorf("failed to read base info at %s (try `./build/las system init`): %w", baseInfoPath, err)
+				return fmt.Errorf("failed to read base info at %s (try `./build/las system detect`): %w", baseInfoPath, err)
+			}
+
+			var serve *modelServePlan
+			if serving {
+				if serve, err = prepareModelServe(cmd, modelID, host, port, dryRun); err != nil {
+					return err
+				}
+				port = serve.port
 			}
 
 			if len(safetensorsFiles) > 0 {
@@ -707,6 +753,7 @@ func RegisterModelCommands(rootCmd *cobra.Command) {
 				}
 				enableTrustRemoteCode := vllmTrustRemoteCode || shouldAutoEnableVLLMTrustRemoteCode(modelDir)
 				textOnlyModel := isLikelyTextOnlyVLLMModel(modelDir)
+				vllmModel := hfMemoryModel(modelDir)
 				if textOnly {
 					vllmDefaults.skipMMProfiling = true
 					vllmDefaults.limitMMPerPrompt = `{"image":0,"video":0}`
@@ -776,7 +823,7 @@ func RegisterModelCommands(rootCmd *cobra.Command) {
 						vllmSmartErr = fmt.Errorf("smart-run refresh requires LLM configuration")
 					}
 				}
-				vllmDefaults = finalizeVLLMRunParams(baseInfo, vllmDefaults, textOnlyModel)
+				vllmDefaults = finalizeVLLMRunParams(baseInfo, vllmDefaults, textOnlyModel, vllmModel)
 				if vllmMaxModelLenChanged && vllmMaxModelLen > 0 {
 					vllmDefaults.maxModelLen = clampInt(vllmMaxModelLen, 256, 131072)
 				}
@@ -794,6 +841,14 @@ func RegisterModelCommands(rootCmd *cobra.Command) {
 				if vllmSmartFatal != nil {
 					return vllmSmartFatal
 				}
+				if explain {
+					if vllmModel == nil {
+						cmd.Println("Memory estimate unavailable: config.json does not describe the model shape")
+					} else {
+						config, devices := vllmMemoryConfig(baseInfo, vllmDefaults)
+						printMemoryEstimate(cmd, memestimate.Compute(*vllmModel, config, devices, memestimate.Device{Name: "host"}))
+					}
+				}
 				cmd.Printf("Starting vLLM server for %s\n", modelID)
 				servedModelName := suggestVLLMServedModelName(modelID)
 				args := buildVLLMServeArgs(modelRef, servedModelName, host, port, vllmDefaults, enableTrustRemoteCode)
@@ -801,6 +856,16 @@ func RegisterModelCommands(rootCmd *cobra.Command) {
 					printDryRunCommand(cmd, vllmPath, args, vllmDefaults.env)
 					return nil
 				}
+				if serve != nil {
+					serve.servedName = fallbackString(servedModelName, modelRef)
+					_, devices := vllmMemoryConfig(baseInfo, vllmDefaults)
+					serve.vram = reservedVRAM(devices)
+					env, err := parseKeyValues("env", vllmDefaults.env)
+					if err != nil {
+						return err
+					}
+					return serve.start(cmd, "vllm", modelID, append([]string{vllmPath}, args...), env, modelRef, vllmAdviceToPersist)
+				}
 				buildVLLMCmd := func(stdout, stderr io.Writer) (*exec.Cmd, error) {
 					servedModelName := suggestVLLMServedModelName(modelID)
 					args := buildVLLMServeArgs(modelRef, servedModelName, host, port, vllmDefaults, enableTrustRemoteCode)
@@ -847,7 +912,7 @@ func RegisterModelCommands(rootCmd *cobra.Command) {
 								"gpu_memory_utilization": vllmGpuMemUtilChanged,
 								"trust_remote_code":      vllmTrustRemoteCodeChanged,
 							})
-							vllmDefaults = finalizeVLLMRunParams(baseInfo, vllmDefaults, textOnlyModel)
+							vllmDefaults = finalizeVLLMRunParams(baseInfo, vllmDefaults, textOnlyModel, vllmModel)
 							env := &smartRunAdviceEnvelope{
 								Reason: "Recovered after startup failure via Qwen/Kimi smart-run chain",
 								VLLM:   advice,
@@ -867,8 +932,20 @@ func RegisterModelCommands(rootCmd *cobra.Command) {
 				cmd.Printf("Auto-selected GGUF file: %s\n", filepath.Base(modelPath))
 			}
 
-			defaults := defaultLlamaRunParams(baseInfo)
-			defaults = autoTuneRunParams(defaults, baseInfo, modelPath)
+			ggufInfo, err := modelmanager.ReadGGUFModel(modelPath)
+			if err != nil {
+				cmd.Printf("Warning: %v; tuning without model metadata\n", err)
+			} else {
+				cmd.Printf("Model: %s %s %s, %d layers, trained context %d\n",
+					fallbackString(ggufInfo.Architecture, "unknown"),
+					modelmanager.FormatParameterCount(ggufInfo.ParameterCount),
+					fallbackString(ggufInfo.Quantization(), "-"),
+					ggufInfo.BlockCount, ggufInfo.ContextLength)
+			}
+
+			memoryModel := ggufMemoryModel(ggufInfo, modelPath)
+			defaults := defaultLlamaRunParams(baseInfo, memoryModel, cacheType)
+			defaults = autoTuneRunParams(defaults, baseInfo, ggufInfo)
 			if threads > 0 {
 				defaults.threads = threads
 			}
@@ -884,7 +961,7 @@ func RegisterModelCommands(rootCmd *cobra.Command) {
 			resolvedBatch := batchSize
 			resolvedUBatch := ubatchSize
 			if autoBatch || resolvedBatch == 0 || resolvedUBatch == 0 {
-				autoResolved := autoTuneBatchParams(baseInfo, modelPath, defaults.ctxSize, defaults.gpuLayers)
+				autoResolved := autoTuneBatchParams(baseInfo, ggufInfo, defaults.ctxSize, defaults.gpuLayers)
 				if resolvedBatch == 0 {
 					resolvedBatch = autoResolved.BatchSize
 				}
@@ -1039,6 +1116,7 @@ func RegisterModelCommands(rootCmd *cobra.Command) {
 				return fmt.Errorf("llama-server not found in PATH (install the llama.cpp module first)")
 			}
 
+			defaults.flashAttn = llamaFlashAttention(baseInfo, defaults)
 			argsList := buildLlamaServerArgs(
 				modelPath,
 				defaults,
@@ -1059,12 +1137,32 @@ func RegisterModelCommands(rootCmd *cobra.Command) {
 			if autoBatch {
 				cmd.Printf("Auto batch tuned: --batch-size %d --ubatch-size %d\n", resolvedBatch, resolvedUBatch)
 			}
+			if explain {
+				if memoryModel == nil {
+					cmd.Println("Memory estimate unavailable: the GGUF header does not describe the model shape")
+				} else {
+					printMemoryEstimate(cmd, estimateLlamaMemory(baseInfo, *memoryModel, defaults, resolvedUBatch))
+				}
+			}
 			cmd.Printf("Starting llama.cpp server for %s\n", filepath.Base(modelPath))
 			if dryRun {
 				printDryRunCommand(cmd, llamaPath, argsList, nil)
 				return nil
 			}
+			if serve != nil {
+				libDir, err := llamaCppLibraryDir()
+				if err != nil {
+					return err
+				}
+				env := map[string]string{"LD_LIBRARY_PATH": prependLibraryPath(libDir, os.Getenv("LD_LIBRARY_PATH"))}
+				if memoryModel != nil {
+					serve.vram = estimatedVRAM(estimateLlamaMemory(baseInfo, *memoryModel, defaults, resolvedUBatch))
+				}
+				return serve.start(cmd, "llama.cpp", modelID, append([]string{llamaPath}, argsList...), env, filepath.Base(modelPath), llamaAdviceToPersist)
+			}
 			buildLlamaCmd := func(stdout, stderr io.Writer) (*exec.Cmd, error) {
+				// Recovery may have changed the offload since the first launch.
+				defaults.flashAttn = llamaFlashAttention(baseInfo, defaults)
 				argsList := buildLlamaServerArgs(
 					modelPath,
 					defaults,
@@ -1148,33 +1246,9 @@ func RegisterModelCommands(rootCmd *cobra.Command) {
 			return startCommandAndPersistAdvice(cmd, buildLlamaCmd, "llama.cpp", modelID, filepath.Base(modelPath), llamaAdviceToPersist, recovery)
 		},
 	}
-	runCmd.Flags().StringP("source", "s", "", "Source of the model (ollama, huggingface, modelscope)")
-	runCmd.Flags().StringP("file", "f", "", "Specific GGUF filename to run")
-	runCmd.Flags().Int("threads", 0, "CPU threads for llama.cpp (0 = auto)")
-	runCmd.Flags().Int("ctx-size", 0, "Context size for llama.cpp (0 = auto)")
-	runCmd.Flags().Int("n-gpu-layers", -1, "GPU layers for llama.cpp (-1 = auto)")
-	runCmd.Flags().String("tensor-split", "", "Tensor split for multi-GPU (comma-separated percentages)")
-	runCmd.Flags().Int("batch-size", 0, "Batch size for llama.cpp (0 = auto)")
-	runCmd.Flags().Int("ubatch-size", 0, "Micro batch size for llama.cpp (0 = auto)")
-	runCmd.Flags().Bool("auto-batch", false, "Auto-tune llama.cpp --batch-size/--ubatch-size from hardware and model")
-	runCmd.Flags().Bool("smart-run", false, "Use LLM to refine runtime parameters from hardware and model context")
-	runCmd.Flags().Bool("smart-run-debug", false, "Print smart-run planner source and fallback reason")
-	runCmd.Flags().Bool("smart-run-refresh", false, "Force smart-run to ignore saved parameters and ask the LLM again")
-	runCmd.Flags().Bool("smart-run-strict", false, "Fail model run if smart-run cannot obtain valid LLM advice")
-	runCmd.Flags().Bool("text-only", false, "Force multimodal vLLM models to serve text-only requests")
-	runCmd.Flags().Bool("dry-run", false, "Print the final runtime command without launching the process")
+	addModelRunFlags(runCmd)
 	runCmd.Flags().String("host", "0.0.0.0", "Host to bind llama.cpp server")
 	runCmd.Flags().Int("port", 8080, "Port to bind llama.cpp server")
-	runCmd.Flags().Float64("temperature", 0.7, "Sampling temperature for llama.cpp")
-	runCmd.Flags().Float64("top-p", 0.8, "Top-p nucleus sampling for llama.cpp (0-1)")
-	runCmd.Flags().Int("top-k", 20, "Top-k sampling for llama.cpp (0 disables)")
-	runCmd.Flags().Float64("min-p", 0.0, "Min-p sampling for llama.cpp (0-1)")
-	runCmd.Flags().Float64("presence-penalty", 1.5, "Presence penalty for llama.cpp")
-	runCmd.Flags().Float64("repeat-penalty", 1.0, "Repeat penalty for llama.cpp")
-	runCmd.Flags().String("chat-template-kwargs", "", "JSON object passed to llama.cpp --chat-template-kwargs (e.g. '{\"enable_thinking\":false}')")
-	runCmd.Flags().Int("vllm-max-model-len", 0, "vLLM max model length (safetensors only)")
-	runCmd.Flags().Float64("vllm-gpu-memory-utilization", 0, "vLLM GPU memory utilization (0-1, safetensors only)")
-	runCmd.Flags().Bool("vllm-trust-remote-code", false, "Allow vLLM to execute model custom code from repo (safetensors only)")
 
 	rmCmd := &cobra.Command{
 		Use:   "rm [model-id]",
@@ -1374,12 +1448,48 @@ func RegisterModelCommands(rootCmd *cobra.Command) {
 	modelCmd.AddCommand(downloadCmd)
 	modelCmd.AddCommand(listCmd)
 	modelCmd.AddCommand(runCmd)
+	modelCmd.AddCommand(newModelServeCommand(runCmd.RunE))
+	modelCmd.AddCommand(newModelPsCommand())
+	modelCmd.AddCommand(newModelStopCommand())
+	modelCmd.AddCommand(newModelLogsCommand())
 	modelCmd.AddCommand(rmCmd)
 	modelCmd.AddCommand(repairCmd)
 	modelCmd.AddCommand(smartRunCacheCmd)
 	rootCmd.AddCommand(modelCmd)
 }
 
+// addModelRunFlags registers the planning flags shared by `model run` and
+// `model serve`.
+func addModelRunFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("source", "s", "", "Source of the model (ollama, huggingface, modelscope)")
+	cmd.Flags().StringP("file", "f", "", "Specific GGUF filename to run")
+	cmd.Flags().Int("threads", 0, "CPU threads for llama.cpp (0 = auto)")
+	cmd.Flags().Int("ctx-size", 0, "Context size for llama.cpp (0 = auto)")
+	cmd.Flags().Int("n-gpu-layers", -1, "GPU layers for llama.cpp (-1 = auto)")
+	cmd.Flags().String("tensor-split", "", "Tensor split for multi-GPU (comma-separated percentages)")
+	cmd.Flags().Int("batch-size", 0, "Batch size for llama.cpp (0 = auto)")
+	cmd.Flags().Int("ubatch-size", 0, "Micro batch size for llama.cpp (0 = auto)")
+	cmd.Flags().Bool("auto-batch", false, "Auto-tune llama.cpp --batch-size/--ubatch-size from hardware and model")
+	cmd.Flags().Bool("smart-run", false, "Use LLM to refine runtime parameters from hardware and model context")
+	cmd.Flags().Bool("smart-run-debug", false, "Print smart-run planner source and fallback reason")
+	cmd.Flags().Bool("smart-run-refresh", false, "Force smart-run to ignore saved parameters and ask the LLM again")
+	cmd.Flags().Bool("smart-run-strict", false, "Fail model run if smart-run cannot obtain valid LLM advice")
+	cmd.Flags().Bool("text-only", false, "Force multimodal vLLM models to serve text-only requests")
+	cmd.Flags().Bool("dry-run", false, "Print the final runtime command without launching the process")
+	cmd.Flags().Bool("explain", false, "Print the predicted memory use per device for the chosen parameters")
+	cmd.Flags().String("cache-type", "", "KV cache type for llama.cpp (f16, q8_0, q4_0, ...)")
+	cmd.Flags().Float64("temperature", 0.7, "Sampling temperature for llama.cpp")
+	cmd.Flags().Float64("top-p", 0.8, "Top-p nucleus sampling for llama.cpp (0-1)")
+	cmd.Flags().Int("top-k", 20, "Top-k sampling for llama.cpp (0 disables)")
+	cmd.Flags().Float64("min-p", 0.0, "Min-p sampling for llama.cpp (0-1)")
+	cmd.Flags().Float64("presence-penalty", 1.5, "Presence penalty for llama.cpp")
+	cmd.Flags().Float64("repeat-penalty", 1.0, "Repeat penalty for llama.cpp")
+	cmd.Flags().String("chat-template-kwargs", "", "JSON object passed to llama.cpp --chat-template-kwargs (e.g. '{\"enable_thinking\":false}')")
+	cmd.Flags().Int("vllm-max-model-len", 0, "vLLM max model length (safetensors only)")
+	cmd.Flags().Float64("vllm-gpu-memory-utilization", 0, "vLLM GPU memory utilization (0-1, safetensors only)")
+	cmd.Flags().Bool("vllm-trust-remote-code", false, "Allow vLLM to execute model custom code from repo (safetensors only)")
+}
+
 func createModelManager() *modelmanager.Manager {
 	home, _ := os.UserHomeDir()
 	modelDir := filepath.Join(home, ".localaistack", "models")
@@ -1479,6 +1589,13 @@ type llamaRunDefaults struct {
 	ctxSize     int
 	gpuLayers   int
 	tensorSplit string
+	cacheType   string
+	// memoryPlanned is set when ctxSize and gpuLayers come from the memory
+	// estimator rather than VRAM rules of thumb.
+	memoryPlanned bool
+	// flashAttn is set by llamaFlashAttention once the launch parameters
+	// are final.
+	flashAttn bool
 }
 
 type llamaBatchParams struct {
@@ -1547,19 +1664,7 @@ type vllmRunDefaults struct {
 }
 
 func resolveBaseInfoPath() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return filepath.Join(".", "base_info.json")
-	}
-	primary := filepath.Join(home, ".localaistack", "base_info.json")
-	if _, err := os.Stat(primary); err == nil {
-		return primary
-	}
-	alternate := filepath.Join(home, ".localiastack", "base_info.json")
-	if _, err := os.Stat(alternate); err == nil {
-		return alternate
-	}
-	return primary
+	return system.ResolveBaseInfoPath()
 }
 
 func loadLlamaRunRecommendations() (string, error) {
@@ -1606,7 +1711,24 @@ func loadBaseInfoPrompt() (string, error) {
 	return content, nil
 }
 
-func defaultLlamaRunParams(info system.BaseInfoSummary) llamaRunDefaults {
+// llamaCacheType normalizes --cache-type to the name llama-server expects,
+// or "" to keep its default.
+func llamaCacheType(value string) (string, error) {
+	cache, err := memestimate.ParseCacheType(value)
+	if err != nil {
+		return "", err
+	}
+	if cache == memestimate.CacheFP8 {
+		return "", fmt.Errorf("KV cache type %q is only supported by vLLM; llama.cpp accepts f32, f16, bf16, q8_0, q5_1, q5_0, q4_1 and q4_0", value)
+	}
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "auto":
+		return "", nil
+	}
+	return string(cache), nil
+}
+
+func defaultLlamaRunParams(info system.BaseInfoSummary, model *memestimate.Model, cacheType string) llamaRunDefaults {
 	threads := info.CPUCores
 	if threads <= 0 {
 		threads = runtime.NumCPU()
@@ -1628,7 +1750,7 @@ func defaultLlamaRunParams(info system.BaseInfoSummary) llamaRunDefaults {
 	}
 
 	gpuLayers := 0
-	vram := parseVRAMFromGPUName(info.GPUName)
+	vram := info.VRAMGB()
 	switch {
 	case vram >= 80:
 		gpuLayers = 80
@@ -1644,22 +1766,39 @@ func defaultLlamaRunParams(info system.BaseInfoSummary) llamaRunDefaults {
 		gpuLayers = 8
 	}
 
-	return llamaRunDefaults{
+	defaults := llamaRunDefaults{
 		threads:     threads,
 		ctxSize:     ctxSize,
 		gpuLayers:   gpuLayers,
 		tensorSplit: "",
+		cacheType:   cacheType,
 	}
+	if model == nil {
+		return defaults
+	}
+	cache, err := memestimate.ParseCacheType(cacheType)
+	if err != nil {
+		return defaults
+	}
+	if estimate, fits := planLlamaMemory(info, *model, cache); fits {
+		defaults.ctxSize = estimate.Config.Context
+		defaults.gpuLayers = estimate.Config.GPULayers
+		if defaults.gpuLayers >= model.Layers {
+			defaults.gpuLayers = 999
+		}
+		defaults.memoryPlanned = true
+	}
+	return defaults
 }
 
 func defaultVLLMRunParams(info system.BaseInfoSummary) vllmRunDefaults {
-	vram := parseVRAMFromGPUName(info.GPUName)
+	vram := info.VRAMGB()
 	gpuCount := info.GPUCount
 	if gpuCount <= 0 && vram > 0 {
 		gpuCount = 1
 	}
 	perGPUVRAM := vram
-	legacyGPU := isLegacyCUDAInferenceGPU(info.GPUName)
+	legacyGPU := isLegacyCUDAInferenceGPU(info)
 
 	maxModelLen := 2048
 	switch {
@@ -1767,14 +1906,14 @@ func defaultVLLMRunParams(info system.BaseInfoSummary) vllmRunDefaults {
 	}
 }
 
-func finalizeVLLMRunParams(info system.BaseInfoSummary, defaults vllmRunDefaults, _ bool) vllmRunDefaults {
-	vram := parseVRAMFromGPUName(info.GPUName)
+func finalizeVLLMRunParams(info system.BaseInfoSummary, defaults vllmRunDefaults, _ bool, model *memestimate.Model) vllmRunDefaults {
+	vram := info.VRAMGB()
 	gpuCount := info.GPUCount
 	if gpuCount <= 0 && vram > 0 {
 		gpuCount = 1
 	}
 	perGPUVRAM := vram
-	legacyGPU := isLegacyCUDAInferenceGPU(info.GPUName)
+	legacyGPU := isLegacyCUDAInferenceGPU(info)
 
 	if gpuCount <= 0 {
 		defaults.tensorParallelSize = 1
@@ -1813,6 +1952,16 @@ func finalizeVLLMRunParams(info system.BaseInfoSummary, defaults vllmRunDefaults
 		defaults.disableCustomAllReduce = defaults.tensorParallelSize > 1
 	}
 
+	if model != nil {
+		// Small and legacy cards keep the conservative length above as a
+		// ceiling; startup probing needs headroom the estimate does not model.
+		ceiling := 0
+		if legacyGPU || (perGPUVRAM > 0 && perGPUVRAM <= 16) {
+			ceiling = defaults.maxModelLen
+		}
+		defaults = planVLLMMemory(info, defaults, *model, ceiling)
+	}
+
 	defaults.maxModelLen = clampInt(defaults.maxModelLen, 256, 131072)
 	defaults.optimizationLevel = clampInt(defaults.optimizationLevel, 0, 3)
 	defaults.maxNumSeqs = clampInt(defaults.maxNumSeqs, 1, 256)
@@ -1846,24 +1995,15 @@ func buildCUDAVisibleDevices(count int) string {
 	return strings.Join(devices, ",")
 }
 
-func parseVRAMFromGPUName(name string) int {
-	if name == "" {
-		return 0
-	}
-	re := regexp.MustCompile(`(?i)(\d+)\s*gb`)
-	match := re.FindStringSubmatch(name)
-	if len(match) < 2 {
-		return 0
-	}
-	value, err := strconv.Atoi(match[1])
-	if err != nil {
-		return 0
+// isLegacyCUDAInferenceGPU reports whether the GPU predates compute
+// capability 8.0. The name is only consulted when no capability was probed.
+func isLegacyCUDAInferenceGPU(info system.BaseInfoSummary) bool {
+	if capability := strings.TrimSpace(info.GPUComputeCapability); capability != "" {
+		if version, err := strconv.ParseFloat(capability, 64); err == nil {
+			return version < 8.0
+		}
 	}
-	return value
-}
-
-func isLegacyCUDAInferenceGPU(name string) bool {
-	lower := strings.ToLower(strings.TrimSpace(name))
+	lower := strings.ToLower(strings.TrimSpace(info.GPUName))
 	if lower == "" {
 		return false
 	}
@@ -1884,63 +2024,95 @@ func isLegacyCUDAInferenceGPU(name string) bool {
 	return false
 }
 
-func inferModelInfo(filename string) (float64, string) {
-	base := strings.ToLower(filepath.Base(filename))
-	re := regexp.MustCompile(`(\d+(?:\.\d+)?)b`)
-	matches := re.FindAllStringSubmatch(base, -1)
-	var max float64
-	for _, match := range matches {
-		if len(match) < 2 {
+// gpuFlashAttention reports whether llama.cpp can use flash attention on
+// the GPUs of info.
+func gpuFlashAttention(info system.BaseInfoSummary) bool {
+	return memoryGPUCount(info) > 0 && !isLegacyCUDAInferenceGPU(info)
+}
+
+// llamaFlashAttention reports whether llama-server is started with flash
+// attention: when layers are offloaded to a GPU that supports it, or when
+// the V cache is quantized, which llama.cpp only allows with it.
+func llamaFlashAttention(info system.BaseInfoSummary, defaults llamaRunDefaults) bool {
+	cache, err := memestimate.ParseCacheType(defaults.cacheType)
+	if err == nil && quantizedLlamaCache(cache) {
+		return true
+	}
+	return defaults.gpuLayers != 0 && gpuFlashAttention(info)
+}
+
+func quantizedLlamaCache(cache memestimate.CacheType) bool {
+	switch cache {
+	case memestimate.CacheF32, memestimate.CacheF16, memestimate.CacheBF16:
+		return false
+	}
+	return true
+}
+
+// describeGGUFModel returns the parameter count of a downloaded model and
+// the quantizations of its GGUF files, or "-" for models without readable
+// GGUF headers.
+func describeGGUFModel(modelPath string) (string, string) {
+	files, err := modelmanager.FindGGUFFiles(modelPath)
+	if err != nil {
+		return "-", "-"
+	}
+	params := "-"
+	var quants []string
+	seen := make(map[string]bool)
+	for _, file := range files {
+		name := strings.ToLower(filepath.Base(file))
+		if strings.Contains(name, "-of-") && !strings.Contains(name, "-00001-of-") {
 			continue
 		}
-		value, err := strconv.ParseFloat(match[1], 64)
+		info, err := modelmanager.ReadGGUFModel(file)
 		if err != nil {
 			continue
 		}
-		if value > max {
-			max = value
+		if params == "-" {
+			params = modelmanager.FormatParameterCount(info.ParameterCount)
 		}
-	}
-
-	quant := ""
-	quantPatterns := []string{
-		"q2_k",
-		"q3_k",
-		"q4_k_m",
-		"q4_k_s",
-		"q4",
-		"q5_k_m",
-		"q5_k_s",
-		"q5",
-		"q6_k",
-		"q6",
-		"q8_0",
-		"q8",
-	}
-	for _, pattern := range quantPatterns {
-		if strings.Contains(base, pattern) {
-			quant = pattern
-			break
+		if quant := info.Quantization(); quant != "" && !seen[quant] {
+			seen[quant] = true
+			quants = append(quants, quant)
 		}
 	}
+	return params, fallbackString(strings.Join(quants, ","), "-")
+}
 
-	return max, quant
+// ggufSizeAndQuant returns the parameter count in billions and the
+// lowercase quantization of a model, or zero values when the header could
+// not be read.
+func ggufSizeAndQuant(model *modelmanager.GGUFInfo) (float64, string) {
+	if model == nil {
+		return 0, ""
+	}
+	return model.ParameterCountB(), strings.ToLower(model.Quantization())
 }
 
-func autoTuneRunParams(defaults llamaRunDefaults, info system.BaseInfoSummary, modelPath string) llamaRunDefaults {
+func autoTuneRunParams(defaults llamaRunDefaults, info system.BaseInfoSummary, model *modelmanager.GGUFInfo) llamaRunDefaults {
 	result := defaults
-	sizeB, quant := inferModelInfo(modelPath)
-	vram := parseVRAMFromGPUName(info.GPUName)
+	sizeB, quant := ggufSizeAndQuant(model)
+	vram := info.VRAMGB()
 	gpuCount := info.GPUCount
 	if gpuCount <= 0 && vram > 0 {
 		gpuCount = 1
 	}
+	if result.memoryPlanned {
+		if gpuCount > 1 && result.gpuLayers != 0 {
+			result.tensorSplit = makeTensorSplit(gpuCount)
+		}
+		return result
+	}
 
 	if info.MemoryKB >= 64*1024*1024 {
 		result.ctxSize = maxInt(result.ctxSize, 8192)
 	} else if info.MemoryKB >= 32*1024*1024 {
 		result.ctxSize = maxInt(result.ctxSize, 4096)
 	}
+	if model != nil && model.ContextLength > 0 && uint64(result.ctxSize) > model.ContextLength {
+		result.ctxSize = int(model.ContextLength)
+	}
 
 	if vram >= 16 && gpuCount >= 1 && sizeB > 0 && sizeB <= 30 {
 		if strings.HasPrefix(quant, "q4") || strings.HasPrefix(quant, "q5") || strings.HasPrefix(quant, "q6") || strings.HasPrefix(quant, "q8") || quant == "" {
@@ -1955,9 +2127,9 @@ func autoTuneRunParams(defaults llamaRunDefaults, info system.BaseInfoSummary, m
 	return result
 }
 
-func autoTuneBatchParams(info system.BaseInfoSummary, modelPath string, ctxSize int, gpuLayers int) llamaBatchParams {
-	sizeB, quant := inferModelInfo(modelPath)
-	vram := parseVRAMFromGPUName(info.GPUName)
+func autoTuneBatchParams(info system.BaseInfoSummary, model *modelmanager.GGUFInfo, ctxSize int, gpuLayers int) llamaBatchParams {
+	sizeB, quant := ggufSizeAndQuant(model)
+	vram := info.VRAMGB()
 	gpuCount := info.GPUCount
 	if gpuCount <= 0 && vram > 0 {
 		gpuCount = 1
@@ -2118,9 +2290,15 @@ func buildLlamaServerArgs(modelPath string, defaults llamaRunDefaults, host stri
 		"--presence-penalty", fmt.Sprintf("%.4g", sampling.PresencePenalty),
 		"--repeat-penalty", fmt.Sprintf("%.4g", sampling.RepeatPenalty),
 	}
+	if defaults.flashAttn {
+		args = append(args, "--flash-attn", "on")
+	}
 	if defaults.tensorSplit != "" {
 		args = append(args, "--tensor-split", defaults.tensorSplit)
 	}
+	if defaults.cacheType != "" {
+		args = append(args, "--cache-type-k", defaults.cacheType, "--cache-type-v", defaults.cacheType)
+	}
 	if batch.BatchSize > 0 {
 		args = append(args, "--batch-size", strconv.Itoa(batch.BatchSize))
 	}
@@ -2211,8 +2389,21 @@ func buildManagedRunLog(stdoutBuf, stderrBuf *bytes.Buffer, runErr error) string
 	return combined
 }
 
+func confirmModuleRebuild(cmd *cobra.Command, moduleName string, mode module.RebuildMode, detected []string) bool {
+	cmd.Printf("%s\n", i18n.T("Existing installation of %s detected:", moduleName))
+	for _, item := range detected {
+		cmd.Printf("  - %s\n", item)
+	}
+	return confirmPrompt(cmd, i18n.T("Run %s cleanup before reinstalling? [y/N]: ", mode))
+}
+
 func promptSmartRunFailureSubmission(cmd *cobra.Command, runtimeName string) bool {
-	cmd.Printf("%s startup failed. Submit the error log to LLMs for better %s parameters? [Y/N]: ", runtimeName, runtimeName)
+	return confirmPrompt(cmd, fmt.Sprintf("%s startup failed. Submit the error log to LLMs for better %s parameters? [Y/N]: ", runtimeName, runtimeName))
+}
+
+// confirmPrompt asks a yes/no question on stdin; anything but yes declines.
+func confirmPrompt(cmd *cobra.Command, prompt string) bool {
+	cmd.Printf("%s", prompt)
 	reader := bufio.NewReader(cmd.InOrStdin())
 	line, err := reader.ReadString('\n')
 	if err != nil && len(line) == 0 {
@@ -2391,13 +2582,22 @@ func loadSmartRunAdvice(runtimeName, modelID, selector string) (smartRunAdviceEn
 }
 
 func saveSmartRunAdvice(runtimeName, modelID, selector string, advice smartRunAdviceEnvelope) error {
-	path, err := smartRunAdvicePath(runtimeName, modelID, selector)
+	path, payload, err := smartRunAdviceFile(runtimeName, modelID, selector, advice)
 	if err != nil {
 		return err
 	}
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return err
 	}
+	return os.WriteFile(path, payload, 0o600)
+}
+
+// smartRunAdviceFile returns where advice is saved and the file contents.
+func smartRunAdviceFile(runtimeName, modelID, selector string, advice smartRunAdviceEnvelope) (string, []byte, error) {
+	path, err := smartRunAdvicePath(runtimeName, modelID, selector)
+	if err != nil {
+		return "", nil, err
+	}
 	payload, err := json.MarshalIndent(persistedSmartRunAdvice{
 		SchemaVersion: smartRunAdviceSchemaVersion,
 		Runtime:       runtimeName,
@@ -2407,9 +2607,9 @@ func saveSmartRunAdvice(runtimeName, modelID, selector string, advice smartRunAd
 		Advice:        advice,
 	}, "", "  ")
 	if err != nil {
-		return err
+		return "", nil, err
 	}
-	return os.WriteFile(path, payload, 0o600)
+	return path, payload, nil
 }
 
 func printSmartRunDebug(cmd *cobra.Command, runtimeName, source, reason string) {
@@ -3255,23 +3455,9 @@ func pickSmallestFile(files []string) (string, error) {
 }
 
 func addLlamaCppLibraryPath(cmd *exec.Cmd) error {
-	libDirs := candidateLibDirs()
-	foundDir := ""
-	for _, dir := range libDirs {
-		if dir == "" {
-			continue
-		}
-		if _, err := os.Stat(filepath.Join(dir, "libmtmd.so.0")); err == nil {
-			foundDir = dir
-			break
-		}
-		if _, err := os.Stat(filepath.Join(dir, "libmtmd.so")); err == nil {
-			foundDir = dir
-			break
-		}
-	}
-	if foundDir == "" {
-		return fmt.Errorf("libmtmd.so.0 not found; reinstall the llama.cpp module or set LD_LIBRARY_PATH to the directory containing libmtmd.so.0 (searched: %s)", strings.Join(libDirs, ", "))
+	libDir, err := llamaCppLibraryDir()
+	if err != nil {
+		return err
 	}
 
 	env := os.Environ()
@@ -3279,23 +3465,46 @@ func addLlamaCppLibraryPath(cmd *exec.Cmd) error {
 	updated := false
 	for i, kv := range env {
 		if strings.HasPrefix(kv, ldKey) {
-			current := strings.TrimPrefix(kv, ldKey)
-			if current == "" {
-				env[i] = ldKey + foundDir
-			} else if !strings.Contains(current, foundDir) {
-				env[i] = ldKey + foundDir + ":" + current
-			}
+			env[i] = ldKey + prependLibraryPath(libDir, strings.TrimPrefix(kv, ldKey))
 			updated = true
 			break
 		}
 	}
 	if !updated {
-		env = append(env, ldKey+foundDir)
+		env = append(env, ldKey+libDir)
 	}
 	cmd.Env = env
 	return nil
 }
 
+// llamaCppLibraryDir finds the directory holding the shared libraries of
+// llama-server.
+func llamaCppLibraryDir() (string, error) {
+	libDirs := candidateLibDirs()
+	for _, dir := range libDirs {
+		if dir == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, "libmtmd.so.0")); err == nil {
+			return dir, nil
+		}
+		if _, err := os.Stat(filepath.Join(dir, "libmtmd.so")); err == nil {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("libmtmd.so.0 not found; reinstall the llama.cpp module or set LD_LIBRARY_PATH to the directory containing libmtmd.so.0 (searched: %s)", strings.Join(libDirs, ", "))
+}
+
+func prependLibraryPath(dir, current string) string {
+	if current == "" {
+		return dir
+	}
+	if strings.Contains(current, dir) {
+		return current
+	}
+	return dir + ":" + current
+}
+
 func candidateLibDirs() []string {
 	home, _ := os.UserHomeDir()
 	return []string{
@@ -3324,10 +3533,35 @@ func RegisterSystemCommands(rootCmd *cobra.Command) {
 	detectCmd := &cobra.Command{
 		Use:   "detect",
 		Short: "Detect hardware capabilities",
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
+			asJSON, _ := cmd.Flags().GetBool("json")
+			// Always the path LoadHardwareSummary reads, so every command
+			// sees the new profile.
+			output := system.ResolveHardwareProfilePath()
+
 			cmd.Println(i18n.T("Detecting hardware..."))
+			doc, err := system.DetectHardwareProfile(nil)
+			if err != nil {
+				return err
+			}
+			if err := system.WriteHardwareProfile(output, doc); err != nil {
+				return err
+			}
+
+			if asJSON {
+				raw, err := json.MarshalIndent(doc, "", "  ")
+				if err != nil {
+					return err
+				}
+				cmd.Println(string(raw))
+			} else {
+				printHardwareProfile(cmd, doc)
+			}
+			cmd.Printf("%s\n", i18n.T("Hardware profile written to %s", output))
+			return nil
 		},
 	}
+	detectCmd.Flags().Bool("json", false, "print the detected profile as JSON")
 
 	infoCmd := &cobra.Command{
 		Use:   "info",
@@ -3343,6 +3577,37 @@ func RegisterSystemCommands(rootCmd *cobra.Command) {
 	rootCmd.AddCommand(systemCmd)
 }
 
+func printHardwareProfile(cmd *cobra.Command, doc system.HardwareProfileDocument) {
+	profile := doc.Profile
+	cmd.Printf("%s\n", i18n.T("CPU: %s (%s, %s), %d cores / %d threads", profile.CPU.ModelName, profile.CPU.Vendor, profile.CPU.Arch, profile.CPU.Cores, profile.CPU.Threads))
+	cmd.Printf("%s\n", i18n.T("Memory: %s total, %s available", modelmanager.FormatBytes(int64(profile.Memory.Total)), modelmanager.FormatBytes(int64(profile.Memory.Available))))
+	if len(profile.GPUs) == 0 {
+		cmd.Println(i18n.T("GPU: none"))
+	}
+	for _, gpu := range profile.GPUs {
+		details := []string{gpu.Vendor}
+		if gpu.VRAMTotal > 0 {
+			details = append(details, i18n.T("%s VRAM", modelmanager.FormatBytes(int64(gpu.VRAMTotal))))
+		}
+		if gpu.ComputeCapability != "" {
+			details = append(details, i18n.T("compute %s", gpu.ComputeCapability))
+		}
+		if gpu.CUDAVersion != "" {
+			details = append(details, i18n.T("CUDA %s", gpu.CUDAVersion))
+		}
+		if gpu.DriverVersion != "" {
+			details = append(details, i18n.T("driver %s", gpu.DriverVersion))
+		}
+		if gpu.NVLink {
+			details = append(details, "NVLink")
+		}
+		cmd.Printf("%s\n", i18n.T("GPU %d: %s (%s)", gpu.Index, gpu.Name, strings.Join(details, ", ")))
+	}
+	for _, storage := range profile.Storage {
+		cmd.Printf("%s\n", i18n.T("Storage %s: %s free of %s (%s)", storage.Path, modelmanager.FormatBytes(int64(storage.Free)), modelmanager.FormatBytes(int64(storage.Total)), storage.Type))
+	}
+}
+
 func RegisterProviderCommands(rootCmd *cobra.Command) {
 	providerCmd := &cobra.Command{
 		Use:   "provider",
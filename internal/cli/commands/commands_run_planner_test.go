@@ -15,6 +15,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/zhuangbiaowei/LocalAIStack/internal/config"
 	"github.com/zhuangbiaowei/LocalAIStack/internal/llm"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/modelmanager"
 	"github.com/zhuangbiaowei/LocalAIStack/internal/system"
 )
 
@@ -65,7 +66,7 @@ func TestBuildVLLMServeArgs(t *testing.T) {
 func TestBuildLlamaServerArgs(t *testing.T) {
 	args := buildLlamaServerArgs(
 		"/models/foo.gguf",
-		llamaRunDefaults{threads: 8, ctxSize: 4096, gpuLayers: 20, tensorSplit: "50,50"},
+		llamaRunDefaults{threads: 8, ctxSize: 4096, gpuLayers: 20, tensorSplit: "50,50", flashAttn: true},
 		"127.0.0.1",
 		9000,
 		llamaSamplingParams{
@@ -88,6 +89,7 @@ func TestBuildLlamaServerArgs(t *testing.T) {
 		"--n-gpu-layers 20",
 		"--host 127.0.0.1",
 		"--port 9000",
+		"--flash-attn on",
 		"--tensor-split 50,50",
 		"--batch-size 256",
 		"--ubatch-size 128",
@@ -100,6 +102,52 @@ func TestBuildLlamaServerArgs(t *testing.T) {
 	}
 }
 
+func TestLlamaFlashAttentionFollowsOffloadAndCapability(t *testing.T) {
+	ampere := system.BaseInfoSummary{GPUName: "NVIDIA A10", GPUCount: 1, GPUVRAMMB: 24 * 1024, GPUComputeCapability: "8.6"}
+	volta := system.BaseInfoSummary{GPUName: "NVIDIA A10", GPUCount: 1, GPUVRAMMB: 24 * 1024, GPUComputeCapability: "7.0"}
+	unprobed := system.BaseInfoSummary{GPUName: "Tesla V100", GPUCount: 1, GPUVRAMMB: 16 * 1024}
+
+	if !isLegacyCUDAInferenceGPU(volta) || isLegacyCUDAInferenceGPU(ampere) {
+		t.Fatalf("expected the compute capability to decide over the GPU name")
+	}
+	if !isLegacyCUDAInferenceGPU(unprobed) {
+		t.Fatalf("expected the GPU name to be used without a compute capability")
+	}
+
+	cases := []struct {
+		name     string
+		info     system.BaseInfoSummary
+		defaults llamaRunDefaults
+		want     bool
+	}{
+		{"offloaded to ampere", ampere, llamaRunDefaults{gpuLayers: 999}, true},
+		{"cpu only", ampere, llamaRunDefaults{gpuLayers: 0}, false},
+		{"offloaded to volta", volta, llamaRunDefaults{gpuLayers: 999}, false},
+		{"quantized cache", volta, llamaRunDefaults{gpuLayers: 0, cacheType: "q8_0"}, true},
+		{"no gpu", system.BaseInfoSummary{}, llamaRunDefaults{gpuLayers: 999}, false},
+	}
+	for _, tc := range cases {
+		if got := llamaFlashAttention(tc.info, tc.defaults); got != tc.want {
+			t.Fatalf("%s: llamaFlashAttention = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLlamaCacheTypeNormalizes(t *testing.T) {
+	cases := map[string]string{"": "", "auto": "", " Q8_0 ": "q8_0", "F16": "f16"}
+	for value, want := range cases {
+		got, err := llamaCacheType(value)
+		if err != nil || got != want {
+			t.Fatalf("llamaCacheType(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"fp8", "fp8_e4m3", "q3_k"} {
+		if _, err := llamaCacheType(value); err == nil {
+			t.Fatalf("expected llamaCacheType(%q) to be rejected", value)
+		}
+	}
+}
+
 func TestBuildLlamaServerArgsSkipsOptional(t *testing.T) {
 	args := buildLlamaServerArgs(
 		"/models/foo.gguf",
@@ -112,7 +160,7 @@ func TestBuildLlamaServerArgsSkipsOptional(t *testing.T) {
 	)
 	joined := strings.Join(args, " ")
 
-	skipTokens := []string{"--tensor-split", "--batch-size", "--ubatch-size", "--chat-template-kwargs"}
+	skipTokens := []string{"--flash-attn", "--tensor-split", "--batch-size", "--ubatch-size", "--chat-template-kwargs"}
 	for _, token := range skipTokens {
 		if strings.Contains(joined, token) {
 			t.Fatalf("did not expect token %q in args: %v", token, args)
@@ -120,6 +168,27 @@ func TestBuildLlamaServerArgsSkipsOptional(t *testing.T) {
 	}
 }
 
+func TestAutoTuneRunParamsUsesGGUFMetadata(t *testing.T) {
+	info := system.BaseInfoSummary{CPUCores: 8, MemoryKB: 64 * 1024 * 1024, GPUName: "RTX 4090", GPUCount: 1, GPUVRAMMB: 24 * 1024}
+	model := &modelmanager.GGUFInfo{ParameterCount: 8_000_000_000, FileType: "Q4_K_M", ContextLength: 2048}
+
+	got := autoTuneRunParams(defaultLlamaRunParams(info, nil, ""), info, model)
+	if got.gpuLayers != 999 {
+		t.Fatalf("expected full offload for an 8B Q4_K_M model on 24GB, got %d", got.gpuLayers)
+	}
+	if got.ctxSize != 2048 {
+		t.Fatalf("expected ctx-size to stop at the trained context length, got %d", got.ctxSize)
+	}
+
+	large := &modelmanager.GGUFInfo{ParameterCount: 70_000_000_000, FileType: "Q4_K_M"}
+	if got := autoTuneRunParams(defaultLlamaRunParams(info, nil, ""), info, large); got.gpuLayers == 999 {
+		t.Fatalf("expected a 70B model not to be fully offloaded")
+	}
+	if small, big := autoTuneBatchParams(info, model, 4096, 999), autoTuneBatchParams(info, large, 4096, 999); big.UBatchSize >= small.UBatchSize {
+		t.Fatalf("expected a smaller ubatch for the larger model, got %d and %d", small.UBatchSize, big.UBatchSize)
+	}
+}
+
 func TestParseSmartRunAdvice(t *testing.T) {
 	text := "```json\n{\"llama\":{\"threads\":12,\"ctx_size\":8192},\"reason\":\"ok\"}\n```"
 	var out smartRunAdviceEnvelope
@@ -11,9 +11,10 @@ import (
 
 func TestDefaultVLLMRunParams_V100DualGPU(t *testing.T) {
 	info := system.BaseInfoSummary{
-		MemoryKB: 32691216,
-		GPUName:  "Tesla V100-SXM2-16GB",
-		GPUCount: 2,
+		MemoryKB:  32691216,
+		GPUName:   "Tesla V100-SXM2-16GB",
+		GPUVRAMMB: 16 * 1024,
+		GPUCount:  2,
 	}
 
 	got := defaultVLLMRunParams(info)
@@ -49,9 +50,10 @@ func TestDefaultVLLMRunParams_V100DualGPU(t *testing.T) {
 
 func TestDefaultVLLMRunParams_A100HighVRAM(t *testing.T) {
 	info := system.BaseInfoSummary{
-		MemoryKB: 262144000,
-		GPUName:  "NVIDIA A100-SXM4-80GB",
-		GPUCount: 4,
+		MemoryKB:  262144000,
+		GPUName:   "NVIDIA A100-SXM4-80GB",
+		GPUVRAMMB: 80 * 1024,
+		GPUCount:  4,
 	}
 
 	got := defaultVLLMRunParams(info)
@@ -87,9 +89,10 @@ func TestDefaultVLLMRunParams_A100HighVRAM(t *testing.T) {
 
 func TestFinalizeVLLMRunParams_V100RejectsUnsafeSmartRunAdvice(t *testing.T) {
 	info := system.BaseInfoSummary{
-		MemoryKB: 32691216,
-		GPUName:  "Tesla V100-SXM2-16GB",
-		GPUCount: 2,
+		MemoryKB:  32691216,
+		GPUName:   "Tesla V100-SXM2-16GB",
+		GPUVRAMMB: 16 * 1024,
+		GPUCount:  2,
 	}
 
 	got := finalizeVLLMRunParams(info, vllmRunDefaults{
@@ -103,7 +106,7 @@ func TestFinalizeVLLMRunParams_V100RejectsUnsafeSmartRunAdvice(t *testing.T) {
 		maxNumBatchedTokens:    512,
 		disableCustomAllReduce: false,
 		env:                    []string{"NCCL_IB_DISABLE=1", "NCCL_P2P_DISABLE=1"},
-	}, true)
+	}, true, nil)
 
 	if got.maxModelLen != 512 {
 		t.Fatalf("expected maxModelLen=512, got %d", got.maxModelLen)
@@ -152,9 +155,10 @@ func TestFinalizeVLLMRunParams_V100RejectsUnsafeSmartRunAdvice(t *testing.T) {
 
 func TestFinalizeVLLMRunParams_RecomputesEnvFromFinalFlags(t *testing.T) {
 	info := system.BaseInfoSummary{
-		MemoryKB: 262144000,
-		GPUName:  "NVIDIA A100-SXM4-80GB",
-		GPUCount: 4,
+		MemoryKB:  262144000,
+		GPUName:   "NVIDIA A100-SXM4-80GB",
+		GPUVRAMMB: 80 * 1024,
+		GPUCount:  4,
 	}
 
 	got := finalizeVLLMRunParams(info, vllmRunDefaults{
@@ -165,7 +169,7 @@ func TestFinalizeVLLMRunParams_RecomputesEnvFromFinalFlags(t *testing.T) {
 		maxNumSeqs:             16,
 		disableCustomAllReduce: false,
 		env:                    []string{"NCCL_IB_DISABLE=1", "NCCL_P2P_DISABLE=1"},
-	}, true)
+	}, true, nil)
 
 	if got.tensorParallelSize != 4 {
 		t.Fatalf("expected tensorParallelSize=4, got %d", got.tensorParallelSize)
@@ -209,16 +213,17 @@ func TestBuildVLLMServeArgs_SkipsUnsetOptimizationLevel(t *testing.T) {
 
 func TestFinalizeVLLMRunParams_UserOverridesCanBeReapplied(t *testing.T) {
 	info := system.BaseInfoSummary{
-		MemoryKB: 32691216,
-		GPUName:  "Tesla V100-SXM2-16GB",
-		GPUCount: 2,
+		MemoryKB:  32691216,
+		GPUName:   "Tesla V100-SXM2-16GB",
+		GPUVRAMMB: 16 * 1024,
+		GPUCount:  2,
 	}
 
 	got := finalizeVLLMRunParams(info, vllmRunDefaults{
 		maxModelLen:        32768,
 		gpuMemUtil:         0.95,
 		tensorParallelSize: 2,
-	}, false)
+	}, false, nil)
 
 	// finalize keeps conservative defaults; caller may reapply explicit user flags afterward.
 	got.maxModelLen = clampInt(32768, 256, 131072)
@@ -234,12 +239,13 @@ func TestFinalizeVLLMRunParams_UserOverridesCanBeReapplied(t *testing.T) {
 
 func TestFinalizeVLLMRunParams_LegacyMultimodalSkipsTextOnlyFlags(t *testing.T) {
 	info := system.BaseInfoSummary{
-		MemoryKB: 32691216,
-		GPUName:  "Tesla V100-SXM2-16GB",
-		GPUCount: 2,
+		MemoryKB:  32691216,
+		GPUName:   "Tesla V100-SXM2-16GB",
+		GPUVRAMMB: 16 * 1024,
+		GPUCount:  2,
 	}
 
-	got := finalizeVLLMRunParams(info, vllmRunDefaults{}, false)
+	got := finalizeVLLMRunParams(info, vllmRunDefaults{}, false, nil)
 
 	if !got.skipMMProfiling {
 		t.Fatalf("expected skipMMProfiling=true")
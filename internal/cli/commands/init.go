@@ -105,6 +105,17 @@ func newInitCommand() *cobra.Command {
 				return err
 			}
 			cmd.Printf("%s\n", i18n.T("Base system info written to %s", baseInfoPath))
+
+			profile, err := system.DetectHardwareProfile(nil)
+			if err != nil {
+				cmd.Printf("%s\n", i18n.T("Hardware detection skipped: %v", err))
+				return nil
+			}
+			profilePath := system.ResolveHardwareProfilePath()
+			if err := system.WriteHardwareProfile(profilePath, profile); err != nil {
+				return err
+			}
+			cmd.Printf("%s\n", i18n.T("Hardware profile written to %s", profilePath))
 			return nil
 		},
 	}
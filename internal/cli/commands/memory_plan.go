@@ -0,0 +1,306 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/memestimate"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/modelmanager"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/system"
+)
+
+const (
+	// llama.cpp contexts are planned between these bounds; the trained
+	// context of the model lowers the ceiling.
+	llamaPlanMaxContext = 32768
+	llamaPlanMinContext = 2048
+	llamaPlanUBatch     = 512
+
+	vllmPlanMaxContext = 131072
+	vllmPlanMinContext = 256
+	vllmPlanBatch      = 2048
+
+	// Memory left to the operating system when sizing host offload.
+	hostMemoryReserve = 2 * memestimate.GiB
+)
+
+// ggufMemoryModel describes a GGUF model for the memory estimator, or
+// returns nil when the header lacks the shape.
+func ggufMemoryModel(info *modelmanager.GGUFInfo, modelPath string) *memestimate.Model {
+	if info == nil || info.BlockCount == 0 || info.EmbeddingLength == 0 {
+		return nil
+	}
+	model := &memestimate.Model{
+		Parameters:    info.ParameterCount,
+		Layers:        int(info.BlockCount),
+		HiddenSize:    int(info.EmbeddingLength),
+		Heads:         int(info.HeadCount),
+		KVHeads:       int(info.HeadCountKV),
+		ContextLength: int(info.ContextLength),
+		WeightBytes:   ggufWeightBytes(modelPath),
+	}
+	if keyLength, ok := info.Metadata[info.Architecture+".attention.key_length"].(uint64); ok {
+		model.HeadDim = int(keyLength)
+	}
+	for _, tensor := range info.Tensors {
+		if tensor.Name == "token_embd.weight" && len(tensor.Shape) == 2 {
+			model.VocabSize = int(tensor.Shape[1])
+		}
+	}
+	if model.WeightBytes == 0 && info.ParameterCount > 0 {
+		model.BitsPerWeight = 16
+	}
+	return model
+}
+
+// ggufWeightBytes sums the size of a GGUF file and, for split models, the
+// shards next to it.
+func ggufWeightBytes(modelPath string) uint64 {
+	var total uint64
+	for _, file := range modelmanager.GGUFShards(modelPath) {
+		if stat, err := os.Stat(file); err == nil {
+			total += uint64(stat.Size())
+		}
+	}
+	return total
+}
+
+// hfMemoryModel describes a safetensors model from its config.json, or
+// returns nil when the shape is not declared there.
+func hfMemoryModel(modelDir string) *memestimate.Model {
+	raw, err := os.ReadFile(filepath.Join(modelDir, "config.json"))
+	if err != nil {
+		return nil
+	}
+	var config struct {
+		hfTextConfig
+		TextConfig *hfTextConfig `json:"text_config"`
+	}
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil
+	}
+	shape := config.hfTextConfig
+	if shape.NumHiddenLayers == 0 && config.TextConfig != nil {
+		shape = *config.TextConfig
+	}
+	if shape.NumHiddenLayers == 0 || shape.HiddenSize == 0 {
+		return nil
+	}
+	model := &memestimate.Model{
+		Layers:        shape.NumHiddenLayers,
+		HiddenSize:    shape.HiddenSize,
+		Heads:         shape.NumAttentionHeads,
+		KVHeads:       shape.NumKeyValueHeads,
+		HeadDim:       shape.HeadDim,
+		VocabSize:     shape.VocabSize,
+		ContextLength: shape.MaxPositionEmbeddings,
+		BitsPerWeight: 16,
+	}
+	if strings.Contains(strings.ToLower(shape.TorchDtype), "float32") {
+		model.BitsPerWeight = 32
+	}
+	if files, err := modelmanager.FindSafetensorsFiles(modelDir); err == nil {
+		for _, file := range files {
+			if stat, err := os.Stat(file); err == nil {
+				model.WeightBytes += uint64(stat.Size())
+			}
+		}
+	}
+	if model.WeightBytes == 0 {
+		return nil
+	}
+	return model
+}
+
+type hfTextConfig struct {
+	NumHiddenLayers       int    `json:"num_hidden_layers"`
+	HiddenSize            int    `json:"hidden_size"`
+	NumAttentionHeads     int    `json:"num_attention_heads"`
+	NumKeyValueHeads      int    `json:"num_key_value_heads"`
+	HeadDim               int    `json:"head_dim"`
+	VocabSize             int    `json:"vocab_size"`
+	MaxPositionEmbeddings int    `json:"max_position_embeddings"`
+	TorchDtype            string `json:"torch_dtype"`
+}
+
+func gpuMemoryDevices(info system.BaseInfoSummary, count int) []memestimate.Device {
+	perGPU := uint64(info.GPUVRAMMB) * memestimate.MiB
+	if perGPU == 0 {
+		perGPU = uint64(info.VRAMGB()) * memestimate.GiB
+	}
+	if perGPU == 0 || count <= 0 {
+		return nil
+	}
+	devices := make([]memestimate.Device, count)
+	for i := range devices {
+		devices[i] = memestimate.Device{Name: fmt.Sprintf("GPU%d", i), Memory: perGPU}
+	}
+	return devices
+}
+
+func hostMemoryDevice(info system.BaseInfoSummary) memestimate.Device {
+	memory := uint64(max(info.MemoryKB, 0)) * 1024
+	if memory > hostMemoryReserve {
+		memory -= hostMemoryReserve
+	}
+	return memestimate.Device{Name: "host", Memory: memory}
+}
+
+func memoryGPUCount(info system.BaseInfoSummary) int {
+	if info.GPUCount <= 0 && info.VRAMGB() > 0 {
+		return 1
+	}
+	return info.GPUCount
+}
+
+// planLlamaMemory picks the largest context and GPU offload that fit the
+// machine for a GGUF model.
+func planLlamaMemory(info system.BaseInfoSummary, model memestimate.Model, cache memestimate.CacheType) (memestimate.Estimate, bool) {
+	largest := llamaPlanMaxContext
+	if model.ContextLength > 0 {
+		largest = min(largest, model.ContextLength)
+	}
+	contexts := memestimate.ContextCandidates(largest, min(llamaPlanMinContext, largest))
+	// Offloading plans run with flash attention where the GPU supports it;
+	// the final estimate follows the launch exactly.
+	flashAttn := quantizedLlamaCache(cache) || gpuFlashAttention(info)
+	config := memestimate.Config{Batch: llamaPlanUBatch, Cache: cache, FlashAttention: flashAttn, Overhead: 512 * memestimate.MiB}
+	return memestimate.LargestFit(model, config, gpuMemoryDevices(info, memoryGPUCount(info)), hostMemoryDevice(info), contexts)
+}
+
+// estimateLlamaMemory predicts the memory use of the final llama.cpp
+// parameters.
+func estimateLlamaMemory(info system.BaseInfoSummary, model memestimate.Model, defaults llamaRunDefaults, ubatch int) memestimate.Estimate {
+	cache, _ := memestimate.ParseCacheType(defaults.cacheType)
+	if ubatch <= 0 {
+		ubatch = llamaPlanUBatch
+	}
+	config := memestimate.Config{
+		Context:        defaults.ctxSize,
+		Batch:          ubatch,
+		Cache:          cache,
+		GPULayers:      defaults.gpuLayers,
+		FlashAttention: llamaFlashAttention(info, defaults),
+		Overhead:       512 * memestimate.MiB,
+	}
+	return memestimate.Compute(model, config, gpuMemoryDevices(info, memoryGPUCount(info)), hostMemoryDevice(info))
+}
+
+// vllmMemoryConfig is what vLLM allocates for one sequence of maxModelLen
+// tokens: weights and KV cache sharded across the tensor-parallel GPUs,
+// each limited to gpuMemUtil of its memory.
+func vllmMemoryConfig(info system.BaseInfoSummary, defaults vllmRunDefaults) (memestimate.Config, []memestimate.Device) {
+	batch := defaults.maxNumBatchedTokens
+	if batch <= 0 {
+		batch = vllmPlanBatch
+	}
+	overhead := uint64(memestimate.GiB)
+	if defaults.enforceEager {
+		overhead = 512 * memestimate.MiB
+	}
+	cache := memestimate.CacheBF16
+	if defaults.dtype == "float16" {
+		cache = memestimate.CacheF16
+	}
+	devices := gpuMemoryDevices(info, max(defaults.tensorParallelSize, 1))
+	util := defaults.gpuMemUtil
+	if util <= 0 {
+		util = 0.9
+	}
+	for i := range devices {
+		devices[i].Memory = uint64(float64(devices[i].Memory) * util)
+	}
+	config := memestimate.Config{
+		Context:        defaults.maxModelLen,
+		Batch:          batch,
+		Cache:          cache,
+		TensorParallel: true,
+		FlashAttention: true,
+		Overhead:       overhead,
+	}
+	return config, devices
+}
+
+// planVLLMMemory raises max_model_len to the largest value that fits, using
+// more tensor-parallel GPUs or a higher memory utilization when the model
+// does not fit otherwise. Defaults are left alone when nothing fits.
+func planVLLMMemory(info system.BaseInfoSummary, defaults vllmRunDefaults, model memestimate.Model, ceiling int) vllmRunDefaults {
+	gpuCount := memoryGPUCount(info)
+	if gpuCount <= 0 {
+		return defaults
+	}
+	largest := vllmPlanMaxContext
+	if model.ContextLength > 0 {
+		largest = min(largest, model.ContextLength)
+	}
+	if ceiling > 0 {
+		largest = min(largest, ceiling)
+	}
+	contexts := memestimate.ContextCandidates(largest, min(vllmPlanMinContext, largest))
+
+	utils := []float64{defaults.gpuMemUtil}
+	if defaults.gpuMemUtil < 0.95 {
+		utils = append(utils, 0.95)
+	}
+	for tp := max(defaults.tensorParallelSize, 1); tp <= gpuCount; tp *= 2 {
+		for _, util := range utils {
+			candidate := defaults
+			candidate.tensorParallelSize = tp
+			candidate.gpuMemUtil = util
+			config, devices := vllmMemoryConfig(info, candidate)
+			if estimate, fits := memestimate.LargestFit(model, config, devices, memestimate.Device{}, contexts); fits {
+				candidate.maxModelLen = estimate.Config.Context
+				return candidate
+			}
+		}
+	}
+	return defaults
+}
+
+// estimatedVRAM is the GPU memory an estimate puts on all GPUs.
+func estimatedVRAM(estimate memestimate.Estimate) uint64 {
+	var total uint64
+	for _, usage := range estimate.GPUs() {
+		total += usage.Total()
+	}
+	return total
+}
+
+// reservedVRAM is what vLLM claims up front: its memory utilization share of
+// every tensor-parallel GPU, whatever the model needs.
+func reservedVRAM(devices []memestimate.Device) uint64 {
+	var total uint64
+	for _, device := range devices {
+		total += device.Memory
+	}
+	return total
+}
+
+func printMemoryEstimate(cmd *cobra.Command, estimate memestimate.Estimate) {
+	config := estimate.Config
+	cmd.Printf("Memory estimate: ctx %d, batch %d, KV cache %s, %d layers on GPU\n",
+		config.Context, config.Batch, fallbackString(string(config.Cache), "f16"), config.GPULayers)
+	writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "DEVICE\tLAYERS\tWEIGHTS\tKV CACHE\tCOMPUTE\tOVERHEAD\tTOTAL\tAVAILABLE\tFITS")
+	for _, usage := range estimate.Devices {
+		available := "-"
+		if usage.Capacity > 0 {
+			available = memestimate.FormatBytes(usage.Capacity)
+		}
+		fits := "yes"
+		if !usage.Fits() {
+			fits = "no"
+		}
+		fmt.Fprintf(writer, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			usage.Device, usage.Layers,
+			memestimate.FormatBytes(usage.Weights), memestimate.FormatBytes(usage.KVCache),
+			memestimate.FormatBytes(usage.Compute), memestimate.FormatBytes(usage.Overhead),
+			memestimate.FormatBytes(usage.Total()), available, fits)
+	}
+	_ = writer.Flush()
+}
@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/memestimate"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/system"
+)
+
+var testLlama3_8B = memestimate.Model{Parameters: 8_030_000_000, Layers: 32, HiddenSize: 4096, Heads: 32, KVHeads: 8, VocabSize: 128256, ContextLength: 131072, WeightBytes: 4_920_000_000}
+
+func TestDefaultLlamaRunParamsPlansFromMemory(t *testing.T) {
+	tests := []struct {
+		name      string
+		info      system.BaseInfoSummary
+		cache     string
+		wantCtx   int
+		wantLayer int
+	}{
+		{"24gb gpu", system.BaseInfoSummary{CPUCores: 8, MemoryKB: 32 * 1024 * 1024, GPUCount: 1, GPUVRAMMB: 24 * 1024}, "", 32768, 999},
+		{"8gb gpu", system.BaseInfoSummary{CPUCores: 8, MemoryKB: 32 * 1024 * 1024, GPUCount: 1, GPUVRAMMB: 8 * 1024}, "", 16384, 999},
+		{"8gb gpu with q8_0 cache", system.BaseInfoSummary{CPUCores: 8, MemoryKB: 32 * 1024 * 1024, GPUCount: 1, GPUVRAMMB: 8 * 1024}, "q8_0", 32768, 999},
+		{"4gb gpu", system.BaseInfoSummary{CPUCores: 8, MemoryKB: 32 * 1024 * 1024, GPUCount: 1, GPUVRAMMB: 4 * 1024}, "", 2048, 26},
+		{"cpu only", system.BaseInfoSummary{CPUCores: 8, MemoryKB: 16 * 1024 * 1024}, "", 32768, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := defaultLlamaRunParams(tt.info, &testLlama3_8B, tt.cache)
+			if !got.memoryPlanned || got.ctxSize != tt.wantCtx || got.gpuLayers != tt.wantLayer {
+				t.Fatalf("expected ctx %d with %d layers, got %+v", tt.wantCtx, tt.wantLayer, got)
+			}
+		})
+	}
+}
+
+func TestFinalizeVLLMRunParamsPlansFromMemory(t *testing.T) {
+	model := memestimate.Model{Layers: 80, HiddenSize: 8192, Heads: 64, KVHeads: 8, VocabSize: 128256, ContextLength: 131072, BitsPerWeight: 16, WeightBytes: 141_000_000_000}
+	info := system.BaseInfoSummary{CPUCores: 64, MemoryKB: 512 * 1024 * 1024, GPUName: "NVIDIA A100-SXM4-80GB", GPUCount: 4, GPUVRAMMB: 80 * 1024}
+
+	got := finalizeVLLMRunParams(info, defaultVLLMRunParams(info), true, &model)
+	if got.tensorParallelSize != 4 {
+		t.Fatalf("expected a 70B bf16 model to need four GPUs, got tp=%d", got.tensorParallelSize)
+	}
+	if got.maxModelLen != 131072 {
+		t.Fatalf("expected the full trained context to fit, got %d", got.maxModelLen)
+	}
+	config, devices := vllmMemoryConfig(info, got)
+	if estimate := memestimate.Compute(model, config, devices, memestimate.Device{}); !estimate.Fits() {
+		t.Fatalf("expected the chosen parameters to fit, got %+v", estimate)
+	}
+	if want := 4 * uint64(float64(80*memestimate.GiB)*got.gpuMemUtil); reservedVRAM(devices) != want {
+		t.Fatalf("expected vLLM to reserve %d bytes, got %d", want, reservedVRAM(devices))
+	}
+
+	llama := estimateLlamaMemory(info, testLlama3_8B, defaultLlamaRunParams(info, &testLlama3_8B, ""), 0)
+	if vram := estimatedVRAM(llama); vram < testLlama3_8B.WeightBytes || vram > 80*memestimate.GiB {
+		t.Fatalf("expected the llama.cpp estimate to cover the weights on one GPU, got %d", vram)
+	}
+}
+
+func TestHFMemoryModelReadsTextConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := `{"architectures":["Qwen2VLForConditionalGeneration"],"text_config":{"num_hidden_layers":28,"hidden_size":3584,"num_attention_heads":28,"num_key_value_heads":4,"vocab_size":152064,"max_position_embeddings":32768}}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0o644); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "model.safetensors"), make([]byte, 1024), 0o644); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+
+	model := hfMemoryModel(dir)
+	if model == nil || model.Layers != 28 || model.KVHeads != 4 || model.ContextLength != 32768 || model.WeightBytes != 1024 {
+		t.Fatalf("unexpected model shape: %+v", model)
+	}
+}
+
+func TestPrintMemoryEstimateMarksOverflow(t *testing.T) {
+	info := system.BaseInfoSummary{CPUCores: 8, MemoryKB: 32 * 1024 * 1024, GPUCount: 1, GPUVRAMMB: 4 * 1024}
+	estimate := estimateLlamaMemory(info, testLlama3_8B, llamaRunDefaults{ctxSize: 8192, gpuLayers: 999}, 512)
+	cmd := &cobra.Command{Use: "test"}
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	printMemoryEstimate(cmd, estimate)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], "GPU0") || !strings.HasSuffix(lines[2], "no") {
+		t.Fatalf("expected the GPU row to be marked as not fitting, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,482 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/api"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/runtime"
+)
+
+const (
+	// Model servers answer /health with an error until the weights are
+	// loaded, which can take minutes.
+	modelHealthStartPeriod = 15 * time.Minute
+	modelHealthInterval    = 10 * time.Second
+	modelLogPollInterval   = 500 * time.Millisecond
+)
+
+var modelInstanceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// newModelServeCommand shares the planning of `las model run`, which hands
+// the final command to the supervisor when it runs as serve.
+func newModelServeCommand(runE func(cmd *cobra.Command, args []string) error) *cobra.Command {
+	serveCmd := &cobra.Command{
+		Use:   "serve [model-id] [gguf-file-or-quant]",
+		Short: "Serve a local model in the background",
+		Long: "Plan the launch like `las model run`, then start llama.cpp or vLLM under the runtime supervisor " +
+			"of las-server as a named instance with its own port and health check.",
+		Args: cobra.RangeArgs(1, 2),
+		RunE: runE,
+	}
+	addModelRunFlags(serveCmd)
+	serveCmd.Flags().String("name", "", "Instance name (default derived from the model id)")
+	serveCmd.Flags().String("host", "0.0.0.0", "Host to bind the model server")
+	serveCmd.Flags().Int("port", 0, "Port to bind the model server (0 = pick a free port)")
+	serveCmd.Flags().String("restart", "", "Restart policy: never|on-failure|always (default never)")
+	serveCmd.Flags().Bool("on-demand", false, "Register the instance and let the las-server gateway start it on the first request and stop it when idle")
+	serveCmd.Flags().String("socket", "", "Runtime supervisor socket (default is runtime.socket_path)")
+	return serveCmd
+}
+
+func newModelPsCommand() *cobra.Command {
+	psCmd := &cobra.Command{
+		Use:   "ps",
+		Short: "List served model instances",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			all, _ := cmd.Flags().GetBool("all")
+			output, _ := cmd.Flags().GetString("output")
+			client, err := newSupervisorClient(cmd)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), serviceRequestTimeout)
+			defer cancel()
+			statuses, err := client.List(ctx)
+			if err != nil {
+				return err
+			}
+			instances := make([]runtime.Status, 0, len(statuses))
+			for _, status := range statuses {
+				if isModelInstance(status) && (all || modelInstanceReserved(status)) {
+					instances = append(instances, status)
+				}
+			}
+			sort.Slice(instances, func(i, j int) bool { return instances[i].Name < instances[j].Name })
+
+			if strings.EqualFold(strings.TrimSpace(output), "json") {
+				payload, err := json.MarshalIndent(instances, "", "  ")
+				if err != nil {
+					return err
+				}
+				cmd.Printf("%s\n", payload)
+				return nil
+			}
+			if len(instances) == 0 {
+				cmd.Println("No model instances running or registered.")
+				return nil
+			}
+			writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(writer, "NAME\tMODEL\tRUNTIME\tENDPOINT\tSTATE\tHEALTH\tPID\tSTARTED")
+			for _, status := range instances {
+				pid := "-"
+				if status.PID > 0 {
+					pid = strconv.Itoa(status.PID)
+				}
+				state := string(status.State)
+				if isOnDemandModelInstance(status) {
+					state += " (on-demand)"
+				}
+				started := "-"
+				if !status.StartedAt.IsZero() {
+					started = status.StartedAt.Local().Format(time.DateTime)
+				}
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					status.Name,
+					status.Labels[api.ModelLabel],
+					status.Labels[api.ModelRuntimeLabel],
+					modelInstanceEndpoint(status),
+					state,
+					status.Health,
+					pid,
+					started,
+				)
+			}
+			return writer.Flush()
+		},
+	}
+	psCmd.Flags().BoolP("all", "a", false, "Include stopped and failed instances that are not on-demand")
+	psCmd.Flags().String("output", "text", "Output format: text|json")
+	psCmd.Flags().String("socket", "", "Runtime supervisor socket (default is runtime.socket_path)")
+	return psCmd
+}
+
+func newModelStopCommand() *cobra.Command {
+	stopCmd := &cobra.Command{
+		Use:   "stop [instance-name]",
+		Short: "Stop a served model instance",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			remove, _ := cmd.Flags().GetBool("remove")
+			client, err := newSupervisorClient(cmd)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), serviceRequestTimeout)
+			defer cancel()
+			status, err := modelInstanceStatus(ctx, client, args[0])
+			if err != nil {
+				return err
+			}
+			if modelInstanceActive(status) {
+				cmd.Printf("Stopping model instance: %s\n", args[0])
+				if status, err = client.Stop(ctx, args[0]); err != nil {
+					return err
+				}
+				cmd.Printf("State: %s\n", status.State)
+				if status.StopSignal != "" {
+					cmd.Printf("Stop signal: %s\n", status.StopSignal)
+				}
+			}
+			if remove {
+				if err := client.Remove(ctx, args[0]); err != nil {
+					return err
+				}
+				cmd.Printf("Removed model instance: %s\n", args[0])
+			} else if isOnDemandModelInstance(status) {
+				cmd.Printf("%s is on-demand and starts again on its next request; use --remove to unregister it\n", args[0])
+			}
+			return nil
+		},
+	}
+	stopCmd.Flags().Bool("remove", false, "Also unregister the instance so it is no longer listed or started on demand")
+	stopCmd.Flags().String("socket", "", "Runtime supervisor socket (default is runtime.socket_path)")
+	return stopCmd
+}
+
+func newModelLogsCommand() *cobra.Command {
+	logsCmd := &cobra.Command{
+		Use:   "logs [instance-name]",
+		Short: "Print the log of a served model instance",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			follow, _ := cmd.Flags().GetBool("follow")
+			client, err := newSupervisorClient(cmd)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), serviceRequestTimeout)
+			status, err := modelInstanceStatus(ctx, client, args[0])
+			cancel()
+			if err != nil {
+				return err
+			}
+			if status.LogPath == "" {
+				return fmt.Errorf("model instance %s has no log", args[0])
+			}
+			if !follow {
+				file, err := os.Open(status.LogPath)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				_, err = io.Copy(cmd.OutOrStdout(), file)
+				return err
+			}
+			// A restart writes to a new log file; follow it.
+			currentLog := func() string {
+				ctx, cancel := context.WithTimeout(cmd.Context(), serviceRequestTimeout)
+				defer cancel()
+				status, err := client.Status(ctx, args[0])
+				if err != nil {
+					return ""
+				}
+				return status.LogPath
+			}
+			return followModelLog(cmd.Context(), cmd.OutOrStdout(), status.LogPath, modelLogPollInterval, currentLog)
+		},
+	}
+	logsCmd.Flags().BoolP("follow", "f", false, "Keep printing the log as it grows")
+	logsCmd.Flags().String("socket", "", "Runtime supervisor socket (default is runtime.socket_path)")
+	return logsCmd
+}
+
+// modelServePlan is where `las model serve` will start an instance.
+type modelServePlan struct {
+	client  *api.SupervisorClient
+	name    string
+	host    string
+	port    int
+	restart runtime.RestartPolicy
+	// onDemand registers the instance for the gateway instead of starting it.
+	onDemand bool
+	// servedName is the model name the server insists on, if any.
+	servedName string
+	// vram is the estimated VRAM of the instance in bytes, 0 if unknown.
+	vram uint64
+}
+
+// prepareModelServe checks the instance name and settles the port before
+// the launch arguments are built. Dry runs do not contact the supervisor.
+func prepareModelServe(cmd *cobra.Command, modelID, host string, port int, dryRun bool) (*modelServePlan, error) {
+	name, _ := cmd.Flags().GetString("name")
+	restart, _ := cmd.Flags().GetString("restart")
+	onDemand, _ := cmd.Flags().GetBool("on-demand")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = suggestVLLMServedModelName(modelID)
+	}
+	if !modelInstanceNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid instance name %q; use --name with letters, digits, '.', '_' or '-'", name)
+	}
+	plan := &modelServePlan{
+		name:     name,
+		host:     host,
+		port:     port,
+		restart:  runtime.RestartPolicy(strings.TrimSpace(restart)),
+		onDemand: onDemand,
+	}
+	switch plan.restart {
+	case "", runtime.RestartNever, runtime.RestartOnFailure, runtime.RestartAlways:
+	default:
+		return nil, fmt.Errorf("invalid --restart %q, expected never|on-failure|always", restart)
+	}
+
+	var statuses []runtime.Status
+	if !dryRun {
+		client, err := newSupervisorClient(cmd)
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(cmd.Context(), serviceRequestTimeout)
+		defer cancel()
+		if statuses, err = client.List(ctx); err != nil {
+			return nil, err
+		}
+		plan.client = client
+	}
+	if err := checkModelInstanceName(statuses, name); err != nil {
+		return nil, err
+	}
+	reserved := modelInstancePorts(statuses)
+	// A stopped instance being served again may keep its port.
+	for reservedPort, owner := range reserved {
+		if owner == name {
+			delete(reserved, reservedPort)
+		}
+	}
+	if port > 0 {
+		if owner, ok := reserved[port]; ok {
+			return nil, fmt.Errorf("port %d is already used by model instance %s", port, owner)
+		}
+		return plan, nil
+	}
+	var err error
+	if plan.port, err = allocateModelPort(host, reserved); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// start hands the planned server command to the supervisor and saves the
+// smart-run parameters once it is started. On-demand instances are only
+// registered; their parameters travel with the spec and the gateway saves
+// them once a launch it made passes the health check.
+func (p *modelServePlan) start(cmd *cobra.Command, runtimeName, modelID string, command []string, env map[string]string, selector string, advice *smartRunAdviceEnvelope) error {
+	spec := modelInstanceSpec(p.name, runtimeName, modelID, p.host, p.port, command, env)
+	spec.Restart.Policy = p.restart
+	if p.servedName != "" {
+		spec.Labels[api.ModelServedNameLabel] = p.servedName
+	}
+	if p.vram > 0 {
+		spec.Labels[api.ModelVRAMLabel] = strconv.FormatUint(p.vram, 10)
+	}
+	ctx, cancel := context.WithTimeout(cmd.Context(), serviceRequestTimeout)
+	defer cancel()
+	if p.onDemand {
+		spec.Labels[api.ModelOnDemandLabel] = "true"
+		if advice != nil {
+			path, payload, err := smartRunAdviceFile(runtimeName, modelID, selector, *advice)
+			if err != nil {
+				return err
+			}
+			spec.Labels[api.ModelAdvicePathLabel] = path
+			spec.Labels[api.ModelAdviceLabel] = string(payload)
+		}
+		status, err := p.client.Register(ctx, spec)
+		if err != nil {
+			return err
+		}
+		cmd.Printf("Model instance %s is registered on %s and starts on the first gateway request for %s\n",
+			status.Name, modelInstanceEndpoint(status), status.Name)
+		return nil
+	}
+	status, err := p.client.Start(ctx, spec)
+	if err != nil {
+		return err
+	}
+	if advice != nil {
+		if err := saveSmartRunAdvice(runtimeName, modelID, selector, *advice); err != nil {
+			cmd.Printf("Warning: failed to save smart-run parameters: %v\n", err)
+		}
+	}
+	cmd.Printf("Model instance %s is starting on %s (pid %d)\n", status.Name, modelInstanceEndpoint(status), status.PID)
+	cmd.Printf("Log: %s\n", status.LogPath)
+	cmd.Printf("Check progress with `las model ps` or `las model logs %s -f`\n", status.Name)
+	return nil
+}
+
+func modelInstanceSpec(name, runtimeName, modelID, host string, port int, command []string, env map[string]string) runtime.ModuleSpec {
+	return runtime.ModuleSpec{
+		Name:    name,
+		Mode:    runtime.ModeNative,
+		Command: command,
+		Env:     env,
+		HealthCheck: runtime.HealthCheck{
+			HTTP:        &runtime.HTTPHealthCheck{URL: "http://" + api.LocalServerAddress(host, strconv.Itoa(port)) + "/health"},
+			Interval:    modelHealthInterval,
+			StartPeriod: modelHealthStartPeriod,
+		},
+		Labels: map[string]string{
+			api.ModelLabel:        modelID,
+			api.ModelRuntimeLabel: runtimeName,
+			api.ModelHostLabel:    host,
+			api.ModelPortLabel:    strconv.Itoa(port),
+		},
+	}
+}
+
+// allocateModelPort asks the kernel for a free port, skipping ports held by
+// instances that have not bound theirs yet.
+func allocateModelPort(host string, reserved map[int]string) (int, error) {
+	for attempt := 0; attempt < 20; attempt++ {
+		listener, err := net.Listen("tcp", net.JoinHostPort(strings.Trim(host, "[]"), "0"))
+		if err != nil {
+			return 0, fmt.Errorf("allocate a port on %s: %w", host, err)
+		}
+		port := listener.Addr().(*net.TCPAddr).Port
+		listener.Close()
+		if _, taken := reserved[port]; !taken {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no free port available on %s", host)
+}
+
+func checkModelInstanceName(statuses []runtime.Status, name string) error {
+	for _, status := range statuses {
+		if status.Name != name {
+			continue
+		}
+		if !isModelInstance(status) {
+			return fmt.Errorf("service %s is not a model instance; choose another --name", name)
+		}
+		if modelInstanceActive(status) {
+			return fmt.Errorf("model instance %s is already %s on %s", name, status.State, modelInstanceEndpoint(status))
+		}
+	}
+	return nil
+}
+
+// modelInstancePorts maps the ports held by model instances to their names.
+func modelInstancePorts(statuses []runtime.Status) map[int]string {
+	ports := make(map[int]string)
+	for _, status := range statuses {
+		if !isModelInstance(status) || !modelInstanceReserved(status) {
+			continue
+		}
+		if port, err := strconv.Atoi(status.Labels[api.ModelPortLabel]); err == nil {
+			ports[port] = status.Name
+		}
+	}
+	return ports
+}
+
+func modelInstanceStatus(ctx context.Context, client *api.SupervisorClient, name string) (runtime.Status, error) {
+	status, err := client.Status(ctx, name)
+	if err != nil {
+		return runtime.Status{}, err
+	}
+	if !isModelInstance(status) {
+		return runtime.Status{}, fmt.Errorf("service %s is not a model instance; use `las service`", name)
+	}
+	return status, nil
+}
+
+func isModelInstance(status runtime.Status) bool {
+	return status.Labels[api.ModelLabel] != ""
+}
+
+// modelInstanceActive reports whether an instance holds its port, including
+// while a restart is pending.
+func modelInstanceActive(status runtime.Status) bool {
+	switch status.State {
+	case runtime.StateStarting, runtime.StateRunning, runtime.StateRestarting:
+		return true
+	}
+	return false
+}
+
+// modelInstanceReserved reports whether an instance is active or may be
+// started by the gateway at any time.
+func modelInstanceReserved(status runtime.Status) bool {
+	return modelInstanceActive(status) || isOnDemandModelInstance(status)
+}
+
+func isOnDemandModelInstance(status runtime.Status) bool {
+	return status.Labels[api.ModelOnDemandLabel] == "true"
+}
+
+func modelInstanceEndpoint(status runtime.Status) string {
+	port := status.Labels[api.ModelPortLabel]
+	if port == "" {
+		return "-"
+	}
+	return net.JoinHostPort(strings.Trim(status.Labels[api.ModelHostLabel], "[]"), port)
+}
+
+// followModelLog copies the log at path to w until ctx is done. When nothing
+// new arrives, current is asked for the instance's log path so a restart,
+// which starts a new file, is followed.
+func followModelLog(ctx context.Context, w io.Writer, path string, interval time.Duration, current func() string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { file.Close() }()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		copied, err := io.Copy(w, file)
+		if err != nil {
+			return err
+		}
+		if copied == 0 && current != nil {
+			if next := current(); next != "" && next != path {
+				if replacement, err := os.Open(next); err == nil {
+					file.Close()
+					file, path = replacement, next
+					continue
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/api"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/runtime"
+)
+
+func TestModelInstanceSpec(t *testing.T) {
+	spec := modelInstanceSpec("qwen3-8b", "llama.cpp", "qwen/Qwen3-8B-GGUF", "0.0.0.0", 41234, []string{"/usr/bin/llama-server", "--port", "41234"}, nil)
+	if spec.Mode != runtime.ModeNative || spec.Name != "qwen3-8b" || len(spec.Command) != 3 {
+		t.Fatalf("unexpected spec: %+v", spec)
+	}
+	if spec.HealthCheck.HTTP == nil || spec.HealthCheck.HTTP.URL != "http://127.0.0.1:41234/health" {
+		t.Fatalf("expected a loopback health check, got %+v", spec.HealthCheck)
+	}
+	if spec.HealthCheck.StartPeriod < time.Minute {
+		t.Fatalf("expected a start period covering model load, got %s", spec.HealthCheck.StartPeriod)
+	}
+	status := runtime.Status{Name: spec.Name, State: runtime.StateRunning, Labels: spec.Labels}
+	if !isModelInstance(status) || modelInstanceEndpoint(status) != "0.0.0.0:41234" {
+		t.Fatalf("unexpected labels: %+v", spec.Labels)
+	}
+}
+
+func TestModelInstanceNamesAndPorts(t *testing.T) {
+	labels := func(port string) map[string]string {
+		return map[string]string{api.ModelLabel: "qwen/Qwen3-8B-GGUF", api.ModelHostLabel: "0.0.0.0", api.ModelPortLabel: port}
+	}
+	statuses := []runtime.Status{
+		{Name: "chat", State: runtime.StateRunning, Labels: labels("41000")},
+		{Name: "embed", State: runtime.StateRestarting, Labels: labels("41001")},
+		{Name: "old", State: runtime.StateStopped, Labels: labels("41002")},
+		{Name: "lazy", State: runtime.StateStopped, Labels: labels("41003")},
+		{Name: "redis", State: runtime.StateRunning},
+	}
+	statuses[3].Labels[api.ModelOnDemandLabel] = "true"
+
+	ports := modelInstancePorts(statuses)
+	if len(ports) != 3 || ports[41000] != "chat" || ports[41001] != "embed" || ports[41003] != "lazy" {
+		t.Fatalf("expected the ports of active and on-demand instances, got %v", ports)
+	}
+	if err := checkModelInstanceName(statuses, "chat"); err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Fatalf("expected a running instance name to be rejected, got %v", err)
+	}
+	if err := checkModelInstanceName(statuses, "redis"); err == nil || !strings.Contains(err.Error(), "not a model instance") {
+		t.Fatalf("expected a service name to be rejected, got %v", err)
+	}
+	for _, name := range []string{"old", "lazy", "new"} {
+		if err := checkModelInstanceName(statuses, name); err != nil {
+			t.Fatalf("expected %s to be usable, got %v", name, err)
+		}
+	}
+
+	port, err := allocateModelPort("127.0.0.1", ports)
+	if err != nil || port <= 0 || ports[port] != "" {
+		t.Fatalf("allocateModelPort = %d, %v", port, err)
+	}
+}
+
+// syncBuffer lets the test read what followModelLog writes concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestFollowModelLogSwitchesToRestartedLog(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.log")
+	second := filepath.Join(dir, "second.log")
+	if err := os.WriteFile(first, []byte("loading\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+	var mu sync.Mutex
+	current := first
+	currentLog := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return current
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := &syncBuffer{}
+	done := make(chan error, 1)
+	go func() { done <- followModelLog(ctx, out, first, 5*time.Millisecond, currentLog) }()
+
+	waitForOutput := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !strings.Contains(out.String(), want) {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %q in followed log, got %q", want, out.String())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	waitForOutput("loading\n")
+
+	file, err := os.OpenFile(first, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile returned error: %v", err)
+	}
+	file.WriteString("crashed\n")
+	file.Close()
+	waitForOutput("crashed\n")
+
+	if err := os.WriteFile(second, []byte("restarted\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+	mu.Lock()
+	current = second
+	mu.Unlock()
+	waitForOutput("restarted\n")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("followModelLog returned error: %v", err)
+	}
+	if out.String() != "loading\ncrashed\nrestarted\n" {
+		t.Fatalf("unexpected followed log: %q", out.String())
+	}
+}
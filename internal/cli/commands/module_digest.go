@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/module"
+)
+
+func newModuleDigestCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "digest [module-name|path]",
+		Short: "Print the payload a module signature covers",
+		Long: `Print the payload a module signature covers. Sign it with
+"minisign -S -l -m <file>" or a plain ed25519 key, store the signature in the
+module directory and name it in integrity.signature of manifest.yaml.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			moduleDir, err := moduleDirFromArg(args[0])
+			if err != nil {
+				return err
+			}
+			digest, err := module.ModuleDigest(moduleDir)
+			if err != nil {
+				return err
+			}
+			_, err = cmd.OutOrStdout().Write(digest)
+			return err
+		},
+	}
+}
+
+// moduleDirFromArg accepts a module directory or the name of a registered
+// module.
+func moduleDirFromArg(arg string) (string, error) {
+	if _, err := os.Stat(filepath.Join(arg, "manifest.yaml")); err == nil {
+		return filepath.Abs(arg)
+	}
+	if registry := loadModuleRegistry(); registry != nil {
+		if records := registry.Get(normalizeModuleArg(arg)); len(records) > 0 {
+			return filepath.Dir(records[0].SourcePath), nil
+		}
+	}
+	return "", i18n.Errorf("module %q not found", arg)
+}
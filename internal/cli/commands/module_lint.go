@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/module"
+)
+
+func newModuleLintCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "lint [module-name|path]",
+		Short: "Validate module manifests and install specs",
+		Long: `Validate manifest.yaml and INSTALL.yaml against their schemas and check
+that step IDs are unique, referenced scripts and templates exist, and every
+install mode has steps. Without an argument all builtin modules are linted.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dirs, err := lintTargets(args)
+			if err != nil {
+				return err
+			}
+			failed := 0
+			for _, dir := range dirs {
+				issues, err := module.LintModule(dir)
+				if err != nil {
+					return err
+				}
+				if len(issues) > 0 {
+					failed++
+				}
+				for _, issue := range issues {
+					cmd.Println(issue.String())
+				}
+			}
+			if failed > 0 {
+				return i18n.Errorf("%d of %d modules failed lint", failed, len(dirs))
+			}
+			cmd.Printf("%s\n", i18n.T("%d modules passed lint", len(dirs)))
+			return nil
+		},
+	}
+}
+
+func lintTargets(args []string) ([]string, error) {
+	root, rootErr := module.FindModulesRoot()
+	if len(args) == 1 {
+		if dir, err := moduleDirFromArg(args[0]); err == nil {
+			return []string{dir}, nil
+		}
+		// Modules whose manifest does not load are missing from the registry
+		// but can still be linted from the builtin directory.
+		if rootErr == nil {
+			dir := filepath.Join(root, normalizeModuleArg(args[0]))
+			if info, err := os.Stat(dir); err == nil && info.IsDir() {
+				return []string{dir}, nil
+			}
+		}
+		return nil, i18n.Errorf("module %q not found", args[0])
+	}
+	if rootErr != nil {
+		return nil, rootErr
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join(root, entry.Name()))
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/module"
+)
+
+const moduleSourceFetchTimeout = 5 * time.Minute
+
+func newModuleRepoCommand() *cobra.Command {
+	repoCmd := &cobra.Command{
+		Use:   "repo",
+		Short: "Manage module sources",
+	}
+
+	addCmd := &cobra.Command{
+		Use:   "add [name] [location]",
+		Short: "Add a module source (local directory, HTTP(S) index URL or git repository)",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sourceType, _ := cmd.Flags().GetString("type")
+			ctx, cancel := context.WithTimeout(cmd.Context(), moduleSourceFetchTimeout)
+			defer cancel()
+			source, count, err := module.AddSource(ctx, args[0], args[1], module.SourceType(strings.ToLower(sourceType)))
+			if err != nil {
+				return err
+			}
+			cmd.Printf("%s\n", i18n.T("Added %s source %s with %d module versions.", source.Type, source.Name, count))
+			return nil
+		},
+	}
+	addCmd.Flags().String("type", "", "Source type: local|http|git (default: detected from the location)")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List module sources in precedence order",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sources, err := module.LoadSources()
+			if err != nil {
+				return err
+			}
+			writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(writer, "NAME\tTYPE\tLOCATION\tUPDATED")
+			if root, err := module.FindModulesRoot(); err == nil {
+				fmt.Fprintf(writer, "%s\t%s\t%s\t-\n", module.BuiltinSource, module.SourceLocal, root)
+			}
+			for _, source := range sources {
+				updated := "-"
+				if !source.UpdatedAt.IsZero() {
+					updated = source.UpdatedAt.Local().Format("2006-01-02 15:04:05")
+				}
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", source.Name, source.Type, source.URL, updated)
+			}
+			return writer.Flush()
+		},
+	}
+
+	updateCmd := &cobra.Command{
+		Use:   "update [name...]",
+		Short: "Refetch module sources (default: all)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names := args
+			if len(names) == 0 {
+				sources, err := module.LoadSources()
+				if err != nil {
+					return err
+				}
+				for _, source := range sources {
+					names = append(names, source.Name)
+				}
+			}
+			var failed []string
+			for _, name := range names {
+				ctx, cancel := context.WithTimeout(cmd.Context(), moduleSourceFetchTimeout)
+				count, err := module.UpdateSource(ctx, name)
+				cancel()
+				if err != nil {
+					cmd.Printf("%s\n", i18n.T("Failed to update %s: %v", name, err))
+					failed = append(failed, name)
+					continue
+				}
+				cmd.Printf("%s\n", i18n.T("Updated %s: %d module versions.", name, count))
+			}
+			if len(failed) > 0 {
+				return i18n.Errorf("failed to update module sources: %s", strings.Join(failed, ", "))
+			}
+			return nil
+		},
+	}
+
+	removeCmd := &cobra.Command{
+		Use:   "remove [name]",
+		Short: "Remove a module source and its cache",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := module.RemoveSource(args[0]); err != nil {
+				return err
+			}
+			cmd.Printf("%s\n", i18n.T("Removed module source %s.", args[0]))
+			return nil
+		},
+	}
+
+	repoCmd.AddCommand(addCmd)
+	repoCmd.AddCommand(listCmd)
+	repoCmd.AddCommand(updateCmd)
+	repoCmd.AddCommand(removeCmd)
+	return repoCmd
+}
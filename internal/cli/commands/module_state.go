@@ -0,0 +1,144 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/config"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/control"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/module"
+)
+
+func openStateManager() (*control.StateManager, error) {
+	dataDir := config.DefaultConfig().Control.DataDir
+	if cfg, err := config.LoadConfig(); err == nil {
+		dataDir = cfg.Control.DataDir
+	}
+	return control.OpenStateManager(dataDir)
+}
+
+func openModuleLifecycle() (*control.Lifecycle, error) {
+	state, err := openStateManager()
+	if err != nil {
+		return nil, i18n.Errorf("failed to open module state: %w", err)
+	}
+	return control.NewLifecycle(state), nil
+}
+
+func moduleStateLabel(state module.State) string {
+	switch state {
+	case module.StateAvailable:
+		return i18n.T("Not installed")
+	case module.StateInstalled:
+		return i18n.T("Installed")
+	default:
+		return string(state)
+	}
+}
+
+func newModuleHistoryCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history [module-name]",
+		Short: "Show module state snapshots",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state, err := openStateManager()
+			if err != nil {
+				return err
+			}
+			filter := ""
+			if len(args) == 1 {
+				filter = strings.ToLower(strings.TrimSpace(args[0]))
+			}
+			printModuleHistory(cmd, state.GetState().History, filter)
+			return nil
+		},
+	}
+	return cmd
+}
+
+// printModuleHistory lists snapshots newest first. Each snapshot holds the
+// module states from before the operation named in its reason; a filter keeps
+// the operations on one module.
+func printModuleHistory(cmd *cobra.Command, history []control.StateSnapshot, filter string) {
+	if len(history) == 0 {
+		cmd.Println(i18n.T("No state snapshots recorded."))
+		return
+	}
+	writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "SNAPSHOT\tCREATED\tREASON\tMODULES")
+	for i := len(history) - 1; i >= 0; i-- {
+		snapshot := history[i]
+		if filter != "" && !strings.Contains(" "+snapshot.Reason+" ", " "+filter+" ") {
+			continue
+		}
+		modules := make([]string, 0, len(snapshot.Modules))
+		for name, moduleState := range snapshot.Modules {
+			if filter != "" && name != filter {
+				continue
+			}
+			entry := name + "=" + string(moduleState.State)
+			if moduleState.Version != "" {
+				entry += "@" + moduleState.Version
+			}
+			modules = append(modules, entry)
+		}
+		sort.Strings(modules)
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n",
+			snapshot.ID,
+			snapshot.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+			snapshot.Reason,
+			fallbackString(strings.Join(modules, ", "), "-"))
+	}
+	_ = writer.Flush()
+}
+
+// loadModuleRegistry returns the modules of the builtin directory and every
+// configured source that could be read.
+func loadModuleRegistry() *module.Registry {
+	registry, _ := module.LoadRegistry()
+	return registry
+}
+
+// installModulePlan installs target together with the module dependencies
+// it declares. Modules outside the registry are installed directly so the
+// install itself reports why they cannot be found.
+func installModulePlan(cmd *cobra.Command, lifecycle *control.Lifecycle, target string, opts module.InstallOptions) error {
+	registry := loadModuleRegistry()
+	if registry == nil || len(registry.Get(normalizeModuleArg(target))) == 0 {
+		return lifecycle.Install(target, opts)
+	}
+	plan, err := lifecycle.PlanInstall(registry, normalizeModuleArg(target))
+	if err != nil {
+		return err
+	}
+	if len(plan) > 1 {
+		cmd.Printf("%s\n", i18n.T("Install plan:"))
+		for i, planned := range plan {
+			if planned.Installed {
+				cmd.Printf("  %d. %s %s %s\n", i+1, planned.Name, planned.Version, i18n.T("(installed at %s, skipping)", planned.InstalledVersion))
+				continue
+			}
+			cmd.Printf("  %d. %s %s\n", i+1, planned.Name, planned.Version)
+		}
+	}
+	return lifecycle.InstallPlan(plan, opts)
+}
+
+func removeModules(cmd *cobra.Command, order []string, message string, remove func(string) error) error {
+	for _, name := range order {
+		cmd.Printf("%s\n", i18n.T(message, name))
+		if err := remove(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func normalizeModuleArg(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,472 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/api"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/config"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/runtime"
+)
+
+const serviceRequestTimeout = 2 * time.Minute
+
+func RegisterServiceCommands(rootCmd *cobra.Command) {
+	serviceCmd := &cobra.Command{
+		Use:   "service",
+		Short: "Manage services",
+	}
+	serviceCmd.PersistentFlags().String("socket", "", "Runtime supervisor socket (default is runtime.socket_path)")
+
+	startCmd := &cobra.Command{
+		Use:   "start [service-name] [-- command args...]",
+		Short: "Start a service",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			spec, err := buildServiceSpec(cmd, args[0], args[1:])
+			if err != nil {
+				return err
+			}
+			client, err := newSupervisorClient(cmd)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), serviceRequestTimeout)
+			defer cancel()
+
+			cmd.Printf("%s\n", i18n.T("Starting service: %s", spec.Name))
+			var status runtime.Status
+			if spec.Image == "" && len(spec.Command) == 0 {
+				status, err = client.Restart(ctx, spec.Name)
+				if errors.Is(err, api.ErrServiceNotFound) {
+					return fmt.Errorf("service %s has not been started before; pass --image or a command after --", spec.Name)
+				}
+			} else {
+				status, err = client.Start(ctx, spec)
+			}
+			if err != nil {
+				return err
+			}
+			recordModuleServiceState(cmd, spec.Name, true)
+			printServiceStatus(cmd, status)
+			return nil
+		},
+	}
+	startCmd.Flags().String("mode", "", "Execution mode: native|container (default is runtime.default_mode)")
+	startCmd.Flags().String("image", "", "Container image (container mode)")
+	startCmd.Flags().StringArray("env", nil, "Environment variable KEY=VALUE (repeatable)")
+	startCmd.Flags().String("workdir", "", "Working directory")
+	startCmd.Flags().String("container-name", "", "Container name (container mode)")
+	startCmd.Flags().String("container-runtime", "", "Container runtime binary: docker|podman")
+	startCmd.Flags().String("health-cmd", "", "Health check command, run by sh -c (inside the container in container mode)")
+	startCmd.Flags().String("health-http", "", "HTTP health check URL, e.g. http://127.0.0.1:8080/health")
+	startCmd.Flags().Int("health-http-status", 0, "Expected HTTP status for --health-http (default any 2xx)")
+	startCmd.Flags().String("health-http-body", "", "Text the --health-http response body must contain")
+	startCmd.Flags().String("health-tcp", "", "TCP health check address host:port")
+	startCmd.Flags().Duration("health-interval", 0, "Health check interval (default 30s)")
+	startCmd.Flags().Duration("health-timeout", 0, "Health check timeout (default 5s)")
+	startCmd.Flags().Duration("health-start-period", 0, "Ignore health check failures for this long after start")
+	startCmd.Flags().Int("health-retries", 0, "Consecutive failures before the service is unhealthy (default 3)")
+	startCmd.Flags().String("restart", "", "Restart policy: never|on-failure|always (default never)")
+	startCmd.Flags().Int("max-retries", 0, "Consecutive fast failures before giving up (default 5)")
+	startCmd.Flags().Duration("stop-grace", 0, "Time between SIGTERM and SIGKILL when stopping (default 10s)")
+	startCmd.Flags().Float64("cpus", 0, "CPU quota in cores, e.g. 2.5")
+	startCmd.Flags().String("memory", "", "Memory limit, e.g. 16G or 512M")
+	startCmd.Flags().Int64("pids-limit", 0, "Maximum number of processes")
+	startCmd.Flags().IntSlice("gpus", nil, "GPU indices the service may use, e.g. 0,1")
+	startCmd.Flags().Bool("all-gpus", false, "Give a container access to every GPU")
+	startCmd.Flags().String("gpu-vendor", "", "GPU vendor for container GPU access: nvidia|amd (default nvidia)")
+	startCmd.Flags().StringArrayP("publish", "p", nil, "Publish a container port [ip:][host-port:]container-port[/udp] (repeatable)")
+	startCmd.Flags().StringArrayP("volume", "v", nil, "Bind mount host-path:container-path[:rw], read-only by default (repeatable)")
+	startCmd.Flags().StringArray("label", nil, "Container label KEY=VALUE (repeatable)")
+	startCmd.Flags().String("user", "", "User to run the container as")
+	startCmd.Flags().String("pull", "", "Image pull policy: always|missing|never")
+
+	stopCmd := &cobra.Command{
+		Use:   "stop [service-name]",
+		Short: "Stop a service",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newSupervisorClient(cmd)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), serviceRequestTimeout)
+			defer cancel()
+
+			cmd.Printf("%s\n", i18n.T("Stopping service: %s", args[0]))
+			status, err := client.Stop(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			recordModuleServiceState(cmd, args[0], false)
+			printServiceStatus(cmd, status)
+			return nil
+		},
+	}
+
+	statusCmd := &cobra.Command{
+		Use:   "status [service-name]",
+		Short: "Get service status",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, _ := cmd.Flags().GetString("output")
+			client, err := newSupervisorClient(cmd)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), serviceRequestTimeout)
+			defer cancel()
+
+			var statuses []runtime.Status
+			if len(args) == 1 {
+				status, err := client.Status(ctx, args[0])
+				if err != nil {
+					return err
+				}
+				statuses = []runtime.Status{status}
+			} else {
+				statuses, err = client.List(ctx)
+				if err != nil {
+					return err
+				}
+			}
+
+			if strings.EqualFold(strings.TrimSpace(output), "json") {
+				payload, err := json.MarshalIndent(statuses, "", "  ")
+				if err != nil {
+					return err
+				}
+				cmd.Printf("%s\n", payload)
+				return nil
+			}
+			if len(args) == 1 {
+				printServiceStatus(cmd, statuses[0])
+				return nil
+			}
+			if len(statuses) == 0 {
+				cmd.Println(i18n.T("No managed services."))
+				return nil
+			}
+			writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(writer, "NAME\tMODE\tSTATE\tHEALTH\tRESTARTS\tPID/CONTAINER\tSTARTED\tLOG")
+			for _, status := range statuses {
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+					status.Name,
+					status.Mode,
+					status.State,
+					status.Health,
+					status.RestartCount,
+					serviceHandle(status),
+					status.StartedAt.Local().Format(time.DateTime),
+					status.LogPath,
+				)
+			}
+			return writer.Flush()
+		},
+	}
+	statusCmd.Flags().String("output", "text", "Output format: text|json")
+
+	serviceCmd.AddCommand(startCmd)
+	serviceCmd.AddCommand(stopCmd)
+	serviceCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(serviceCmd)
+}
+
+// recordModuleServiceState marks a module that runs as a service as running
+// or stopped. Services that are not installed modules are left alone.
+func recordModuleServiceState(cmd *cobra.Command, name string, running bool) {
+	lifecycle, err := openModuleLifecycle()
+	if err != nil {
+		return
+	}
+	if running {
+		err = lifecycle.MarkRunning(name)
+	} else {
+		err = lifecycle.MarkStopped(name)
+	}
+	if err != nil {
+		cmd.Printf("%s\n", i18n.T("Failed to record state of module %s: %v", name, err))
+	}
+}
+
+func newSupervisorClient(cmd *cobra.Command) (*api.SupervisorClient, error) {
+	socket, _ := cmd.Flags().GetString("socket")
+	if strings.TrimSpace(socket) == "" {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return nil, err
+		}
+		socket = api.SupervisorSocketPath(cfg.Runtime)
+	}
+	return api.NewSupervisorClient(socket), nil
+}
+
+// healthCommand runs a --health-cmd through the shell, so quoting, pipes
+// and redirections behave as typed.
+func healthCommand(raw string) []string {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	return []string{"sh", "-c", raw}
+}
+
+func buildServiceSpec(cmd *cobra.Command, name string, command []string) (runtime.ModuleSpec, error) {
+	mode, _ := cmd.Flags().GetString("mode")
+	image, _ := cmd.Flags().GetString("image")
+	envPairs, _ := cmd.Flags().GetStringArray("env")
+	workDir, _ := cmd.Flags().GetString("workdir")
+	containerName, _ := cmd.Flags().GetString("container-name")
+	containerRuntime, _ := cmd.Flags().GetString("container-runtime")
+	healthCmd, _ := cmd.Flags().GetString("health-cmd")
+	healthInterval, _ := cmd.Flags().GetDuration("health-interval")
+	healthTimeout, _ := cmd.Flags().GetDuration("health-timeout")
+	healthHTTP, _ := cmd.Flags().GetString("health-http")
+	healthHTTPStatus, _ := cmd.Flags().GetInt("health-http-status")
+	healthHTTPBody, _ := cmd.Flags().GetString("health-http-body")
+	healthTCP, _ := cmd.Flags().GetString("health-tcp")
+	healthStartPeriod, _ := cmd.Flags().GetDuration("health-start-period")
+	healthRetries, _ := cmd.Flags().GetInt("health-retries")
+	restartPolicy, _ := cmd.Flags().GetString("restart")
+	maxRetries, _ := cmd.Flags().GetInt("max-retries")
+	stopGrace, _ := cmd.Flags().GetDuration("stop-grace")
+	cpus, _ := cmd.Flags().GetFloat64("cpus")
+	memory, _ := cmd.Flags().GetString("memory")
+	pidsLimit, _ := cmd.Flags().GetInt64("pids-limit")
+	gpus, _ := cmd.Flags().GetIntSlice("gpus")
+	allGPUs, _ := cmd.Flags().GetBool("all-gpus")
+	gpuVendor, _ := cmd.Flags().GetString("gpu-vendor")
+	publish, _ := cmd.Flags().GetStringArray("publish")
+	volumes, _ := cmd.Flags().GetStringArray("volume")
+	labels, _ := cmd.Flags().GetStringArray("label")
+	user, _ := cmd.Flags().GetString("user")
+	pull, _ := cmd.Flags().GetString("pull")
+
+	spec := runtime.ModuleSpec{
+		Name:             strings.TrimSpace(name),
+		Mode:             runtime.ExecutionMode(strings.TrimSpace(mode)),
+		Image:            strings.TrimSpace(image),
+		Command:          command,
+		WorkDir:          workDir,
+		ContainerName:    containerName,
+		ContainerRuntime: containerRuntime,
+		HealthCheck: runtime.HealthCheck{
+			Command:          healthCommand(healthCmd),
+			Interval:         healthInterval,
+			Timeout:          healthTimeout,
+			StartPeriod:      healthStartPeriod,
+			FailureThreshold: healthRetries,
+		},
+		Restart: runtime.RestartSpec{
+			Policy:     runtime.RestartPolicy(strings.TrimSpace(restartPolicy)),
+			MaxRetries: maxRetries,
+		},
+		StopGracePeriod: stopGrace,
+		Resources: runtime.Resources{
+			CPUs:      cpus,
+			PidsMax:   pidsLimit,
+			GPUs:      gpus,
+			AllGPUs:   allGPUs,
+			GPUVendor: strings.TrimSpace(gpuVendor),
+		},
+		User:       strings.TrimSpace(user),
+		PullPolicy: runtime.PullPolicy(strings.TrimSpace(pull)),
+	}
+	if spec.Name == "" {
+		return runtime.ModuleSpec{}, fmt.Errorf("service name is required")
+	}
+	if strings.TrimSpace(memory) != "" {
+		memoryMax, err := parseMemoryLimit(memory)
+		if err != nil {
+			return runtime.ModuleSpec{}, err
+		}
+		spec.Resources.MemoryMax = memoryMax
+	}
+	if url := strings.TrimSpace(healthHTTP); url != "" {
+		spec.HealthCheck.HTTP = &runtime.HTTPHealthCheck{URL: url, ExpectedStatus: healthHTTPStatus, BodyContains: healthHTTPBody}
+	}
+	if address := strings.TrimSpace(healthTCP); address != "" {
+		spec.HealthCheck.TCP = &runtime.TCPHealthCheck{Address: address}
+	}
+	switch spec.Restart.Policy {
+	case "", runtime.RestartNever, runtime.RestartOnFailure, runtime.RestartAlways:
+	default:
+		return runtime.ModuleSpec{}, fmt.Errorf("invalid --restart %q, expected never|on-failure|always", restartPolicy)
+	}
+	var err error
+	if spec.Env, err = parseKeyValues("env", envPairs); err != nil {
+		return runtime.ModuleSpec{}, err
+	}
+	if spec.Labels, err = parseKeyValues("label", labels); err != nil {
+		return runtime.ModuleSpec{}, err
+	}
+	for _, raw := range publish {
+		port, err := parsePortMapping(raw)
+		if err != nil {
+			return runtime.ModuleSpec{}, err
+		}
+		spec.Ports = append(spec.Ports, port)
+	}
+	for _, raw := range volumes {
+		mount, err := parseVolume(raw)
+		if err != nil {
+			return runtime.ModuleSpec{}, err
+		}
+		spec.Mounts = append(spec.Mounts, mount)
+	}
+	return spec, nil
+}
+
+func parseKeyValues(flag string, pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	values := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid --%s %q, expected KEY=VALUE", flag, pair)
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	return values, nil
+}
+
+// parsePortMapping parses the docker -p syntax
+// [ip:][host-port:]container-port[/protocol].
+func parsePortMapping(raw string) (runtime.PortMapping, error) {
+	invalid := fmt.Errorf("invalid --publish %q, expected [ip:][host-port:]container-port[/udp]", raw)
+	value := strings.TrimSpace(raw)
+	var mapping runtime.PortMapping
+	if spec, protocol, ok := strings.Cut(value, "/"); ok {
+		value, mapping.Protocol = spec, protocol
+	}
+	if strings.HasPrefix(value, "[") {
+		end := strings.Index(value, "]:")
+		if end < 0 {
+			return runtime.PortMapping{}, invalid
+		}
+		mapping.HostIP, value = value[1:end], value[end+2:]
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) == 3 && mapping.HostIP == "" {
+		mapping.HostIP, parts = parts[0], parts[1:]
+	}
+	if len(parts) > 2 {
+		return runtime.PortMapping{}, invalid
+	}
+	containerPort, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil {
+		return runtime.PortMapping{}, invalid
+	}
+	mapping.ContainerPort = containerPort
+	if len(parts) == 2 && parts[0] != "" {
+		if mapping.HostPort, err = strconv.Atoi(parts[0]); err != nil {
+			return runtime.PortMapping{}, invalid
+		}
+	}
+	return mapping, nil
+}
+
+// parseVolume parses host-path:container-path[:ro|rw].
+func parseVolume(raw string) (runtime.Mount, error) {
+	parts := strings.Split(strings.TrimSpace(raw), ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return runtime.Mount{}, fmt.Errorf("invalid --volume %q, expected host-path:container-path[:rw]", raw)
+	}
+	mount := runtime.Mount{Source: parts[0], Target: parts[1]}
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "rw":
+			mount.ReadWrite = true
+		case "ro":
+		default:
+			return runtime.Mount{}, fmt.Errorf("invalid --volume mode %q, expected ro or rw", parts[2])
+		}
+	}
+	if abs, err := filepath.Abs(mount.Source); err == nil {
+		mount.Source = abs
+	}
+	return mount, nil
+}
+
+// parseMemoryLimit accepts a byte count with an optional K, M, G or T suffix
+// (binary units, "B" and "i" are optional: 16G, 16GB and 16GiB are equal).
+func parseMemoryLimit(raw string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(raw))
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "B"), "I")
+	multiplier := float64(1)
+	if value != "" {
+		switch value[len(value)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			value = value[:len(value)-1]
+		}
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("invalid --memory %q, expected a size such as 16G", raw)
+	}
+	return int64(number * multiplier), nil
+}
+
+func printServiceStatus(cmd *cobra.Command, status runtime.Status) {
+	cmd.Printf("Name: %s\n", status.Name)
+	cmd.Printf("Mode: %s\n", status.Mode)
+	cmd.Printf("State: %s\n", status.State)
+	cmd.Printf("Health: %s\n", status.Health)
+	if handle := serviceHandle(status); handle != "" {
+		cmd.Printf("Process: %s\n", handle)
+	}
+	cmd.Printf("Started: %s\n", status.StartedAt.Local().Format(time.DateTime))
+	if status.FinishedAt != nil {
+		cmd.Printf("Finished: %s\n", status.FinishedAt.Local().Format(time.DateTime))
+	}
+	if status.RestartCount > 0 {
+		cmd.Printf("Restarts: %d\n", status.RestartCount)
+	}
+	if status.StopSignal != "" {
+		cmd.Printf("Stop signal: %s\n", status.StopSignal)
+	}
+	if status.LastExitCode != nil {
+		cmd.Printf("Last exit code: %d\n", *status.LastExitCode)
+	}
+	if status.Cgroup != "" {
+		cmd.Printf("Cgroup: %s\n", status.Cgroup)
+	}
+	cmd.Printf("Log: %s\n", status.LogPath)
+	if status.LastError != "" {
+		cmd.Printf("Last error: %s\n", status.LastError)
+	}
+}
+
+func serviceHandle(status runtime.Status) string {
+	if status.ContainerID != "" {
+		id := status.ContainerID
+		if len(id) > 12 {
+			id = id[:12]
+		}
+		return "container " + id
+	}
+	if status.PID > 0 {
+		return fmt.Sprintf("pid %d", status.PID)
+	}
+	return ""
+}
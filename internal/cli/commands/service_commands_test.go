@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/runtime"
+)
+
+func TestParseMemoryLimit(t *testing.T) {
+	cases := map[string]int64{
+		"1073741824": 1 << 30,
+		"16G":        16 << 30,
+		"16GB":       16 << 30,
+		"16GiB":      16 << 30,
+		"512m":       512 << 20,
+		"1.5T":       3 << 39,
+	}
+	for raw, want := range cases {
+		got, err := parseMemoryLimit(raw)
+		if err != nil || got != want {
+			t.Fatalf("parseMemoryLimit(%q) = %d, %v; want %d", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"", "G", "-1G", "lots"} {
+		if _, err := parseMemoryLimit(raw); err == nil {
+			t.Fatalf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestHealthCommandKeepsQuotedArguments(t *testing.T) {
+	if got := healthCommand("  "); got != nil {
+		t.Fatalf("expected no health command, got %v", got)
+	}
+	raw := `curl -fsS -H "Accept: application/json" http://127.0.0.1:8080/health`
+	got := healthCommand(raw)
+	if len(got) != 3 || got[0] != "sh" || got[1] != "-c" || got[2] != raw {
+		t.Fatalf("healthCommand(%q) = %q", raw, got)
+	}
+}
+
+func TestParsePortMappingAndVolume(t *testing.T) {
+	cases := map[string]runtime.PortMapping{
+		"8080":                {ContainerPort: 8080},
+		"18080:8080":          {HostPort: 18080, ContainerPort: 8080},
+		"127.0.0.1:8080:8080": {HostIP: "127.0.0.1", HostPort: 8080, ContainerPort: 8080},
+		"127.0.0.1::8080":     {HostIP: "127.0.0.1", ContainerPort: 8080},
+		"[::1]:53:53/udp":     {HostIP: "::1", HostPort: 53, ContainerPort: 53, Protocol: "udp"},
+	}
+	for raw, want := range cases {
+		got, err := parsePortMapping(raw)
+		if err != nil || got != want {
+			t.Fatalf("parsePortMapping(%q) = %+v, %v; want %+v", raw, got, err, want)
+		}
+	}
+	if _, err := parsePortMapping("a:b:c:d"); err == nil {
+		t.Fatalf("expected invalid port mapping to be rejected")
+	}
+
+	mount, err := parseVolume("/srv/models:/models")
+	if err != nil || mount.ReadWrite || mount.Source != "/srv/models" || mount.Target != "/models" {
+		t.Fatalf("unexpected default mount: %+v (%v)", mount, err)
+	}
+	if mount, err := parseVolume("/srv/cache:/cache:rw"); err != nil || !mount.ReadWrite {
+		t.Fatalf("expected rw mount, got %+v (%v)", mount, err)
+	}
+	if _, err := parseVolume("/srv/cache:/cache:z"); err == nil {
+		t.Fatalf("expected unknown mount mode to be rejected")
+	}
+}
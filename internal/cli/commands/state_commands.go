@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"context"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/api"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/control"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+)
+
+func RegisterStateCommands(rootCmd *cobra.Command) {
+	stateCmd := &cobra.Command{
+		Use:   "state",
+		Short: "Manage recorded module state",
+	}
+
+	rollbackCmd := &cobra.Command{
+		Use:   "rollback [snapshot-id]",
+		Short: "Restore the modules of a state snapshot (default: the latest)",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			assumeYes, _ := cmd.Flags().GetBool("yes")
+
+			state, err := openStateManager()
+			if err != nil {
+				return err
+			}
+			snapshotID := ""
+			if len(args) == 1 {
+				snapshotID = strings.TrimSpace(args[0])
+			}
+			snapshot, err := state.Snapshot(snapshotID)
+			if err != nil {
+				return err
+			}
+			plan, err := control.PlanRollback(state.GetState().Modules, snapshot, loadModuleRegistry())
+			if err != nil {
+				return err
+			}
+
+			printRollbackPlan(cmd, plan)
+			if dryRun || len(plan.Steps) == 0 {
+				return nil
+			}
+			if !assumeYes && !confirmPrompt(cmd, i18n.T("Apply this plan? [y/N]: ")) {
+				return i18n.Errorf("rollback cancelled")
+			}
+
+			var services control.ServiceController
+			if client, err := newSupervisorClient(cmd); err == nil {
+				services = supervisorServices{client: client}
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), serviceRequestTimeout)
+			defer cancel()
+			if err := control.NewLifecycle(state).ExecuteRollback(ctx, plan, services); err != nil {
+				return err
+			}
+			cmd.Printf("%s\n", i18n.T("Restored snapshot %s.", plan.Snapshot.ID))
+			return nil
+		},
+	}
+	rollbackCmd.Flags().Bool("dry-run", false, "Show the rollback plan without executing it")
+	rollbackCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation")
+	rollbackCmd.Flags().String("socket", "", "Runtime supervisor socket (default is runtime.socket_path)")
+
+	stateCmd.AddCommand(rollbackCmd)
+	rootCmd.AddCommand(stateCmd)
+}
+
+func printRollbackPlan(cmd *cobra.Command, plan control.RollbackPlan) {
+	cmd.Printf("%s\n", i18n.T("Rollback to snapshot %s (%s, %s):", plan.Snapshot.ID, plan.Snapshot.Reason, plan.Snapshot.CreatedAt.Local().Format("2006-01-02 15:04:05")))
+	if len(plan.Steps) == 0 {
+		cmd.Printf("%s\n", i18n.T("Nothing to do; modules already match the snapshot."))
+		return
+	}
+	for i, step := range plan.Steps {
+		target := step.Module
+		if step.Version != "" {
+			target += " " + step.Version
+		}
+		cmd.Printf("  %d. %-9s %s (%s)\n", i+1, step.Action, target, step.Reason)
+	}
+}
+
+type supervisorServices struct {
+	client *api.SupervisorClient
+}
+
+func (s supervisorServices) Start(ctx context.Context, name string) error {
+	_, err := s.client.Restart(ctx, name)
+	return err
+}
+
+func (s supervisorServices) Stop(ctx context.Context, name string) error {
+	_, err := s.client.Stop(ctx, name)
+	return err
+}
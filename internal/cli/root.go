@@ -45,6 +45,7 @@ func init() {
 	commands.RegisterServiceCommands(rootCmd)
 	commands.RegisterModelCommands(rootCmd)
 	commands.RegisterProviderCommands(rootCmd)
+	commands.RegisterStateCommands(rootCmd)
 	commands.RegisterFailureCommands(rootCmd)
 	commands.RegisterSystemCommands(rootCmd)
 	commands.RegisterInitCommand(rootCmd)
@@ -92,16 +93,16 @@ func localizeCommand(cmd *cobra.Command) {
 		return
 	}
 	if cmd.Short != "" {
-		cmd.Short = i18n.T(cmd.Short)
+		cmd.Short = i18n.Text(cmd.Short)
 	}
 	if cmd.Long != "" {
-		cmd.Long = i18n.T(cmd.Long)
+		cmd.Long = i18n.Text(cmd.Long)
 	}
 	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
-		flag.Usage = i18n.T(flag.Usage)
+		flag.Usage = i18n.Text(flag.Usage)
 	})
 	cmd.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
-		flag.Usage = i18n.T(flag.Usage)
+		flag.Usage = i18n.Text(flag.Usage)
 	})
 	for _, sub := range cmd.Commands() {
 		localizeCommand(sub)
@@ -25,6 +25,8 @@ type Config struct {
 	Control ControlConfig `mapstructure:"control"`
 	Storage StorageConfig `mapstructure:"storage"`
 	Runtime RuntimeConfig `mapstructure:"runtime"`
+	Modules ModulesConfig `mapstructure:"modules"`
+	Gateway GatewayConfig `mapstructure:"gateway"`
 	LLM     LLMConfig     `mapstructure:"llm"`
 	I18n    I18nConfig    `mapstructure:"i18n"`
 }
@@ -61,6 +63,30 @@ type RuntimeConfig struct {
 	NativeEnabled bool   `mapstructure:"native_enabled"`
 	DefaultMode   string `mapstructure:"default_mode"`
 	LogDir        string `mapstructure:"log_dir"`
+	SocketPath    string `mapstructure:"socket_path"`
+}
+
+// ModulesConfig controls how module signatures are checked. SignaturePolicy
+// is off, warn or enforce; TrustedKeys holds base64 ed25519 or minisign
+// public keys.
+type ModulesConfig struct {
+	SignaturePolicy string   `mapstructure:"signature_policy"`
+	TrustedKeys     []string `mapstructure:"trusted_keys"`
+}
+
+// GatewayConfig controls the OpenAI-compatible endpoints of las-server.
+// Models not served by a local instance are looked up in the Ollama daemon
+// at OllamaURL; an empty URL disables that.
+//
+// On-demand instances are started by the first request for their model and
+// stopped after IdleTTLSeconds without requests (0 keeps them loaded), or
+// earlier when a load needs their share of VRAMBudgetMB. A zero budget uses
+// the VRAM detected on the machine.
+type GatewayConfig struct {
+	OllamaURL          string `mapstructure:"ollama_url"`
+	IdleTTLSeconds     int    `mapstructure:"idle_ttl_seconds"`
+	VRAMBudgetMB       int    `mapstructure:"vram_budget_mb"`
+	LoadTimeoutSeconds int    `mapstructure:"load_timeout_seconds"`
 }
 
 type LLMConfig struct {
@@ -120,6 +146,14 @@ func DefaultConfig() *Config {
 			DefaultMode:   "container",
 			LogDir:        "/var/lib/localaistack/runtime",
 		},
+		Modules: ModulesConfig{
+			SignaturePolicy: "warn",
+		},
+		Gateway: GatewayConfig{
+			OllamaURL:          "http://127.0.0.1:11434",
+			IdleTTLSeconds:     600,
+			LoadTimeoutSeconds: 900,
+		},
 		LLM: LLMConfig{
 			Provider:       "siliconflow",
 			Model:          "deepseek-ai/DeepSeek-V3.2",
@@ -248,6 +282,15 @@ func applyDefaults(v *viper.Viper, defaults *Config) {
 	v.SetDefault("runtime.native_enabled", defaults.Runtime.NativeEnabled)
 	v.SetDefault("runtime.default_mode", defaults.Runtime.DefaultMode)
 	v.SetDefault("runtime.log_dir", defaults.Runtime.LogDir)
+	v.SetDefault("runtime.socket_path", defaults.Runtime.SocketPath)
+
+	v.SetDefault("modules.signature_policy", defaults.Modules.SignaturePolicy)
+	v.SetDefault("modules.trusted_keys", defaults.Modules.TrustedKeys)
+
+	v.SetDefault("gateway.ollama_url", defaults.Gateway.OllamaURL)
+	v.SetDefault("gateway.idle_ttl_seconds", defaults.Gateway.IdleTTLSeconds)
+	v.SetDefault("gateway.vram_budget_mb", defaults.Gateway.VRAMBudgetMB)
+	v.SetDefault("gateway.load_timeout_seconds", defaults.Gateway.LoadTimeoutSeconds)
 
 	v.SetDefault("llm.provider", defaults.LLM.Provider)
 	v.SetDefault("llm.model", defaults.LLM.Model)
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
 
@@ -84,7 +83,7 @@ func BuildStaticPlan(moduleName, modelID string, info system.BaseInfoSummary) (P
 		case info.MemoryKB >= 32*1024*1024:
 			ctxSize = 4096
 		}
-		nGPULayers := estimateLlamaGPULayers(parseVRAMFromGPUName(info.GPUName))
+		nGPULayers := estimateLlamaGPULayers(info.VRAMGB())
 
 		plan.Changes = append(plan.Changes,
 			Change{Scope: "model.run.llama.cpp", Key: "threads", Value: threads, Reason: "match available CPU cores"},
@@ -92,7 +91,7 @@ func BuildStaticPlan(moduleName, modelID string, info system.BaseInfoSummary) (P
 			Change{Scope: "model.run.llama.cpp", Key: "n_gpu_layers", Value: nGPULayers, Reason: "fit detected GPU memory"},
 		)
 	case "vllm":
-		vram := parseVRAMFromGPUName(info.GPUName)
+		vram := info.VRAMGB()
 		maxModelLen := 2048
 		switch {
 		case vram >= 80:
@@ -167,36 +166,7 @@ func ApplyPlan(plan Plan) (string, error) {
 }
 
 func ResolveBaseInfoPath() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return filepath.Join(".", "base_info.json")
-	}
-	primary := filepath.Join(home, ".localaistack", "base_info.json")
-	if _, err := os.Stat(primary); err == nil {
-		return primary
-	}
-	alternate := filepath.Join(home, ".localiastack", "base_info.json")
-	if _, err := os.Stat(alternate); err == nil {
-		return alternate
-	}
-	return primary
-}
-
-func parseVRAMFromGPUName(name string) int {
-	if strings.TrimSpace(name) == "" {
-		return 0
-	}
-	re := regexp.MustCompile(`(?i)(\d+)\s*gb`)
-	match := re.FindStringSubmatch(name)
-	if len(match) < 2 {
-		return 0
-	}
-	value := strings.TrimSpace(match[1])
-	n := 0
-	for i := 0; i < len(value); i++ {
-		n = n*10 + int(value[i]-'0')
-	}
-	return n
+	return system.ResolveBaseInfoPath()
 }
 
 func estimateLlamaGPULayers(vram int) int {
@@ -10,7 +10,7 @@ import (
 )
 
 func TestBuildStaticPlanLlama(t *testing.T) {
-	info := system.BaseInfoSummary{CPUCores: 12, MemoryKB: 64 * 1024 * 1024, GPUName: "NVIDIA RTX 4090 24GB"}
+	info := system.BaseInfoSummary{CPUCores: 12, MemoryKB: 64 * 1024 * 1024, GPUName: "NVIDIA RTX 4090 24GB", GPUVRAMMB: 24 * 1024}
 	plan, err := BuildStaticPlan("llama.cpp", "demo", info)
 	if err != nil {
 		t.Fatalf("BuildStaticPlan returned error: %v", err)
@@ -23,6 +23,19 @@ func TestBuildStaticPlanLlama(t *testing.T) {
 	}
 }
 
+func TestBuildStaticPlanUsesProfileVRAM(t *testing.T) {
+	info := system.BaseInfoSummary{CPUCores: 8, MemoryKB: 32 * 1024 * 1024, GPUName: "NVIDIA GeForce RTX 4090", GPUCount: 1, GPUVRAMMB: 24564}
+	plan, err := BuildStaticPlan("llama.cpp", "demo", info)
+	if err != nil {
+		t.Fatalf("BuildStaticPlan returned error: %v", err)
+	}
+	for _, change := range plan.Changes {
+		if change.Key == "n_gpu_layers" && change.Value != 40 {
+			t.Fatalf("expected 40 GPU layers for 24GB, got %v", change.Value)
+		}
+	}
+}
+
 func TestBuildStaticPlanUnknownModule(t *testing.T) {
 	_, err := BuildStaticPlan("unknown-module", "", system.BaseInfoSummary{})
 	if err == nil {
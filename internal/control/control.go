@@ -159,21 +159,30 @@ func stateCandidateDirs(primary string) []string {
 
 func (c *ControlLayer) initStateManager(ctx context.Context) error {
 	log.Info().Msg(i18n.T("Initializing state manager"))
-	paths := stateCandidateDirs(c.cfg.Control.DataDir)
+	manager, err := OpenStateManager(c.cfg.Control.DataDir)
+	if err != nil {
+		return err
+	}
+	c.stateManager = manager
+	log.Info().Str("path", filepath.Dir(manager.dataPath)).Msg(i18n.T("State directory ready"))
+	return nil
+}
+
+// OpenStateManager opens the state in the first usable directory, starting
+// with dataDir and falling back to per-user locations.
+func OpenStateManager(dataDir string) (*StateManager, error) {
 	var lastErr error
-	for _, path := range paths {
+	for _, path := range stateCandidateDirs(dataDir) {
 		manager, err := NewStateManager(path)
 		if err == nil {
-			c.stateManager = manager
-			log.Info().Str("path", path).Msg(i18n.T("State directory ready"))
-			return nil
+			return manager, nil
 		}
 		lastErr = err
 	}
 	if lastErr != nil {
-		return lastErr
+		return nil, lastErr
 	}
-	return i18n.Errorf("state directory not available")
+	return nil, i18n.Errorf("state directory not available")
 }
 
 func (c *ControlLayer) detectHardware(ctx context.Context) error {
@@ -0,0 +1,237 @@
+package control
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/module"
+)
+
+// PlannedInstall is one module of a resolved install plan.
+type PlannedInstall struct {
+	Name    string
+	Version string
+	// Dir is the module directory of the planned version, empty when the
+	// record was not loaded from disk.
+	Dir string
+	// Installed is set for dependencies that are already installed at a
+	// version satisfying every constraint on them; they are skipped.
+	Installed        bool
+	InstalledVersion string
+}
+
+// PlanInstall resolves target ("name" or "name@constraint") and its module
+// dependencies in install order. The target itself is always reinstalled.
+func (l *Lifecycle) PlanInstall(registry *module.Registry, target string) ([]PlannedInstall, error) {
+	resolved, err := module.NewResolver(registry).ResolveInstallPlan([]string{target})
+	if err != nil {
+		return nil, err
+	}
+	targetName, targetConstraint, err := module.ParseModuleDependency(target)
+	if err != nil {
+		return nil, err
+	}
+
+	constraints := make(map[string][]module.VersionConstraint)
+	if targetConstraint != nil {
+		constraints[targetName] = append(constraints[targetName], *targetConstraint)
+	}
+	for _, name := range resolved.Order {
+		for _, dep := range resolved.Modules[name].Manifest.Dependencies.Modules {
+			depName, constraint, err := module.ParseModuleDependency(dep)
+			if err != nil {
+				return nil, err
+			}
+			if constraint != nil {
+				constraints[depName] = append(constraints[depName], *constraint)
+			}
+		}
+	}
+
+	plan := make([]PlannedInstall, 0, len(resolved.Order))
+	for _, name := range resolved.Order {
+		record := resolved.Modules[name]
+		planned := PlannedInstall{Name: name, Version: record.Manifest.Version}
+		if record.SourcePath != "" {
+			planned.Dir = filepath.Dir(record.SourcePath)
+		}
+		if name != targetName {
+			if current, ok := l.state.GetModule(name); ok && isInstalledState(current.State) && satisfiesAll(current.Version, constraints[name]) {
+				planned.Installed = true
+				planned.InstalledVersion = current.Version
+			}
+		}
+		plan = append(plan, planned)
+	}
+	return plan, nil
+}
+
+func satisfiesAll(version string, constraints []module.VersionConstraint) bool {
+	if len(constraints) == 0 {
+		return true
+	}
+	parsed, err := module.ParseVersion(version)
+	if err != nil {
+		return false
+	}
+	for _, constraint := range constraints {
+		if !constraint.Match(parsed) {
+			return false
+		}
+	}
+	return true
+}
+
+// InstallPlan installs the modules of a plan that are not installed yet. The
+// options apply to the last module, the one that was asked for; dependencies
+// only inherit NoRollback. Each module is installed from the directory of
+// its planned version.
+func (l *Lifecycle) InstallPlan(plan []PlannedInstall, opts module.InstallOptions) error {
+	for i, planned := range plan {
+		if planned.Installed {
+			continue
+		}
+		stepOpts := module.InstallOptions{NoRollback: opts.NoRollback}
+		if i == len(plan)-1 {
+			stepOpts = opts
+		}
+		err := l.checkPlannedVersion(planned)
+		if err == nil {
+			stepOpts.ModuleDir = planned.Dir
+			err = l.Install(planned.Name, stepOpts)
+		}
+		if err != nil {
+			if i < len(plan)-1 {
+				return i18n.Errorf("install dependency %s: %w", planned.Name, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPlannedVersion refuses to install a module whose directory no longer
+// holds the version that was planned.
+func (l *Lifecycle) checkPlannedVersion(planned PlannedInstall) error {
+	if planned.Dir == "" {
+		return nil
+	}
+	manifest, err := l.manifestAt(planned.Dir)
+	if err != nil {
+		return err
+	}
+	if manifest.Version != planned.Version {
+		return i18n.Errorf("module %s in %s is version %s, but version %s was planned", planned.Name, planned.Dir, manifest.Version, planned.Version)
+	}
+	return nil
+}
+
+// Dependents returns the installed modules that depend on name, directly or
+// through other installed modules, ordered so that each module comes before
+// the modules it depends on.
+func (l *Lifecycle) Dependents(registry *module.Registry, name string) []string {
+	name = normalizeModuleName(name)
+	dependsOn := make(map[string][]string)
+	for moduleName, current := range l.state.GetState().Modules {
+		if isInstalledState(current.State) {
+			dependsOn[moduleName] = moduleDependencies(registry, moduleName, current.Version)
+		}
+	}
+
+	// Collect every module that transitively depends on name.
+	affected := make(map[string]bool)
+	queue := []string{name}
+	for len(queue) > 0 {
+		target := queue[0]
+		queue = queue[1:]
+		for moduleName, deps := range dependsOn {
+			if affected[moduleName] || moduleName == name {
+				continue
+			}
+			for _, dep := range deps {
+				if dep == target {
+					affected[moduleName] = true
+					queue = append(queue, moduleName)
+					break
+				}
+			}
+		}
+	}
+
+	return dependentsFirst(affected, dependsOn)
+}
+
+// moduleDependencies returns the module dependencies of name at version,
+// falling back to the newest registered version.
+func moduleDependencies(registry *module.Registry, name, version string) []string {
+	records := registry.Get(name)
+	if len(records) == 0 {
+		return nil
+	}
+	record := records[0]
+	for _, candidate := range records {
+		if candidate.Manifest.Version == version {
+			record = candidate
+			break
+		}
+	}
+	var deps []string
+	for _, dep := range record.Manifest.Dependencies.Modules {
+		if depName, _, err := module.ParseModuleDependency(dep); err == nil {
+			deps = append(deps, depName)
+		}
+	}
+	return deps
+}
+
+// dependentsFirst orders modules so that each comes before the modules it
+// depends on, by name where the order is free. It consumes modules.
+func dependentsFirst(modules map[string]bool, dependsOn map[string][]string) []string {
+	// Repeatedly take the modules no remaining module depends on.
+	var ordered []string
+	for len(modules) > 0 {
+		var ready []string
+		for candidate := range modules {
+			needed := false
+			for other := range modules {
+				for _, dep := range dependsOn[other] {
+					if dep == candidate && other != candidate {
+						needed = true
+					}
+				}
+			}
+			if !needed {
+				ready = append(ready, candidate)
+			}
+		}
+		if len(ready) == 0 {
+			// A dependency cycle; remove the rest in name order.
+			for candidate := range modules {
+				ready = append(ready, candidate)
+			}
+		}
+		sort.Strings(ready)
+		for _, candidate := range ready {
+			delete(modules, candidate)
+		}
+		ordered = append(ordered, ready...)
+	}
+	return ordered
+}
+
+// RemovalOrder returns the modules to remove for name: name alone, or with
+// cascade its installed dependents first. Without cascade a module that
+// installed modules still depend on is refused.
+func (l *Lifecycle) RemovalOrder(registry *module.Registry, name string, cascade bool) ([]string, error) {
+	name = normalizeModuleName(name)
+	var dependents []string
+	if registry != nil {
+		dependents = l.Dependents(registry, name)
+	}
+	if len(dependents) > 0 && !cascade {
+		return nil, i18n.Errorf("module %s is required by installed modules: %s (use --cascade to remove them too)", name, strings.Join(dependents, ", "))
+	}
+	return append(dependents, name), nil
+}
@@ -0,0 +1,150 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/module"
+)
+
+func TestPlanInstallSkipsSatisfiedDependencies(t *testing.T) {
+	registry := module.NewRegistry()
+	addTestRecord(t, registry, "python", "3.11.0")
+	addTestRecord(t, registry, "cuda", "12.4.0")
+	addTestRecord(t, registry, "vllm", "0.6.0", "python@>=3.10.0", "cuda")
+
+	lifecycle, state := newTestLifecycle(t)
+	if err := state.UpdateModule("python", "3.11.0", module.StateInstalled); err != nil {
+		t.Fatalf("UpdateModule returned error: %v", err)
+	}
+	if err := state.UpdateModule("vllm", "0.6.0", module.StateInstalled); err != nil {
+		t.Fatalf("UpdateModule returned error: %v", err)
+	}
+
+	plan, err := lifecycle.PlanInstall(registry, "vllm")
+	if err != nil {
+		t.Fatalf("PlanInstall returned error: %v", err)
+	}
+	if len(plan) != 3 || plan[2].Name != "vllm" || plan[2].Installed {
+		t.Fatalf("expected vllm last and reinstalled, got %+v", plan)
+	}
+	skipped := make(map[string]bool)
+	for _, planned := range plan[:2] {
+		skipped[planned.Name] = planned.Installed
+	}
+	if !skipped["python"] || skipped["cuda"] {
+		t.Fatalf("expected only python to be skipped, got %+v", plan)
+	}
+
+	var installed []string
+	lifecycle.install = func(name string, _ module.InstallOptions) error {
+		installed = append(installed, name)
+		return nil
+	}
+	if err := lifecycle.InstallPlan(plan, module.InstallOptions{}); err != nil {
+		t.Fatalf("InstallPlan returned error: %v", err)
+	}
+	if fmt.Sprint(installed) != "[cuda vllm]" {
+		t.Fatalf("unexpected installs: %v", installed)
+	}
+
+	lifecycle.install = func(name string, _ module.InstallOptions) error {
+		if name == "cuda" {
+			return errors.New("driver missing")
+		}
+		t.Fatalf("install continued after a failed dependency")
+		return nil
+	}
+	if err := lifecycle.InstallPlan(plan, module.InstallOptions{}); err == nil || !strings.Contains(err.Error(), "install dependency cuda") {
+		t.Fatalf("expected dependency failure, got %v", err)
+	}
+}
+
+func TestInstallPlanUsesPlannedModuleDir(t *testing.T) {
+	registry := module.NewRegistry()
+	for _, version := range []string{"0.5.0", "0.6.0"} {
+		parsed, err := module.ParseVersion(version)
+		if err != nil {
+			t.Fatalf("ParseVersion returned error: %v", err)
+		}
+		record := module.ModuleRecord{
+			Manifest:   module.Manifest{Name: "vllm", Version: version},
+			Version:    parsed,
+			SourcePath: filepath.Join("sources", version, "vllm", "manifest.yaml"),
+		}
+		if err := registry.Add(record); err != nil {
+			t.Fatalf("Add returned error: %v", err)
+		}
+	}
+
+	lifecycle, state := newTestLifecycle(t)
+	plan, err := lifecycle.PlanInstall(registry, "vllm@<0.6.0")
+	if err != nil {
+		t.Fatalf("PlanInstall returned error: %v", err)
+	}
+	wantDir := filepath.Join("sources", "0.5.0", "vllm")
+	if len(plan) != 1 || plan[0].Version != "0.5.0" || plan[0].Dir != wantDir {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+
+	dirVersion := "0.5.0"
+	lifecycle.manifestAt = func(string) (module.Manifest, error) {
+		return module.Manifest{Name: "vllm", Version: dirVersion}, nil
+	}
+	var gotDir string
+	lifecycle.install = func(_ string, opts module.InstallOptions) error {
+		gotDir = opts.ModuleDir
+		return nil
+	}
+	if err := lifecycle.InstallPlan(plan, module.InstallOptions{}); err != nil {
+		t.Fatalf("InstallPlan returned error: %v", err)
+	}
+	if gotDir != wantDir {
+		t.Fatalf("expected install from %s, got %q", wantDir, gotDir)
+	}
+	if current, ok := state.GetModule("vllm"); !ok || current.Version != "0.5.0" {
+		t.Fatalf("expected the planned version to be recorded, got %+v", current)
+	}
+
+	dirVersion = "0.6.0"
+	gotDir = ""
+	if err := lifecycle.InstallPlan(plan, module.InstallOptions{}); err == nil || !strings.Contains(err.Error(), "0.5.0 was planned") {
+		t.Fatalf("expected a version mismatch error, got %v", err)
+	}
+	if gotDir != "" {
+		t.Fatalf("install ran despite the version mismatch")
+	}
+}
+
+func TestRemovalOrderRequiresCascade(t *testing.T) {
+	registry := module.NewRegistry()
+	addTestRecord(t, registry, "python", "3.11.0")
+	addTestRecord(t, registry, "vllm", "0.6.0", "python")
+	addTestRecord(t, registry, "openwebui", "0.3.0", "vllm")
+	addTestRecord(t, registry, "comfyui", "0.1.0", "python")
+
+	lifecycle, state := newTestLifecycle(t)
+	for _, name := range []string{"python", "vllm", "openwebui"} {
+		if err := state.UpdateModule(name, "", module.StateInstalled); err != nil {
+			t.Fatalf("UpdateModule returned error: %v", err)
+		}
+	}
+
+	_, err := lifecycle.RemovalOrder(registry, "python", false)
+	if err == nil || !strings.Contains(err.Error(), "openwebui, vllm") {
+		t.Fatalf("expected python removal to be refused, got %v", err)
+	}
+	order, err := lifecycle.RemovalOrder(registry, "python", true)
+	if err != nil {
+		t.Fatalf("RemovalOrder returned error: %v", err)
+	}
+	if fmt.Sprint(order) != "[openwebui vllm python]" {
+		t.Fatalf("unexpected removal order: %v", order)
+	}
+	if order, err := lifecycle.RemovalOrder(registry, "openwebui", false); err != nil || len(order) != 1 {
+		t.Fatalf("expected a leaf module to be removable, got %v, %v", order, err)
+	}
+}
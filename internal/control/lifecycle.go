@@ -0,0 +1,160 @@
+package control
+
+import (
+	"strings"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/module"
+)
+
+// Lifecycle runs module operations through the module state machine and
+// records every transition in the state manager.
+type Lifecycle struct {
+	state *StateManager
+
+	install    func(name string, opts module.InstallOptions) error
+	update     func(name string) error
+	uninstall  func(name string) error
+	purge      func(name string) error
+	check      func(name string) error
+	manifest   func(name string) (module.Manifest, error)
+	manifestAt func(moduleDir string) (module.Manifest, error)
+}
+
+func NewLifecycle(state *StateManager) *Lifecycle {
+	return &Lifecycle{
+		state:      state,
+		install:    module.InstallWithOptions,
+		update:     module.Update,
+		uninstall:  module.Uninstall,
+		purge:      module.Purge,
+		check:      module.Check,
+		manifest:   module.LoadManifest,
+		manifestAt: module.LoadManifestFromDir,
+	}
+}
+
+// Install resolves and installs a module. Installed or stopped modules are
+// reinstalled; running modules must be stopped first. With opts.ModuleDir the
+// recorded version is the one in that directory.
+func (l *Lifecycle) Install(name string, opts module.InstallOptions) error {
+	manifest := l.manifest
+	if opts.ModuleDir != "" {
+		manifest = func(string) (module.Manifest, error) { return l.manifestAt(opts.ModuleDir) }
+	}
+	return l.run(name, "install", module.StateInstalled, manifest, func() error {
+		return l.install(name, opts)
+	})
+}
+
+func (l *Lifecycle) Update(name string) error {
+	return l.run(name, "update", module.StateInstalled, l.manifest, func() error {
+		return l.update(name)
+	})
+}
+
+func (l *Lifecycle) Uninstall(name string) error {
+	return l.remove(name, "uninstall", l.uninstall)
+}
+
+func (l *Lifecycle) Purge(name string) error {
+	return l.remove(name, "purge", l.purge)
+}
+
+// State returns the recorded state and version of a module. Untracked
+// modules are available.
+func (l *Lifecycle) State(name string) (module.State, string) {
+	current, ok := l.state.GetModule(normalizeModuleName(name))
+	if !ok {
+		return module.StateAvailable, ""
+	}
+	return current.State, current.Version
+}
+
+// MarkRunning and MarkStopped record service state changes of modules that
+// are tracked in the state manager; other services are ignored.
+func (l *Lifecycle) MarkRunning(name string) error {
+	return l.mark(name, module.StateRunning)
+}
+
+func (l *Lifecycle) MarkStopped(name string) error {
+	return l.mark(name, module.StateStopped)
+}
+
+// Probe re-runs the check script of a module and corrects its recorded state:
+// a healthy module becomes installed, a missing one available.
+func (l *Lifecycle) Probe(name string) (module.State, error) {
+	name = normalizeModuleName(name)
+	current, tracked := l.state.GetModule(name)
+	if err := l.check(name); err != nil {
+		if tracked && current.State != module.StateAvailable {
+			return module.StateAvailable, l.state.UpdateModule(name, current.Version, module.StateAvailable)
+		}
+		return module.StateAvailable, nil
+	}
+	switch current.State {
+	case module.StateInstalled, module.StateRunning, module.StateStopped:
+		return current.State, nil
+	}
+	version := current.Version
+	if manifest, err := l.manifest(name); err == nil {
+		version = manifest.Version
+	}
+	return module.StateInstalled, l.state.UpdateModule(name, version, module.StateInstalled)
+}
+
+func (l *Lifecycle) run(name, operation string, target module.State, load func(string) (module.Manifest, error), fn func() error) error {
+	name = normalizeModuleName(name)
+	if name == "" {
+		return i18n.Errorf("module name is required")
+	}
+	manifest, err := load(name)
+	if err != nil {
+		// Unknown modules are not tracked; the operation reports the error.
+		return fn()
+	}
+	reason := i18n.T("%s module %s", operation, name)
+	version := manifest.Version
+	if err := l.state.TransitionModule(name, version, module.StateResolved, reason); err != nil {
+		return i18n.Errorf("cannot %s module: %w", operation, err)
+	}
+	if err := fn(); err != nil {
+		_ = l.state.TransitionModule(name, "", module.StateFailed, reason)
+		return err
+	}
+	return l.state.TransitionModule(name, version, target, reason)
+}
+
+func (l *Lifecycle) remove(name, operation string, fn func(string) error) error {
+	name = normalizeModuleName(name)
+	if name == "" {
+		return i18n.Errorf("module name is required")
+	}
+	reason := i18n.T("%s module %s", operation, name)
+	if current, ok := l.state.GetModule(name); ok && !module.CanTransition(current.State, module.StateAvailable) {
+		return i18n.Errorf("cannot %s module: module %s cannot go from %s to %s", operation, name, current.State, module.StateAvailable)
+	}
+	if err := fn(name); err != nil {
+		_ = l.state.TransitionModule(name, "", module.StateFailed, reason)
+		return err
+	}
+	return l.state.TransitionModule(name, "", module.StateAvailable, reason)
+}
+
+func (l *Lifecycle) mark(name string, state module.State) error {
+	name = normalizeModuleName(name)
+	current, ok := l.state.GetModule(name)
+	if !ok {
+		return nil
+	}
+	switch current.State {
+	case module.StateInstalled, module.StateRunning, module.StateStopped:
+	default:
+		return nil
+	}
+	return l.state.TransitionModule(name, "", state, i18n.T("mark module %s %s", name, state))
+}
+
+func normalizeModuleName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,86 @@
+package control
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/module"
+)
+
+func newTestLifecycle(t *testing.T) (*Lifecycle, *StateManager) {
+	t.Helper()
+	state, err := NewStateManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStateManager returned error: %v", err)
+	}
+	lifecycle := NewLifecycle(state)
+	lifecycle.manifest = func(name string) (module.Manifest, error) {
+		return module.Manifest{Name: name, Version: "1.2.0"}, nil
+	}
+	lifecycle.install = func(string, module.InstallOptions) error { return nil }
+	lifecycle.uninstall = func(string) error { return nil }
+	return lifecycle, state
+}
+
+func TestLifecycleRecordsTransitions(t *testing.T) {
+	lifecycle, state := newTestLifecycle(t)
+
+	if err := lifecycle.Install("Ollama", module.InstallOptions{}); err != nil {
+		t.Fatalf("Install returned error: %v", err)
+	}
+	current, ok := state.GetModule("ollama")
+	if !ok || current.State != module.StateInstalled || current.Version != "1.2.0" {
+		t.Fatalf("unexpected state after install: %+v", current)
+	}
+
+	if err := lifecycle.MarkRunning("ollama"); err != nil {
+		t.Fatalf("MarkRunning returned error: %v", err)
+	}
+	err := lifecycle.Uninstall("ollama")
+	if err == nil || !strings.Contains(err.Error(), "cannot go from running to available") {
+		t.Fatalf("expected uninstalling a running module to be rejected, got %v", err)
+	}
+	if err := lifecycle.Install("ollama", module.InstallOptions{}); err == nil {
+		t.Fatalf("expected reinstalling a running module to be rejected")
+	}
+
+	if err := lifecycle.MarkStopped("ollama"); err != nil {
+		t.Fatalf("MarkStopped returned error: %v", err)
+	}
+	if err := lifecycle.Uninstall("ollama"); err != nil {
+		t.Fatalf("Uninstall returned error: %v", err)
+	}
+	if got, _ := lifecycle.State("ollama"); got != module.StateAvailable {
+		t.Fatalf("expected available after uninstall, got %s", got)
+	}
+
+	history := state.GetState().History
+	if len(history) != 5 || history[0].Reason != "install module ollama" || history[0].Modules["ollama"].State != "" {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+}
+
+func TestLifecycleRecordsFailedInstall(t *testing.T) {
+	lifecycle, state := newTestLifecycle(t)
+	lifecycle.install = func(string, module.InstallOptions) error { return errors.New("step S10 failed") }
+
+	if err := lifecycle.Install("vllm", module.InstallOptions{}); err == nil {
+		t.Fatalf("expected install error")
+	}
+	if current, _ := state.GetModule("vllm"); current.State != module.StateFailed {
+		t.Fatalf("expected failed state, got %+v", current)
+	}
+	// Services that are not installed modules are not tracked.
+	if err := lifecycle.MarkRunning("my-api"); err != nil {
+		t.Fatalf("MarkRunning returned error: %v", err)
+	}
+	if _, ok := state.GetModule("my-api"); ok {
+		t.Fatalf("expected untracked services to be ignored")
+	}
+
+	lifecycle.install = func(string, module.InstallOptions) error { return nil }
+	if err := lifecycle.Install("vllm", module.InstallOptions{}); err != nil {
+		t.Fatalf("retry after failure returned error: %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+package control
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/module"
+)
+
+type RollbackAction string
+
+const (
+	RollbackStop      RollbackAction = "stop"
+	RollbackUninstall RollbackAction = "uninstall"
+	RollbackInstall   RollbackAction = "install"
+	RollbackStart     RollbackAction = "start"
+)
+
+// RollbackStep is one operation that moves a module towards its state in a
+// snapshot.
+type RollbackStep struct {
+	Action  RollbackAction
+	Module  string
+	Version string
+	// Dir is the module directory of Version for installs, empty when the
+	// registry record was not loaded from disk.
+	Dir    string
+	Reason string
+}
+
+type RollbackPlan struct {
+	Snapshot StateSnapshot
+	Steps    []RollbackStep
+}
+
+// ServiceController starts and stops module services during a rollback.
+type ServiceController interface {
+	Start(ctx context.Context, name string) error
+	Stop(ctx context.Context, name string) error
+}
+
+// Snapshot returns the snapshot with the given ID, or the latest one when id
+// is empty.
+func (m *StateManager) Snapshot(id string) (StateSnapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.state.History) == 0 {
+		return StateSnapshot{}, i18n.Errorf("no snapshots available")
+	}
+	if id == "" {
+		return cloneSnapshot(m.state.History[len(m.state.History)-1]), nil
+	}
+	for _, snapshot := range m.state.History {
+		if snapshot.ID == id {
+			return cloneSnapshot(snapshot), nil
+		}
+	}
+	return StateSnapshot{}, i18n.Errorf("snapshot %s not found", id)
+}
+
+func cloneSnapshot(snapshot StateSnapshot) StateSnapshot {
+	snapshot.Modules = cloneModules(snapshot.Modules)
+	return snapshot
+}
+
+func isInstalledState(state module.State) bool {
+	switch state {
+	case module.StateInstalled, module.StateRunning, module.StateStopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// PlanRollback diffs the current module states against a snapshot. Running
+// services are stopped first, then modules are uninstalled dependents first,
+// installed at the snapshot version in dependency order, and finally services
+// are started. The registry is required when modules need to be installed.
+func PlanRollback(current map[string]ModuleState, snapshot StateSnapshot, registry *module.Registry) (RollbackPlan, error) {
+	names := make([]string, 0, len(current)+len(snapshot.Modules))
+	seen := make(map[string]bool)
+	for _, modules := range []map[string]ModuleState{current, snapshot.Modules} {
+		for name := range modules {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var stops, uninstalls, starts []RollbackStep
+	var installTargets []string
+	installReasons := make(map[string]string)
+	uninstalling := make(map[string]bool)
+	for _, name := range names {
+		cur, target := current[name], snapshot.Modules[name]
+		if cur.State == "" {
+			cur.State = module.StateAvailable
+		}
+		if target.State == "" {
+			target.State = module.StateAvailable
+		}
+		curInstalled, targetInstalled := isInstalledState(cur.State), isInstalledState(target.State)
+		versionChange := curInstalled && targetInstalled && target.Version != "" && target.Version != cur.Version
+		curRunning, targetRunning := cur.State == module.StateRunning, target.State == module.StateRunning
+
+		if curRunning && (!targetRunning || versionChange) {
+			stops = append(stops, RollbackStep{Action: RollbackStop, Module: name, Reason: i18n.T("%s in snapshot", target.State)})
+		}
+		switch {
+		case !targetInstalled && cur.State != module.StateAvailable && cur.State != module.StateDeprecated:
+			uninstalls = append(uninstalls, RollbackStep{Action: RollbackUninstall, Module: name, Version: cur.Version, Reason: i18n.T("%s in snapshot", target.State)})
+			uninstalling[name] = true
+		case targetInstalled && !curInstalled:
+			installTargets = append(installTargets, dependencyTarget(name, target.Version))
+			installReasons[name] = i18n.T("%s in snapshot", target.State)
+		case versionChange:
+			installTargets = append(installTargets, dependencyTarget(name, target.Version))
+			installReasons[name] = i18n.T("version %s -> %s", cur.Version, target.Version)
+		}
+		if targetRunning && (!curRunning || versionChange) {
+			starts = append(starts, RollbackStep{Action: RollbackStart, Module: name, Reason: i18n.T("running in snapshot")})
+		}
+	}
+
+	var installs []RollbackStep
+	if len(installTargets) > 0 {
+		if registry == nil {
+			return RollbackPlan{}, i18n.Errorf("module registry is required to install modules")
+		}
+		resolved, err := module.NewResolver(registry).ResolveInstallPlan(installTargets)
+		if err != nil {
+			return RollbackPlan{}, i18n.Errorf("cannot restore snapshot %s: %w", snapshot.ID, err)
+		}
+		for _, name := range resolved.Order {
+			reason, requested := installReasons[name]
+			if !requested {
+				if isInstalledState(current[name].State) {
+					// Keep dependencies that are still needed.
+					if uninstalling[name] {
+						uninstalls = removeStep(uninstalls, name)
+					}
+					continue
+				}
+				reason = i18n.T("required dependency")
+			}
+			record := resolved.Modules[name]
+			step := RollbackStep{
+				Action:  RollbackInstall,
+				Module:  name,
+				Version: record.Manifest.Version,
+				Reason:  reason,
+			}
+			if record.SourcePath != "" {
+				step.Dir = filepath.Dir(record.SourcePath)
+			}
+			installs = append(installs, step)
+		}
+	}
+
+	if registry != nil {
+		uninstalls = uninstallOrder(uninstalls, current, registry)
+	}
+
+	plan := RollbackPlan{Snapshot: snapshot}
+	for _, steps := range [][]RollbackStep{stops, uninstalls, installs, starts} {
+		plan.Steps = append(plan.Steps, steps...)
+	}
+	return plan, nil
+}
+
+// uninstallOrder puts the uninstalls of modules before those of the modules
+// they depend on.
+func uninstallOrder(steps []RollbackStep, current map[string]ModuleState, registry *module.Registry) []RollbackStep {
+	byName := make(map[string]RollbackStep, len(steps))
+	names := make(map[string]bool, len(steps))
+	dependsOn := make(map[string][]string, len(steps))
+	for _, step := range steps {
+		byName[step.Module] = step
+		names[step.Module] = true
+		dependsOn[step.Module] = moduleDependencies(registry, step.Module, current[step.Module].Version)
+	}
+	ordered := make([]RollbackStep, 0, len(steps))
+	for _, name := range dependentsFirst(names, dependsOn) {
+		ordered = append(ordered, byName[name])
+	}
+	return ordered
+}
+
+func dependencyTarget(name, version string) string {
+	if _, err := module.ParseVersion(version); err != nil {
+		return name
+	}
+	return name + "@=" + version
+}
+
+func removeStep(steps []RollbackStep, name string) []RollbackStep {
+	kept := steps[:0]
+	for _, step := range steps {
+		if step.Module != name {
+			kept = append(kept, step)
+		}
+	}
+	return kept
+}
+
+// ExecuteRollback runs a rollback plan and stops at the first failing step.
+// Installs run from the directory of the planned version and only succeed
+// when it still provides that version. Snapshots record no module
+// configuration, so configuration changes are not rolled back.
+func (l *Lifecycle) ExecuteRollback(ctx context.Context, plan RollbackPlan, services ServiceController) error {
+	for _, step := range plan.Steps {
+		if err := l.executeRollbackStep(ctx, step, services); err != nil {
+			return i18n.Errorf("rollback step %s %s failed: %w", step.Action, step.Module, err)
+		}
+	}
+	return nil
+}
+
+func (l *Lifecycle) executeRollbackStep(ctx context.Context, step RollbackStep, services ServiceController) error {
+	switch step.Action {
+	case RollbackStop, RollbackStart:
+		if services == nil {
+			return i18n.Errorf("runtime supervisor is not available")
+		}
+		if step.Action == RollbackStop {
+			if err := services.Stop(ctx, step.Module); err != nil {
+				return err
+			}
+			return l.MarkStopped(step.Module)
+		}
+		if err := services.Start(ctx, step.Module); err != nil {
+			return err
+		}
+		return l.MarkRunning(step.Module)
+	case RollbackUninstall:
+		return l.Uninstall(step.Module)
+	case RollbackInstall:
+		if step.Dir != "" {
+			if err := l.checkPlannedVersion(PlannedInstall{Name: step.Module, Version: step.Version, Dir: step.Dir}); err != nil {
+				return err
+			}
+			return l.Install(step.Module, module.InstallOptions{ModuleDir: step.Dir})
+		}
+		if step.Version != "" {
+			manifest, err := l.manifest(step.Module)
+			if err != nil {
+				return err
+			}
+			if manifest.Version != step.Version {
+				return i18n.Errorf("version %s is not available, modules directory provides %s", step.Version, manifest.Version)
+			}
+		}
+		return l.Install(step.Module, module.InstallOptions{})
+	default:
+		return i18n.Errorf("unknown rollback action %q", step.Action)
+	}
+}
@@ -0,0 +1,201 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/module"
+)
+
+func addTestRecord(t *testing.T, registry *module.Registry, name, version string, deps ...string) {
+	t.Helper()
+	parsed, err := module.ParseVersion(version)
+	if err != nil {
+		t.Fatalf("ParseVersion returned error: %v", err)
+	}
+	manifest := module.Manifest{Name: name, Version: version, Dependencies: module.Dependencies{Modules: deps}}
+	if err := registry.Add(module.ModuleRecord{Manifest: manifest, Version: parsed}); err != nil {
+		t.Fatalf("Add returned error: %v", err)
+	}
+}
+
+func describePlan(plan RollbackPlan) string {
+	parts := make([]string, 0, len(plan.Steps))
+	for _, step := range plan.Steps {
+		part := string(step.Action) + " " + step.Module
+		if step.Action == RollbackInstall {
+			part += "@" + step.Version
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func TestPlanRollback(t *testing.T) {
+	registry := module.NewRegistry()
+	addTestRecord(t, registry, "python", "3.11.0")
+	addTestRecord(t, registry, "vllm", "0.5.2", "python")
+	addTestRecord(t, registry, "vllm", "0.6.0", "python")
+	addTestRecord(t, registry, "ollama", "0.1.0")
+
+	current := map[string]ModuleState{
+		"vllm":    {Name: "vllm", Version: "0.6.0", State: module.StateRunning},
+		"comfyui": {Name: "comfyui", Version: "0.1.0", State: module.StateInstalled},
+		"python":  {Name: "python", Version: "3.11.0", State: module.StateInstalled},
+	}
+	snapshot := StateSnapshot{ID: "1", Modules: map[string]ModuleState{
+		"vllm":    {Name: "vllm", Version: "0.5.2", State: module.StateRunning},
+		"ollama":  {Name: "ollama", Version: "0.1.0", State: module.StateStopped},
+		"python":  {Name: "python", Version: "3.11.0", State: module.StateInstalled},
+		"llmfit":  {Name: "llmfit", State: module.StateFailed},
+		"comfyui": {Name: "comfyui", State: module.StateAvailable},
+	}}
+
+	plan, err := PlanRollback(current, snapshot, registry)
+	if err != nil {
+		t.Fatalf("PlanRollback returned error: %v", err)
+	}
+	want := "stop vllm, uninstall comfyui, install ollama@0.1.0, install vllm@0.5.2, start vllm"
+	if got := describePlan(plan); got != want {
+		t.Fatalf("unexpected plan:\n got: %s\nwant: %s", got, want)
+	}
+
+	snapshot.Modules["vllm"] = ModuleState{Name: "vllm", Version: "0.4.0", State: module.StateInstalled}
+	if _, err := PlanRollback(current, snapshot, registry); err == nil {
+		t.Fatalf("expected an unavailable version to be reported")
+	}
+}
+
+func TestPlanRollbackUninstallsDependentsFirst(t *testing.T) {
+	registry := module.NewRegistry()
+	addTestRecord(t, registry, "cuda", "12.4.0")
+	addTestRecord(t, registry, "python", "3.11.0")
+	addTestRecord(t, registry, "vllm", "0.6.0", "python", "cuda")
+	addTestRecord(t, registry, "openwebui", "0.3.0", "vllm")
+
+	current := make(map[string]ModuleState)
+	for _, name := range []string{"cuda", "python", "vllm", "openwebui"} {
+		current[name] = ModuleState{Name: name, State: module.StateInstalled}
+	}
+	plan, err := PlanRollback(current, StateSnapshot{ID: "1"}, registry)
+	if err != nil {
+		t.Fatalf("PlanRollback returned error: %v", err)
+	}
+	want := "uninstall openwebui, uninstall vllm, uninstall cuda, uninstall python"
+	if got := describePlan(plan); got != want {
+		t.Fatalf("unexpected plan:\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestRollbackInstallsOlderVersionFromItsDir(t *testing.T) {
+	registry := module.NewRegistry()
+	for _, version := range []string{"0.5.2", "0.6.0"} {
+		parsed, err := module.ParseVersion(version)
+		if err != nil {
+			t.Fatalf("ParseVersion returned error: %v", err)
+		}
+		record := module.ModuleRecord{
+			Manifest:   module.Manifest{Name: "vllm", Version: version},
+			Version:    parsed,
+			SourcePath: filepath.Join("sources", version, "vllm", "manifest.yaml"),
+		}
+		if err := registry.Add(record); err != nil {
+			t.Fatalf("Add returned error: %v", err)
+		}
+	}
+	current := map[string]ModuleState{"vllm": {Name: "vllm", Version: "0.6.0", State: module.StateInstalled}}
+	snapshot := StateSnapshot{ID: "1", Modules: map[string]ModuleState{
+		"vllm": {Name: "vllm", Version: "0.5.2", State: module.StateInstalled},
+	}}
+	plan, err := PlanRollback(current, snapshot, registry)
+	if err != nil {
+		t.Fatalf("PlanRollback returned error: %v", err)
+	}
+	wantDir := filepath.Join("sources", "0.5.2", "vllm")
+	if len(plan.Steps) != 1 || plan.Steps[0].Dir != wantDir {
+		t.Fatalf("unexpected plan: %+v", plan.Steps)
+	}
+
+	lifecycle, state := newTestLifecycle(t)
+	lifecycle.manifest = func(string) (module.Manifest, error) {
+		return module.Manifest{Name: "vllm", Version: "0.6.0"}, nil
+	}
+	lifecycle.manifestAt = func(dir string) (module.Manifest, error) {
+		return module.Manifest{Name: "vllm", Version: filepath.Base(filepath.Dir(dir))}, nil
+	}
+	var gotDir string
+	lifecycle.install = func(_ string, opts module.InstallOptions) error {
+		gotDir = opts.ModuleDir
+		return nil
+	}
+	if err := lifecycle.ExecuteRollback(context.Background(), plan, nil); err != nil {
+		t.Fatalf("ExecuteRollback returned error: %v", err)
+	}
+	if gotDir != wantDir {
+		t.Fatalf("expected install from %s, got %q", wantDir, gotDir)
+	}
+	if current, ok := state.GetModule("vllm"); !ok || current.Version != "0.5.2" {
+		t.Fatalf("expected the snapshot version to be recorded, got %+v", current)
+	}
+}
+
+type fakeServices struct {
+	calls []string
+}
+
+func (f *fakeServices) Start(_ context.Context, name string) error {
+	f.calls = append(f.calls, "start "+name)
+	return nil
+}
+
+func (f *fakeServices) Stop(_ context.Context, name string) error {
+	f.calls = append(f.calls, "stop "+name)
+	return nil
+}
+
+func TestExecuteRollback(t *testing.T) {
+	lifecycle, state := newTestLifecycle(t)
+	var installed []string
+	lifecycle.install = func(name string, _ module.InstallOptions) error {
+		installed = append(installed, name)
+		return nil
+	}
+	if err := lifecycle.Install("vllm", module.InstallOptions{}); err != nil {
+		t.Fatalf("Install returned error: %v", err)
+	}
+	before, err := state.Snapshot("")
+	if err != nil {
+		t.Fatalf("Snapshot returned error: %v", err)
+	}
+	if err := lifecycle.MarkRunning("vllm"); err != nil {
+		t.Fatalf("MarkRunning returned error: %v", err)
+	}
+
+	plan := RollbackPlan{Snapshot: before, Steps: []RollbackStep{
+		{Action: RollbackStop, Module: "vllm"},
+		{Action: RollbackInstall, Module: "vllm", Version: "1.2.0"},
+		{Action: RollbackStart, Module: "vllm"},
+	}}
+	services := &fakeServices{}
+	if err := lifecycle.ExecuteRollback(context.Background(), plan, services); err != nil {
+		t.Fatalf("ExecuteRollback returned error: %v", err)
+	}
+	if fmt.Sprint(services.calls) != "[stop vllm start vllm]" || len(installed) != 2 {
+		t.Fatalf("unexpected calls: services=%v installs=%v", services.calls, installed)
+	}
+	if got, _ := lifecycle.State("vllm"); got != module.StateRunning {
+		t.Fatalf("expected vllm to run again, got %s", got)
+	}
+
+	plan.Steps = []RollbackStep{{Action: RollbackInstall, Module: "vllm", Version: "0.9.0"}}
+	if err := lifecycle.ExecuteRollback(context.Background(), plan, services); err == nil || !strings.Contains(err.Error(), "not available") {
+		t.Fatalf("expected a missing version to fail, got %v", err)
+	}
+	plan.Steps = []RollbackStep{{Action: RollbackStop, Module: "vllm"}}
+	if err := lifecycle.ExecuteRollback(context.Background(), plan, nil); err == nil {
+		t.Fatalf("expected service steps to need a supervisor")
+	}
+}
@@ -152,6 +152,37 @@ func (m *StateManager) UpdateModule(name, version string, state module.State) er
 	return m.saveLocked()
 }
 
+// TransitionModule moves a module to state if the module state machine allows
+// it. Modules without a record start out available. An empty version keeps
+// the recorded one.
+func (m *StateManager) TransitionModule(name, version string, state module.State, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if name == "" {
+		return i18n.Errorf("module name is required")
+	}
+	current, ok := m.state.Modules[name]
+	if !ok {
+		current = ModuleState{Name: name, State: module.StateAvailable}
+	}
+	if !module.CanTransition(current.State, state) {
+		return i18n.Errorf("module %s cannot go from %s to %s", name, current.State, state)
+	}
+	if version == "" {
+		version = current.Version
+	}
+
+	m.pushSnapshotLocked(reason)
+	m.state.Modules[name] = ModuleState{
+		Name:      name,
+		Version:   version,
+		State:     state,
+		UpdatedAt: time.Now().UTC(),
+	}
+	return m.saveLocked()
+}
+
 func (m *StateManager) RollbackTo(snapshotID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
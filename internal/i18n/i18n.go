@@ -61,6 +61,14 @@ func T(key string, args ...any) string {
 	return service.T(key, args...)
 }
 
+// Text translates a key that is not a format string, such as command help text.
+func Text(key string) string {
+	defaultMu.RLock()
+	service := defaultService
+	defaultMu.RUnlock()
+	return service.formatKey(key)
+}
+
 func Errorf(key string, args ...any) error {
 	defaultMu.RLock()
 	service := defaultService
@@ -0,0 +1,380 @@
+// Package memestimate predicts how much GPU and host memory an inference
+// server needs for a model shape, context and batch size, so launch
+// parameters can be chosen before the server fails to allocate.
+package memestimate
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	MiB = 1 << 20
+	GiB = 1 << 30
+)
+
+// CacheType is the storage type of the KV cache.
+type CacheType string
+
+const (
+	CacheF32  CacheType = "f32"
+	CacheF16  CacheType = "f16"
+	CacheBF16 CacheType = "bf16"
+	CacheQ8_0 CacheType = "q8_0"
+	CacheQ5_1 CacheType = "q5_1"
+	CacheQ5_0 CacheType = "q5_0"
+	CacheQ4_1 CacheType = "q4_1"
+	CacheQ4_0 CacheType = "q4_0"
+	CacheFP8  CacheType = "fp8"
+)
+
+// Bytes per element, including the block scales of the quantized types.
+var cacheTypeBytes = map[CacheType]float64{
+	CacheF32:  4,
+	CacheF16:  2,
+	CacheBF16: 2,
+	CacheQ8_0: 34.0 / 32,
+	CacheQ5_1: 24.0 / 32,
+	CacheQ5_0: 22.0 / 32,
+	CacheQ4_1: 20.0 / 32,
+	CacheQ4_0: 18.0 / 32,
+	CacheFP8:  1,
+}
+
+// ParseCacheType accepts the cache type names of llama.cpp and vLLM.
+func ParseCacheType(value string) (CacheType, error) {
+	normalized := CacheType(strings.ToLower(strings.TrimSpace(value)))
+	switch normalized {
+	case "", "auto":
+		return CacheF16, nil
+	case "fp8_e4m3", "fp8_e5m2":
+		return CacheFP8, nil
+	}
+	if _, ok := cacheTypeBytes[normalized]; !ok {
+		return "", fmt.Errorf("unsupported KV cache type %q", value)
+	}
+	return normalized, nil
+}
+
+func (c CacheType) bytesPerElement() float64 {
+	if size, ok := cacheTypeBytes[c]; ok {
+		return size
+	}
+	return cacheTypeBytes[CacheF16]
+}
+
+// Model is the shape of a transformer as far as memory is concerned.
+type Model struct {
+	Parameters    uint64
+	Layers        int
+	HiddenSize    int
+	Heads         int
+	KVHeads       int
+	HeadDim       int
+	VocabSize     int
+	ContextLength int
+	BitsPerWeight float64
+	// WeightBytes is the size of the weights on disk. It is preferred over
+	// Parameters and BitsPerWeight because it includes mixed quantization.
+	WeightBytes uint64
+}
+
+func (m Model) weightBytes() uint64 {
+	if m.WeightBytes > 0 {
+		return m.WeightBytes
+	}
+	return uint64(float64(m.Parameters) * m.BitsPerWeight / 8)
+}
+
+func (m Model) headDim() int {
+	if m.HeadDim > 0 {
+		return m.HeadDim
+	}
+	if m.Heads > 0 {
+		return m.HiddenSize / m.Heads
+	}
+	return 0
+}
+
+func (m Model) kvHeads() int {
+	if m.KVHeads > 0 {
+		return m.KVHeads
+	}
+	return m.Heads
+}
+
+// KVBytesPerToken is the size of the keys and values of one token across all
+// layers.
+func (m Model) KVBytesPerToken(cache CacheType) float64 {
+	return 2 * float64(m.Layers) * float64(m.kvHeads()) * float64(m.headDim()) * cache.bytesPerElement()
+}
+
+// embeddingBytes is the size of one vocabulary-sized matrix, the token
+// embedding or the output projection. It is bounded so a wrong vocabulary
+// size cannot claim most of the weights.
+func (m Model) embeddingBytes() uint64 {
+	total := m.weightBytes()
+	bits := m.BitsPerWeight
+	if bits <= 0 && m.Parameters > 0 {
+		bits = float64(total) * 8 / float64(m.Parameters)
+	}
+	size := uint64(float64(m.VocabSize) * float64(m.HiddenSize) * bits / 8)
+	return min(size, total/4)
+}
+
+// Device is a GPU, or the host when it holds what the GPUs do not.
+type Device struct {
+	Name   string
+	Memory uint64
+}
+
+// Config is the launch configuration to estimate.
+type Config struct {
+	Context int
+	// Batch is the number of tokens evaluated per step: the micro batch of
+	// llama.cpp or max_num_batched_tokens of vLLM.
+	Batch int
+	// Sequences is the number of sequences that each hold Context tokens of
+	// KV cache. Zero means one.
+	Sequences int
+	Cache     CacheType
+	// GPULayers is the number of repeating layers placed on the GPUs. Values
+	// at or above the layer count also offload the output projection.
+	GPULayers int
+	// TensorParallel shards weights and KV cache evenly across the GPUs, as
+	// vLLM does, instead of assigning whole layers to each GPU.
+	TensorParallel bool
+	// FlashAttention avoids materializing the attention scores, which
+	// otherwise grow with Batch × Context.
+	FlashAttention bool
+	// Overhead is reserved on every GPU for the runtime context and, for
+	// vLLM, CUDA graphs.
+	Overhead uint64
+}
+
+// Usage is the predicted memory use of one device.
+type Usage struct {
+	Device   string
+	Capacity uint64
+	Weights  uint64
+	KVCache  uint64
+	Compute  uint64
+	Overhead uint64
+	Layers   int
+}
+
+// Total is the sum of all components.
+func (u Usage) Total() uint64 {
+	return u.Weights + u.KVCache + u.Compute + u.Overhead
+}
+
+// Fits reports whether the device holds the prediction. Devices of unknown
+// capacity always fit.
+func (u Usage) Fits() bool {
+	return u.Capacity == 0 || u.Total() <= u.Capacity
+}
+
+// Estimate is the predicted memory use of every device. The host is always
+// the last entry.
+type Estimate struct {
+	Config  Config
+	Devices []Usage
+}
+
+// Fits reports whether every device holds its share.
+func (e Estimate) Fits() bool {
+	for _, usage := range e.Devices {
+		if !usage.Fits() {
+			return false
+		}
+	}
+	return true
+}
+
+// Host returns the usage of system memory.
+func (e Estimate) Host() Usage {
+	if len(e.Devices) == 0 {
+		return Usage{}
+	}
+	return e.Devices[len(e.Devices)-1]
+}
+
+// GPUs returns the usage of each GPU.
+func (e Estimate) GPUs() []Usage {
+	if len(e.Devices) == 0 {
+		return nil
+	}
+	return e.Devices[:len(e.Devices)-1]
+}
+
+// Compute predicts the memory use of config on gpus and host.
+func Compute(model Model, config Config, gpus []Device, host Device) Estimate {
+	if config.Sequences <= 0 {
+		config.Sequences = 1
+	}
+	switch {
+	case len(gpus) == 0:
+		config.GPULayers = 0
+	case config.TensorParallel:
+		config.GPULayers = model.Layers
+	}
+	config.GPULayers = max(0, min(config.GPULayers, model.Layers))
+
+	usages := make([]Usage, len(gpus)+1)
+	for i, gpu := range gpus {
+		usages[i] = Usage{Device: gpu.Name, Capacity: gpu.Memory}
+	}
+	hostUsage := &usages[len(gpus)]
+	hostUsage.Device, hostUsage.Capacity = host.Name, host.Memory
+	if hostUsage.Device == "" {
+		hostUsage.Device = "host"
+	}
+
+	weights := model.weightBytes()
+	embedding := model.embeddingBytes()
+	perLayerWeights := uint64(0)
+	if model.Layers > 0 && weights > 2*embedding {
+		perLayerWeights = (weights - 2*embedding) / uint64(model.Layers)
+	}
+	kvPerLayer := uint64(model.KVBytesPerToken(config.Cache) / math.Max(1, float64(model.Layers)) *
+		float64(config.Context) * float64(config.Sequences))
+	fullOffload := len(gpus) > 0 && config.GPULayers >= model.Layers
+
+	if config.TensorParallel && len(gpus) > 0 {
+		share := uint64(len(gpus))
+		for i := range gpus {
+			usages[i].Layers = model.Layers
+			usages[i].Weights = weights / share
+			usages[i].KVCache = kvPerLayer * uint64(model.Layers) / share
+		}
+	} else {
+		// llama.cpp looks the token embedding up on the host and keeps the
+		// output projection there until every layer is offloaded.
+		hostUsage.Weights = embedding
+		if !fullOffload {
+			hostUsage.Weights += embedding
+		}
+		for i, layers := range splitLayers(config.GPULayers, gpus) {
+			usages[i].Layers = layers
+			usages[i].Weights = perLayerWeights * uint64(layers)
+			usages[i].KVCache = kvPerLayer * uint64(layers)
+		}
+		if fullOffload {
+			usages[len(gpus)-1].Weights += embedding
+		}
+	}
+	hostLayers := model.Layers - config.GPULayers
+	hostUsage.Layers = hostLayers
+	hostUsage.Weights += perLayerWeights * uint64(hostLayers)
+	hostUsage.KVCache = kvPerLayer * uint64(hostLayers)
+
+	// Compute buffers hold the activations of one batch and, without flash
+	// attention, the attention scores of every head against the context.
+	activations := 4 * uint64(config.Batch) * uint64(4*model.HiddenSize)
+	if !config.FlashAttention {
+		activations += 4 * uint64(config.Batch) * uint64(model.Heads) * uint64(config.Context)
+	}
+	logits := 4 * uint64(config.Batch) * uint64(model.VocabSize)
+	for i := range gpus {
+		if usages[i].Layers == 0 {
+			continue
+		}
+		usages[i].Overhead = config.Overhead
+		usages[i].Compute = activations
+		if config.TensorParallel {
+			usages[i].Compute = activations/uint64(len(gpus)) + logits
+		}
+	}
+	if fullOffload && !config.TensorParallel {
+		usages[len(gpus)-1].Compute += logits
+	}
+	if hostLayers > 0 {
+		hostUsage.Compute = activations + logits
+	} else {
+		hostUsage.Compute = 4 * uint64(config.Batch) * uint64(model.HiddenSize)
+	}
+
+	return Estimate{Config: config, Devices: usages}
+}
+
+// splitLayers assigns layers to GPUs in proportion to their memory, the way
+// llama.cpp splits by default.
+func splitLayers(layers int, gpus []Device) []int {
+	counts := make([]int, len(gpus))
+	if layers <= 0 || len(gpus) == 0 {
+		return counts
+	}
+	var total uint64
+	for _, gpu := range gpus {
+		total += gpu.Memory
+	}
+	assigned := 0
+	for i, gpu := range gpus {
+		if total == 0 {
+			counts[i] = layers / len(gpus)
+		} else {
+			counts[i] = int(uint64(layers) * gpu.Memory / total)
+		}
+		assigned += counts[i]
+	}
+	for i := len(gpus) - 1; assigned < layers; i = (i + len(gpus) - 1) % len(gpus) {
+		counts[i]++
+		assigned++
+	}
+	return counts
+}
+
+// LargestFit returns the first configuration, trying contexts in the given
+// order, that fits with every layer on the GPUs. When none does, it keeps
+// the last context and offloads as many layers as fit. The result reports
+// false when even that does not fit.
+func LargestFit(model Model, config Config, gpus []Device, host Device, contexts []int) (Estimate, bool) {
+	if len(contexts) == 0 {
+		contexts = []int{config.Context}
+	}
+	var estimate Estimate
+	for _, context := range contexts {
+		config.Context = context
+		config.GPULayers = model.Layers
+		estimate = Compute(model, config, gpus, host)
+		if estimate.Fits() {
+			return estimate, true
+		}
+	}
+	if config.TensorParallel {
+		return estimate, false
+	}
+	for layers := model.Layers - 1; layers >= 0; layers-- {
+		config.GPULayers = layers
+		estimate = Compute(model, config, gpus, host)
+		if estimate.Fits() {
+			return estimate, true
+		}
+	}
+	return estimate, false
+}
+
+// ContextCandidates returns contexts from largest down to smallest, halving
+// each step, for LargestFit.
+func ContextCandidates(largest, smallest int) []int {
+	if smallest <= 0 {
+		smallest = 1
+	}
+	if largest < smallest {
+		largest = smallest
+	}
+	var contexts []int
+	for context := largest; context > smallest; context /= 2 {
+		contexts = append(contexts, context)
+	}
+	return append(contexts, smallest)
+}
+
+// FormatBytes renders a size in GiB with two decimals, or MiB below 1 GiB.
+func FormatBytes(size uint64) string {
+	if size >= GiB {
+		return fmt.Sprintf("%.2f GiB", float64(size)/GiB)
+	}
+	return fmt.Sprintf("%.0f MiB", float64(size)/MiB)
+}
@@ -0,0 +1,129 @@
+package memestimate
+
+import (
+	"math"
+	"testing"
+)
+
+var (
+	llama2_7B  = Model{Parameters: 6_740_000_000, Layers: 32, HiddenSize: 4096, Heads: 32, KVHeads: 32, VocabSize: 32000, BitsPerWeight: 16}
+	llama3_8B  = Model{Parameters: 8_030_000_000, Layers: 32, HiddenSize: 4096, Heads: 32, KVHeads: 8, VocabSize: 128256, WeightBytes: 4_920_000_000}
+	qwen25_7B  = Model{Parameters: 7_620_000_000, Layers: 28, HiddenSize: 3584, Heads: 28, KVHeads: 4, VocabSize: 152064, BitsPerWeight: 16}
+	llama3_70B = Model{Parameters: 70_550_000_000, Layers: 80, HiddenSize: 8192, Heads: 64, KVHeads: 8, VocabSize: 128256, WeightBytes: 42_500_000_000}
+)
+
+func TestKVCacheOfKnownShapes(t *testing.T) {
+	tests := []struct {
+		name    string
+		model   Model
+		context int
+		cache   CacheType
+		want    uint64
+	}{
+		{"llama2-7b f16 4k", llama2_7B, 4096, CacheF16, 2 * GiB},
+		{"llama3-8b f16 8k", llama3_8B, 8192, CacheF16, 1 * GiB},
+		{"llama3-8b q8_0 8k", llama3_8B, 8192, CacheQ8_0, 544 * MiB},
+		{"qwen2.5-7b f16 32k", qwen25_7B, 32768, CacheF16, 1792 * MiB},
+		{"llama3-70b f16 8k", llama3_70B, 8192, CacheF16, 2560 * MiB},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate := Compute(tt.model, Config{Context: tt.context, Batch: 512, Cache: tt.cache, GPULayers: tt.model.Layers}, []Device{{Name: "gpu0", Memory: 80 * GiB}}, Device{})
+			var kv uint64
+			for _, usage := range estimate.Devices {
+				kv += usage.KVCache
+			}
+			if kv != tt.want {
+				t.Fatalf("expected %s of KV cache, got %s", FormatBytes(tt.want), FormatBytes(kv))
+			}
+		})
+	}
+}
+
+func TestComputeSplitsWeightsByOffload(t *testing.T) {
+	tests := []struct {
+		name       string
+		model      Model
+		gpuLayers  int
+		gpus       []Device
+		wantLayers []int
+	}{
+		{"full offload", llama3_8B, 32, []Device{{Name: "gpu0", Memory: 24 * GiB}}, []int{32, 0}},
+		{"partial offload", llama3_8B, 20, []Device{{Name: "gpu0", Memory: 8 * GiB}}, []int{20, 12}},
+		{"cpu only", llama3_8B, 32, nil, []int{32}},
+		{"uneven gpus", llama3_70B, 80, []Device{{Name: "gpu0", Memory: 48 * GiB}, {Name: "gpu1", Memory: 24 * GiB}}, []int{53, 27, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate := Compute(tt.model, Config{Context: 4096, Batch: 512, Cache: CacheF16, GPULayers: tt.gpuLayers}, tt.gpus, Device{Name: "host"})
+			var weights uint64
+			for i, usage := range estimate.Devices {
+				if usage.Layers != tt.wantLayers[i] {
+					t.Fatalf("expected layers %v, got %+v", tt.wantLayers, estimate.Devices)
+				}
+				weights += usage.Weights
+			}
+			// Integer division per layer may lose a few bytes.
+			if diff := math.Abs(float64(weights) - float64(tt.model.weightBytes())); diff > float64(tt.model.Layers) {
+				t.Fatalf("expected the weights to add up to %d, got %d", tt.model.weightBytes(), weights)
+			}
+		})
+	}
+}
+
+func TestLargestFitPrefersFullOffloadThenContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		model       Model
+		gpus        []Device
+		host        Device
+		wantContext int
+		wantLayers  int
+		wantFit     bool
+	}{
+		{"8b on 24gb keeps the full context", llama3_8B, []Device{{Name: "gpu0", Memory: 24 * GiB}}, Device{Memory: 32 * GiB}, 32768, 32, true},
+		{"8b on 8gb shrinks the context", llama3_8B, []Device{{Name: "gpu0", Memory: 8 * GiB}}, Device{Memory: 32 * GiB}, 16384, 32, true},
+		{"70b on 24gb offloads part of the layers", llama3_70B, []Device{{Name: "gpu0", Memory: 24 * GiB}}, Device{Memory: 64 * GiB}, 2048, 48, true},
+		{"70b on two 48gb gpus", llama3_70B, []Device{{Name: "gpu0", Memory: 48 * GiB}, {Name: "gpu1", Memory: 48 * GiB}}, Device{Memory: 64 * GiB}, 32768, 80, true},
+		{"70b without enough memory", llama3_70B, nil, Device{Memory: 16 * GiB}, 2048, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate, fits := LargestFit(tt.model, Config{Batch: 512, Cache: CacheF16, FlashAttention: true, Overhead: 512 * MiB}, tt.gpus, tt.host, ContextCandidates(32768, 2048))
+			if fits != tt.wantFit || estimate.Config.Context != tt.wantContext || estimate.Config.GPULayers != tt.wantLayers {
+				t.Fatalf("expected ctx %d with %d layers (fits=%v), got ctx %d with %d layers (fits=%v)",
+					tt.wantContext, tt.wantLayers, tt.wantFit, estimate.Config.Context, estimate.Config.GPULayers, fits)
+			}
+			if fits {
+				for _, usage := range estimate.Devices {
+					if !usage.Fits() {
+						t.Fatalf("device %s over capacity: %+v", usage.Device, usage)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTensorParallelShardsEvenly(t *testing.T) {
+	gpus := []Device{{Name: "gpu0", Memory: 80 * GiB}, {Name: "gpu1", Memory: 80 * GiB}}
+	estimate := Compute(llama3_70B, Config{Context: 8192, Batch: 2048, Cache: CacheF16, TensorParallel: true, FlashAttention: true}, gpus, Device{})
+	first, second := estimate.GPUs()[0], estimate.GPUs()[1]
+	if first.Weights != llama3_70B.WeightBytes/2 || first.Total() != second.Total() {
+		t.Fatalf("expected an even split, got %+v and %+v", first, second)
+	}
+	if host := estimate.Host(); host.Weights != 0 || host.KVCache != 0 {
+		t.Fatalf("expected nothing on the host, got %+v", host)
+	}
+}
+
+func TestParseCacheType(t *testing.T) {
+	for input, want := range map[string]CacheType{"": CacheF16, "auto": CacheF16, "Q8_0": CacheQ8_0, "fp8_e5m2": CacheFP8} {
+		if got, err := ParseCacheType(input); err != nil || got != want {
+			t.Fatalf("ParseCacheType(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseCacheType("q3_k"); err == nil {
+		t.Fatalf("expected an unsupported cache type to be rejected")
+	}
+}
@@ -0,0 +1,537 @@
+package modelmanager
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const ggufMagic = "GGUF"
+
+// Strings longer than this are skipped instead of read into memory. Chat
+// templates are a few kilobytes; anything larger is tokenizer data.
+const ggufMaxStringLen = 1 << 20
+
+// Integer arrays up to this length are kept, so per-layer head counts are
+// available while vocabulary-sized arrays are skipped.
+const ggufMaxKeptArrayLen = 4096
+
+// GGUF metadata value types.
+const (
+	ggufTypeUint8 uint32 = iota
+	ggufTypeInt8
+	ggufTypeUint16
+	ggufTypeInt16
+	ggufTypeUint32
+	ggufTypeInt32
+	ggufTypeFloat32
+	ggufTypeBool
+	ggufTypeString
+	ggufTypeArray
+	ggufTypeUint64
+	ggufTypeInt64
+	ggufTypeFloat64
+)
+
+// GGMLType is the storage type of a tensor.
+type GGMLType uint32
+
+var ggmlTypeNames = map[GGMLType]string{
+	0: "F32", 1: "F16", 2: "Q4_0", 3: "Q4_1", 6: "Q5_0", 7: "Q5_1", 8: "Q8_0", 9: "Q8_1",
+	10: "Q2_K", 11: "Q3_K", 12: "Q4_K", 13: "Q5_K", 14: "Q6_K", 15: "Q8_K",
+	16: "IQ2_XXS", 17: "IQ2_XS", 18: "IQ3_XXS", 19: "IQ1_S", 20: "IQ4_NL", 21: "IQ3_S",
+	22: "IQ2_S", 23: "IQ4_XS", 24: "I8", 25: "I16", 26: "I32", 27: "I64", 28: "F64",
+	29: "IQ1_M", 30: "BF16", 34: "TQ1_0", 35: "TQ2_0", 39: "MXFP4",
+}
+
+func (t GGMLType) String() string {
+	if name, ok := ggmlTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("TYPE_%d", uint32(t))
+}
+
+// ggufFileTypeNames maps general.file_type to the quantization name
+// llama.cpp uses for the whole file.
+var ggufFileTypeNames = map[uint64]string{
+	0: "F32", 1: "F16", 2: "Q4_0", 3: "Q4_1", 7: "Q8_0", 8: "Q5_0", 9: "Q5_1",
+	10: "Q2_K", 11: "Q3_K_S", 12: "Q3_K_M", 13: "Q3_K_L", 14: "Q4_K_S", 15: "Q4_K_M",
+	16: "Q5_K_S", 17: "Q5_K_M", 18: "Q6_K", 19: "IQ2_XXS", 20: "IQ2_XS", 21: "Q2_K_S",
+	22: "IQ3_XS", 23: "IQ3_XXS", 24: "IQ1_S", 25: "IQ4_NL", 26: "IQ3_S", 27: "IQ3_M",
+	28: "IQ2_S", 29: "IQ2_M", 30: "IQ4_XS", 31: "IQ1_M", 32: "BF16", 36: "TQ1_0",
+	37: "TQ2_0", 38: "MXFP4_MOE",
+}
+
+// GGUFTensor describes one tensor of a GGUF file.
+type GGUFTensor struct {
+	Name  string
+	Type  GGMLType
+	Shape []uint64
+}
+
+// Elements returns the number of values in the tensor.
+func (t GGUFTensor) Elements() uint64 {
+	if len(t.Shape) == 0 {
+		return 0
+	}
+	count := uint64(1)
+	for _, dim := range t.Shape {
+		count *= dim
+	}
+	return count
+}
+
+// GGUFInfo is the model metadata read from a GGUF header.
+type GGUFInfo struct {
+	Version         uint32
+	Architecture    string
+	Name            string
+	FileType        string
+	ParameterCount  uint64
+	BlockCount      uint64
+	ContextLength   uint64
+	EmbeddingLength uint64
+	HeadCount       uint64
+	HeadCountKV     uint64
+	ChatTemplate    string
+	Tensors         []GGUFTensor
+	// Metadata holds the scalar values and short integer arrays of the
+	// header, keyed by their GGUF key.
+	Metadata map[string]any
+}
+
+// Quantization returns the file type recorded by the converter, or the type
+// holding most of the weights when the header does not record one.
+func (info *GGUFInfo) Quantization() string {
+	if info.FileType != "" {
+		return info.FileType
+	}
+	weights := make(map[GGMLType]uint64)
+	for _, tensor := range info.Tensors {
+		if len(tensor.Shape) >= 2 {
+			weights[tensor.Type] += tensor.Elements()
+		}
+	}
+	var dominant GGMLType
+	var most uint64
+	for tensorType, count := range weights {
+		if count > most || (count == most && tensorType < dominant) {
+			dominant, most = tensorType, count
+		}
+	}
+	if most == 0 {
+		return ""
+	}
+	return dominant.String()
+}
+
+// ParameterCountB returns the parameter count in billions.
+func (info *GGUFInfo) ParameterCountB() float64 {
+	return float64(info.ParameterCount) / 1e9
+}
+
+var ggufSplitPattern = regexp.MustCompile(`^(.*)-(\d{5})-of-(\d{5})\.gguf$`)
+
+// ReadGGUFModel reads the header of a GGUF file. When the file is one shard
+// of a split model, the tensors of the other shards are added so the
+// parameter count covers the whole model.
+func ReadGGUFModel(path string) (*GGUFInfo, error) {
+	info, err := ReadGGUFFile(path)
+	if err != nil {
+		return nil, err
+	}
+	shards := GGUFShards(path)
+	if len(shards) == 1 {
+		return info, nil
+	}
+	for _, shard := range shards {
+		if shard == path {
+			continue
+		}
+		shardInfo, err := ReadGGUFFile(shard)
+		if err != nil {
+			return nil, err
+		}
+		info.Tensors = append(info.Tensors, shardInfo.Tensors...)
+		if info.Architecture == "" {
+			info.adopt(shardInfo)
+		}
+	}
+	if _, recorded := info.Metadata["general.parameter_count"]; !recorded {
+		info.ParameterCount = countParameters(info.Tensors)
+	}
+	return info, nil
+}
+
+// GGUFShards returns every shard of the split model path belongs to, or
+// just path when it is not split.
+func GGUFShards(path string) []string {
+	match := ggufSplitPattern.FindStringSubmatch(filepath.Base(path))
+	if match == nil || match[3] == "00001" {
+		return []string{path}
+	}
+	shards, err := filepath.Glob(filepath.Join(filepath.Dir(path), match[1]+"-*-of-"+match[3]+".gguf"))
+	if err != nil || len(shards) == 0 {
+		return []string{path}
+	}
+	sort.Strings(shards)
+	return shards
+}
+
+// adopt copies the model-level metadata of the first shard, which is the
+// only one that carries it.
+func (info *GGUFInfo) adopt(other *GGUFInfo) {
+	tensors := info.Tensors
+	*info = *other
+	info.Tensors = tensors
+}
+
+// ReadGGUFFile reads the metadata and tensor descriptions of a single GGUF
+// file without loading tensor data.
+func ReadGGUFFile(path string) (*GGUFInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := ParseGGUF(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GGUF header of %s: %w", filepath.Base(path), err)
+	}
+	return info, nil
+}
+
+// ParseGGUF decodes a GGUF header from r.
+func ParseGGUF(r io.Reader) (*GGUFInfo, error) {
+	p := &ggufParser{r: bufio.NewReaderSize(r, 1<<16)}
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(p.r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != ggufMagic {
+		return nil, errors.New("not a GGUF file")
+	}
+	version, err := p.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if version < 2 || version > 3 {
+		return nil, fmt.Errorf("unsupported GGUF version %d", version)
+	}
+	tensorCount, err := p.uint64()
+	if err != nil {
+		return nil, err
+	}
+	kvCount, err := p.uint64()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &GGUFInfo{Version: version, Metadata: make(map[string]any)}
+	for i := uint64(0); i < kvCount; i++ {
+		key, err := p.string()
+		if err != nil {
+			return nil, fmt.Errorf("metadata key %d: %w", i, err)
+		}
+		valueType, err := p.uint32()
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.value(valueType)
+		if err != nil {
+			return nil, fmt.Errorf("metadata %s: %w", key, err)
+		}
+		if value != nil {
+			info.Metadata[key] = value
+		}
+	}
+
+	// A corrupt count fails on the first short read; only the preallocation
+	// needs a bound.
+	info.Tensors = make([]GGUFTensor, 0, min(tensorCount, 1<<16))
+	for i := uint64(0); i < tensorCount; i++ {
+		tensor, err := p.tensor()
+		if err != nil {
+			return nil, fmt.Errorf("tensor %d: %w", i, err)
+		}
+		info.Tensors = append(info.Tensors, tensor)
+	}
+
+	info.fill()
+	return info, nil
+}
+
+func (info *GGUFInfo) fill() {
+	info.Architecture = info.stringValue("general.architecture")
+	info.Name = info.stringValue("general.name")
+	info.ChatTemplate = info.stringValue("tokenizer.chat_template")
+	if fileType, ok := info.uintValue("general.file_type"); ok {
+		if name, known := ggufFileTypeNames[fileType]; known {
+			info.FileType = name
+		}
+	}
+	arch := info.Architecture
+	info.BlockCount, _ = info.uintValue(arch + ".block_count")
+	info.ContextLength, _ = info.uintValue(arch + ".context_length")
+	info.EmbeddingLength, _ = info.uintValue(arch + ".embedding_length")
+	info.HeadCount, _ = info.uintValue(arch + ".attention.head_count")
+	info.HeadCountKV, _ = info.uintValue(arch + ".attention.head_count_kv")
+	if info.HeadCountKV == 0 {
+		info.HeadCountKV = info.HeadCount
+	}
+	if count, ok := info.uintValue("general.parameter_count"); ok {
+		info.ParameterCount = count
+	} else {
+		info.ParameterCount = countParameters(info.Tensors)
+	}
+}
+
+func countParameters(tensors []GGUFTensor) uint64 {
+	var total uint64
+	for _, tensor := range tensors {
+		total += tensor.Elements()
+	}
+	return total
+}
+
+func (info *GGUFInfo) stringValue(key string) string {
+	value, _ := info.Metadata[key].(string)
+	return value
+}
+
+// uintValue reads an integer value. Per-layer arrays report their largest
+// entry.
+func (info *GGUFInfo) uintValue(key string) (uint64, bool) {
+	switch value := info.Metadata[key].(type) {
+	case uint64:
+		return value, true
+	case int64:
+		if value >= 0 {
+			return uint64(value), true
+		}
+	case []int64:
+		var largest int64
+		for _, entry := range value {
+			largest = max(largest, entry)
+		}
+		return uint64(largest), len(value) > 0
+	}
+	return 0, false
+}
+
+type ggufParser struct {
+	r *bufio.Reader
+}
+
+func (p *ggufParser) uint32() (uint32, error) {
+	var value uint32
+	err := binary.Read(p.r, binary.LittleEndian, &value)
+	return value, err
+}
+
+func (p *ggufParser) uint64() (uint64, error) {
+	var value uint64
+	err := binary.Read(p.r, binary.LittleEndian, &value)
+	return value, err
+}
+
+func (p *ggufParser) string() (string, error) {
+	length, err := p.uint64()
+	if err != nil {
+		return "", err
+	}
+	if length > ggufMaxStringLen {
+		return "", fmt.Errorf("string of %d bytes is too long", length)
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(p.r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// value reads a metadata value. Integers are widened to uint64 or int64,
+// floats to float64; strings too long to be useful and arrays other than
+// short integer arrays are skipped and reported as nil.
+func (p *ggufParser) value(valueType uint32) (any, error) {
+	switch valueType {
+	case ggufTypeUint8, ggufTypeUint16, ggufTypeUint32, ggufTypeUint64, ggufTypeBool:
+		value, err := p.unsigned(valueType)
+		if valueType == ggufTypeBool {
+			return value != 0, err
+		}
+		return value, err
+	case ggufTypeInt8, ggufTypeInt16, ggufTypeInt32, ggufTypeInt64:
+		return p.signed(valueType)
+	case ggufTypeFloat32:
+		bits, err := p.uint32()
+		return float64(math.Float32frombits(bits)), err
+	case ggufTypeFloat64:
+		bits, err := p.uint64()
+		return math.Float64frombits(bits), err
+	case ggufTypeString:
+		length, err := p.uint64()
+		if err != nil {
+			return nil, err
+		}
+		if length > ggufMaxStringLen {
+			return nil, p.skip(length)
+		}
+		buf := make([]byte, length)
+		if _, err := io.ReadFull(p.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf), nil
+	case ggufTypeArray:
+		return p.array()
+	default:
+		return nil, fmt.Errorf("unknown value type %d", valueType)
+	}
+}
+
+func (p *ggufParser) array() (any, error) {
+	elementType, err := p.uint32()
+	if err != nil {
+		return nil, err
+	}
+	count, err := p.uint64()
+	if err != nil {
+		return nil, err
+	}
+	integer := elementType <= ggufTypeInt32 || elementType == ggufTypeUint64 || elementType == ggufTypeInt64
+	if integer && count <= ggufMaxKeptArrayLen {
+		values := make([]int64, 0, count)
+		for i := uint64(0); i < count; i++ {
+			value, err := p.value(elementType)
+			if err != nil {
+				return nil, err
+			}
+			switch v := value.(type) {
+			case uint64:
+				values = append(values, int64(v))
+			case int64:
+				values = append(values, v)
+			}
+		}
+		return values, nil
+	}
+	if size := ggufFixedSize(elementType); size > 0 {
+		if count > math.MaxInt64/size {
+			return nil, fmt.Errorf("array of %d elements is too large", count)
+		}
+		return nil, p.skip(count * size)
+	}
+	for i := uint64(0); i < count; i++ {
+		switch elementType {
+		case ggufTypeString:
+			length, err := p.uint64()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.skip(length); err != nil {
+				return nil, err
+			}
+		case ggufTypeArray:
+			if _, err := p.array(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown value type %d", elementType)
+		}
+	}
+	return nil, nil
+}
+
+func (p *ggufParser) unsigned(valueType uint32) (uint64, error) {
+	size := ggufFixedSize(valueType)
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(p.r, buf[:size]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buf), nil
+}
+
+func (p *ggufParser) signed(valueType uint32) (int64, error) {
+	value, err := p.unsigned(valueType)
+	if err != nil {
+		return 0, err
+	}
+	shift := 64 - 8*ggufFixedSize(valueType)
+	return int64(value<<shift) >> shift, nil
+}
+
+func (p *ggufParser) skip(n uint64) error {
+	if n > math.MaxInt64 {
+		return fmt.Errorf("invalid length %d", n)
+	}
+	_, err := io.CopyN(io.Discard, p.r, int64(n))
+	return err
+}
+
+func (p *ggufParser) tensor() (GGUFTensor, error) {
+	name, err := p.string()
+	if err != nil {
+		return GGUFTensor{}, err
+	}
+	dims, err := p.uint32()
+	if err != nil {
+		return GGUFTensor{}, err
+	}
+	if dims > 8 {
+		return GGUFTensor{}, fmt.Errorf("tensor %s has %d dimensions", name, dims)
+	}
+	shape := make([]uint64, dims)
+	for i := range shape {
+		if shape[i], err = p.uint64(); err != nil {
+			return GGUFTensor{}, err
+		}
+	}
+	tensorType, err := p.uint32()
+	if err != nil {
+		return GGUFTensor{}, err
+	}
+	// The data offset is not needed to describe the model.
+	if _, err := p.uint64(); err != nil {
+		return GGUFTensor{}, err
+	}
+	return GGUFTensor{Name: name, Type: GGMLType(tensorType), Shape: shape}, nil
+}
+
+func ggufFixedSize(valueType uint32) uint64 {
+	switch valueType {
+	case ggufTypeUint8, ggufTypeInt8, ggufTypeBool:
+		return 1
+	case ggufTypeUint16, ggufTypeInt16:
+		return 2
+	case ggufTypeUint32, ggufTypeInt32, ggufTypeFloat32:
+		return 4
+	case ggufTypeUint64, ggufTypeInt64, ggufTypeFloat64:
+		return 8
+	}
+	return 0
+}
+
+// FormatParameterCount renders a parameter count the way model names do,
+// e.g. 7.2B or 494M.
+func FormatParameterCount(count uint64) string {
+	switch {
+	case count == 0:
+		return "-"
+	case count >= 1e9:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(count)/1e9), ".0") + "B"
+	case count >= 1e6:
+		return fmt.Sprintf("%.0fM", float64(count)/1e6)
+	default:
+		return fmt.Sprintf("%.0fK", float64(count)/1e3)
+	}
+}
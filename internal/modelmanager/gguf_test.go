@@ -0,0 +1,192 @@
+package modelmanager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type ggufTestTensor struct {
+	name  string
+	typ   GGMLType
+	shape []uint64
+}
+
+// ggufTestWriter builds minimal GGUF v3 headers.
+type ggufTestWriter struct {
+	kv      bytes.Buffer
+	kvCount uint64
+}
+
+func (w *ggufTestWriter) put(data any) {
+	_ = binary.Write(&w.kv, binary.LittleEndian, data)
+}
+
+func (w *ggufTestWriter) putString(value string) {
+	w.put(uint64(len(value)))
+	w.kv.WriteString(value)
+}
+
+func (w *ggufTestWriter) key(name string, valueType uint32) {
+	w.kvCount++
+	w.putString(name)
+	w.put(valueType)
+}
+
+func (w *ggufTestWriter) String(name, value string) {
+	w.key(name, ggufTypeString)
+	w.putString(value)
+}
+
+func (w *ggufTestWriter) Uint32(name string, value uint32) {
+	w.key(name, ggufTypeUint32)
+	w.put(value)
+}
+
+func (w *ggufTestWriter) Uint64(name string, value uint64) {
+	w.key(name, ggufTypeUint64)
+	w.put(value)
+}
+
+func (w *ggufTestWriter) Int32Array(name string, values ...int32) {
+	w.key(name, ggufTypeArray)
+	w.put(ggufTypeInt32)
+	w.put(uint64(len(values)))
+	for _, value := range values {
+		w.put(value)
+	}
+}
+
+func (w *ggufTestWriter) StringArray(name string, values ...string) {
+	w.key(name, ggufTypeArray)
+	w.put(ggufTypeString)
+	w.put(uint64(len(values)))
+	for _, value := range values {
+		w.putString(value)
+	}
+}
+
+func (w *ggufTestWriter) bytes(tensors ...ggufTestTensor) []byte {
+	var out bytes.Buffer
+	out.WriteString(ggufMagic)
+	_ = binary.Write(&out, binary.LittleEndian, uint32(3))
+	_ = binary.Write(&out, binary.LittleEndian, uint64(len(tensors)))
+	_ = binary.Write(&out, binary.LittleEndian, w.kvCount)
+	out.Write(w.kv.Bytes())
+	for _, tensor := range tensors {
+		_ = binary.Write(&out, binary.LittleEndian, uint64(len(tensor.name)))
+		out.WriteString(tensor.name)
+		_ = binary.Write(&out, binary.LittleEndian, uint32(len(tensor.shape)))
+		for _, dim := range tensor.shape {
+			_ = binary.Write(&out, binary.LittleEndian, dim)
+		}
+		_ = binary.Write(&out, binary.LittleEndian, uint32(tensor.typ))
+		_ = binary.Write(&out, binary.LittleEndian, uint64(0))
+	}
+	return out.Bytes()
+}
+
+func TestParseGGUFReadsModelMetadata(t *testing.T) {
+	w := &ggufTestWriter{}
+	w.String("general.architecture", "llama")
+	w.String("general.name", "Tiny Llama")
+	w.Uint32("general.file_type", 15)
+	w.Uint32("llama.block_count", 2)
+	w.Uint64("llama.context_length", 4096)
+	w.Uint32("llama.embedding_length", 64)
+	w.Uint32("llama.attention.head_count", 8)
+	w.Uint32("llama.attention.head_count_kv", 2)
+	w.StringArray("tokenizer.ggml.tokens", "<s>", "</s>", "hello")
+	w.String("tokenizer.chat_template", "{{ messages }}")
+	data := w.bytes(
+		ggufTestTensor{"token_embd.weight", 12, []uint64{64, 100}},
+		ggufTestTensor{"blk.0.attn_norm.weight", 0, []uint64{64}},
+		ggufTestTensor{"blk.0.ffn_down.weight", 14, []uint64{64, 64}},
+	)
+
+	info, err := ParseGGUF(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseGGUF returned error: %v", err)
+	}
+	if info.Architecture != "llama" || info.Name != "Tiny Llama" || info.BlockCount != 2 || info.ContextLength != 4096 {
+		t.Fatalf("unexpected model metadata: %+v", info)
+	}
+	if info.EmbeddingLength != 64 || info.HeadCount != 8 || info.HeadCountKV != 2 {
+		t.Fatalf("unexpected attention metadata: %+v", info)
+	}
+	if info.ChatTemplate != "{{ messages }}" {
+		t.Fatalf("expected the chat template, got %q", info.ChatTemplate)
+	}
+	if info.ParameterCount != 64*100+64+64*64 {
+		t.Fatalf("expected the parameter count from tensor shapes, got %d", info.ParameterCount)
+	}
+	if info.Quantization() != "Q4_K_M" {
+		t.Fatalf("expected Q4_K_M, got %q", info.Quantization())
+	}
+	if len(info.Tensors) != 3 || info.Tensors[2].Type.String() != "Q6_K" {
+		t.Fatalf("unexpected tensors: %+v", info.Tensors)
+	}
+}
+
+func TestGGUFQuantizationFallsBackToTensorTypes(t *testing.T) {
+	w := &ggufTestWriter{}
+	w.String("general.architecture", "qwen2")
+	w.Uint64("general.parameter_count", 494_000_000)
+	w.Int32Array("qwen2.attention.head_count", 14, 14, 16)
+	data := w.bytes(
+		ggufTestTensor{"token_embd.weight", 8, []uint64{64, 1000}},
+		ggufTestTensor{"blk.0.ffn_up.weight", 12, []uint64{64, 64}},
+	)
+
+	info, err := ParseGGUF(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseGGUF returned error: %v", err)
+	}
+	if info.Quantization() != "Q8_0" {
+		t.Fatalf("expected the dominant tensor type, got %q", info.Quantization())
+	}
+	if info.ParameterCount != 494_000_000 || FormatParameterCount(info.ParameterCount) != "494M" {
+		t.Fatalf("expected general.parameter_count to win, got %d", info.ParameterCount)
+	}
+	if info.HeadCount != 16 || info.HeadCountKV != 16 {
+		t.Fatalf("expected per-layer head counts to report the largest, got %+v", info)
+	}
+}
+
+func TestReadGGUFModelCombinesSplitShards(t *testing.T) {
+	dir := t.TempDir()
+	first := &ggufTestWriter{}
+	first.String("general.architecture", "llama")
+	first.Uint32("llama.block_count", 2)
+	second := &ggufTestWriter{}
+	shards := map[string][]byte{
+		"model-00001-of-00002.gguf": first.bytes(ggufTestTensor{"blk.0.ffn_up.weight", 12, []uint64{1000, 1000}}),
+		"model-00002-of-00002.gguf": second.bytes(ggufTestTensor{"blk.1.ffn_up.weight", 12, []uint64{1000, 1000}}),
+	}
+	for name, data := range shards {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatalf("WriteFile returned error: %v", err)
+		}
+	}
+
+	info, err := ReadGGUFModel(filepath.Join(dir, "model-00002-of-00002.gguf"))
+	if err != nil {
+		t.Fatalf("ReadGGUFModel returned error: %v", err)
+	}
+	if info.Architecture != "llama" || info.BlockCount != 2 || info.ParameterCount != 2_000_000 {
+		t.Fatalf("expected metadata from the first shard and tensors from both, got %+v", info)
+	}
+}
+
+func TestParseGGUFRejectsInvalidHeaders(t *testing.T) {
+	if _, err := ParseGGUF(strings.NewReader("GGML\x03\x00\x00\x00")); err == nil {
+		t.Fatalf("expected a wrong magic to be rejected")
+	}
+	data := (&ggufTestWriter{}).bytes(ggufTestTensor{"w", 0, []uint64{4, 4}})
+	if _, err := ParseGGUF(bytes.NewReader(data[:len(data)-4])); err == nil {
+		t.Fatalf("expected a truncated header to be rejected")
+	}
+}
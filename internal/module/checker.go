@@ -10,19 +10,38 @@ import (
 
 func Check(name string) error {
 	normalized := strings.ToLower(name)
-	moduleDir, err := resolveModuleDir(normalized)
+	moduleDir, err := resolveVerifiedModuleDir(normalized)
 	if err != nil {
 		return err
 	}
 	return runModuleCheck(name, moduleDir)
 }
 
+// LoadManifest reads the manifest of an installable module by name.
+func LoadManifest(name string) (Manifest, error) {
+	moduleDir, err := resolveModuleDir(strings.ToLower(strings.TrimSpace(name)))
+	if err != nil {
+		return Manifest{}, err
+	}
+	return LoadManifestFromDir(moduleDir)
+}
+
+// LoadManifestFromDir loads the manifest of the module in moduleDir.
+func LoadManifestFromDir(moduleDir string) (Manifest, error) {
+	record, err := LoadModuleRecord(filepath.Join(moduleDir, "manifest.yaml"))
+	if err != nil {
+		return Manifest{}, err
+	}
+	return record.Manifest, nil
+}
+
 func resolveModuleDir(name string) (string, error) {
 	roots := []string{"."}
 	if exePath, err := os.Executable(); err == nil {
 		exeDir := filepath.Dir(exePath)
 		roots = append(roots, exeDir, filepath.Dir(exeDir))
 	}
+	builtinDir := ""
 	for _, root := range roots {
 		moduleDir := filepath.Join(root, "modules", name)
 		manifestPath := filepath.Join(moduleDir, "manifest.yaml")
@@ -31,11 +50,18 @@ func resolveModuleDir(name string) (string, error) {
 			if err != nil {
 				return "", i18n.Errorf("failed to resolve module path for %q: %w", name, err)
 			}
-			return absDir, nil
+			builtinDir = absDir
+			break
 		} else if !os.IsNotExist(err) {
 			return "", i18n.Errorf("failed to read module config for %q: %w", name, err)
 		}
 	}
+	if moduleDir, ok := sourceModuleDir(name, builtinDir); ok {
+		return moduleDir, nil
+	}
+	if builtinDir != "" {
+		return builtinDir, nil
+	}
 	return "", i18n.Errorf("module %q not found", name)
 }
 
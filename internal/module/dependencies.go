@@ -7,7 +7,10 @@ import (
 	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
 )
 
-var constraintPattern = regexp.MustCompile(`^(==|=|!=|>=|<=|>|<)?\s*([0-9]+(?:\.[0-9]+){0,2})$`)
+var (
+	comparatorPattern = regexp.MustCompile(`^(==|=|!=|>=|<=|>|<|\^|~)?\s*(\S+)$`)
+	wildcardPattern   = regexp.MustCompile(`^(?:(\d+)\.)?(?:(\d+)\.)?[xX*]$`)
+)
 
 func ParseModuleDependency(value string) (string, *VersionConstraint, error) {
 	parts := strings.SplitN(value, "@", 2)
@@ -25,22 +28,74 @@ func ParseModuleDependency(value string) (string, *VersionConstraint, error) {
 	return name, &constraint, nil
 }
 
+// ParseConstraint parses a version range such as ">=1.2,<2.0", "^1.4",
+// "~0.5.1" or "1.2.*". A bare version means exactly that version.
 func ParseConstraint(value string) (VersionConstraint, error) {
 	if value == "" {
 		return VersionConstraint{}, i18n.Errorf("constraint is required")
 	}
-	matches := constraintPattern.FindStringSubmatch(value)
+	var constraint VersionConstraint
+	for _, term := range strings.Split(value, ",") {
+		term = strings.TrimSpace(term)
+		comparators, err := parseConstraintTerm(term)
+		if err != nil {
+			return VersionConstraint{}, i18n.Errorf("invalid constraint %q: %w", value, err)
+		}
+		constraint.comparators = append(constraint.comparators, comparators...)
+	}
+	return constraint, nil
+}
+
+func parseConstraintTerm(term string) ([]comparator, error) {
+	matches := comparatorPattern.FindStringSubmatch(term)
 	if matches == nil {
-		return VersionConstraint{}, i18n.Errorf("invalid constraint %q", value)
+		return nil, i18n.Errorf("empty or malformed range %q", term)
 	}
-	operator := matches[1]
-	versionValue := matches[2]
-	parsed, err := ParseVersion(versionValue)
-	if err != nil {
-		return VersionConstraint{}, err
+	operator, versionValue := matches[1], matches[2]
+
+	if wildcard := wildcardPattern.FindStringSubmatch(versionValue); wildcard != nil {
+		if operator != "" && operator != "=" && operator != "==" {
+			return nil, i18n.Errorf("operator %s cannot be used with wildcard %q", operator, versionValue)
+		}
+		if wildcard[1] == "" {
+			return nil, nil
+		}
+		prefix := wildcard[1]
+		if wildcard[2] != "" {
+			prefix += "." + wildcard[2]
+		}
+		return rangeFrom(prefix, "~")
 	}
-	if operator == "" {
+
+	switch operator {
+	case "^", "~":
+		return rangeFrom(versionValue, operator)
+	case "":
 		operator = "="
 	}
-	return VersionConstraint{Operator: operator, Version: parsed}, nil
+	version, err := ParseVersion(versionValue)
+	if err != nil {
+		return nil, err
+	}
+	return []comparator{{Operator: operator, Version: version}}, nil
+}
+
+// rangeFrom expands caret and tilde ranges into a lower and an upper bound.
+// ^ allows changes that keep the left-most non-zero segment, ~ allows patch
+// changes, or minor ones when only the major version is given.
+func rangeFrom(value, operator string) ([]comparator, error) {
+	lower, segments, err := parseVersionSegments(value)
+	if err != nil {
+		return nil, err
+	}
+	upper := Version{Major: lower.Major + 1}
+	switch {
+	case operator == "^" && lower.Major == 0 && segments >= 2 && (lower.Minor > 0 || segments == 2):
+		upper = Version{Minor: lower.Minor + 1}
+	case operator == "^" && lower.Major == 0 && segments == 3:
+		upper = Version{Patch: lower.Patch + 1}
+	case operator == "~" && segments >= 2:
+		upper = Version{Major: lower.Major, Minor: lower.Minor + 1}
+	}
+	return []comparator{{Operator: ">=", Version: lower}, {Operator: "<", Version: upper}}, nil
 }
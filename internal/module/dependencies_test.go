@@ -0,0 +1,90 @@
+package module
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConstraintMatching(t *testing.T) {
+	cases := []struct {
+		constraint string
+		match      []string
+		reject     []string
+	}{
+		{">=1.2,<2.0", []string{"1.2.0", "1.9.9"}, []string{"1.1.9", "2.0.0", "2.0.0-rc.1"}},
+		{"^1.4", []string{"1.4.0", "1.9.0"}, []string{"1.3.9", "2.0.0"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0", "0.2.2"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"~0.5.1", []string{"0.5.1", "0.5.7"}, []string{"0.6.0", "0.5.0"}},
+		{"~1", []string{"1.0.0", "1.8.0"}, []string{"2.0.0"}},
+		{"1.2.*", []string{"1.2.0", "1.2.15"}, []string{"1.3.0", "1.1.0"}},
+		{"1.x", []string{"1.0.0", "1.99.0"}, []string{"2.0.0"}},
+		{"*", []string{"0.0.1", "7.0.0"}, []string{"7.0.0-beta"}},
+		{"1.2", []string{"1.2.0", "1.2.0+build.5"}, []string{"1.2.1"}},
+		{">=2.0.0-rc.1", []string{"2.0.0-rc.1", "2.0.0-rc.2", "2.0.0", "2.1.0"}, []string{"2.1.0-rc.1", "2.0.0-beta"}},
+	}
+	for _, tc := range cases {
+		constraint, err := ParseConstraint(tc.constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q) returned error: %v", tc.constraint, err)
+		}
+		for _, value := range tc.match {
+			version, err := ParseVersion(value)
+			if err != nil {
+				t.Fatalf("ParseVersion(%q) returned error: %v", value, err)
+			}
+			if !constraint.Match(version) {
+				t.Errorf("expected %q to match %s", tc.constraint, value)
+			}
+		}
+		for _, value := range tc.reject {
+			version, err := ParseVersion(value)
+			if err != nil {
+				t.Fatalf("ParseVersion(%q) returned error: %v", value, err)
+			}
+			if constraint.Match(version) {
+				t.Errorf("expected %q to reject %s", tc.constraint, value)
+			}
+		}
+	}
+
+	for _, invalid := range []string{">=1.2,", "^", ">=1.x", "1.2.3.4", "latest"} {
+		if _, err := ParseConstraint(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestVersionPrecedence(t *testing.T) {
+	ordered := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0"}
+	for i := 1; i < len(ordered); i++ {
+		lower, _ := ParseVersion(ordered[i-1])
+		higher, _ := ParseVersion(ordered[i])
+		if lower.Compare(higher) >= 0 {
+			t.Errorf("expected %s < %s", ordered[i-1], ordered[i])
+		}
+	}
+	withBuild, _ := ParseVersion("1.0.0+20240101")
+	plain, _ := ParseVersion("1.0.0")
+	if withBuild.Compare(plain) != 0 || withBuild.String() != "1.0.0+20240101" {
+		t.Fatalf("expected build metadata to be ignored when comparing, got %s", withBuild)
+	}
+}
+
+func TestValidateManifestReportsInvalidConstraints(t *testing.T) {
+	manifest := Manifest{
+		Name:         "vllm",
+		Category:     CategoryRuntime,
+		Version:      "0.6.0",
+		Description:  "vLLM",
+		Runtime:      RuntimeConfig{Modes: []string{"native"}},
+		Dependencies: Dependencies{Modules: []string{"python@^3.10", "llama.cpp@>=1.2,<"}},
+	}
+	err := ValidateManifest(manifest)
+	if err == nil || !strings.Contains(err.Error(), `invalid module dependency "llama.cpp@>=1.2,<"`) {
+		t.Fatalf("expected the invalid range to be reported, got %v", err)
+	}
+	if strings.Contains(err.Error(), "python") {
+		t.Fatalf("expected the valid caret range to pass, got %v", err)
+	}
+}
@@ -24,10 +24,17 @@ import (
 type moduleInstallSpec struct {
 	SupportedPlatforms []string                 `yaml:"supported_platforms"`
 	InstallModes       []string                 `yaml:"install_modes"`
+	RebuildModes       []string                 `yaml:"rebuild_modes"`
+	EnvironmentRebuild environmentRebuild       `yaml:"environment_rebuild"`
 	DecisionMatrix     installDecisionMatrix    `yaml:"decision_matrix"`
 	Preconditions      []installPrecondition    `yaml:"preconditions"`
 	Install            map[string][]installStep `yaml:"install"`
 	Configuration      installConfiguration     `yaml:"configuration"`
+	Rollback           installRollback          `yaml:"rollback"`
+}
+
+type installRollback struct {
+	Script string `yaml:"script"`
 }
 
 type installDecisionMatrix struct {
@@ -47,26 +54,28 @@ type installPrecondition struct {
 }
 
 type installStep struct {
-	ID         string        `yaml:"id"`
-	Intent     string        `yaml:"intent"`
-	Tool       string        `yaml:"tool"`
-	Command    string        `yaml:"command"`
-	Edit       installEdit   `yaml:"edit"`
-	Expected   installExpect `yaml:"expected"`
-	Idempotent bool          `yaml:"idempotent"`
+	ID         string        `yaml:"id" json:"id"`
+	Intent     string        `yaml:"intent" json:"intent,omitempty"`
+	Tool       string        `yaml:"tool" json:"tool"`
+	Command    string        `yaml:"command" json:"command,omitempty"`
+	Edit       installEdit   `yaml:"edit" json:"edit,omitempty"`
+	Expected   installExpect `yaml:"expected" json:"expected,omitempty"`
+	Idempotent bool          `yaml:"idempotent" json:"idempotent,omitempty"`
+	// Undo is a shell command that reverts the step during rollback.
+	Undo string `yaml:"undo" json:"undo,omitempty"`
 }
 
 type installEdit struct {
-	Template    string `yaml:"template"`
-	Destination string `yaml:"destination"`
+	Template    string `yaml:"template" json:"template,omitempty"`
+	Destination string `yaml:"destination" json:"destination,omitempty"`
 }
 
 type installExpect struct {
-	Equals   string `yaml:"equals"`
-	ExitCode *int   `yaml:"exit_code"`
-	Bin      string `yaml:"bin"`
-	Unit     string `yaml:"unit"`
-	Service  string `yaml:"service"`
+	Equals   string `yaml:"equals" json:"equals,omitempty"`
+	ExitCode *int   `yaml:"exit_code" json:"exit_code,omitempty"`
+	Bin      string `yaml:"bin" json:"bin,omitempty"`
+	Unit     string `yaml:"unit" json:"unit,omitempty"`
+	Service  string `yaml:"service" json:"service,omitempty"`
 }
 
 type llmInstallPlan struct {
@@ -121,17 +130,58 @@ type installPlannerConditionHint struct {
 	Command string `json:"command,omitempty"`
 }
 
-func Install(name string) (retErr error) {
+// InstallOptions tunes InstallWithOptions.
+type InstallOptions struct {
+	Rebuild RebuildMode
+	// Confirm is asked before cleanup steps run, with the intents of the
+	// detect steps that found an existing installation. A nil Confirm
+	// proceeds without asking.
+	Confirm func(detected []string) bool
+	// Resume continues the journaled install that failed last time.
+	Resume bool
+	// NoRollback leaves a failed install in place instead of rolling it back.
+	NoRollback bool
+	// ModuleDir installs from this module directory instead of the one the
+	// name resolves to, e.g. the version picked by the dependency resolver.
+	ModuleDir string
+}
+
+func Install(name string) error {
+	return InstallWithOptions(name, InstallOptions{})
+}
+
+// InstallWithOptions installs a module, first cleaning up an existing
+// installation when opts.Rebuild is soft or full. Progress is journaled so
+// that a failed install is rolled back, or resumed with opts.Resume.
+func InstallWithOptions(name string, opts InstallOptions) (retErr error) {
 	normalized := strings.ToLower(strings.TrimSpace(name))
 	if normalized == "" {
 		return i18n.Errorf("module name is required")
 	}
 	plannerProvider := ""
 	plannerModel := ""
+	var journal *installJournal
+	rollback := rollbackResult{Method: rollbackNone}
 	defer func() {
 		if retErr == nil {
 			return
 		}
+		context := map[string]any{
+			"entry":   "module.Install",
+			"rebuild": string(opts.Rebuild),
+			"resume":  opts.Resume,
+		}
+		if journal != nil {
+			context["failed_step"] = journal.FailedStep
+			context["completed_steps"] = journalStepIDs(journal.Completed)
+			context["rollback"] = rollback.Method
+			if len(rollback.Kept) > 0 {
+				context["rollback_kept_steps"] = rollback.Kept
+			}
+			if rollback.Err != nil {
+				context["rollback_error"] = rollback.Err.Error()
+			}
+		}
 		classification, advice, logPath := failure.RecordWithResultBestEffort(failure.Event{
 			Phase:    inferInstallFailurePhase(retErr),
 			Module:   normalized,
@@ -139,16 +189,23 @@ func Install(name string) (retErr error) {
 			Provider: plannerProvider,
 			Error:    retErr.Error(),
 			Message:  "module install failed",
-			Context: map[string]any{
-				"entry": "module.Install",
-			},
+			Context:  context,
 		})
 		if failure.FailureDebugEnabled() {
 			fmt.Printf("Failure handling: phase=%s category=%s retryable=%t log=%s suggestion=%s\n",
 				inferInstallFailurePhase(retErr), classification.Category, advice.Retryable, fallbackString(logPath, "n/a"), advice.Suggestion)
 		}
 	}()
-	moduleDir, err := resolveModuleDir(normalized)
+	if opts.Resume && opts.Rebuild != "" && opts.Rebuild != RebuildNone {
+		return i18n.Errorf("--resume cannot be combined with --rebuild")
+	}
+	var err error
+	moduleDir := opts.ModuleDir
+	if moduleDir == "" {
+		moduleDir, err = resolveVerifiedModuleDir(normalized)
+	} else {
+		err = verifyModuleDir(normalized, moduleDir)
+	}
 	if err != nil {
 		return err
 	}
@@ -180,14 +237,79 @@ func Install(name string) (retErr error) {
 		return i18n.Errorf("install plan for module %q has no steps for mode %q", normalized, mode)
 	}
 
+	vars := flattenDefaults(spec.Configuration.Defaults)
+	var planSteps []installStep
+	if opts.Resume {
+		resumed, err := loadInstallJournal(normalized)
+		if err != nil {
+			return err
+		}
+		if planSteps, err = resumed.resumeSteps(spec); err != nil {
+			return err
+		}
+		journal = resumed
+		journal.Status = journalInProgress
+		journal.FailedStep = ""
+		journal.Error = ""
+	} else {
+		if err := rebuildEnvironment(normalized, moduleDir, spec, opts, vars, env); err != nil {
+			return err
+		}
+		var plannerMode string
+		planSteps, plannerMode, plannerProvider, plannerModel, err = planInstallSteps(normalized, spec, mode, steps, env)
+		if err != nil {
+			return err
+		}
+		journal = newInstallJournal(normalized, plannerMode, planSteps)
+	}
+	saveJournalBestEffort(journal)
+
+	for _, step := range planSteps {
+		if journal.isCompleted(step.ID) {
+			continue
+		}
+		if err := runInstallStep(normalized, moduleDir, step, vars, env); err != nil {
+			journal.Status = journalFailed
+			journal.FailedStep = step.ID
+			journal.Error = err.Error()
+			if !opts.NoRollback {
+				fmt.Printf("%s\n", i18n.T("Install step %s failed, rolling back module %s", step.ID, normalized))
+				rollback = rollbackInstall(moduleDir, spec, journal, env)
+				journal.Rollback = rollback.Method
+				switch {
+				case rollback.Err != nil || rollback.Method == rollbackNone:
+				case len(rollback.Kept) > 0:
+					journal.Status = journalPartiallyRolledBack
+					fmt.Printf("%s\n", i18n.T("Warning: steps %s of module %s have no undo and were left in place", strings.Join(rollback.Kept, ", "), normalized))
+				default:
+					journal.Status = journalRolledBack
+				}
+			}
+			saveJournalBestEffort(journal)
+			return err
+		}
+		journal.complete(step)
+		saveJournalBestEffort(journal)
+	}
+	journal.Status = journalCompleted
+	saveJournalBestEffort(journal)
+	return nil
+}
+
+// planInstallSteps lets the configured LLM select the steps to run and falls
+// back to the static plan. It returns the steps, their mode and the planner
+// provider and model.
+func planInstallSteps(moduleName string, spec moduleInstallSpec, mode string, steps []installStep, env map[string]string) ([]installStep, string, string, string, error) {
 	planSteps := steps
 	plannerSource := "static"
 	plannerErr := ""
 	plannerMode := mode
+	plannerProvider := ""
+	plannerModel := ""
 	if cfg, cfgErr := config.LoadConfig(); cfgErr == nil {
 		plannerProvider = strings.TrimSpace(cfg.LLM.Provider)
 		plannerModel = strings.TrimSpace(cfg.LLM.Model)
-		if llmPlan, err := interpretInstallPlanWithLLM(cfg.LLM, normalized, spec, mode, steps); err == nil {
+		if llmPlan, err := interpretInstallPlanWithLLM(cfg.LLM, moduleName, spec, mode, steps); err == nil {
 			resolvedMode, resolvedSteps, applyErr := applyLLMInstallPlan(spec, mode, steps, env, llmPlan)
 			if applyErr == nil {
 				plannerMode = resolvedMode
@@ -213,16 +335,9 @@ func Install(name string) (retErr error) {
 		if strings.TrimSpace(plannerErr) == "" {
 			plannerErr = "LLM install planner did not produce a valid plan"
 		}
-		return i18n.Errorf("install planner strict mode: %s", plannerErr)
+		return nil, "", plannerProvider, plannerModel, i18n.Errorf("install planner strict mode: %s", plannerErr)
 	}
-
-	vars := flattenDefaults(spec.Configuration.Defaults)
-	for _, step := range planSteps {
-		if err := runInstallStep(normalized, moduleDir, step, vars, env); err != nil {
-			return err
-		}
-	}
-	return nil
+	return planSteps, plannerMode, plannerProvider, plannerModel, nil
 }
 
 func fallbackString(value, fallback string) string {
@@ -298,16 +413,15 @@ func selectInstallModeForSystem(moduleName string, spec moduleInstallSpec) (stri
 		return mode, env
 	}
 
-	baseInfoPath := resolveBaseInfoPath()
-	baseInfo, err := system.LoadBaseInfoSummary(baseInfoPath)
+	baseInfo, err := system.LoadHardwareSummary(system.ResolveBaseInfoPath())
 	if err != nil {
 		return mode, env
 	}
 
-	if strings.TrimSpace(baseInfo.GPUName) != "" {
+	if baseInfo.HasCUDAGPU() {
 		mode = "source"
 		env["LLAMA_CUDA"] = "1"
-		if archs := detectCudaArchs(baseInfo.GPUName); archs != "" {
+		if archs := detectCudaArchs(baseInfo); archs != "" {
 			env["LLAMA_CUDA_ARCHS"] = archs
 		}
 	}
@@ -850,24 +964,14 @@ func formatEnv(values map[string]string) []string {
 	return env
 }
 
-func resolveBaseInfoPath() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return filepath.Join(".", "base_info.json")
-	}
-	primary := filepath.Join(home, ".localaistack", "base_info.json")
-	if _, err := os.Stat(primary); err == nil {
-		return primary
-	}
-	alternate := filepath.Join(home, ".localiastack", "base_info.json")
-	if _, err := os.Stat(alternate); err == nil {
-		return alternate
+func detectCudaArchs(info system.BaseInfoSummary) string {
+	if capability := strings.TrimSpace(info.GPUComputeCapability); capability != "" {
+		if major, minor, ok := strings.Cut(capability, "."); ok {
+			return major + minor
+		}
+		return capability
 	}
-	return primary
-}
-
-func detectCudaArchs(gpuName string) string {
-	name := strings.ToLower(strings.TrimSpace(gpuName))
+	name := strings.ToLower(strings.TrimSpace(info.GPUName))
 	switch {
 	case strings.Contains(name, "v100"):
 		return "70"
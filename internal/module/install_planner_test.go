@@ -1,6 +1,10 @@
 package module
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/system"
+)
 
 func TestParseLLMInstallPlanFromMarkdownJSON(t *testing.T) {
 	text := "```json\n{\"mode\":\"native\",\"steps\":[\"a\",\"a\",\"b\"]}\n```"
@@ -132,3 +136,12 @@ func TestBuildInstallPlannerInputIncludesModeCatalogAndSummary(t *testing.T) {
 		t.Fatalf("unexpected precondition hints: %+v", input.Preconditions)
 	}
 }
+
+func TestDetectCudaArchsPrefersComputeCapability(t *testing.T) {
+	if got := detectCudaArchs(system.BaseInfoSummary{GPUName: "NVIDIA L40S", GPUComputeCapability: "8.9"}); got != "89" {
+		t.Fatalf("expected 89 from compute capability, got %q", got)
+	}
+	if got := detectCudaArchs(system.BaseInfoSummary{GPUName: "Tesla V100-SXM2-16GB"}); got != "70" {
+		t.Fatalf("expected name fallback 70, got %q", got)
+	}
+}
@@ -0,0 +1,222 @@
+package module
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+)
+
+const (
+	journalInProgress          = "in_progress"
+	journalFailed              = "failed"
+	journalRolledBack          = "rolled_back"
+	journalPartiallyRolledBack = "partially_rolled_back"
+	journalCompleted           = "completed"
+)
+
+// installJournal records the progress of a module install so that a failed
+// install can be rolled back or resumed. Steps holds the planned steps as
+// they were run, so a resume runs the same commands even if the planner or
+// INSTALL.yaml would now choose differently.
+type installJournal struct {
+	Module     string         `json:"module"`
+	Mode       string         `json:"mode"`
+	Plan       []string       `json:"plan"`
+	Steps      []installStep  `json:"steps,omitempty"`
+	Completed  []journalEntry `json:"completed"`
+	Status     string         `json:"status"`
+	FailedStep string         `json:"failed_step,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	Rollback   string         `json:"rollback,omitempty"`
+	StartedAt  time.Time      `json:"started_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+type journalEntry struct {
+	ID          string    `json:"id"`
+	Idempotent  bool      `json:"idempotent"`
+	Undo        string    `json:"undo,omitempty"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+func installJournalPath(moduleName string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".localaistack", "install-journal", moduleName+".json"), nil
+}
+
+func newInstallJournal(moduleName, mode string, steps []installStep) *installJournal {
+	now := time.Now().UTC()
+	return &installJournal{
+		Module:    moduleName,
+		Mode:      mode,
+		Plan:      stepIDs(steps),
+		Steps:     steps,
+		Status:    journalInProgress,
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+func loadInstallJournal(moduleName string) (*installJournal, error) {
+	path, err := installJournalPath(moduleName)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, i18n.Errorf("no install journal found for module %q", moduleName)
+		}
+		return nil, i18n.Errorf("failed to read install journal for module %q: %w", moduleName, err)
+	}
+	var journal installJournal
+	if err := json.Unmarshal(raw, &journal); err != nil {
+		return nil, i18n.Errorf("failed to parse install journal for module %q: %w", moduleName, err)
+	}
+	return &journal, nil
+}
+
+func (j *installJournal) save() error {
+	path, err := installJournalPath(j.Module)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	j.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (j *installJournal) complete(step installStep) {
+	j.Completed = append(j.Completed, journalEntry{
+		ID:          step.ID,
+		Idempotent:  step.Idempotent,
+		Undo:        strings.TrimSpace(step.Undo),
+		CompletedAt: time.Now().UTC(),
+	})
+}
+
+func (j *installJournal) isCompleted(id string) bool {
+	for _, entry := range j.Completed {
+		if entry.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// resumeSteps returns the journaled plan. Journals written before the steps
+// were recorded are matched against the install spec by step ID. Only a
+// failed install whose completed steps are all idempotent can be resumed.
+func (j *installJournal) resumeSteps(spec moduleInstallSpec) ([]installStep, error) {
+	switch j.Status {
+	case journalCompleted:
+		return nil, i18n.Errorf("install of module %q already completed; nothing to resume", j.Module)
+	case journalRolledBack, journalPartiallyRolledBack:
+		return nil, i18n.Errorf("install of module %q was rolled back; run a fresh install", j.Module)
+	}
+	for _, entry := range j.Completed {
+		if !entry.Idempotent {
+			return nil, i18n.Errorf("cannot resume install of module %q: completed step %s is not idempotent", j.Module, entry.ID)
+		}
+	}
+
+	if len(j.Steps) > 0 {
+		return j.Steps, nil
+	}
+	byID := make(map[string]installStep)
+	for _, step := range spec.Install[j.Mode] {
+		byID[strings.TrimSpace(step.ID)] = step
+	}
+	steps := make([]installStep, 0, len(j.Plan))
+	for _, id := range j.Plan {
+		step, ok := byID[id]
+		if !ok {
+			return nil, i18n.Errorf("cannot resume install of module %q: step %s is no longer in the install plan", j.Module, id)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+const (
+	rollbackNone   = "none"
+	rollbackUndo   = "undo"
+	rollbackScript = "script"
+)
+
+type rollbackResult struct {
+	Method string
+	// Kept lists the completed steps that could not be reverted.
+	Kept []string
+	Err  error
+}
+
+// rollbackInstall reverts the completed steps of a failed install. Step-level
+// undo commands are used when every completed step has one; otherwise the
+// module's rollback script runs. Without a script, the undo commands that
+// exist are run and the other steps are reported as kept.
+func rollbackInstall(moduleDir string, spec moduleInstallSpec, journal *installJournal, env map[string]string) rollbackResult {
+	var undo []journalEntry
+	for i := len(journal.Completed) - 1; i >= 0; i-- {
+		if journal.Completed[i].Undo != "" {
+			undo = append(undo, journal.Completed[i])
+		}
+	}
+	var kept []string
+	for _, entry := range journal.Completed {
+		if entry.Undo == "" {
+			kept = append(kept, entry.ID)
+		}
+	}
+	script := strings.TrimSpace(spec.Rollback.Script)
+	if len(undo) > 0 && (len(kept) == 0 || script == "") {
+		var errs []error
+		for _, entry := range undo {
+			if _, _, err := runShellCommandWithEnv(entry.Undo, moduleDir, true, env); err != nil {
+				errs = append(errs, i18n.Errorf("undo of step %s failed: %w", entry.ID, err))
+			}
+		}
+		return rollbackResult{Method: rollbackUndo, Kept: kept, Err: errors.Join(errs...)}
+	}
+
+	if script == "" {
+		return rollbackResult{Method: rollbackNone}
+	}
+	if _, err := runModuleScript(absoluteModuleScriptPath(moduleDir, script), moduleDir, nil, env, true); err != nil {
+		return rollbackResult{Method: rollbackScript, Err: i18n.Errorf("rollback script failed: %w", err)}
+	}
+	return rollbackResult{Method: rollbackScript}
+}
+
+func journalStepIDs(entries []journalEntry) []string {
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
+
+func saveJournalBestEffort(journal *installJournal) {
+	if err := journal.save(); err != nil {
+		fmt.Printf("%s\n", i18n.T("Warning: failed to write install journal: %v", err))
+	}
+}
@@ -0,0 +1,212 @@
+package module
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+const journalTestInstallPlan = `supported_platforms: []
+install_modes:
+  - native
+install:
+  native:
+    - id: S10
+      intent: Create artifact
+      tool: shell
+      command: echo run >> s10.log && touch artifact
+      undo: rm -f artifact
+      idempotent: true
+    - id: S20
+      intent: Needs approval
+      tool: shell
+      command: test -f approved
+      idempotent: true
+`
+
+func setupJournalTestModule(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses bash commands")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("LOCALAISTACK_CONFIG", filepath.Join(home, "missing.yaml"))
+
+	root := t.TempDir()
+	moduleDir := filepath.Join(root, "modules", "demo")
+	if err := os.MkdirAll(moduleDir, 0o755); err != nil {
+		t.Fatalf("create module dir: %v", err)
+	}
+	files := map[string]string{
+		"manifest.yaml": "name: demo\n",
+		"INSTALL.yaml":  journalTestInstallPlan,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(moduleDir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	t.Chdir(root)
+	return moduleDir
+}
+
+func TestInstallRollsBackCompletedSteps(t *testing.T) {
+	moduleDir := setupJournalTestModule(t)
+
+	if err := Install("demo"); err == nil {
+		t.Fatalf("expected install to fail at S20")
+	}
+	if _, err := os.Stat(filepath.Join(moduleDir, "artifact")); !os.IsNotExist(err) {
+		t.Fatalf("expected the undo of S10 to remove the artifact")
+	}
+	journal, err := loadInstallJournal("demo")
+	if err != nil {
+		t.Fatalf("loadInstallJournal returned error: %v", err)
+	}
+	if journal.Status != journalRolledBack || journal.FailedStep != "S20" || journal.Rollback != rollbackUndo {
+		t.Fatalf("unexpected journal: %+v", journal)
+	}
+	if err := InstallWithOptions("demo", InstallOptions{Resume: true}); err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("expected a rolled back install not to be resumable, got %v", err)
+	}
+
+	home, _ := os.UserHomeDir()
+	data, err := os.ReadFile(filepath.Join(home, ".localaistack", "failures", time.Now().Format("20060102")+".jsonl"))
+	if err != nil {
+		t.Fatalf("read failure log: %v", err)
+	}
+	if !strings.Contains(string(data), `"rollback":"undo"`) || !strings.Contains(string(data), `"failed_step":"S20"`) {
+		t.Fatalf("expected the rollback outcome in the failure event, got %s", data)
+	}
+}
+
+func TestInstallResumesFromLastGoodStep(t *testing.T) {
+	moduleDir := setupJournalTestModule(t)
+
+	if err := InstallWithOptions("demo", InstallOptions{NoRollback: true}); err == nil {
+		t.Fatalf("expected install to fail at S20")
+	}
+	if _, err := os.Stat(filepath.Join(moduleDir, "artifact")); err != nil {
+		t.Fatalf("expected --no-rollback to keep the artifact: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(moduleDir, "approved"), nil, 0o644); err != nil {
+		t.Fatalf("write approval: %v", err)
+	}
+	if err := InstallWithOptions("demo", InstallOptions{Resume: true}); err != nil {
+		t.Fatalf("resume returned error: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(moduleDir, "s10.log"))
+	if runs := strings.Count(string(data), "run"); runs != 1 {
+		t.Fatalf("expected S10 to be skipped on resume, ran %d times", runs)
+	}
+	journal, err := loadInstallJournal("demo")
+	if err != nil || journal.Status != journalCompleted || len(journal.Completed) != 2 {
+		t.Fatalf("unexpected journal after resume: %+v (%v)", journal, err)
+	}
+}
+
+func TestResumeRequiresIdempotentSteps(t *testing.T) {
+	journal := &installJournal{
+		Module:    "demo",
+		Mode:      "native",
+		Plan:      []string{"S10", "S20"},
+		Status:    journalFailed,
+		Completed: []journalEntry{{ID: "S10"}},
+	}
+	spec := moduleInstallSpec{Install: map[string][]installStep{"native": {{ID: "S10"}, {ID: "S20"}}}}
+	if _, err := journal.resumeSteps(spec); err == nil || !strings.Contains(err.Error(), "not idempotent") {
+		t.Fatalf("expected non-idempotent step to block resume, got %v", err)
+	}
+	journal.Completed[0].Idempotent = true
+	steps, err := journal.resumeSteps(spec)
+	if err != nil || len(steps) != 2 {
+		t.Fatalf("unexpected resume steps %v (%v)", steps, err)
+	}
+}
+
+func TestRollbackWithStepsLackingUndo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses bash commands")
+	}
+	moduleDir := t.TempDir()
+	artifact := filepath.Join(moduleDir, "artifact")
+	journal := &installJournal{Module: "demo", Completed: []journalEntry{
+		{ID: "S10", Undo: "rm -f artifact"},
+		{ID: "S15"},
+	}}
+
+	if err := os.WriteFile(artifact, nil, 0o644); err != nil {
+		t.Fatalf("write artifact: %v", err)
+	}
+	result := rollbackInstall(moduleDir, moduleInstallSpec{}, journal, nil)
+	if result.Method != rollbackUndo || result.Err != nil || strings.Join(result.Kept, ",") != "S15" {
+		t.Fatalf("expected a partial undo that keeps S15, got %+v", result)
+	}
+	if _, err := os.Stat(artifact); !os.IsNotExist(err) {
+		t.Fatalf("expected the undo of S10 to run")
+	}
+
+	if err := os.WriteFile(filepath.Join(moduleDir, "rollback.sh"), []byte("touch rolled-back\n"), 0o644); err != nil {
+		t.Fatalf("write rollback script: %v", err)
+	}
+	spec := moduleInstallSpec{Rollback: installRollback{Script: "rollback.sh"}}
+	result = rollbackInstall(moduleDir, spec, journal, nil)
+	if result.Method != rollbackScript || result.Err != nil || len(result.Kept) != 0 {
+		t.Fatalf("expected the rollback script to cover steps without undo, got %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(moduleDir, "rolled-back")); err != nil {
+		t.Fatalf("expected the rollback script to run: %v", err)
+	}
+}
+
+func TestInstallReportsPartialRollback(t *testing.T) {
+	moduleDir := setupJournalTestModule(t)
+	plan := strings.Replace(journalTestInstallPlan, "    - id: S20", `    - id: S15
+      intent: Write config
+      tool: shell
+      command: touch config
+      idempotent: true
+    - id: S20`, 1)
+	if err := os.WriteFile(filepath.Join(moduleDir, "INSTALL.yaml"), []byte(plan), 0o644); err != nil {
+		t.Fatalf("write install plan: %v", err)
+	}
+
+	if err := Install("demo"); err == nil {
+		t.Fatalf("expected install to fail at S20")
+	}
+	journal, err := loadInstallJournal("demo")
+	if err != nil {
+		t.Fatalf("loadInstallJournal returned error: %v", err)
+	}
+	if journal.Status != journalPartiallyRolledBack || journal.Rollback != rollbackUndo {
+		t.Fatalf("expected a partial rollback, got %+v", journal)
+	}
+	if _, err := os.Stat(filepath.Join(moduleDir, "config")); err != nil {
+		t.Fatalf("expected the step without undo to be left in place: %v", err)
+	}
+	if err := InstallWithOptions("demo", InstallOptions{Resume: true}); err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("expected a partially rolled back install not to be resumable, got %v", err)
+	}
+}
+
+func TestResumeUsesJournaledSteps(t *testing.T) {
+	journal := &installJournal{
+		Module:    "demo",
+		Mode:      "native",
+		Plan:      []string{"S10", "S30"},
+		Steps:     []installStep{{ID: "S10", Command: "true", Idempotent: true}, {ID: "S30", Command: "echo planned"}},
+		Status:    journalFailed,
+		Completed: []journalEntry{{ID: "S10", Idempotent: true}},
+	}
+	spec := moduleInstallSpec{Install: map[string][]installStep{"native": {{ID: "S10"}, {ID: "S20"}}}}
+	steps, err := journal.resumeSteps(spec)
+	if err != nil || len(steps) != 2 || steps[1].Command != "echo planned" {
+		t.Fatalf("expected the steps that were planned, got %+v (%v)", steps, err)
+	}
+}
@@ -0,0 +1,333 @@
+package module
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"gopkg.in/yaml.v3"
+)
+
+// LintIssue is one problem found in a module file.
+type LintIssue struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (issue LintIssue) String() string {
+	if issue.Line == 0 {
+		return fmt.Sprintf("%s: %s", issue.File, issue.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", issue.File, issue.Line, issue.Column, issue.Message)
+}
+
+var (
+	yamlErrorLine       = regexp.MustCompile(`line (\d+)`)
+	moduleFileReference = regexp.MustCompile(`(?:^|[\s"'=])((?:scripts|templates)/[A-Za-z0-9._/-]+)`)
+)
+
+type moduleLinter struct {
+	dir    string
+	issues []LintIssue
+}
+
+// LintModule validates manifest.yaml and INSTALL.yaml of a module directory
+// against the module schemas and the InstallSpec rules that a schema cannot
+// express. Issues are reported with paths relative to the working directory
+// when possible.
+func LintModule(moduleDir string) ([]LintIssue, error) {
+	manifestSchema, err := loadSchema("modules.schema.yaml")
+	if err != nil {
+		return nil, err
+	}
+	installSchema, err := loadSchema("installspec.schema.yaml")
+	if err != nil {
+		return nil, err
+	}
+	l := &moduleLinter{dir: moduleDir}
+
+	manifest := l.parse("manifest.yaml", manifestSchema)
+	spec := l.parse("INSTALL.yaml", installSchema)
+	if manifest != nil {
+		l.checkManifest(manifest)
+	}
+	if spec != nil {
+		l.checkInstallSpec(spec, manifest)
+	}
+	return l.issues, nil
+}
+
+func (l *moduleLinter) display(name string) string {
+	path := filepath.Join(l.dir, name)
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
+
+func (l *moduleLinter) report(file string, node *yaml.Node, format string, args ...any) {
+	issue := LintIssue{File: l.display(file), Message: i18n.T(format, args...)}
+	if node != nil {
+		issue.Line, issue.Column = node.Line, node.Column
+	}
+	l.issues = append(l.issues, issue)
+}
+
+// parse reads a module file and validates it against schema. It returns the
+// root mapping, or nil when the file is missing or not valid YAML.
+func (l *moduleLinter) parse(name string, schema *jsonSchema) *yaml.Node {
+	raw, err := os.ReadFile(filepath.Join(l.dir, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			l.report(name, nil, "file is missing")
+		} else {
+			l.report(name, nil, "%v", err)
+		}
+		return nil
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(raw, &document); err != nil {
+		issue := LintIssue{File: l.display(name), Message: err.Error()}
+		if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+			issue.Line, _ = strconv.Atoi(match[1])
+		}
+		l.issues = append(l.issues, issue)
+		return nil
+	}
+	if len(document.Content) == 0 {
+		l.report(name, nil, "file is empty")
+		return nil
+	}
+	for _, violation := range validateSchema(schema, &document) {
+		l.issues = append(l.issues, LintIssue{File: l.display(name), Line: violation.Line, Column: violation.Column, Message: violation.Message})
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil
+	}
+	return root
+}
+
+func (l *moduleLinter) checkManifest(manifest *yaml.Node) {
+	for _, dep := range sequenceItems(mappingValue(manifest, "dependencies", "modules")) {
+		if _, _, err := ParseModuleDependency(dep.Value); err != nil {
+			l.report("manifest.yaml", dep, "invalid module dependency %q: %v", dep.Value, err)
+		}
+	}
+	if version := mappingValue(manifest, "version"); version != nil {
+		if _, err := ParseVersion(version.Value); err != nil {
+			l.report("manifest.yaml", version, "%v", err)
+		}
+	}
+}
+
+func (l *moduleLinter) checkInstallSpec(spec, manifest *yaml.Node) {
+	const file = "INSTALL.yaml"
+
+	if manifest != nil {
+		for _, field := range [][2]string{{"id", "name"}, {"category", "category"}} {
+			specValue, manifestValue := mappingValue(spec, field[0]), mappingValue(manifest, field[1])
+			if specValue != nil && manifestValue != nil && specValue.Value != manifestValue.Value {
+				l.report(file, specValue, "%s %q does not match manifest.yaml %s %q", field[0], specValue.Value, field[1], manifestValue.Value)
+			}
+		}
+	}
+
+	// Declared install modes and the install section must agree.
+	declared := make(map[string]bool)
+	for _, mode := range sequenceItems(mappingValue(spec, "install_modes")) {
+		declared[mode.Value] = true
+	}
+	install := mappingValue(spec, "install")
+	for _, mode := range sequenceItems(mappingValue(spec, "install_modes")) {
+		if steps := mappingValue(install, mode.Value); steps == nil || len(steps.Content) == 0 {
+			l.report(file, mode, "install mode %q has no steps under install", mode.Value)
+		}
+	}
+	for _, entry := range mappingEntries(install) {
+		if len(declared) > 0 && !declared[entry[0].Value] {
+			l.report(file, entry[0], "install mode %q is not listed in install_modes", entry[0].Value)
+		}
+		l.checkSteps(file, entry[1], "install."+entry[0].Value)
+	}
+	if fallback := mappingValue(spec, "decision_matrix", "default"); fallback != nil && len(declared) > 0 && !declared[fallback.Value] {
+		l.report(file, fallback, "decision_matrix.default %q is not listed in install_modes", fallback.Value)
+	}
+	for _, rule := range sequenceItems(mappingValue(spec, "decision_matrix", "rules")) {
+		if use := mappingValue(rule, "use"); use != nil && len(declared) > 0 && !declared[use.Value] {
+			l.report(file, use, "decision_matrix rule uses undeclared install mode %q", use.Value)
+		}
+	}
+
+	// Rebuild modes need the cleanup they promise.
+	for _, mode := range sequenceItems(mappingValue(spec, "rebuild_modes")) {
+		section := map[string]string{"soft": "soft_cleanup", "full": "full_cleanup"}[mode.Value]
+		if section == "" {
+			continue
+		}
+		if steps := mappingValue(spec, "environment_rebuild", section); steps == nil || len(steps.Content) == 0 {
+			l.report(file, mode, "rebuild mode %q requires environment_rebuild.%s steps", mode.Value, section)
+		}
+	}
+
+	l.checkSteps(file, mappingValue(spec, "preconditions"), "preconditions")
+	rebuildIDs := make(map[string]*yaml.Node)
+	for _, section := range []string{"detect", "soft_cleanup", "full_cleanup"} {
+		l.checkStepIDs(file, mappingValue(spec, "environment_rebuild", section), rebuildIDs, "environment_rebuild")
+		l.checkStepReferences(file, mappingValue(spec, "environment_rebuild", section))
+	}
+
+	// Scripts and templates referenced by the plan must exist.
+	for _, section := range []string{"verification", "rollback", "uninstall", "purge", "update"} {
+		if script := mappingValue(spec, section, "script"); script != nil {
+			l.checkFile(file, script, script.Value)
+		}
+	}
+	for _, template := range sequenceItems(mappingValue(spec, "configuration", "templates")) {
+		l.checkFile(file, template, template.Value)
+	}
+	l.checkPlatformScripts(spec)
+}
+
+// checkSteps checks one list of steps: unique IDs, the command or edit the
+// tool needs, and the files the steps reference.
+func (l *moduleLinter) checkSteps(file string, steps *yaml.Node, scope string) {
+	l.checkStepIDs(file, steps, make(map[string]*yaml.Node), scope)
+	for _, step := range sequenceItems(steps) {
+		tool := mappingValue(step, "tool")
+		switch {
+		case tool == nil:
+		case tool.Value == "shell" && mappingValue(step, "command") == nil:
+			l.report(file, step, "step with tool shell needs a command")
+		case tool.Value == "template" && mappingValue(step, "edit") == nil:
+			l.report(file, step, "step with tool template needs an edit block")
+		}
+	}
+	l.checkStepReferences(file, steps)
+}
+
+func (l *moduleLinter) checkStepIDs(file string, steps *yaml.Node, seen map[string]*yaml.Node, scope string) {
+	for _, step := range sequenceItems(steps) {
+		id := mappingValue(step, "id")
+		if id == nil || id.Value == "" {
+			continue
+		}
+		if first, ok := seen[id.Value]; ok {
+			l.report(file, id, "duplicate step id %q in %s (first defined on line %d)", id.Value, scope, first.Line)
+			continue
+		}
+		seen[id.Value] = id
+	}
+}
+
+func (l *moduleLinter) checkStepReferences(file string, steps *yaml.Node) {
+	for _, step := range sequenceItems(steps) {
+		if template := mappingValue(step, "edit", "template"); template != nil {
+			l.checkFile(file, template, template.Value)
+		}
+		for _, key := range []string{"command", "undo"} {
+			command := mappingValue(step, key)
+			if command == nil || command.Kind != yaml.ScalarNode {
+				continue
+			}
+			for _, match := range moduleFileReference.FindAllStringSubmatch(command.Value, -1) {
+				l.checkFile(file, command, match[1])
+			}
+		}
+	}
+}
+
+func (l *moduleLinter) checkFile(file string, node *yaml.Node, relative string) {
+	relative = strings.TrimSpace(relative)
+	if relative == "" || filepath.IsAbs(relative) {
+		return
+	}
+	if _, err := os.Stat(filepath.Join(l.dir, filepath.FromSlash(relative))); err != nil {
+		l.report(file, node, "referenced file %s does not exist", relative)
+	}
+}
+
+// checkPlatformScripts requires a PowerShell variant of every referenced
+// shell script when the module supports Windows, since that is what runs
+// there.
+func (l *moduleLinter) checkPlatformScripts(spec *yaml.Node) {
+	windows := false
+	for _, platform := range sequenceItems(mappingValue(spec, "supported_platforms")) {
+		if strings.HasPrefix(platform.Value, "windows/") {
+			windows = true
+		}
+	}
+	if !windows {
+		return
+	}
+	reported := make(map[string]bool)
+	var visit func(node *yaml.Node)
+	visit = func(node *yaml.Node) {
+		if node.Kind == yaml.ScalarNode {
+			for _, match := range moduleFileReference.FindAllStringSubmatch(" "+node.Value, -1) {
+				script := match[1]
+				if !strings.HasSuffix(script, ".sh") || reported[script] {
+					continue
+				}
+				if _, err := os.Stat(filepath.Join(l.dir, filepath.FromSlash(script))); err != nil {
+					continue
+				}
+				variant := strings.TrimSuffix(script, ".sh") + ".ps1"
+				if _, err := os.Stat(filepath.Join(l.dir, filepath.FromSlash(variant))); err != nil {
+					reported[script] = true
+					l.report("INSTALL.yaml", node, "supported_platforms includes windows but %s has no %s variant", script, variant)
+				}
+			}
+			return
+		}
+		for _, child := range node.Content {
+			visit(child)
+		}
+	}
+	visit(spec)
+}
+
+// mappingValue follows keys through nested mappings.
+func mappingValue(node *yaml.Node, keys ...string) *yaml.Node {
+	for _, key := range keys {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		node = next
+	}
+	return node
+}
+
+func mappingEntries(node *yaml.Node) [][2]*yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	entries := make([][2]*yaml.Node, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		entries = append(entries, [2]*yaml.Node{node.Content[i], node.Content[i+1]})
+	}
+	return entries
+}
+
+func sequenceItems(node *yaml.Node) []*yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return node.Content
+}
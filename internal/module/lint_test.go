@@ -0,0 +1,129 @@
+package module
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const brokenInstallSpec = `apiVersion: las.installspec/v0.1.0
+kind: InstallPlan
+id: demo
+category: runtime
+supported_platforms:
+  - linux/amd64
+install_modes:
+  - native
+  - container
+install:
+  native:
+    - id: S10
+      intent: install
+      tool: shell
+      command: bash scripts/install.sh
+      expected:
+        exit_code: 0
+      idempotent: true
+    - id: S10
+      intent: configure
+      tool: template
+      edit:
+        template: templates/missing.tmpl
+        destination: /etc/demo.conf
+      expected: {}
+      idempotent: true
+      retries: 3
+verification:
+  script: scripts/verify.sh
+rollback:
+  script: scripts/rollback.sh
+uninstall:
+  script: scripts/uninstall.sh
+`
+
+func TestLintModuleReportsLines(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "manifest.yaml"), testManifest("demo", "1.0.0"))
+	writeTestFile(t, filepath.Join(dir, "INSTALL.yaml"), brokenInstallSpec)
+	for _, script := range []string{"install.sh", "verify.sh", "uninstall.sh"} {
+		writeTestFile(t, filepath.Join(dir, "scripts", script), "#!/bin/sh\n")
+	}
+
+	issues, err := LintModule(dir)
+	if err != nil {
+		t.Fatalf("LintModule returned error: %v", err)
+	}
+	want := map[int]string{
+		9:  `install mode "container" has no steps`,
+		19: `duplicate step id "S10" in install.native (first defined on line 12)`,
+		23: "referenced file templates/missing.tmpl does not exist",
+		27: `unknown key "retries"`,
+		31: "referenced file scripts/rollback.sh does not exist",
+	}
+	for _, issue := range issues {
+		if !strings.HasSuffix(issue.File, "INSTALL.yaml") {
+			t.Errorf("unexpected issue in %s", issue)
+			continue
+		}
+		message, ok := want[issue.Line]
+		if !ok || !strings.Contains(issue.Message, message) {
+			t.Errorf("unexpected issue %s", issue)
+			continue
+		}
+		delete(want, issue.Line)
+	}
+	for line, message := range want {
+		t.Errorf("missing issue on line %d: %s", line, message)
+	}
+}
+
+func TestLintModuleReportsSyntaxErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "manifest.yaml"), testManifest("demo", "1.0.0"))
+	writeTestFile(t, filepath.Join(dir, "INSTALL.yaml"), "id: demo\ninstall:\n  native: [\n")
+
+	issues, err := LintModule(dir)
+	if err != nil {
+		t.Fatalf("LintModule returned error: %v", err)
+	}
+	if len(issues) != 1 || issues[0].Line == 0 {
+		t.Fatalf("expected one positioned syntax error, got %v", issues)
+	}
+}
+
+func TestBuiltinModulesLintClean(t *testing.T) {
+	root := filepath.Join("..", "..", "modules")
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatalf("ReadDir returned error: %v", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		issues, err := LintModule(filepath.Join(root, entry.Name()))
+		if err != nil {
+			t.Fatalf("LintModule(%s) returned error: %v", entry.Name(), err)
+		}
+		for _, issue := range issues {
+			t.Errorf("%s", issue)
+		}
+	}
+}
+
+func TestEmbeddedSchemasMatchDocs(t *testing.T) {
+	for _, name := range []string{"modules.schema.yaml", "installspec.schema.yaml"} {
+		embedded, err := schemaFiles.ReadFile("schema/" + name)
+		if err != nil {
+			t.Fatalf("ReadFile(%s) returned error: %v", name, err)
+		}
+		docs, err := os.ReadFile(filepath.Join("..", "..", "docs", name))
+		if err != nil {
+			t.Fatalf("ReadFile(%s) returned error: %v", name, err)
+		}
+		if string(embedded) != string(docs) {
+			t.Errorf("internal/module/schema/%s differs from docs/%s", name, name)
+		}
+	}
+}
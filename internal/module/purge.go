@@ -20,7 +20,7 @@ func Purge(name string) error {
 	if normalized == "" {
 		return i18n.Errorf("module name is required")
 	}
-	moduleDir, err := resolveModuleDir(normalized)
+	moduleDir, err := resolveVerifiedModuleDir(normalized)
 	if err != nil {
 		return err
 	}
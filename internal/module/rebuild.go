@@ -0,0 +1,110 @@
+package module
+
+import (
+	"strings"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+)
+
+// RebuildMode selects how an existing installation is cleaned up before a
+// module is installed again.
+type RebuildMode string
+
+const (
+	RebuildNone RebuildMode = "none"
+	RebuildSoft RebuildMode = "soft"
+	RebuildFull RebuildMode = "full"
+)
+
+type environmentRebuild struct {
+	Detect      []installStep `yaml:"detect"`
+	SoftCleanup []installStep `yaml:"soft_cleanup"`
+	FullCleanup []installStep `yaml:"full_cleanup"`
+}
+
+func ParseRebuildMode(value string) (RebuildMode, error) {
+	switch mode := RebuildMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "", RebuildNone:
+		return RebuildNone, nil
+	case RebuildSoft, RebuildFull:
+		return mode, nil
+	default:
+		return "", i18n.Errorf("unsupported rebuild mode %q (expected none, soft or full)", value)
+	}
+}
+
+func (spec moduleInstallSpec) cleanupSteps(moduleName string, mode RebuildMode) ([]installStep, error) {
+	if len(spec.RebuildModes) > 0 {
+		supported := false
+		for _, candidate := range spec.RebuildModes {
+			if RebuildMode(strings.ToLower(strings.TrimSpace(candidate))) == mode {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return nil, i18n.Errorf("module %q does not support rebuild mode %q", moduleName, mode)
+		}
+	}
+	var steps []installStep
+	switch mode {
+	case RebuildSoft:
+		steps = spec.EnvironmentRebuild.SoftCleanup
+	case RebuildFull:
+		steps = spec.EnvironmentRebuild.FullCleanup
+	}
+	if len(steps) == 0 {
+		return nil, i18n.Errorf("module %q does not define %s cleanup steps", moduleName, mode)
+	}
+	return steps, nil
+}
+
+// detectExistingInstall runs the detect steps and returns the intents of
+// those whose expectations hold.
+func detectExistingInstall(steps []installStep, moduleDir string, env map[string]string) []string {
+	var detected []string
+	for _, step := range steps {
+		if strings.TrimSpace(step.Tool) != "shell" {
+			continue
+		}
+		output, exitCode, err := runShellCommandWithEnv(step.Command, moduleDir, false, env)
+		if err != nil {
+			continue
+		}
+		if step.Expected.ExitCode != nil && exitCode != *step.Expected.ExitCode {
+			continue
+		}
+		if step.Expected.Equals != "" && normalizedOutput(output) != normalizedOutput(step.Expected.Equals) {
+			continue
+		}
+		detected = append(detected, fallbackString(strings.TrimSpace(step.Intent), step.ID))
+	}
+	return detected
+}
+
+// rebuildEnvironment cleans up an existing installation of a module so that
+// the following install starts fresh. It does nothing when no detect step
+// finds an existing installation.
+func rebuildEnvironment(moduleName, moduleDir string, spec moduleInstallSpec, opts InstallOptions, vars, env map[string]string) error {
+	if opts.Rebuild == "" || opts.Rebuild == RebuildNone {
+		return nil
+	}
+	steps, err := spec.cleanupSteps(moduleName, opts.Rebuild)
+	if err != nil {
+		return err
+	}
+
+	detected := detectExistingInstall(spec.EnvironmentRebuild.Detect, moduleDir, env)
+	if len(spec.EnvironmentRebuild.Detect) > 0 && len(detected) == 0 {
+		return nil
+	}
+	if opts.Confirm != nil && !opts.Confirm(detected) {
+		return i18n.Errorf("rebuild of module %q cancelled", moduleName)
+	}
+	for _, step := range steps {
+		if err := runInstallStep(moduleName, moduleDir, step, vars, env); err != nil {
+			return i18n.Errorf("%s cleanup failed: %w", opts.Rebuild, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package module
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func rebuildTestSpec() moduleInstallSpec {
+	zero := 0
+	return moduleInstallSpec{
+		RebuildModes: []string{"none", "soft", "full"},
+		EnvironmentRebuild: environmentRebuild{
+			Detect: []installStep{
+				{ID: "R10", Intent: "Detect existing artifacts", Tool: "shell", Command: "test -f installed", Expected: installExpect{ExitCode: &zero}},
+			},
+			SoftCleanup: []installStep{
+				{ID: "C10", Intent: "Soft cleanup", Tool: "shell", Command: "echo soft >> cleanup.log"},
+			},
+			FullCleanup: []installStep{
+				{ID: "C20", Intent: "Full cleanup", Tool: "shell", Command: "echo full >> cleanup.log && rm -f installed"},
+			},
+		},
+	}
+}
+
+func TestRebuildEnvironmentRunsCleanupAfterConfirmation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses bash commands")
+	}
+	dir := t.TempDir()
+	spec := rebuildTestSpec()
+
+	// Nothing detected: no confirmation and no cleanup.
+	confirm := func([]string) bool {
+		t.Fatalf("confirmation requested without an existing installation")
+		return false
+	}
+	if err := rebuildEnvironment("demo", dir, spec, InstallOptions{Rebuild: RebuildFull, Confirm: confirm}, nil, nil); err != nil {
+		t.Fatalf("rebuildEnvironment returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cleanup.log")); !os.IsNotExist(err) {
+		t.Fatalf("cleanup ran without an existing installation")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "installed"), nil, 0o644); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+	var asked []string
+	declined := InstallOptions{Rebuild: RebuildFull, Confirm: func(detected []string) bool {
+		asked = detected
+		return false
+	}}
+	if err := rebuildEnvironment("demo", dir, spec, declined, nil, nil); err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("expected a cancelled rebuild, got %v", err)
+	}
+	if len(asked) != 1 || asked[0] != "Detect existing artifacts" {
+		t.Fatalf("unexpected detected list %v", asked)
+	}
+
+	if err := rebuildEnvironment("demo", dir, spec, InstallOptions{Rebuild: RebuildFull}, nil, nil); err != nil {
+		t.Fatalf("rebuildEnvironment returned error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "cleanup.log"))
+	if err != nil || strings.TrimSpace(string(data)) != "full" {
+		t.Fatalf("expected only the full cleanup to run, got %q (%v)", data, err)
+	}
+}
+
+func TestRebuildEnvironmentRejectsUnsupportedModes(t *testing.T) {
+	spec := rebuildTestSpec()
+	spec.RebuildModes = []string{"none", "full"}
+	if err := rebuildEnvironment("demo", t.TempDir(), spec, InstallOptions{Rebuild: RebuildSoft}, nil, nil); err == nil {
+		t.Fatalf("expected soft rebuild to be rejected")
+	}
+	if err := rebuildEnvironment("demo", t.TempDir(), moduleInstallSpec{}, InstallOptions{Rebuild: RebuildFull}, nil, nil); err == nil {
+		t.Fatalf("expected rebuild without cleanup steps to be rejected")
+	}
+	if _, err := ParseRebuildMode("hard"); err == nil {
+		t.Fatalf("expected unknown rebuild mode to be rejected")
+	}
+}
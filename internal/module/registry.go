@@ -3,6 +3,7 @@ package module
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -19,6 +20,12 @@ type ModuleRecord struct {
 	SourcePath string
 	Checksum   string
 	Signature  string
+	// SignedBy is the trusted key that signed the module directory, and
+	// SignatureWarning why it could not be verified under the warn policy.
+	SignedBy         string
+	SignatureWarning string
+	// Source names the module source the record was loaded from.
+	Source string
 }
 
 type Registry struct {
@@ -31,13 +38,32 @@ func NewRegistry() *Registry {
 	}
 }
 
+// LoadRegistryFromDir loads the modules under root. Modules rejected by the
+// signature policy are skipped and reported in the returned error, next to a
+// registry holding everything else.
 func LoadRegistryFromDir(root string) (*Registry, error) {
 	registry := NewRegistry()
+	err := addRecordsFromDir(registry, root, "", loadSignatureSettings())
+	var untrusted *untrustedModuleError
+	if err != nil && !errors.As(err, &untrusted) {
+		return nil, err
+	}
+	return registry, err
+}
+
+// addRecordsFromDir registers every manifest under root. Modules rejected by
+// the signature policy are skipped and returned joined in the error once the
+// rest are registered; any other failure stops the walk.
+func addRecordsFromDir(registry *Registry, root, source string, settings signatureSettings) error {
+	var rejected []error
 	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		ext := strings.ToLower(filepath.Ext(path))
@@ -47,19 +73,25 @@ func LoadRegistryFromDir(root string) (*Registry, error) {
 		if filepath.Base(path) != "manifest.yaml" {
 			return nil
 		}
-		record, err := LoadModuleRecord(path)
+		record, err := loadModuleRecord(path, settings)
 		if err != nil {
+			var untrusted *untrustedModuleError
+			if errors.As(err, &untrusted) {
+				rejected = append(rejected, i18n.Errorf("skipped module manifest %s: %w", path, err))
+				return nil
+			}
 			return i18n.Errorf("load module manifest %s: %w", path, err)
 		}
+		record.Source = source
 		if err := registry.Add(record); err != nil {
 			return i18n.Errorf("register module %s: %w", record.Manifest.Name, err)
 		}
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return registry, nil
+	return errors.Join(rejected...)
 }
 
 func FindModulesRoot() (string, error) {
@@ -89,6 +121,10 @@ func FindModulesRoot() (string, error) {
 }
 
 func LoadModuleRecord(path string) (ModuleRecord, error) {
+	return loadModuleRecord(path, loadSignatureSettings())
+}
+
+func loadModuleRecord(path string, settings signatureSettings) (ModuleRecord, error) {
 	raw, err := os.ReadFile(path)
 	if err != nil {
 		return ModuleRecord{}, err
@@ -114,12 +150,19 @@ func LoadModuleRecord(path string) (ModuleRecord, error) {
 		}
 	}
 
+	signer, warning, err := checkModuleSignature(filepath.Dir(path), manifest, settings)
+	if err != nil {
+		return ModuleRecord{}, err
+	}
+
 	return ModuleRecord{
-		Manifest:   manifest,
-		Version:    version,
-		SourcePath: path,
-		Checksum:   checksum,
-		Signature:  manifest.Integrity.Signature,
+		Manifest:         manifest,
+		Version:          version,
+		SourcePath:       path,
+		Checksum:         checksum,
+		Signature:        manifest.Integrity.Signature,
+		SignedBy:         signer,
+		SignatureWarning: warning,
 	}, nil
 }
 
@@ -135,6 +178,9 @@ func normalizeChecksum(value string) string {
 	return trimmed
 }
 
+// Add registers a module version. Records added earlier take precedence: a
+// version that is already registered, e.g. from a higher-priority source,
+// keeps its existing record.
 func (r *Registry) Add(record ModuleRecord) error {
 	if r.records == nil {
 		r.records = make(map[string][]ModuleRecord)
@@ -143,6 +189,16 @@ func (r *Registry) Add(record ModuleRecord) error {
 	if name == "" {
 		return i18n.Errorf("module name is required")
 	}
+	for _, dep := range record.Manifest.Dependencies.Modules {
+		if _, _, err := ParseModuleDependency(dep); err != nil {
+			return i18n.Errorf("invalid module dependency %q: %w", dep, err)
+		}
+	}
+	for _, existing := range r.records[name] {
+		if existing.Version.Compare(record.Version) == 0 {
+			return nil
+		}
+	}
 	r.records[name] = append(r.records[name], record)
 	sort.Slice(r.records[name], func(i, j int) bool {
 		return r.records[name][i].Version.Compare(r.records[name][j].Version) > 0
@@ -1,6 +1,10 @@
 package module
 
-import "github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+import (
+	"strings"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+)
 
 type InstallPlan struct {
 	Order   []string
@@ -15,16 +19,37 @@ func NewResolver(registry *Registry) *Resolver {
 	return &Resolver{registry: registry}
 }
 
+// requirement is a constraint on a module together with who imposes it. An
+// empty from means the module was requested directly.
+type requirement struct {
+	from       string
+	constraint *VersionConstraint
+}
+
+// resolution is the search state of one ResolveInstallPlan call.
+type resolution struct {
+	registry     *Registry
+	selected     map[string]ModuleRecord
+	requirements map[string][]requirement
+	// conflict is the first unsatisfiable requirement set found during the
+	// search; it explains the failure when no assignment exists.
+	conflict error
+}
+
+// ResolveInstallPlan picks one version per module so that every dependency
+// constraint holds, trying newer versions first and backtracking when a
+// choice leads to a conflict. Order lists dependencies before dependents.
 func (r *Resolver) ResolveInstallPlan(targets []string) (InstallPlan, error) {
 	if r.registry == nil {
 		return InstallPlan{}, i18n.Errorf("registry is required")
 	}
-	plan := InstallPlan{
-		Modules: make(map[string]ModuleRecord),
+	s := &resolution{
+		registry:     r.registry,
+		selected:     make(map[string]ModuleRecord),
+		requirements: make(map[string][]requirement),
 	}
-	visited := make(map[string]bool)
-	var stack []string
 
+	var roots []string
 	for _, target := range targets {
 		if target == "" {
 			continue
@@ -33,56 +58,187 @@ func (r *Resolver) ResolveInstallPlan(targets []string) (InstallPlan, error) {
 		if err != nil {
 			return InstallPlan{}, err
 		}
-		if err := r.resolveModule(name, constraint, visited, &stack, &plan); err != nil {
-			return InstallPlan{}, err
+		s.requirements[name] = append(s.requirements[name], requirement{constraint: constraint})
+		if !s.satisfiable(name) {
+			return InstallPlan{}, s.explain(name)
 		}
+		roots = append(roots, name)
 	}
 
-	plan.Order = append([]string(nil), stack...)
-	return plan, nil
+	if !s.solve(roots) {
+		if s.conflict != nil {
+			return InstallPlan{}, s.conflict
+		}
+		return InstallPlan{}, i18n.Errorf("no consistent set of module versions found for %s", strings.Join(roots, ", "))
+	}
+	return s.plan(roots)
 }
 
-func (r *Resolver) resolveModule(name string, constraint *VersionConstraint, visited map[string]bool, stack *[]string, plan *InstallPlan) error {
-	if existing, ok := plan.Modules[name]; ok {
-		if constraint != nil && !constraint.Match(existing.Version) {
-			return i18n.Errorf("version conflict for %s: selected %s does not satisfy %s%s", name, existing.Version, constraint.Operator, constraint.Version)
+// solve assigns a version to every module reachable from pending.
+func (s *resolution) solve(pending []string) bool {
+	for len(pending) > 0 {
+		if _, ok := s.selected[pending[0]]; !ok {
+			break
 		}
-		return nil
+		pending = pending[1:]
 	}
-	if visited[name] {
-		return i18n.Errorf("circular dependency detected at %s", name)
+	if len(pending) == 0 {
+		return true
 	}
-	visited[name] = true
+	name, rest := pending[0], pending[1:]
 
-	record, err := r.selectRecord(name, constraint)
-	if err != nil {
-		return err
+	records := s.registry.Get(name)
+	if len(records) == 0 {
+		s.fail(i18n.Errorf("module %s not found in registry", name))
+		return false
 	}
+	for _, record := range records {
+		if !s.allows(name, record.Version) {
+			continue
+		}
+		s.selected[name] = record
+		added, ok := s.require(record)
+		if ok {
+			next := append(append([]string(nil), rest...), added...)
+			if s.solve(next) {
+				return true
+			}
+		}
+		s.release(added)
+		delete(s.selected, name)
+	}
+	return false
+}
+
+// require records the dependency constraints of record. It returns the
+// dependencies it added requirements for, so that release can undo them, and
+// false when a constraint cannot hold with the current selection.
+func (s *resolution) require(record ModuleRecord) ([]string, bool) {
+	from := record.Manifest.Name + " " + record.Version.String()
+	var added []string
 	for _, dep := range record.Manifest.Dependencies.Modules {
-		depName, depConstraint, err := ParseModuleDependency(dep)
+		depName, constraint, err := ParseModuleDependency(dep)
 		if err != nil {
-			return err
+			s.fail(i18n.Errorf("invalid dependency %q of %s: %w", dep, from, err))
+			return added, false
+		}
+		s.requirements[depName] = append(s.requirements[depName], requirement{from: from, constraint: constraint})
+		added = append(added, depName)
+
+		if !s.satisfiable(depName) {
+			if len(s.registry.Get(depName)) == 0 {
+				s.fail(i18n.Errorf("module %s not found in registry (required by %s)", depName, from))
+			} else {
+				s.fail(s.explain(depName))
+			}
+			return added, false
 		}
-		if err := r.resolveModule(depName, depConstraint, visited, stack, plan); err != nil {
-			return err
+		if selected, ok := s.selected[depName]; ok && constraint != nil && !constraint.Match(selected.Version) {
+			// Another version would do; let the search revisit the choice.
+			return added, false
 		}
 	}
+	return added, true
+}
 
-	plan.Modules[name] = record
-	*stack = append(*stack, name)
-	visited[name] = false
-	return nil
+func (s *resolution) release(added []string) {
+	for i := len(added) - 1; i >= 0; i-- {
+		name := added[i]
+		s.requirements[name] = s.requirements[name][:len(s.requirements[name])-1]
+	}
 }
 
-func (r *Resolver) selectRecord(name string, constraint *VersionConstraint) (ModuleRecord, error) {
-	records := r.registry.Get(name)
+func (s *resolution) allows(name string, version Version) bool {
+	for _, req := range s.requirements[name] {
+		if req.constraint != nil && !req.constraint.Match(version) {
+			return false
+		}
+	}
+	return true
+}
+
+// satisfiable reports whether some registered version of name meets all of
+// its current requirements.
+func (s *resolution) satisfiable(name string) bool {
+	for _, record := range s.registry.Get(name) {
+		if s.allows(name, record.Version) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *resolution) fail(err error) {
+	if s.conflict == nil {
+		s.conflict = err
+	}
+}
+
+// explain describes why no version of name satisfies its requirements, for
+// example "vllm 0.6.0 needs python>=3.11.0, comfyui 0.1.0 needs python<3.11.0".
+func (s *resolution) explain(name string) error {
+	records := s.registry.Get(name)
 	if len(records) == 0 {
-		return ModuleRecord{}, i18n.Errorf("module %s not found in registry", name)
+		return i18n.Errorf("module %s not found in registry", name)
 	}
+	available := make([]string, 0, len(records))
 	for _, record := range records {
-		if constraint == nil || constraint.Match(record.Version) {
-			return record, nil
+		available = append(available, record.Version.String())
+	}
+	reqs := s.requirements[name]
+	needs := make([]string, 0, len(reqs))
+	for _, req := range reqs {
+		needs = append(needs, describeRequirement(name, req))
+	}
+	if len(reqs) == 1 {
+		return i18n.Errorf("no available versions for %s satisfy constraint: %s (available: %s)", name, needs[0], strings.Join(available, ", "))
+	}
+	return i18n.Errorf("version conflict for %s: %s (available: %s)", name, strings.Join(needs, ", "), strings.Join(available, ", "))
+}
+
+func describeRequirement(name string, req requirement) string {
+	target := name
+	if req.constraint != nil {
+		target += req.constraint.String()
+	}
+	if req.from == "" {
+		return i18n.T("install request needs %s", target)
+	}
+	return i18n.T("%s needs %s", req.from, target)
+}
+
+// plan orders the selected modules so that dependencies come first.
+func (s *resolution) plan(roots []string) (InstallPlan, error) {
+	plan := InstallPlan{Modules: make(map[string]ModuleRecord)}
+	visiting := make(map[string]bool)
+	var visit func(name string) error
+	visit = func(name string) error {
+		if _, done := plan.Modules[name]; done {
+			return nil
+		}
+		if visiting[name] {
+			return i18n.Errorf("circular dependency detected at %s", name)
+		}
+		visiting[name] = true
+		record := s.selected[name]
+		for _, dep := range record.Manifest.Dependencies.Modules {
+			depName, _, err := ParseModuleDependency(dep)
+			if err != nil {
+				return err
+			}
+			if err := visit(depName); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		plan.Modules[name] = record
+		plan.Order = append(plan.Order, name)
+		return nil
+	}
+	for _, root := range roots {
+		if err := visit(root); err != nil {
+			return InstallPlan{}, err
 		}
 	}
-	return ModuleRecord{}, i18n.Errorf("no available versions for %s satisfy constraint", name)
+	return plan, nil
 }
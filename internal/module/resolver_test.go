@@ -0,0 +1,84 @@
+package module
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func newResolverTestRegistry(t *testing.T, records map[string][]string) *Registry {
+	t.Helper()
+	registry := NewRegistry()
+	for key, deps := range records {
+		name, version, _ := strings.Cut(key, " ")
+		parsed, err := ParseVersion(version)
+		if err != nil {
+			t.Fatalf("ParseVersion returned error: %v", err)
+		}
+		manifest := Manifest{Name: name, Version: version, Dependencies: Dependencies{Modules: deps}}
+		if err := registry.Add(ModuleRecord{Manifest: manifest, Version: parsed}); err != nil {
+			t.Fatalf("Add returned error: %v", err)
+		}
+	}
+	return registry
+}
+
+func TestResolverBacktracksToConsistentVersions(t *testing.T) {
+	registry := newResolverTestRegistry(t, map[string][]string{
+		"app 1.0.0":       {"vllm", "llama.cpp@<2.0.0"},
+		"vllm 0.6.0":      {"llama.cpp@>=2.0.0", "python"},
+		"vllm 0.5.0":      {"llama.cpp@>=1.0.0", "python"},
+		"llama.cpp 2.1.0": nil,
+		"llama.cpp 1.4.0": nil,
+		"python 3.11.0":   nil,
+	})
+
+	plan, err := NewResolver(registry).ResolveInstallPlan([]string{"app"})
+	if err != nil {
+		t.Fatalf("ResolveInstallPlan returned error: %v", err)
+	}
+	if got := plan.Modules["vllm"].Version.String(); got != "0.5.0" {
+		t.Fatalf("expected vllm 0.5.0, got %s", got)
+	}
+	if got := plan.Modules["llama.cpp"].Version.String(); got != "1.4.0" {
+		t.Fatalf("expected llama.cpp 1.4.0, got %s", got)
+	}
+	if fmt.Sprint(plan.Order) != "[llama.cpp python vllm app]" {
+		t.Fatalf("unexpected order: %v", plan.Order)
+	}
+}
+
+func TestResolverExplainsConflicts(t *testing.T) {
+	registry := newResolverTestRegistry(t, map[string][]string{
+		"app 1.0.0":       {"vllm", "llama.cpp@<2.0.0"},
+		"vllm 0.6.0":      {"llama.cpp@>=2.0.0"},
+		"llama.cpp 2.1.0": nil,
+		"llama.cpp 1.4.0": nil,
+	})
+
+	_, err := NewResolver(registry).ResolveInstallPlan([]string{"app"})
+	if err == nil {
+		t.Fatalf("expected a conflict")
+	}
+	for _, want := range []string{"version conflict for llama.cpp", "app 1.0.0 needs llama.cpp<2.0.0", "vllm 0.6.0 needs llama.cpp>=2.0.0"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %q", want, err)
+		}
+	}
+
+	_, err = NewResolver(registry).ResolveInstallPlan([]string{"vllm@>=1.0.0"})
+	if err == nil || !strings.Contains(err.Error(), "install request needs vllm>=1.0.0 (available: 0.6.0)") {
+		t.Fatalf("unexpected error for unavailable target: %v", err)
+	}
+}
+
+func TestResolverDetectsCycles(t *testing.T) {
+	registry := newResolverTestRegistry(t, map[string][]string{
+		"a 1.0.0": {"b"},
+		"b 1.0.0": {"a"},
+	})
+	_, err := NewResolver(registry).ResolveInstallPlan([]string{"a"})
+	if err == nil || !strings.Contains(err.Error(), "circular dependency") {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package module
+
+import (
+	"embed"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"gopkg.in/yaml.v3"
+)
+
+// The schemas are copies of docs/modules.schema.yaml and
+// docs/installspec.schema.yaml; a test keeps them in sync.
+//
+//go:embed schema/*.yaml
+var schemaFiles embed.FS
+
+// jsonSchema is the subset of JSON Schema the module schemas use.
+type jsonSchema struct {
+	Ref                  string                 `yaml:"$ref"`
+	Defs                 map[string]*jsonSchema `yaml:"$defs"`
+	Type                 schemaTypes            `yaml:"type"`
+	Required             []string               `yaml:"required"`
+	Properties           map[string]*jsonSchema `yaml:"properties"`
+	AdditionalProperties *additionalProperties  `yaml:"additionalProperties"`
+	Items                *jsonSchema            `yaml:"items"`
+	Enum                 []string               `yaml:"enum"`
+	Pattern              string                 `yaml:"pattern"`
+	MinLength            *int                   `yaml:"minLength"`
+	MinItems             *int                   `yaml:"minItems"`
+	Minimum              *float64               `yaml:"minimum"`
+}
+
+// schemaTypes accepts both `type: string` and `type: [string, object]`.
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*t = schemaTypes{node.Value}
+		return nil
+	}
+	var types []string
+	if err := node.Decode(&types); err != nil {
+		return err
+	}
+	*t = types
+	return nil
+}
+
+// additionalProperties is either a boolean or a schema for the values of
+// undeclared keys.
+type additionalProperties struct {
+	Allowed bool
+	Schema  *jsonSchema
+}
+
+func (a *additionalProperties) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		allowed, err := strconv.ParseBool(node.Value)
+		if err != nil {
+			return err
+		}
+		a.Allowed = allowed
+		return nil
+	}
+	a.Allowed = true
+	a.Schema = &jsonSchema{}
+	return node.Decode(a.Schema)
+}
+
+func loadSchema(name string) (*jsonSchema, error) {
+	raw, err := schemaFiles.ReadFile("schema/" + name)
+	if err != nil {
+		return nil, err
+	}
+	var schema jsonSchema
+	if err := yaml.Unmarshal(raw, &schema); err != nil {
+		return nil, i18n.Errorf("failed to parse schema %s: %w", name, err)
+	}
+	return &schema, nil
+}
+
+// schemaError is a violation at a position of the validated document.
+type schemaError struct {
+	Path    string
+	Line    int
+	Column  int
+	Message string
+}
+
+type schemaValidator struct {
+	root   *jsonSchema
+	errors []schemaError
+}
+
+// validateSchema checks a YAML document against schema and returns the
+// violations in document order.
+func validateSchema(schema *jsonSchema, document *yaml.Node) []schemaError {
+	v := &schemaValidator{root: schema}
+	node := document
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	v.validate(schema, node, "")
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		if v.errors[i].Line != v.errors[j].Line {
+			return v.errors[i].Line < v.errors[j].Line
+		}
+		return v.errors[i].Column < v.errors[j].Column
+	})
+	return v.errors
+}
+
+func (v *schemaValidator) fail(node *yaml.Node, path, format string, args ...any) {
+	v.errors = append(v.errors, schemaError{Path: path, Line: node.Line, Column: node.Column, Message: i18n.T(format, args...)})
+}
+
+func (v *schemaValidator) resolve(schema *jsonSchema) *jsonSchema {
+	for schema != nil && schema.Ref != "" {
+		name, ok := strings.CutPrefix(schema.Ref, "#/$defs/")
+		if !ok {
+			return nil
+		}
+		schema = v.root.Defs[name]
+	}
+	return schema
+}
+
+func (v *schemaValidator) validate(schema *jsonSchema, node *yaml.Node, path string) {
+	schema = v.resolve(schema)
+	if schema == nil {
+		return
+	}
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+
+	if len(schema.Type) > 0 {
+		actual := yamlNodeType(node)
+		matched := false
+		for _, want := range schema.Type {
+			if want == actual || (want == "number" && actual == "integer") {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(node, path, "%s must be %s, got %s", displayPath(path), strings.Join(schema.Type, " or "), actual)
+			return
+		}
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		v.validateObject(schema, node, path)
+	case yaml.SequenceNode:
+		if schema.MinItems != nil && len(node.Content) < *schema.MinItems {
+			v.fail(node, path, "%s must have at least %d entries", displayPath(path), *schema.MinItems)
+		}
+		if schema.Items != nil {
+			for i, item := range node.Content {
+				v.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case yaml.ScalarNode:
+		v.validateScalar(schema, node, path)
+	}
+}
+
+func (v *schemaValidator) validateObject(schema *jsonSchema, node *yaml.Node, path string) {
+	present := make(map[string]bool, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		present[key.Value] = true
+		child := joinSchemaPath(path, key.Value)
+		if property, ok := schema.Properties[key.Value]; ok {
+			v.validate(property, value, child)
+			continue
+		}
+		if schema.AdditionalProperties == nil {
+			continue
+		}
+		if !schema.AdditionalProperties.Allowed {
+			v.fail(key, child, "unknown key %q", key.Value)
+			continue
+		}
+		if schema.AdditionalProperties.Schema != nil {
+			v.validate(schema.AdditionalProperties.Schema, value, child)
+		}
+	}
+	for _, name := range schema.Required {
+		if !present[name] {
+			v.fail(node, path, "%s is required", joinSchemaPath(path, name))
+		}
+	}
+}
+
+func (v *schemaValidator) validateScalar(schema *jsonSchema, node *yaml.Node, path string) {
+	if len(schema.Enum) > 0 {
+		found := false
+		for _, allowed := range schema.Enum {
+			if node.Value == allowed {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(node, path, "%s must be one of %s, got %q", displayPath(path), strings.Join(schema.Enum, ", "), node.Value)
+		}
+	}
+	if schema.Pattern != "" {
+		if pattern, err := regexp.Compile(schema.Pattern); err == nil && !pattern.MatchString(node.Value) {
+			v.fail(node, path, "%s %q does not match %s", displayPath(path), node.Value, schema.Pattern)
+		}
+	}
+	if schema.MinLength != nil && len(strings.TrimSpace(node.Value)) < *schema.MinLength {
+		v.fail(node, path, "%s must not be empty", displayPath(path))
+	}
+	if schema.Minimum != nil {
+		if number, err := strconv.ParseFloat(node.Value, 64); err == nil && number < *schema.Minimum {
+			v.fail(node, path, "%s must be at least %v", displayPath(path), *schema.Minimum)
+		}
+	}
+}
+
+// yamlNodeType maps a node to its JSON Schema type name.
+func yamlNodeType(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch node.ShortTag() {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	default:
+		return "string"
+	}
+}
+
+func joinSchemaPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return i18n.T("document")
+	}
+	return path
+}
@@ -17,7 +17,7 @@ func Setting(name string, args []string) error {
 		return i18n.Errorf("setting arguments are required")
 	}
 
-	moduleDir, err := resolveModuleDir(normalized)
+	moduleDir, err := resolveVerifiedModuleDir(normalized)
 	if err != nil {
 		return err
 	}
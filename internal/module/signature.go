@@ -0,0 +1,303 @@
+package module
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/config"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"gopkg.in/yaml.v3"
+)
+
+type SignaturePolicy string
+
+const (
+	SignatureOff     SignaturePolicy = "off"
+	SignatureWarn    SignaturePolicy = "warn"
+	SignatureEnforce SignaturePolicy = "enforce"
+)
+
+// signedPaths are the parts of a module directory covered by its signature:
+// everything that decides what runs during install.
+var signedPaths = []string{"manifest.yaml", "INSTALL.yaml", "scripts", "templates"}
+
+// sharedSignedFiles live next to the module directories and are sourced by
+// module scripts, so they are signed along with each module that has them.
+var sharedSignedFiles = []string{"scripts_common.ps1"}
+
+type signatureSettings struct {
+	policy SignaturePolicy
+	keys   []string
+}
+
+var loadSignatureSettings = func() signatureSettings {
+	cfg := config.DefaultConfig()
+	if loaded, err := config.LoadConfig(); err == nil {
+		cfg = loaded
+	}
+	return signatureSettings{
+		policy: SignaturePolicy(strings.ToLower(strings.TrimSpace(cfg.Modules.SignaturePolicy))),
+		keys:   cfg.Modules.TrustedKeys,
+	}
+}
+
+// ModuleDigest returns the signed payload of a module directory: one
+// "sha256  path" line per file of the manifest, INSTALL.yaml, scripts,
+// templates and shared scripts, sorted by path. Sign it with minisign -S -l
+// or plain ed25519.
+func ModuleDigest(moduleDir string) ([]byte, error) {
+	var files []string
+	for _, name := range sharedSignedFiles {
+		info, err := os.Lstat(filepath.Join(moduleDir, "..", name))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			return nil, i18n.Errorf("%s is not a regular file", filepath.Join(moduleDir, "..", name))
+		}
+		files = append(files, "../"+name)
+	}
+	for _, name := range signedPaths {
+		root := filepath.Join(moduleDir, name)
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) && path == root {
+					return nil
+				}
+				return err
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			if !entry.Type().IsRegular() {
+				return i18n.Errorf("%s is not a regular file", path)
+			}
+			relative, err := filepath.Rel(moduleDir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(relative))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+
+	var digest bytes.Buffer
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(moduleDir, filepath.FromSlash(file)))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(&digest, "%s  %s\n", hex.EncodeToString(sum[:]), file)
+	}
+	return digest.Bytes(), nil
+}
+
+// checkModuleSignature applies the configured policy to a module directory.
+// It returns the ID of the key that signed it, or under the warn policy a
+// warning instead of an error.
+func checkModuleSignature(moduleDir string, manifest Manifest, settings signatureSettings) (string, string, error) {
+	switch settings.policy {
+	case SignatureOff:
+		return "", "", nil
+	case "", SignatureWarn, SignatureEnforce:
+	default:
+		return "", "", i18n.Errorf("invalid modules.signature_policy %q (want off, warn or enforce)", settings.policy)
+	}
+	signer, err := verifyModuleSignature(moduleDir, manifest, settings.keys)
+	if err == nil {
+		return signer, "", nil
+	}
+	if settings.policy == SignatureEnforce {
+		return "", "", &untrustedModuleError{module: manifest.Name, err: err}
+	}
+	return "", err.Error(), nil
+}
+
+// untrustedModuleError rejects a module under the enforce policy. Registry
+// loads skip such modules instead of failing.
+type untrustedModuleError struct {
+	module string
+	err    error
+}
+
+func (e *untrustedModuleError) Error() string {
+	return i18n.T("module %s: %v", e.module, e.err)
+}
+
+func (e *untrustedModuleError) Unwrap() error {
+	return e.err
+}
+
+func verifyModuleSignature(moduleDir string, manifest Manifest, trustedKeys []string) (string, error) {
+	signatureFile := strings.TrimSpace(manifest.Integrity.Signature)
+	if signatureFile == "" {
+		return "", i18n.Errorf("module is not signed")
+	}
+	if filepath.IsAbs(signatureFile) || strings.HasPrefix(filepath.Clean(signatureFile), "..") {
+		return "", i18n.Errorf("integrity.signature %q must be a file inside the module directory", signatureFile)
+	}
+	raw, err := os.ReadFile(filepath.Join(moduleDir, signatureFile))
+	if err != nil {
+		return "", i18n.Errorf("failed to read signature: %w", err)
+	}
+	keys, err := parseTrustedKeys(trustedKeys)
+	if err != nil {
+		return "", err
+	}
+	if len(keys) == 0 {
+		return "", i18n.Errorf("no trusted keys configured in modules.trusted_keys")
+	}
+	digest, err := ModuleDigest(moduleDir)
+	if err != nil {
+		return "", i18n.Errorf("failed to digest module: %w", err)
+	}
+	return verifySignature(raw, digest, keys)
+}
+
+type trustedKey struct {
+	id  string
+	key ed25519.PublicKey
+	// minisignID is the 8-byte key ID of minisign keys.
+	minisignID []byte
+}
+
+// parseTrustedKeys accepts base64 ed25519 public keys, optionally prefixed
+// with "ed25519:", and minisign public keys (the base64 line of a .pub file).
+func parseTrustedKeys(values []string) ([]trustedKey, error) {
+	keys := make([]trustedKey, 0, len(values))
+	for _, value := range values {
+		encoded := strings.TrimPrefix(strings.TrimSpace(value), "ed25519:")
+		if encoded == "" {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, i18n.Errorf("invalid trusted key %q: %w", value, err)
+		}
+		switch {
+		case len(raw) == ed25519.PublicKeySize:
+			keys = append(keys, trustedKey{id: "ed25519:" + encoded[:8], key: ed25519.PublicKey(raw)})
+		case len(raw) == 42 && string(raw[:2]) == "Ed":
+			keys = append(keys, trustedKey{
+				id:         "minisign:" + strings.ToUpper(hex.EncodeToString(reverseBytes(raw[2:10]))),
+				key:        ed25519.PublicKey(raw[10:]),
+				minisignID: raw[2:10],
+			})
+		default:
+			return nil, i18n.Errorf("invalid trusted key %q: not an ed25519 or minisign public key", value)
+		}
+	}
+	return keys, nil
+}
+
+// verifySignature checks a raw base64 ed25519 signature or a minisign
+// signature file over message.
+func verifySignature(signature, message []byte, keys []trustedKey) (string, error) {
+	lines := strings.Split(strings.TrimSpace(string(signature)), "\n")
+	if len(lines) == 1 {
+		sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[0]))
+		if err != nil || len(sig) != ed25519.SignatureSize {
+			return "", i18n.Errorf("malformed signature")
+		}
+		for _, key := range keys {
+			if ed25519.Verify(key.key, message, sig) {
+				return key.id, nil
+			}
+		}
+		return "", i18n.Errorf("signature does not match any trusted key")
+	}
+
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return "", i18n.Errorf("malformed minisign signature")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 74 {
+		return "", i18n.Errorf("malformed minisign signature")
+	}
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return "", i18n.Errorf("malformed minisign signature")
+	}
+	switch string(sig[:2]) {
+	case "Ed":
+	case "ED":
+		return "", i18n.Errorf("prehashed minisign signatures are not supported; sign with minisign -S -l")
+	default:
+		return "", i18n.Errorf("unsupported minisign signature algorithm %q", sig[:2])
+	}
+	trustedComment := strings.TrimPrefix(strings.TrimRight(lines[2], "\r"), "trusted comment: ")
+	for _, key := range keys {
+		if key.minisignID == nil || !bytes.Equal(key.minisignID, sig[2:10]) {
+			continue
+		}
+		if !ed25519.Verify(key.key, message, sig[10:]) {
+			return "", i18n.Errorf("signature by %s does not match the module contents", key.id)
+		}
+		if !ed25519.Verify(key.key, append(append([]byte(nil), sig[10:]...), trustedComment...), globalSig) {
+			return "", i18n.Errorf("trusted comment of signature by %s has been altered", key.id)
+		}
+		return key.id, nil
+	}
+	return "", i18n.Errorf("signature key %s is not trusted", strings.ToUpper(hex.EncodeToString(reverseBytes(sig[2:10]))))
+}
+
+func reverseBytes(value []byte) []byte {
+	reversed := make([]byte, len(value))
+	for i, b := range value {
+		reversed[len(value)-1-i] = b
+	}
+	return reversed
+}
+
+// resolveVerifiedModuleDir resolves a module directory and checks its
+// signature before any of its scripts run. Warnings go to stderr.
+func resolveVerifiedModuleDir(name string) (string, error) {
+	moduleDir, err := resolveModuleDir(name)
+	if err != nil {
+		return "", err
+	}
+	if err := verifyModuleDir(name, moduleDir); err != nil {
+		return "", err
+	}
+	return moduleDir, nil
+}
+
+func verifyModuleDir(name, moduleDir string) error {
+	raw, err := os.ReadFile(filepath.Join(moduleDir, "manifest.yaml"))
+	if err != nil {
+		return i18n.Errorf("failed to read manifest for module %q: %w", name, err)
+	}
+	var manifest Manifest
+	if err := yaml.Unmarshal(raw, &manifest); err != nil {
+		return i18n.Errorf("failed to parse manifest for module %q: %w", name, err)
+	}
+	if manifest.Name == "" {
+		manifest.Name = name
+	}
+	_, warning, err := checkModuleSignature(moduleDir, manifest, loadSignatureSettings())
+	if err != nil {
+		return err
+	}
+	if warning != "" {
+		fmt.Fprintf(os.Stderr, "%s\n", i18n.T("Warning: module %s: %s", manifest.Name, warning))
+	}
+	return nil
+}
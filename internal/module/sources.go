@@ -0,0 +1,541 @@
+package module
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"gopkg.in/yaml.v3"
+)
+
+type SourceType string
+
+const (
+	SourceLocal SourceType = "local"
+	SourceHTTP  SourceType = "http"
+	SourceGit   SourceType = "git"
+)
+
+// BuiltinSource names the modules directory that ships with las. It takes
+// precedence over configured sources that provide the same version.
+const BuiltinSource = "builtin"
+
+const sourceIndexFile = "index.yaml"
+
+var sourceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// Source is a configured module source. Sources are consulted in the order
+// they were added, after the builtin modules directory.
+type Source struct {
+	Name      string     `yaml:"name"`
+	Type      SourceType `yaml:"type"`
+	URL       string     `yaml:"url"`
+	UpdatedAt time.Time  `yaml:"updated_at,omitempty"`
+}
+
+type sourceList struct {
+	Sources []Source `yaml:"sources"`
+}
+
+// SourceIndex is the index.yaml of a source. HTTP sources must provide one;
+// for local and git sources it is optional and only used to verify checksums.
+type SourceIndex struct {
+	Modules []SourceIndexEntry `yaml:"modules"`
+}
+
+// SourceIndexEntry describes one module version. Path is the module
+// directory relative to the index, Checksum the sha256 of its manifest.yaml
+// and Files the sha256 of each other file to fetch from it, such as
+// INSTALL.yaml and scripts, keyed by path.
+type SourceIndexEntry struct {
+	Name     string            `yaml:"name"`
+	Version  string            `yaml:"version"`
+	Path     string            `yaml:"path"`
+	Checksum string            `yaml:"checksum"`
+	Files    map[string]string `yaml:"files,omitempty"`
+}
+
+// files returns the manifest followed by the other files of the entry in
+// path order.
+func (e SourceIndexEntry) files() []string {
+	files := make([]string, 0, len(e.Files))
+	for file := range e.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return append([]string{"manifest.yaml"}, files...)
+}
+
+var sourceHTTPClient = &http.Client{Timeout: 60 * time.Second}
+
+// maxSourceFileSize bounds every file downloaded from an HTTP source.
+const maxSourceFileSize = 32 << 20
+
+func localAIStackDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".localaistack"), nil
+}
+
+func sourcesPath() (string, error) {
+	base, err := localAIStackDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "module-sources.yaml"), nil
+}
+
+// SourceCacheDir returns where a remote source is fetched to.
+func SourceCacheDir(name string) (string, error) {
+	base, err := localAIStackDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "module-cache", name), nil
+}
+
+func LoadSources() ([]Source, error) {
+	path, err := sourcesPath()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, i18n.Errorf("failed to read module sources: %w", err)
+	}
+	var list sourceList
+	if err := yaml.Unmarshal(raw, &list); err != nil {
+		return nil, i18n.Errorf("failed to parse module sources %s: %w", path, err)
+	}
+	return list.Sources, nil
+}
+
+func saveSources(sources []Source) error {
+	path, err := sourcesPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(sourceList{Sources: sources})
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// DetectSourceType guesses the type of a source from its location.
+func DetectSourceType(location string) SourceType {
+	switch {
+	case strings.HasSuffix(location, ".git"), strings.HasPrefix(location, "git@"), strings.HasPrefix(location, "git://"):
+		return SourceGit
+	case strings.HasPrefix(location, "http://"), strings.HasPrefix(location, "https://"):
+		return SourceHTTP
+	default:
+		return SourceLocal
+	}
+}
+
+// AddSource registers a module source and fetches it. The source is only
+// saved when the first fetch succeeds.
+func AddSource(ctx context.Context, name, location string, sourceType SourceType) (Source, int, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	location = strings.TrimSpace(location)
+	if !sourceNamePattern.MatchString(name) || name == BuiltinSource {
+		return Source{}, 0, i18n.Errorf("invalid source name %q", name)
+	}
+	if location == "" {
+		return Source{}, 0, i18n.Errorf("source location is required")
+	}
+	if sourceType == "" {
+		sourceType = DetectSourceType(location)
+	}
+	switch sourceType {
+	case SourceLocal:
+		abs, err := filepath.Abs(location)
+		if err != nil {
+			return Source{}, 0, err
+		}
+		location = abs
+	case SourceHTTP, SourceGit:
+	default:
+		return Source{}, 0, i18n.Errorf("unsupported source type %q", sourceType)
+	}
+
+	sources, err := LoadSources()
+	if err != nil {
+		return Source{}, 0, err
+	}
+	for _, existing := range sources {
+		if existing.Name == name {
+			return Source{}, 0, i18n.Errorf("module source %s already exists", name)
+		}
+	}
+	source := Source{Name: name, Type: sourceType, URL: location}
+	count, err := fetchSource(ctx, &source)
+	if err != nil {
+		if sourceType != SourceLocal {
+			if cacheDir, cacheErr := SourceCacheDir(name); cacheErr == nil {
+				_ = os.RemoveAll(cacheDir)
+			}
+		}
+		return Source{}, 0, err
+	}
+	if err := saveSources(append(sources, source)); err != nil {
+		return Source{}, 0, err
+	}
+	return source, count, nil
+}
+
+// UpdateSource refetches a configured source and returns how many modules
+// it provides.
+func UpdateSource(ctx context.Context, name string) (int, error) {
+	sources, err := LoadSources()
+	if err != nil {
+		return 0, err
+	}
+	for i := range sources {
+		if sources[i].Name != name {
+			continue
+		}
+		count, err := fetchSource(ctx, &sources[i])
+		if err != nil {
+			return 0, err
+		}
+		return count, saveSources(sources)
+	}
+	return 0, i18n.Errorf("module source %s not found", name)
+}
+
+// RemoveSource forgets a source and deletes its cache.
+func RemoveSource(name string) error {
+	sources, err := LoadSources()
+	if err != nil {
+		return err
+	}
+	kept := sources[:0]
+	found := false
+	for _, source := range sources {
+		if source.Name == name {
+			found = true
+			continue
+		}
+		kept = append(kept, source)
+	}
+	if !found {
+		return i18n.Errorf("module source %s not found", name)
+	}
+	if err := saveSources(kept); err != nil {
+		return err
+	}
+	cacheDir, err := SourceCacheDir(name)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(cacheDir)
+}
+
+// SourceRoot returns the directory the modules of a source are read from.
+func SourceRoot(source Source) (string, error) {
+	if source.Type == SourceLocal {
+		return source.URL, nil
+	}
+	return SourceCacheDir(source.Name)
+}
+
+func fetchSource(ctx context.Context, source *Source) (int, error) {
+	root, err := SourceRoot(*source)
+	if err != nil {
+		return 0, err
+	}
+	settings := loadSignatureSettings()
+	switch source.Type {
+	case SourceLocal:
+		info, err := os.Stat(root)
+		if err != nil || !info.IsDir() {
+			return 0, i18n.Errorf("module source directory %s not found", root)
+		}
+	case SourceHTTP:
+		if err := fetchHTTPSource(ctx, source.URL, root, settings); err != nil {
+			return 0, i18n.Errorf("fetch module source %s: %w", source.Name, err)
+		}
+	case SourceGit:
+		if err := fetchGitSource(ctx, source.URL, root); err != nil {
+			return 0, i18n.Errorf("fetch module source %s: %w", source.Name, err)
+		}
+	default:
+		return 0, i18n.Errorf("unsupported source type %q", source.Type)
+	}
+	if err := verifySourceIndex(root, settings); err != nil {
+		return 0, i18n.Errorf("module source %s: %w", source.Name, err)
+	}
+	registry := NewRegistry()
+	if err := addRecordsFromDir(registry, root, source.Name, settings); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, records := range registry.All() {
+		count += len(records)
+	}
+	source.UpdatedAt = time.Now().UTC()
+	return count, nil
+}
+
+// fetchHTTPSource downloads the index and the files it lists into a fresh
+// directory and swaps it in place of the previous cache.
+func fetchHTTPSource(ctx context.Context, indexURL, cacheDir string, settings signatureSettings) error {
+	base, err := url.Parse(indexURL)
+	if err != nil {
+		return err
+	}
+	rawIndex, err := httpGet(ctx, base)
+	if err != nil {
+		return err
+	}
+	var index SourceIndex
+	if err := yaml.Unmarshal(rawIndex, &index); err != nil {
+		return i18n.Errorf("failed to parse index %s: %w", indexURL, err)
+	}
+
+	staging := cacheDir + ".tmp"
+	if err := os.RemoveAll(staging); err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+	if err := writeSourceFile(staging, sourceIndexFile, rawIndex); err != nil {
+		return err
+	}
+	for _, entry := range index.Modules {
+		for _, file := range entry.files() {
+			relative, err := sourceRelativePath(entry.Path, file)
+			if err != nil {
+				return i18n.Errorf("module %s %s: %w", entry.Name, entry.Version, err)
+			}
+			ref, err := url.Parse(relative)
+			if err != nil {
+				return err
+			}
+			data, err := httpGet(ctx, base.ResolveReference(ref))
+			if err != nil {
+				return err
+			}
+			if err := writeSourceFile(staging, relative, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := verifySourceIndex(staging, settings); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(cacheDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cacheDir), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(staging, cacheDir)
+}
+
+func httpGet(ctx context.Context, target *url.URL) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := sourceHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, i18n.Errorf("GET %s: %s", target, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSourceFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSourceFileSize {
+		return nil, i18n.Errorf("GET %s: response exceeds %d MiB", target, maxSourceFileSize>>20)
+	}
+	return data, nil
+}
+
+// sourceRelativePath joins an index path and file name, rejecting paths
+// that would escape the source directory.
+func sourceRelativePath(dir, file string) (string, error) {
+	joined := path.Clean(path.Join(dir, file))
+	if path.IsAbs(joined) || joined == ".." || strings.HasPrefix(joined, "../") {
+		return "", i18n.Errorf("path %q leaves the source directory", path.Join(dir, file))
+	}
+	return joined, nil
+}
+
+func writeSourceFile(root, relative string, data []byte) error {
+	target := filepath.Join(root, filepath.FromSlash(relative))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	mode := os.FileMode(0o644)
+	if strings.HasSuffix(relative, ".sh") {
+		mode = 0o755
+	}
+	return os.WriteFile(target, data, mode)
+}
+
+func fetchGitSource(ctx context.Context, repo, cacheDir string) error {
+	var cmd *exec.Cmd
+	if _, err := os.Stat(filepath.Join(cacheDir, ".git")); err == nil {
+		cmd = exec.CommandContext(ctx, "git", "-C", cacheDir, "pull", "--ff-only")
+	} else {
+		if err := os.MkdirAll(filepath.Dir(cacheDir), 0o755); err != nil {
+			return err
+		}
+		cmd = exec.CommandContext(ctx, "git", "clone", "--depth", "1", repo, cacheDir)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return i18n.Errorf("%s: %s", strings.Join(cmd.Args, " "), strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// verifySourceIndex checks every file listed in root/index.yaml against its
+// checksum. Sources without an index are not verified.
+func verifySourceIndex(root string, settings signatureSettings) error {
+	raw, err := os.ReadFile(filepath.Join(root, sourceIndexFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	var index SourceIndex
+	if err := yaml.Unmarshal(raw, &index); err != nil {
+		return i18n.Errorf("failed to parse %s: %w", sourceIndexFile, err)
+	}
+	for _, entry := range index.Modules {
+		for _, file := range entry.files() {
+			checksum := entry.Files[file]
+			if file == "manifest.yaml" {
+				checksum = entry.Checksum
+			}
+			if err := verifySourceFile(root, entry, file, checksum); err != nil {
+				return err
+			}
+		}
+		relative, err := sourceRelativePath(entry.Path, "manifest.yaml")
+		if err != nil {
+			return err
+		}
+		record, err := loadModuleRecord(filepath.Join(root, filepath.FromSlash(relative)), settings)
+		if err != nil {
+			return i18n.Errorf("module %s %s: %w", entry.Name, entry.Version, err)
+		}
+		if record.Manifest.Name != entry.Name || record.Manifest.Version != entry.Version {
+			return i18n.Errorf("index lists %s %s but %s declares %s %s", entry.Name, entry.Version, relative, record.Manifest.Name, record.Manifest.Version)
+		}
+	}
+	return nil
+}
+
+func verifySourceFile(root string, entry SourceIndexEntry, file, checksum string) error {
+	relative, err := sourceRelativePath(entry.Path, file)
+	if err != nil {
+		return i18n.Errorf("module %s %s: %w", entry.Name, entry.Version, err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(relative)))
+	if err != nil {
+		return i18n.Errorf("module %s %s: %w", entry.Name, entry.Version, err)
+	}
+	if checksum == "" {
+		return i18n.Errorf("module %s %s has no checksum for %s in %s", entry.Name, entry.Version, file, sourceIndexFile)
+	}
+	expected := normalizeChecksum(checksum)
+	if actual := ComputeChecksum(data); !strings.EqualFold(actual, expected) {
+		return i18n.Errorf("checksum mismatch for %s %s %s: expected %s got %s", entry.Name, entry.Version, file, expected, actual)
+	}
+	return nil
+}
+
+// LoadRegistry loads the builtin modules directory followed by every
+// configured source. A source that cannot be read is skipped and reported in
+// the returned error, next to a registry holding everything else.
+func LoadRegistry() (*Registry, error) {
+	registry := NewRegistry()
+	settings := loadSignatureSettings()
+	var errs []error
+	if modulesRoot, err := FindModulesRoot(); err == nil {
+		if err := addRecordsFromDir(registry, modulesRoot, BuiltinSource, settings); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	sources, err := LoadSources()
+	if err != nil {
+		return registry, err
+	}
+	for _, source := range sources {
+		root, err := SourceRoot(source)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, err := os.Stat(root); err != nil {
+			errs = append(errs, i18n.Errorf("module source %s has not been fetched; run `las module repo update %s`", source.Name, source.Name))
+			continue
+		}
+		if err := addRecordsFromDir(registry, root, source.Name, settings); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source.Name, err))
+		}
+	}
+	return registry, errors.Join(errs...)
+}
+
+// sourceModuleDir picks a module from the configured sources when it wins
+// over the builtin one in builtinDir under the registry order: the newest
+// version, and for equal versions the earlier source.
+func sourceModuleDir(name, builtinDir string) (string, bool) {
+	sources, err := LoadSources()
+	if err != nil || len(sources) == 0 {
+		return "", false
+	}
+	registry := NewRegistry()
+	settings := loadSignatureSettings()
+	if builtinDir != "" {
+		if record, err := loadModuleRecord(filepath.Join(builtinDir, "manifest.yaml"), settings); err == nil {
+			record.Source = BuiltinSource
+			_ = registry.Add(record)
+		}
+	}
+	for _, source := range sources {
+		if root, err := SourceRoot(source); err == nil {
+			_ = addRecordsFromDir(registry, root, source.Name, settings)
+		}
+	}
+	records := registry.Get(name)
+	if len(records) == 0 || records[0].Source == BuiltinSource {
+		return "", false
+	}
+	return filepath.Dir(records[0].SourcePath), true
+}
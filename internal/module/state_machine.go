@@ -6,11 +6,16 @@ var allowedTransitions = map[State]map[State]struct{}{
 		StateDeprecated: {},
 	},
 	StateResolved: {
+		StateAvailable:  {},
 		StateInstalled:  {},
 		StateFailed:     {},
 		StateDeprecated: {},
 	},
+	// Installed and stopped modules may be resolved again for a reinstall or
+	// update, and return to available once uninstalled.
 	StateInstalled: {
+		StateAvailable:  {},
+		StateResolved:   {},
 		StateRunning:    {},
 		StateStopped:    {},
 		StateFailed:     {},
@@ -22,11 +27,14 @@ var allowedTransitions = map[State]map[State]struct{}{
 		StateDeprecated: {},
 	},
 	StateStopped: {
+		StateAvailable:  {},
+		StateResolved:   {},
 		StateRunning:    {},
 		StateFailed:     {},
 		StateDeprecated: {},
 	},
 	StateFailed: {
+		StateAvailable:  {},
 		StateResolved:   {},
 		StateDeprecated: {},
 	},
@@ -24,7 +24,7 @@ func Uninstall(name string) error {
 	if normalized == "" {
 		return i18n.Errorf("module name is required")
 	}
-	moduleDir, err := resolveModuleDir(normalized)
+	moduleDir, err := resolveVerifiedModuleDir(normalized)
 	if err != nil {
 		return err
 	}
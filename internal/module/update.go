@@ -21,7 +21,7 @@ func Update(name string) error {
 	if normalized == "" {
 		return i18n.Errorf("module name is required")
 	}
-	moduleDir, err := resolveModuleDir(normalized)
+	moduleDir, err := resolveVerifiedModuleDir(normalized)
 	if err != nil {
 		return err
 	}
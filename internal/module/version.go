@@ -9,32 +9,51 @@ import (
 	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
 )
 
-var versionPattern = regexp.MustCompile(`^\d+(?:\.\d+){0,2}$`)
+var versionPattern = regexp.MustCompile(`^(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?$`)
 
+// Version is a semantic version. Missing minor or patch segments are zero;
+// build metadata is kept but ignored when comparing.
 type Version struct {
-	Major int
-	Minor int
-	Patch int
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+	Build      string
 }
 
 func ParseVersion(value string) (Version, error) {
-	if !versionPattern.MatchString(value) {
-		return Version{}, i18n.Errorf("invalid version format: %q", value)
+	version, _, err := parseVersionSegments(value)
+	return version, err
+}
+
+// parseVersionSegments also returns how many numeric segments value spells
+// out, which caret and tilde ranges depend on.
+func parseVersionSegments(value string) (Version, int, error) {
+	matches := versionPattern.FindStringSubmatch(value)
+	if matches == nil {
+		return Version{}, 0, i18n.Errorf("invalid version format: %q", value)
 	}
 
-	parts := strings.Split(value, ".")
 	parsed := []int{0, 0, 0}
-	for i, part := range parts {
+	segments := 0
+	for i, part := range matches[1:4] {
+		if part == "" {
+			break
+		}
 		number, err := strconv.Atoi(part)
 		if err != nil {
-			return Version{}, i18n.Errorf("invalid version segment %q: %w", part, err)
+			return Version{}, 0, i18n.Errorf("invalid version segment %q: %w", part, err)
 		}
 		parsed[i] = number
+		segments++
 	}
 
-	return Version{Major: parsed[0], Minor: parsed[1], Patch: parsed[2]}, nil
+	version := Version{Major: parsed[0], Minor: parsed[1], Patch: parsed[2], Prerelease: matches[4], Build: matches[5]}
+	return version, segments, nil
 }
 
+// Compare orders versions by semver precedence: a pre-release sorts before
+// the release it precedes, and build metadata does not count.
 func (v Version) Compare(other Version) int {
 	if v.Major != other.Major {
 		return compareInt(v.Major, other.Major)
@@ -42,11 +61,56 @@ func (v Version) Compare(other Version) int {
 	if v.Minor != other.Minor {
 		return compareInt(v.Minor, other.Minor)
 	}
-	return compareInt(v.Patch, other.Patch)
+	if v.Patch != other.Patch {
+		return compareInt(v.Patch, other.Patch)
+	}
+	return comparePrerelease(v.Prerelease, other.Prerelease)
 }
 
 func (v Version) String() string {
-	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	value := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		value += "-" + v.Prerelease
+	}
+	if v.Build != "" {
+		value += "+" + v.Build
+	}
+	return value
+}
+
+func (v Version) sameRelease(other Version) bool {
+	return v.Major == other.Major && v.Minor == other.Minor && v.Patch == other.Patch
+}
+
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	left, right := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(left) && i < len(right); i++ {
+		leftNumber, leftErr := strconv.Atoi(left[i])
+		rightNumber, rightErr := strconv.Atoi(right[i])
+		switch {
+		case leftErr == nil && rightErr == nil:
+			if leftNumber != rightNumber {
+				return compareInt(leftNumber, rightNumber)
+			}
+		case leftErr == nil:
+			return -1
+		case rightErr == nil:
+			return 1
+		default:
+			if cmp := strings.Compare(left[i], right[i]); cmp != 0 {
+				return cmp
+			}
+		}
+	}
+	return compareInt(len(left), len(right))
 }
 
 func compareInt(a, b int) int {
@@ -60,14 +124,14 @@ func compareInt(a, b int) int {
 	}
 }
 
-type VersionConstraint struct {
+type comparator struct {
 	Operator string
 	Version  Version
 }
 
-func (constraint VersionConstraint) Match(version Version) bool {
-	comparison := version.Compare(constraint.Version)
-	switch constraint.Operator {
+func (c comparator) match(version Version) bool {
+	comparison := version.Compare(c.Version)
+	switch c.Operator {
 	case "", "=", "==":
 		return comparison == 0
 	case "!=":
@@ -84,3 +148,40 @@ func (constraint VersionConstraint) Match(version Version) bool {
 		return false
 	}
 }
+
+// VersionConstraint is a range of versions, written as comma-separated
+// comparators that must all match. An empty constraint matches any release.
+type VersionConstraint struct {
+	comparators []comparator
+}
+
+// Match reports whether version lies in the range. Pre-releases only match
+// when a comparator names a pre-release of the same major.minor.patch, so
+// ">=1.0.0" does not pull in 2.0.0-rc.1.
+func (constraint VersionConstraint) Match(version Version) bool {
+	for _, c := range constraint.comparators {
+		if !c.match(version) {
+			return false
+		}
+	}
+	if version.Prerelease == "" {
+		return true
+	}
+	for _, c := range constraint.comparators {
+		if c.Version.Prerelease != "" && c.Version.sameRelease(version) {
+			return true
+		}
+	}
+	return false
+}
+
+func (constraint VersionConstraint) String() string {
+	if len(constraint.comparators) == 0 {
+		return "*"
+	}
+	parts := make([]string, 0, len(constraint.comparators))
+	for _, c := range constraint.comparators {
+		parts = append(parts, c.Operator+c.Version.String())
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,184 @@
+package runtime
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+)
+
+const (
+	defaultCgroupRoot   = "/sys/fs/cgroup"
+	defaultCgroupParent = "localaistack.slice"
+	// cpuPeriod is the cpu.max period in microseconds.
+	cpuPeriod = 100000
+)
+
+func validateResources(res Resources) error {
+	if res.CPUs < 0 || res.MemoryMax < 0 || res.PidsMax < 0 {
+		return i18n.Errorf("resource limits must not be negative")
+	}
+	for _, index := range res.GPUs {
+		if index < 0 {
+			return i18n.Errorf("invalid gpu index %d", index)
+		}
+	}
+	switch strings.ToLower(res.GPUVendor) {
+	case "", "nvidia", "amd":
+	default:
+		return i18n.Errorf("unsupported gpu vendor %q", res.GPUVendor)
+	}
+	return nil
+}
+
+func (res Resources) hasCgroupLimits() bool {
+	return res.CPUs > 0 || res.MemoryMax > 0 || res.PidsMax > 0
+}
+
+func (res Resources) gpuList() string {
+	indices := make([]string, 0, len(res.GPUs))
+	for _, index := range res.GPUs {
+		indices = append(indices, strconv.Itoa(index))
+	}
+	return strings.Join(indices, ",")
+}
+
+// cgroupFiles returns the cgroup v2 interface files that enforce res.
+func (res Resources) cgroupFiles() map[string]string {
+	files := make(map[string]string)
+	if res.CPUs > 0 {
+		quota := int64(res.CPUs * cpuPeriod)
+		if quota < 1000 {
+			quota = 1000
+		}
+		files["cpu.max"] = strconv.FormatInt(quota, 10) + " " + strconv.Itoa(cpuPeriod)
+	}
+	if res.MemoryMax > 0 {
+		files["memory.max"] = strconv.FormatInt(res.MemoryMax, 10)
+	}
+	if res.PidsMax > 0 {
+		files["pids.max"] = strconv.FormatInt(res.PidsMax, 10)
+	}
+	return files
+}
+
+func (res Resources) cgroupControllers() []string {
+	var controllers []string
+	if res.CPUs > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	if res.MemoryMax > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if res.PidsMax > 0 {
+		controllers = append(controllers, "pids")
+	}
+	return controllers
+}
+
+// createCgroup creates <root>/<parent>/<name> with the limits of res. It
+// returns an empty path when res sets no cgroup limits.
+func (m *Manager) createCgroup(name string, res Resources) (string, error) {
+	if !res.hasCgroupLimits() {
+		return "", nil
+	}
+	if _, err := os.Stat(filepath.Join(m.cgroupRoot, "cgroup.controllers")); err != nil {
+		return "", i18n.Errorf("cgroup v2 is not available at %s", m.cgroupRoot)
+	}
+	parent := filepath.Join(m.cgroupRoot, m.cgroupParent)
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return "", i18n.Errorf("create cgroup %s: %w", parent, err)
+	}
+	controllers := res.cgroupControllers()
+	for _, dir := range []string{m.cgroupRoot, parent} {
+		if err := enableControllers(dir, controllers); err != nil {
+			return "", err
+		}
+	}
+
+	path := filepath.Join(parent, name)
+	if err := os.Mkdir(path, 0o755); err != nil && !errors.Is(err, os.ErrExist) {
+		return "", i18n.Errorf("create cgroup %s: %w", path, err)
+	}
+	for file, value := range res.cgroupFiles() {
+		if err := os.WriteFile(filepath.Join(path, file), []byte(value), 0o644); err != nil {
+			return "", i18n.Errorf("set %s: %w", file, err)
+		}
+	}
+	return path, nil
+}
+
+func enableControllers(dir string, controllers []string) error {
+	enabled, _ := os.ReadFile(filepath.Join(dir, "cgroup.subtree_control"))
+	have := strings.Fields(string(enabled))
+	var missing []string
+	for _, controller := range controllers {
+		found := false
+		for _, name := range have {
+			if name == controller {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, "+"+controller)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte(strings.Join(missing, " ")), 0o644); err != nil {
+		return i18n.Errorf("enable cgroup controllers in %s: %w", dir, err)
+	}
+	return nil
+}
+
+func attachCgroup(path string, pid int) error {
+	if err := os.WriteFile(filepath.Join(path, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0o644); err != nil {
+		return i18n.Errorf("move process %d into cgroup: %w", pid, err)
+	}
+	return nil
+}
+
+// killCgroup kills every process left in the cgroup at path and waits
+// briefly for them to go so that the cgroup can be removed. Kernels before
+// 5.14 have no cgroup.kill; there the stragglers keep the cgroup alive.
+func killCgroup(path string) {
+	if path == "" {
+		return
+	}
+	file, err := os.OpenFile(filepath.Join(path, "cgroup.kill"), os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	_, err = file.WriteString("1")
+	file.Close()
+	if err != nil {
+		log.Debug().Err(err).Str("cgroup", path).Msg(i18n.T("failed to kill cgroup"))
+		return
+	}
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		procs, err := os.ReadFile(filepath.Join(path, "cgroup.procs"))
+		if err != nil || strings.TrimSpace(string(procs)) == "" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// removeCgroup deletes the cgroup of an exited module. It fails harmlessly
+// while stragglers are still inside.
+func removeCgroup(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Debug().Err(err).Str("cgroup", path).Msg(i18n.T("failed to remove cgroup"))
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package runtime
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+func startInCgroup(*exec.Cmd, string) (*os.File, error) {
+	return nil, errors.New("cgroups are only available on Linux")
+}
@@ -0,0 +1,124 @@
+package runtime
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fakeCgroupRoot(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpu memory pids"), 0o644); err != nil {
+		t.Fatalf("write cgroup.controllers: %v", err)
+	}
+	return root
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func TestCreateCgroupWritesLimits(t *testing.T) {
+	manager := newTestManager(t, t.TempDir())
+	manager.cgroupRoot = fakeCgroupRoot(t)
+
+	path, err := manager.createCgroup("llama", Resources{CPUs: 2.5, MemoryMax: 8 << 30, PidsMax: 512})
+	if err != nil {
+		t.Fatalf("createCgroup returned error: %v", err)
+	}
+	if path != filepath.Join(manager.cgroupRoot, defaultCgroupParent, "llama") {
+		t.Fatalf("unexpected cgroup path %s", path)
+	}
+	want := map[string]string{
+		"cpu.max":    "250000 100000",
+		"memory.max": strconv.FormatInt(8<<30, 10),
+		"pids.max":   "512",
+	}
+	for file, value := range want {
+		if got := readFile(t, filepath.Join(path, file)); got != value {
+			t.Fatalf("%s = %q, want %q", file, got, value)
+		}
+	}
+	for _, dir := range []string{manager.cgroupRoot, filepath.Dir(path)} {
+		if got := readFile(t, filepath.Join(dir, "cgroup.subtree_control")); got != "+cpu +memory +pids" {
+			t.Fatalf("unexpected subtree_control in %s: %q", dir, got)
+		}
+	}
+
+	if path, err := manager.createCgroup("gpu-only", Resources{GPUs: []int{1}}); err != nil || path != "" {
+		t.Fatalf("GPU-only limits need no cgroup, got %q (%v)", path, err)
+	}
+	manager.cgroupRoot = t.TempDir()
+	if _, err := manager.createCgroup("llama", Resources{PidsMax: 1}); err == nil {
+		t.Fatalf("expected an error without cgroup v2")
+	}
+}
+
+func TestStartNativeAppliesResources(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	manager := newTestManager(t, t.TempDir())
+	manager.cgroupRoot = fakeCgroupRoot(t)
+
+	started, err := manager.Start(context.Background(), ModuleSpec{
+		Name:      "vllm",
+		Command:   []string{"sh", "-c", `echo "cuda=$CUDA_VISIBLE_DEVICES rocr=$ROCR_VISIBLE_DEVICES"; exec sleep 30`},
+		Env:       map[string]string{"ROCR_VISIBLE_DEVICES": "7"},
+		Resources: Resources{MemoryMax: 1 << 30, GPUs: []int{0, 2}},
+	})
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	defer manager.Stop(context.Background(), "vllm")
+
+	status, _ := manager.Status("vllm")
+	if status.Cgroup == "" {
+		t.Fatalf("expected the module to be placed in a cgroup, got %+v", status)
+	}
+	if got := readFile(t, filepath.Join(status.Cgroup, "cgroup.procs")); got != strconv.Itoa(started.PID) {
+		t.Fatalf("cgroup.procs = %q, want %d", got, started.PID)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(status.LogPath)
+		if strings.Contains(string(data), "cuda=0,2 rocr=7") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected GPU environment in log: %q", data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := manager.Start(context.Background(), ModuleSpec{Name: "bad", Command: []string{"true"}, Resources: Resources{CPUs: -1}}); err == nil {
+		t.Fatalf("expected negative limits to be rejected")
+	}
+}
+
+func TestContainerResourceArgs(t *testing.T) {
+	res := Resources{CPUs: 1.5, MemoryMax: 4 << 30, PidsMax: 256, GPUs: []int{0, 1}}
+	docker := strings.Join(containerResourceArgs("docker", res), " ")
+	if docker != `--cpus 1.5 --memory 4294967296 --pids-limit 256 --gpus "device=0,1"` {
+		t.Fatalf("unexpected docker args: %s", docker)
+	}
+	podman := strings.Join(containerResourceArgs("/usr/bin/podman", Resources{GPUs: []int{0, 1}}), " ")
+	if podman != "--device nvidia.com/gpu=0 --device nvidia.com/gpu=1" {
+		t.Fatalf("unexpected podman args: %s", podman)
+	}
+	if args := containerResourceArgs("docker", Resources{}); len(args) != 0 {
+		t.Fatalf("expected no args without limits, got %v", args)
+	}
+}
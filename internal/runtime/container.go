@@ -4,7 +4,11 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
@@ -22,28 +26,21 @@ func (m *Manager) startContainer(ctx context.Context, spec ModuleSpec, proc *pro
 	if spec.Image == "" {
 		return i18n.Errorf("container image is required")
 	}
-
-	args := []string{"run", "-d", "--rm", "--name", name}
-	for key, value := range spec.Env {
-		args = append(args, "-e", fmt.Sprintf("%s=%s", key, value))
-	}
-	if spec.WorkDir != "" {
-		args = append(args, "-w", spec.WorkDir)
-	}
-	args = append(args, spec.Image)
-	if len(spec.Command) > 0 {
-		args = append(args, spec.Command...)
-	}
-	if len(spec.Args) > 0 {
-		args = append(args, spec.Args...)
+	args, err := containerRunArgs(containerBin, name, spec)
+	if err != nil {
+		return err
 	}
 
 	cmd := exec.CommandContext(ctx, containerBin, args...)
-	output, err := cmd.CombinedOutput()
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
 	if err != nil {
-		return i18n.Errorf("start container: %w (%s)", err, strings.TrimSpace(string(output)))
+		return i18n.Errorf("start container: %w (%s)", err, strings.TrimSpace(stderr.String()))
 	}
-	containerID := strings.TrimSpace(string(output))
+	// Pull progress may precede the id, which is always the last line.
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	containerID := strings.TrimSpace(lines[len(lines)-1])
 	if containerID == "" {
 		return i18n.Errorf("container runtime did not return container id")
 	}
@@ -52,16 +49,177 @@ func (m *Manager) startContainer(ctx context.Context, spec ModuleSpec, proc *pro
 	proc.status.ContainerID = containerID
 	proc.containerID = containerID
 	proc.containerBin = containerBin
+	proc.done = make(chan struct{})
 
-	m.startContainerLogs(proc)
+	m.startContainerLogs(proc, time.Time{})
 	go m.watchContainer(proc)
 	return nil
 }
 
-func (m *Manager) startContainerLogs(proc *process) {
+// containerLabelModule tags every container with the module that owns it.
+const containerLabelModule = "io.localaistack.module"
+
+// containerRunArgs builds the `run` arguments for spec. Docker and Podman
+// accept the same flags except for GPU requests.
+func containerRunArgs(containerBin, name string, spec ModuleSpec) ([]string, error) {
+	args := []string{"run", "-d", "--rm", "--name", name}
+	switch spec.PullPolicy {
+	case "":
+	case PullAlways, PullMissing, PullNever:
+		args = append(args, "--pull", string(spec.PullPolicy))
+	default:
+		return nil, i18n.Errorf("invalid pull policy %q", spec.PullPolicy)
+	}
+	if spec.User != "" {
+		args = append(args, "--user", spec.User)
+	}
+	labels := map[string]string{containerLabelModule: spec.Name}
+	for key, value := range spec.Labels {
+		labels[key] = value
+	}
+	for _, key := range sortedKeys(labels) {
+		args = append(args, "--label", key+"="+labels[key])
+	}
+	for _, key := range sortedKeys(spec.Env) {
+		args = append(args, "-e", fmt.Sprintf("%s=%s", key, spec.Env[key]))
+	}
+	if spec.WorkDir != "" {
+		args = append(args, "-w", spec.WorkDir)
+	}
+	for _, port := range spec.Ports {
+		publish, err := port.publishArg()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-p", publish)
+	}
+	for _, mount := range spec.Mounts {
+		mountArg, err := mount.mountArg()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--mount", mountArg)
+	}
+	args = append(args, containerResourceArgs(containerBin, spec.Resources)...)
+	args = append(args, spec.Image)
+	args = append(args, spec.Command...)
+	args = append(args, spec.Args...)
+	return args, nil
+}
+
+func (p PortMapping) publishArg() (string, error) {
+	if p.ContainerPort <= 0 || p.ContainerPort > 65535 || p.HostPort < 0 || p.HostPort > 65535 {
+		return "", i18n.Errorf("invalid port mapping %d:%d", p.HostPort, p.ContainerPort)
+	}
+	publish := strconv.Itoa(p.ContainerPort)
+	if protocol := strings.ToLower(p.Protocol); protocol != "" && protocol != "tcp" {
+		if protocol != "udp" {
+			return "", i18n.Errorf("invalid port protocol %q", p.Protocol)
+		}
+		publish += "/udp"
+	}
+	host := ""
+	if p.HostPort > 0 {
+		host = strconv.Itoa(p.HostPort)
+	}
+	if p.HostIP != "" {
+		ip := p.HostIP
+		if strings.Contains(ip, ":") {
+			ip = "[" + ip + "]"
+		}
+		return ip + ":" + host + ":" + publish, nil
+	}
+	if host != "" {
+		return host + ":" + publish, nil
+	}
+	return publish, nil
+}
+
+func (mount Mount) mountArg() (string, error) {
+	if mount.Source == "" || mount.Target == "" {
+		return "", i18n.Errorf("mount requires a source and a target")
+	}
+	if !filepath.IsAbs(mount.Source) {
+		return "", i18n.Errorf("mount source %q must be an absolute path", mount.Source)
+	}
+	if strings.Contains(mount.Source, ",") || strings.Contains(mount.Target, ",") {
+		return "", i18n.Errorf("mount paths must not contain commas")
+	}
+	arg := "type=bind,source=" + mount.Source + ",target=" + mount.Target
+	if !mount.ReadWrite {
+		arg += ",readonly"
+	}
+	return arg, nil
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containerResourceArgs(containerBin string, res Resources) []string {
+	var args []string
+	if res.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(res.CPUs, 'f', -1, 64))
+	}
+	if res.MemoryMax > 0 {
+		args = append(args, "--memory", strconv.FormatInt(res.MemoryMax, 10))
+	}
+	if res.PidsMax > 0 {
+		args = append(args, "--pids-limit", strconv.FormatInt(res.PidsMax, 10))
+	}
+	return append(args, containerGPUArgs(containerBin, res)...)
+}
+
+func containerGPUArgs(containerBin string, res Resources) []string {
+	if len(res.GPUs) == 0 && !res.AllGPUs {
+		return nil
+	}
+	if strings.EqualFold(res.GPUVendor, "amd") {
+		// ROCm needs the kernel driver and render nodes; visibility is
+		// narrowed inside the container.
+		args := []string{"--device", "/dev/kfd", "--device", "/dev/dri", "--group-add", "video"}
+		if !res.AllGPUs {
+			args = append(args, "-e", "ROCR_VISIBLE_DEVICES="+res.gpuList())
+		}
+		return args
+	}
+	if isPodman(containerBin) {
+		// Podman exposes NVIDIA GPUs through CDI device names.
+		if res.AllGPUs {
+			return []string{"--device", "nvidia.com/gpu=all"}
+		}
+		var args []string
+		for _, index := range res.GPUs {
+			args = append(args, "--device", "nvidia.com/gpu="+strconv.Itoa(index))
+		}
+		return args
+	}
+	if res.AllGPUs {
+		return []string{"--gpus", "all"}
+	}
+	return []string{"--gpus", `"device=` + res.gpuList() + `"`}
+}
+
+func isPodman(containerBin string) bool {
+	return strings.Contains(filepath.Base(containerBin), "podman")
+}
+
+// startContainerLogs follows the container output into the log file. A
+// non-zero since skips output that an earlier server already captured.
+func (m *Manager) startContainerLogs(proc *process, since time.Time) {
 	ctx, cancel := context.WithCancel(context.Background())
 	proc.cancelLogs = cancel
-	logCmd := exec.CommandContext(ctx, proc.containerBin, "logs", "-f", proc.containerID)
+	args := []string{"logs", "-f"}
+	if !since.IsZero() {
+		args = append(args, "--since", since.UTC().Format(time.RFC3339))
+	}
+	args = append(args, proc.containerID)
+	logCmd := exec.CommandContext(ctx, proc.containerBin, args...)
 	logCmd.Stdout = proc.logFile
 	logCmd.Stderr = proc.logFile
 	if err := logCmd.Start(); err != nil {
@@ -76,29 +234,68 @@ func (m *Manager) startContainerLogs(proc *process) {
 
 func (m *Manager) watchContainer(proc *process) {
 	cmd := exec.Command(proc.containerBin, "wait", proc.containerID)
-	if err := cmd.Run(); err != nil {
+	output, err := cmd.Output()
+	var exitCode *int
+	if err == nil {
+		if code, convErr := strconv.Atoi(strings.TrimSpace(string(output))); convErr == nil {
+			exitCode = &code
+			if code != 0 {
+				err = i18n.Errorf("container exited with code %d", code)
+			}
+		}
+	}
+
+	m.mu.Lock()
+	stopping := proc.stopping
+	m.mu.Unlock()
+	if stopping {
+		m.markStopped(proc, nil)
+	} else {
 		m.markStopped(proc, err)
-		m.stopLogStream(proc)
-		return
 	}
-	m.markStopped(proc, nil)
 	m.stopLogStream(proc)
+	m.handleExit(proc, exitCode, err)
+	if proc.done != nil {
+		close(proc.done)
+	}
 }
 
 func (m *Manager) stopContainer(ctx context.Context, proc *process) error {
 	if proc.containerBin == "" || proc.containerID == "" {
 		return nil
 	}
-	cmd := exec.CommandContext(ctx, proc.containerBin, "stop", "-t", "10", proc.containerID)
+	grace := int(stopGracePeriod(proc.spec).Round(time.Second) / time.Second)
+	cmd := exec.CommandContext(ctx, proc.containerBin, "stop", "-t", strconv.Itoa(grace), proc.containerID)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return i18n.Errorf("stop container: %w (%s)", err, strings.TrimSpace(string(output)))
 	}
+	if proc.done != nil {
+		// watchContainer records the exit code reported by `wait`.
+		select {
+		case <-proc.done:
+		case <-ctx.Done():
+		}
+	}
+	m.mu.Lock()
+	proc.status.StopSignal = containerStopSignal(proc.status.LastExitCode)
+	m.mu.Unlock()
 	m.markStopped(proc, nil)
 	m.stopLogStream(proc)
+	m.saveState(proc)
 	return nil
 }
 
+// containerStopSignal maps the exit code of a stopped container to the
+// signal that ended it: 128+9 after the grace period ran out, otherwise the
+// SIGTERM sent by `stop`.
+func containerStopSignal(exitCode *int) string {
+	if exitCode != nil && *exitCode == 128+int(syscall.SIGKILL) {
+		return "SIGKILL"
+	}
+	return "SIGTERM"
+}
+
 func (m *Manager) resolveContainerRuntime(preferred string) (string, error) {
 	if !m.dockerEnabled {
 		return "", i18n.Errorf("container runtime disabled")
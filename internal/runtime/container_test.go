@@ -0,0 +1,166 @@
+package runtime
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/config"
+)
+
+// fakeContainerScript mimics the subset of the docker/podman CLI used by the
+// manager. Every invocation is appended to calls.log in its directory.
+const fakeContainerScript = `#!/bin/sh
+dir=$(dirname "$0")
+echo "$@" >> "$dir/calls.log"
+case "$1" in
+run)
+	echo "Pulling image..." >&2
+	echo "Trying to pull"
+	echo "c0ffee1234567890"
+	;;
+wait)
+	while [ ! -f "$dir/stopped" ]; do sleep 0.02; done
+	echo 143
+	;;
+stop)
+	touch "$dir/stopped"
+	echo "$3"
+	;;
+logs)
+	echo "server listening"
+	;;
+inspect)
+	echo running
+	;;
+esac
+`
+
+func fakeContainerRuntime(t *testing.T, name string) (string, string) {
+	t.Helper()
+	if goruntime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	bin := filepath.Join(dir, name)
+	if err := os.WriteFile(bin, []byte(fakeContainerScript), 0o755); err != nil {
+		t.Fatalf("write fake runtime: %v", err)
+	}
+	return bin, filepath.Join(dir, "calls.log")
+}
+
+func newContainerTestManager(t *testing.T) *Manager {
+	t.Helper()
+	return NewManager(config.RuntimeConfig{
+		DockerEnabled: true,
+		DefaultMode:   string(ModeContainer),
+		LogDir:        t.TempDir(),
+	})
+}
+
+func containerCalls(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read calls: %v", err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestStartContainerWithFakeDocker(t *testing.T) {
+	bin, calls := fakeContainerRuntime(t, "docker")
+	manager := newContainerTestManager(t)
+
+	started, err := manager.Start(context.Background(), ModuleSpec{
+		Name:             "vllm",
+		Image:            "vllm/vllm-openai:latest",
+		Args:             []string{"--model", "/models/qwen"},
+		Env:              map[string]string{"HF_HOME": "/models/.hf", "B": "2"},
+		ContainerName:    "las-vllm",
+		ContainerRuntime: bin,
+		Ports:            []PortMapping{{HostIP: "127.0.0.1", HostPort: 8000, ContainerPort: 8000}},
+		Mounts: []Mount{
+			{Source: "/var/lib/localaistack/models", Target: "/models"},
+			{Source: "/tmp/cache", Target: "/root/.cache", ReadWrite: true},
+		},
+		Labels:     map[string]string{"io.localaistack.model": "qwen"},
+		User:       "1000:1000",
+		PullPolicy: PullMissing,
+		Resources:  Resources{AllGPUs: true, MemoryMax: 1 << 30},
+	})
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	if started.ContainerID != "c0ffee1234567890" {
+		t.Fatalf("expected the id from the last line of output, got %q", started.ContainerID)
+	}
+
+	run := containerCalls(t, calls)[0]
+	want := "run -d --rm --name las-vllm --pull missing --user 1000:1000" +
+		" --label io.localaistack.model=qwen --label io.localaistack.module=vllm" +
+		" -e B=2 -e HF_HOME=/models/.hf" +
+		" -p 127.0.0.1:8000:8000" +
+		" --mount type=bind,source=/var/lib/localaistack/models,target=/models,readonly" +
+		" --mount type=bind,source=/tmp/cache,target=/root/.cache" +
+		" --memory 1073741824 --gpus all" +
+		" vllm/vllm-openai:latest --model /models/qwen"
+	if run != want {
+		t.Fatalf("unexpected run args:\n got: %s\nwant: %s", run, want)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := manager.Stop(ctx, "vllm"); err != nil {
+		t.Fatalf("Stop returned error: %v", err)
+	}
+	status, _ := manager.Status("vllm")
+	if status.State != StateStopped || status.StopSignal != "SIGTERM" || status.LastExitCode == nil || *status.LastExitCode != 143 {
+		t.Fatalf("unexpected status after stop: %+v", status)
+	}
+	found := false
+	for _, call := range containerCalls(t, calls) {
+		if call == "stop -t 10 c0ffee1234567890" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected stop with the grace period, got %v", containerCalls(t, calls))
+	}
+}
+
+func TestContainerRunArgsPodmanGPUs(t *testing.T) {
+	args, err := containerRunArgs("/usr/bin/podman", "ollama", ModuleSpec{
+		Name:      "ollama",
+		Image:     "ollama/ollama",
+		Ports:     []PortMapping{{ContainerPort: 11434}, {HostPort: 5353, ContainerPort: 53, Protocol: "udp"}},
+		Resources: Resources{GPUs: []int{1}},
+	})
+	if err != nil {
+		t.Fatalf("containerRunArgs returned error: %v", err)
+	}
+	got := strings.Join(args, " ")
+	want := "run -d --rm --name ollama --label io.localaistack.module=ollama -p 11434 -p 5353:53/udp --device nvidia.com/gpu=1 ollama/ollama"
+	if got != want {
+		t.Fatalf("unexpected podman args:\n got: %s\nwant: %s", got, want)
+	}
+
+	amd := strings.Join(containerGPUArgs("docker", Resources{GPUs: []int{0, 1}, GPUVendor: "amd"}), " ")
+	if amd != "--device /dev/kfd --device /dev/dri --group-add video -e ROCR_VISIBLE_DEVICES=0,1" {
+		t.Fatalf("unexpected amd args: %s", amd)
+	}
+
+	invalid := []ModuleSpec{
+		{Name: "x", Image: "i", PullPolicy: "sometimes"},
+		{Name: "x", Image: "i", Ports: []PortMapping{{ContainerPort: 70000}}},
+		{Name: "x", Image: "i", Mounts: []Mount{{Source: "models", Target: "/models"}}},
+	}
+	for _, spec := range invalid {
+		if _, err := containerRunArgs("docker", "x", spec); err == nil {
+			t.Fatalf("expected %+v to be rejected", spec)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package runtime
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+)
+
+const (
+	defaultHealthInterval         = 30 * time.Second
+	defaultHealthTimeout          = 5 * time.Second
+	defaultHealthFailureThreshold = 3
+	// healthBodyLimit caps how much of an HTTP response is searched for
+	// BodyContains.
+	healthBodyLimit = 1 << 20
+)
+
+func validateHealthCheck(check HealthCheck) error {
+	kinds := 0
+	if len(check.Command) > 0 {
+		kinds++
+	}
+	if check.HTTP != nil {
+		kinds++
+		if strings.TrimSpace(check.HTTP.URL) == "" {
+			return i18n.Errorf("http health check requires a url")
+		}
+	}
+	if check.TCP != nil {
+		kinds++
+		if strings.TrimSpace(check.TCP.Address) == "" {
+			return i18n.Errorf("tcp health check requires an address")
+		}
+	}
+	if kinds > 1 {
+		return i18n.Errorf("health check must use only one of command, http or tcp")
+	}
+	return nil
+}
+
+func (m *Manager) startHealthMonitor(proc *process) {
+	if proc.healthCheck.Interval == 0 {
+		proc.healthCheck.Interval = defaultHealthInterval
+	}
+	if proc.healthCheck.Timeout == 0 {
+		proc.healthCheck.Timeout = defaultHealthTimeout
+	}
+	if proc.healthCheck.FailureThreshold <= 0 {
+		proc.healthCheck.FailureThreshold = defaultHealthFailureThreshold
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.mu.Lock()
+	if proc.status.State != StateRunning && proc.status.State != StateStarting {
+		// The process already exited; waitForExit will not cancel us.
+		m.mu.Unlock()
+		cancel()
+		return
+	}
+	proc.cancelHealth = cancel
+	m.mu.Unlock()
+	go func() {
+		ticker := time.NewTicker(proc.healthCheck.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.recordHealth(proc, m.checkHealth(ctx, proc), time.Now())
+			}
+		}
+	}()
+}
+
+func (m *Manager) stopHealthMonitor(proc *process) {
+	m.mu.Lock()
+	cancel := proc.cancelHealth
+	m.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// recordHealth folds one probe result into the reported health. Failures
+// inside the start period are ignored until the first success, and the
+// state only turns unhealthy after FailureThreshold consecutive failures.
+func (m *Manager) recordHealth(proc *process, result HealthState, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if proc.status.State != StateRunning {
+		return
+	}
+	switch result {
+	case HealthHealthy:
+		proc.healthFailures = 0
+		proc.healthPassed = true
+		proc.status.Health = HealthHealthy
+	case HealthUnhealthy:
+		if !proc.healthPassed && now.Sub(proc.status.StartedAt) < proc.healthCheck.StartPeriod {
+			return
+		}
+		proc.healthFailures++
+		threshold := proc.healthCheck.FailureThreshold
+		if threshold <= 0 {
+			threshold = defaultHealthFailureThreshold
+		}
+		if proc.healthFailures >= threshold {
+			proc.status.Health = HealthUnhealthy
+		}
+	}
+}
+
+func (m *Manager) checkHealth(ctx context.Context, proc *process) HealthState {
+	check := proc.healthCheck
+	switch {
+	case check.HTTP != nil:
+		return probeHTTP(ctx, *check.HTTP, check.Timeout)
+	case check.TCP != nil:
+		return probeTCP(ctx, check.TCP.Address, check.Timeout)
+	}
+	switch proc.status.Mode {
+	case ModeNative:
+		return m.checkNativeHealth(proc)
+	case ModeContainer:
+		return m.checkContainerHealth(proc)
+	default:
+		return HealthUnknown
+	}
+}
+
+func probeHTTP(ctx context.Context, check HTTPHealthCheck, timeout time.Duration) HealthState {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.URL, nil)
+	if err != nil {
+		return HealthUnhealthy
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return HealthUnhealthy
+	}
+	defer resp.Body.Close()
+
+	if check.ExpectedStatus != 0 {
+		if resp.StatusCode != check.ExpectedStatus {
+			return HealthUnhealthy
+		}
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return HealthUnhealthy
+	}
+	if check.BodyContains != "" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, healthBodyLimit))
+		if err != nil || !strings.Contains(string(body), check.BodyContains) {
+			return HealthUnhealthy
+		}
+	}
+	return HealthHealthy
+}
+
+func probeTCP(ctx context.Context, address string, timeout time.Duration) HealthState {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return HealthUnhealthy
+	}
+	conn.Close()
+	return HealthHealthy
+}
+
+func (m *Manager) checkNativeHealth(proc *process) HealthState {
+	if len(proc.healthCheck.Command) == 0 {
+		if proc.status.State == StateRunning {
+			return HealthHealthy
+		}
+		if proc.status.State == StateFailed || proc.status.State == StateStopped {
+			return HealthUnhealthy
+		}
+		return HealthUnknown
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), proc.healthCheck.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, proc.healthCheck.Command[0], proc.healthCheck.Command[1:]...)
+	if err := cmd.Run(); err != nil {
+		return HealthUnhealthy
+	}
+	return HealthHealthy
+}
+
+func (m *Manager) checkContainerHealth(proc *process) HealthState {
+	if proc.containerBin == "" || proc.containerID == "" {
+		return HealthUnknown
+	}
+	if len(proc.healthCheck.Command) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), proc.healthCheck.Timeout)
+		defer cancel()
+		args := append([]string{"exec", proc.containerID}, proc.healthCheck.Command...)
+		cmd := exec.CommandContext(ctx, proc.containerBin, args...)
+		if err := cmd.Run(); err != nil {
+			return HealthUnhealthy
+		}
+		return HealthHealthy
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, proc.containerBin, "inspect", "--format", "{{if .State.Health}}{{.State.Health.Status}}{{else}}{{.State.Status}}{{end}}", proc.containerID)
+	output, err := cmd.Output()
+	if err != nil {
+		return HealthUnknown
+	}
+	status := strings.TrimSpace(strings.ToLower(string(output)))
+	switch status {
+	case "healthy", "running":
+		return HealthHealthy
+	case "unhealthy", "exited", "dead":
+		return HealthUnhealthy
+	default:
+		return HealthUnknown
+	}
+}
@@ -0,0 +1,147 @@
+package runtime
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	goruntime "runtime"
+	"testing"
+	"time"
+)
+
+func TestProbeHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.Write([]byte(`{"status":"ok"}`))
+		case "/loading":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"loading model"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		name  string
+		check HTTPHealthCheck
+		want  HealthState
+	}{
+		{"2xx by default", HTTPHealthCheck{URL: srv.URL + "/health"}, HealthHealthy},
+		{"body match", HTTPHealthCheck{URL: srv.URL + "/health", BodyContains: `"ok"`}, HealthHealthy},
+		{"body mismatch", HTTPHealthCheck{URL: srv.URL + "/health", BodyContains: "ready"}, HealthUnhealthy},
+		{"non-2xx", HTTPHealthCheck{URL: srv.URL + "/loading"}, HealthUnhealthy},
+		{"expected status", HTTPHealthCheck{URL: srv.URL + "/loading", ExpectedStatus: http.StatusServiceUnavailable}, HealthHealthy},
+		{"unexpected status", HTTPHealthCheck{URL: srv.URL + "/health", ExpectedStatus: http.StatusNoContent}, HealthUnhealthy},
+		{"not found", HTTPHealthCheck{URL: srv.URL + "/api/tags"}, HealthUnhealthy},
+	}
+	for _, tc := range cases {
+		if got := probeHTTP(context.Background(), tc.check, time.Second); got != tc.want {
+			t.Fatalf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestProbeTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	address := listener.Addr().String()
+	if got := probeTCP(context.Background(), address, time.Second); got != HealthHealthy {
+		t.Fatalf("expected open port to be healthy, got %s", got)
+	}
+	listener.Close()
+	if got := probeTCP(context.Background(), address, time.Second); got != HealthUnhealthy {
+		t.Fatalf("expected closed port to be unhealthy, got %s", got)
+	}
+}
+
+func TestRecordHealthStartPeriodAndThreshold(t *testing.T) {
+	manager := newTestManager(t, t.TempDir())
+	started := time.Now()
+	proc := &process{
+		status:      Status{Name: "llama", State: StateRunning, Health: HealthUnknown, StartedAt: started},
+		healthCheck: HealthCheck{StartPeriod: time.Minute, FailureThreshold: 2},
+	}
+
+	manager.recordHealth(proc, HealthUnhealthy, started.Add(10*time.Second))
+	manager.recordHealth(proc, HealthUnhealthy, started.Add(20*time.Second))
+	if proc.status.Health != HealthUnknown || proc.healthFailures != 0 {
+		t.Fatalf("failures inside the start period must be ignored, got %s/%d", proc.status.Health, proc.healthFailures)
+	}
+
+	manager.recordHealth(proc, HealthHealthy, started.Add(30*time.Second))
+	if proc.status.Health != HealthHealthy {
+		t.Fatalf("expected healthy, got %s", proc.status.Health)
+	}
+	manager.recordHealth(proc, HealthUnhealthy, started.Add(40*time.Second))
+	if proc.status.Health != HealthHealthy {
+		t.Fatalf("a single failure below the threshold must not flip health, got %s", proc.status.Health)
+	}
+	manager.recordHealth(proc, HealthUnhealthy, started.Add(50*time.Second))
+	if proc.status.Health != HealthUnhealthy {
+		t.Fatalf("expected unhealthy after reaching the threshold, got %s", proc.status.Health)
+	}
+	manager.recordHealth(proc, HealthHealthy, started.Add(60*time.Second))
+	if proc.status.Health != HealthHealthy || proc.healthFailures != 0 {
+		t.Fatalf("a success must reset the failure count, got %s/%d", proc.status.Health, proc.healthFailures)
+	}
+}
+
+func TestHTTPHealthCheckDrivesStatus(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("requires a POSIX sleep binary")
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"models":[]}`))
+	}))
+
+	manager := newTestManager(t, t.TempDir())
+	_, err := manager.Start(context.Background(), ModuleSpec{
+		Name:    "ollama",
+		Command: []string{"sleep", "30"},
+		HealthCheck: HealthCheck{
+			HTTP:             &HTTPHealthCheck{URL: srv.URL + "/api/tags", BodyContains: "models"},
+			Interval:         10 * time.Millisecond,
+			Timeout:          time.Second,
+			FailureThreshold: 2,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	defer manager.Stop(context.Background(), "ollama")
+
+	waitForHealth(t, manager, "ollama", HealthHealthy)
+	srv.Close()
+	waitForHealth(t, manager, "ollama", HealthUnhealthy)
+	if status, _ := manager.Status("ollama"); status.State != StateRunning {
+		t.Fatalf("health must not change the process state, got %+v", status)
+	}
+
+	if _, err := manager.Start(context.Background(), ModuleSpec{
+		Name:        "ambiguous",
+		Command:     []string{"sleep", "30"},
+		HealthCheck: HealthCheck{HTTP: &HTTPHealthCheck{URL: srv.URL}, TCP: &TCPHealthCheck{Address: "127.0.0.1:1"}},
+	}); err == nil {
+		t.Fatalf("expected a health check with both http and tcp to be rejected")
+	}
+}
+
+func waitForHealth(t *testing.T, manager *Manager, name string, want HealthState) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, _ := manager.Status(name)
+		if status.Health == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("module %s never became %s, last status %+v", name, want, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -23,18 +23,33 @@ type Manager struct {
 	nativeEnabled bool
 	mu            sync.Mutex
 	processes     map[string]*process
+	stateMu       sync.Mutex
+	// adoptPollInterval is how often re-adopted native processes are checked.
+	adoptPollInterval time.Duration
+	cgroupRoot        string
+	cgroupParent      string
 }
 
 type process struct {
+	spec         ModuleSpec
 	status       Status
 	cmd          *exec.Cmd
 	containerID  string
 	containerBin string
-	cancelRun    context.CancelFunc
 	cancelLogs   context.CancelFunc
 	cancelHealth context.CancelFunc
 	logFile      *os.File
 	healthCheck  HealthCheck
+	stopping     bool
+	done         chan struct{}
+	pidStartTime uint64
+	adopted      bool
+	restartTimer *time.Timer
+	fastFailures int
+	// healthFailures counts consecutive failed probes; healthPassed is set
+	// once any probe succeeded, which ends the start period early.
+	healthFailures int
+	healthPassed   bool
 }
 
 func NewManager(cfg config.RuntimeConfig) *Manager {
@@ -47,30 +62,41 @@ func NewManager(cfg config.RuntimeConfig) *Manager {
 		mode = ModeContainer
 	}
 	return &Manager{
-		baseDir:       baseDir,
-		defaultMode:   mode,
-		dockerEnabled: cfg.DockerEnabled,
-		nativeEnabled: cfg.NativeEnabled,
-		processes:     make(map[string]*process),
+		baseDir:           baseDir,
+		defaultMode:       mode,
+		dockerEnabled:     cfg.DockerEnabled,
+		nativeEnabled:     cfg.NativeEnabled,
+		processes:         make(map[string]*process),
+		adoptPollInterval: 2 * time.Second,
+		cgroupRoot:        defaultCgroupRoot,
+		cgroupParent:      defaultCgroupParent,
 	}
 }
 
 func (m *Manager) Start(ctx context.Context, spec ModuleSpec) (*Status, error) {
-	if strings.TrimSpace(spec.Name) == "" {
-		return nil, i18n.Errorf("module name is required")
-	}
-	mode := spec.Mode
-	if mode == "" {
-		mode = m.defaultMode
-	}
-	if err := m.validateMode(mode); err != nil {
+	return m.start(ctx, spec, nil)
+}
+
+// start launches spec. prev is the process being replaced by an automatic
+// restart; its restart bookkeeping carries over to the new process.
+func (m *Manager) start(ctx context.Context, spec ModuleSpec, prev *process) (*Status, error) {
+	mode, err := m.validateSpec(spec)
+	if err != nil {
 		return nil, err
 	}
 
 	m.mu.Lock()
-	if existing, ok := m.processes[spec.Name]; ok && existing.status.State == StateRunning {
-		m.mu.Unlock()
-		return nil, i18n.Errorf("module %q already running", spec.Name)
+	if existing, ok := m.processes[spec.Name]; ok && existing != prev {
+		if existing.status.State == StateRunning || existing.status.State == StateStarting {
+			m.mu.Unlock()
+			return nil, i18n.Errorf("module %q already running", spec.Name)
+		}
+		if existing.status.State == StateUnknown {
+			m.mu.Unlock()
+			return nil, i18n.Errorf("module %q may still be running from before the server restarted; stop it first", spec.Name)
+		}
+		// A manual start supersedes any pending automatic restart.
+		existing.cancelRestart()
 	}
 	m.mu.Unlock()
 
@@ -86,13 +112,22 @@ func (m *Manager) Start(ctx context.Context, spec ModuleSpec) (*Status, error) {
 		Health:    HealthUnknown,
 		StartedAt: time.Now(),
 		LogPath:   logPath,
+		Labels:    spec.Labels,
 	}
 
 	proc := &process{
+		spec:        spec,
 		status:      status,
 		logFile:     logFile,
 		healthCheck: spec.HealthCheck,
 	}
+	if prev != nil {
+		m.mu.Lock()
+		proc.status.RestartCount = prev.status.RestartCount + 1
+		proc.status.LastExitCode = prev.status.LastExitCode
+		proc.fastFailures = prev.fastFailures
+		m.mu.Unlock()
+	}
 
 	switch mode {
 	case ModeNative:
@@ -111,20 +146,104 @@ func (m *Manager) Start(ctx context.Context, spec ModuleSpec) (*Status, error) {
 	}
 
 	m.mu.Lock()
+	if prev != nil && (prev.stopping || m.processes[spec.Name] != prev) {
+		// Stopped or replaced while the restart was launching.
+		m.mu.Unlock()
+		proc.stopping = true
+		_ = m.stopProcess(context.Background(), proc)
+		return nil, i18n.Errorf("restart of module %q was cancelled", spec.Name)
+	}
 	m.processes[spec.Name] = proc
 	m.mu.Unlock()
 
+	m.saveState(proc)
 	m.startHealthMonitor(proc)
 
 	return &proc.status, nil
 }
 
+// Register records spec as a stopped module without starting it, so that
+// it can be started later with the spec it was registered with.
+func (m *Manager) Register(spec ModuleSpec) (*Status, error) {
+	mode, err := m.validateSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	proc := &process{
+		spec: spec,
+		status: Status{
+			Name:   spec.Name,
+			Mode:   mode,
+			State:  StateStopped,
+			Health: HealthUnknown,
+			Labels: spec.Labels,
+		},
+		healthCheck: spec.HealthCheck,
+	}
+
+	m.mu.Lock()
+	if existing, ok := m.processes[spec.Name]; ok {
+		if existing.status.State == StateRunning || existing.status.State == StateStarting {
+			m.mu.Unlock()
+			return nil, i18n.Errorf("module %q already running", spec.Name)
+		}
+		existing.cancelRestart()
+		proc.status.LogPath = existing.status.LogPath
+	}
+	m.processes[spec.Name] = proc
+	m.mu.Unlock()
+
+	m.saveState(proc)
+	return &proc.status, nil
+}
+
+// Remove forgets a module that is not running, including its saved state.
+func (m *Manager) Remove(name string) error {
+	m.mu.Lock()
+	proc, ok := m.processes[name]
+	if !ok {
+		m.mu.Unlock()
+		return i18n.Errorf("module %q not found", name)
+	}
+	if proc.status.State == StateRunning || proc.status.State == StateStarting {
+		m.mu.Unlock()
+		return i18n.Errorf("module %q is running; stop it first", name)
+	}
+	proc.stopping = true
+	proc.cancelRestart()
+	delete(m.processes, name)
+	m.mu.Unlock()
+
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	if err := os.Remove(m.statePath(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return i18n.Errorf("remove runtime state: %w", err)
+	}
+	return nil
+}
+
 func (m *Manager) Stop(ctx context.Context, name string) error {
 	proc, ok := m.getProcess(name)
 	if !ok {
 		return i18n.Errorf("module %q not found", name)
 	}
 
+	m.mu.Lock()
+	proc.stopping = true
+	// An unknown process cannot be told apart from a reused PID, so it is
+	// forgotten rather than signalled.
+	if proc.restartTimer != nil || proc.status.State == StateRestarting || proc.status.State == StateCrashLoop || proc.status.State == StateUnknown {
+		proc.cancelRestart()
+		m.mu.Unlock()
+		m.markStopped(proc, nil)
+		return nil
+	}
+	m.mu.Unlock()
+
+	return m.stopProcess(ctx, proc)
+}
+
+func (m *Manager) stopProcess(ctx context.Context, proc *process) error {
 	var err error
 	switch proc.status.Mode {
 	case ModeNative:
@@ -149,6 +268,17 @@ func (m *Manager) Status(name string) (Status, bool) {
 	return proc.status, true
 }
 
+// Spec returns the spec a service was last started with.
+func (m *Manager) Spec(name string) (ModuleSpec, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	proc, ok := m.processes[name]
+	if !ok {
+		return ModuleSpec{}, false
+	}
+	return proc.spec, true
+}
+
 func (m *Manager) List() []Status {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -159,6 +289,30 @@ func (m *Manager) List() []Status {
 	return statuses
 }
 
+// validateSpec checks spec and returns the mode it runs in.
+func (m *Manager) validateSpec(spec ModuleSpec) (ExecutionMode, error) {
+	if strings.TrimSpace(spec.Name) == "" {
+		return "", i18n.Errorf("module name is required")
+	}
+	mode := spec.Mode
+	if mode == "" {
+		mode = m.defaultMode
+	}
+	if err := m.validateMode(mode); err != nil {
+		return "", err
+	}
+	if err := validateRestartPolicy(spec.Restart.Policy); err != nil {
+		return "", err
+	}
+	if err := validateHealthCheck(spec.HealthCheck); err != nil {
+		return "", err
+	}
+	if err := validateResources(spec.Resources); err != nil {
+		return "", err
+	}
+	return mode, nil
+}
+
 func (m *Manager) validateMode(mode ExecutionMode) error {
 	switch mode {
 	case ModeContainer:
@@ -216,10 +370,11 @@ func (m *Manager) updateProcess(name string, update func(*process)) {
 
 func (m *Manager) markStopped(proc *process, err error) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 	if proc.status.State == StateStopped || proc.status.State == StateFailed {
+		m.mu.Unlock()
 		return
 	}
+	proc.cancelRestart()
 	finished := time.Now()
 	proc.status.FinishedAt = &finished
 	if err != nil {
@@ -229,102 +384,8 @@ func (m *Manager) markStopped(proc *process, err error) {
 		proc.status.State = StateStopped
 	}
 	proc.status.Health = HealthUnhealthy
-}
-
-func (m *Manager) startHealthMonitor(proc *process) {
-	if proc.healthCheck.Interval == 0 {
-		proc.healthCheck.Interval = 30 * time.Second
-	}
-	if proc.healthCheck.Timeout == 0 {
-		proc.healthCheck.Timeout = 5 * time.Second
-	}
-	ctx, cancel := context.WithCancel(context.Background())
-	proc.cancelHealth = cancel
-	go func() {
-		ticker := time.NewTicker(proc.healthCheck.Interval)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				health := m.checkHealth(proc)
-				m.updateProcess(proc.status.Name, func(p *process) {
-					p.status.Health = health
-				})
-			}
-		}
-	}()
-}
-
-func (m *Manager) stopHealthMonitor(proc *process) {
-	if proc.cancelHealth != nil {
-		proc.cancelHealth()
-	}
-}
-
-func (m *Manager) checkHealth(proc *process) HealthState {
-	switch proc.status.Mode {
-	case ModeNative:
-		return m.checkNativeHealth(proc)
-	case ModeContainer:
-		return m.checkContainerHealth(proc)
-	default:
-		return HealthUnknown
-	}
-}
-
-func (m *Manager) checkNativeHealth(proc *process) HealthState {
-	if len(proc.healthCheck.Command) == 0 {
-		if proc.status.State == StateRunning {
-			return HealthHealthy
-		}
-		if proc.status.State == StateFailed || proc.status.State == StateStopped {
-			return HealthUnhealthy
-		}
-		return HealthUnknown
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), proc.healthCheck.Timeout)
-	defer cancel()
-	cmd := exec.CommandContext(ctx, proc.healthCheck.Command[0], proc.healthCheck.Command[1:]...)
-	if err := cmd.Run(); err != nil {
-		return HealthUnhealthy
-	}
-	return HealthHealthy
-}
-
-func (m *Manager) checkContainerHealth(proc *process) HealthState {
-	if proc.containerBin == "" || proc.containerID == "" {
-		return HealthUnknown
-	}
-	if len(proc.healthCheck.Command) > 0 {
-		ctx, cancel := context.WithTimeout(context.Background(), proc.healthCheck.Timeout)
-		defer cancel()
-		args := append([]string{"exec", proc.containerID}, proc.healthCheck.Command...)
-		cmd := exec.CommandContext(ctx, proc.containerBin, args...)
-		if err := cmd.Run(); err != nil {
-			return HealthUnhealthy
-		}
-		return HealthHealthy
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	cmd := exec.CommandContext(ctx, proc.containerBin, "inspect", "--format", "{{if .State.Health}}{{.State.Health.Status}}{{else}}{{.State.Status}}{{end}}", proc.containerID)
-	output, err := cmd.Output()
-	if err != nil {
-		return HealthUnknown
-	}
-	status := strings.TrimSpace(strings.ToLower(string(output)))
-	switch status {
-	case "healthy", "running":
-		return HealthHealthy
-	case "unhealthy", "exited", "dead":
-		return HealthUnhealthy
-	default:
-		return HealthUnknown
-	}
+	m.mu.Unlock()
+	m.saveState(proc)
 }
 
 func (m *Manager) stopLogStream(proc *process) {
@@ -334,7 +395,16 @@ func (m *Manager) stopLogStream(proc *process) {
 }
 
 func (m *Manager) waitForExit(proc *process, waitFunc func() error) {
-	if err := waitFunc(); err != nil {
+	err := waitFunc()
+	m.mu.Lock()
+	stopping := proc.stopping
+	m.mu.Unlock()
+	if !stopping {
+		// Workers left by a leader that exited on its own would hold on to
+		// GPU memory next to a restarted instance. Stop ends them itself.
+		reapNative(proc)
+	}
+	if err != nil && !stopping {
 		m.markStopped(proc, err)
 	} else {
 		m.markStopped(proc, nil)
@@ -342,6 +412,10 @@ func (m *Manager) waitForExit(proc *process, waitFunc func() error) {
 	m.stopLogStream(proc)
 	m.stopHealthMonitor(proc)
 	m.closeLogFile(proc)
+	m.handleExit(proc, exitCodeOf(err), err)
+	if proc.done != nil {
+		close(proc.done)
+	}
 }
 
 func buildEnv(env map[string]string) []string {
@@ -2,65 +2,208 @@ package runtime
 
 import (
 	"context"
+	"errors"
+	"os"
 	"os/exec"
+	"syscall"
+	"time"
 
+	"github.com/rs/zerolog/log"
 	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
 )
 
+const defaultStopGracePeriod = 10 * time.Second
+
 func (m *Manager) startNative(_ context.Context, spec ModuleSpec, proc *process) error {
 	command, err := joinCommand(spec.Command, spec.Args)
 	if err != nil {
 		return err
 	}
-	ctx, cancel := context.WithCancel(context.Background())
-	proc.cancelRun = cancel
 
-	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
-	cmd.Stdout = proc.logFile
-	cmd.Stderr = proc.logFile
-	if spec.WorkDir != "" {
-		cmd.Dir = spec.WorkDir
+	// The process leads its own group so Stop can reach the workers it forks.
+	newCmd := func() *exec.Cmd {
+		cmd := exec.Command(command[0], command[1:]...)
+		setProcessGroup(cmd)
+		cmd.Stdout = proc.logFile
+		cmd.Stderr = proc.logFile
+		if spec.WorkDir != "" {
+			cmd.Dir = spec.WorkDir
+		}
+		cmd.Env = buildEnv(nativeEnv(spec))
+		return cmd
 	}
-	cmd.Env = buildEnv(spec.Env)
 
-	if err := cmd.Start(); err != nil {
+	cgroup, err := m.createCgroup(spec.Name, spec.Resources)
+	if err != nil {
+		// Limits are best effort: unprivileged servers and non-Linux hosts
+		// cannot create cgroups.
+		log.Warn().Err(err).Str("module", spec.Name).Msg(i18n.T("resource limits are not enforced"))
+	}
+
+	cmd := newCmd()
+	inCgroup := false
+	if cgroup != "" {
+		if dir, err := startInCgroup(cmd, cgroup); err == nil {
+			defer dir.Close()
+			inCgroup = true
+		}
+	}
+	err = cmd.Start()
+	if err != nil && inCgroup {
+		// Kernels before 5.7 cannot start a process inside a cgroup; it is
+		// moved there right after starting instead.
+		inCgroup = false
+		cmd = newCmd()
+		err = cmd.Start()
+	}
+	if err != nil {
+		removeCgroup(cgroup)
 		return i18n.Errorf("start native process: %w", err)
 	}
+	if cgroup != "" && !inCgroup {
+		if err := attachCgroup(cgroup, cmd.Process.Pid); err != nil {
+			log.Warn().Err(err).Str("module", spec.Name).Msg(i18n.T("resource limits are not enforced"))
+			removeCgroup(cgroup)
+			cgroup = ""
+		}
+	}
+	proc.status.Cgroup = cgroup
 	proc.cmd = cmd
+	proc.done = make(chan struct{})
 	proc.status.State = StateRunning
 	proc.status.PID = cmd.Process.Pid
+	if startTime, err := processStartTime(cmd.Process.Pid); err == nil {
+		proc.pidStartTime = startTime
+	}
 
 	go m.waitForExit(proc, cmd.Wait)
 	return nil
 }
 
 func (m *Manager) stopNative(ctx context.Context, proc *process) error {
-	if proc.cancelRun != nil {
-		proc.cancelRun()
-	}
-	if proc.cmd == nil {
+	if proc.done == nil || proc.status.PID <= 0 {
 		return nil
 	}
-
-	done := make(chan error, 1)
-	go func() {
-		done <- proc.cmd.Wait()
-	}()
-
+	// The leader has exited, but workers it forked may outlive it.
 	select {
-	case err := <-done:
-		if err != nil {
-			return i18n.Errorf("native process exit: %w", err)
+	case <-proc.done:
+		if signal := reapNative(proc); signal != "" {
+			m.mu.Lock()
+			proc.status.StopSignal = signal
+			m.mu.Unlock()
 		}
 		m.markStopped(proc, nil)
-	case <-ctx.Done():
-		if proc.cmd.Process != nil {
-			if killErr := proc.cmd.Process.Kill(); killErr != nil {
-				return i18n.Errorf("kill native process: %w", killErr)
+		return nil
+	default:
+	}
+	if proc.adopted {
+		// Never signal a PID that has been reused since we adopted it.
+		if startTime, err := processStartTime(proc.status.PID); err != nil || startTime != proc.pidStartTime {
+			select {
+			case <-proc.done:
+			case <-ctx.Done():
 			}
+			m.markStopped(proc, nil)
+			return nil
 		}
-		return i18n.Errorf("timeout stopping native process")
 	}
 
+	signal, err := terminateProcessGroup(ctx, proc.status.PID, proc.done, stopGracePeriod(proc.spec))
+	if signal != "" {
+		m.mu.Lock()
+		proc.status.StopSignal = signal
+		m.mu.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+	reapNative(proc)
+	m.markStopped(proc, nil)
+	m.saveState(proc)
 	return nil
 }
+
+// reapNative ends what the leader of proc left behind after it exited: the
+// rest of its process group, then anything else in its cgroup. The leader's
+// PID cannot be reused while members of its group live, so the group never
+// belongs to another process. It returns the signal that ended the group.
+func reapNative(proc *process) string {
+	var signal string
+	if proc.status.PID > 0 && processGroupAlive(proc.status.PID) {
+		exited := make(chan struct{})
+		close(exited)
+		signal, _ = terminateProcessGroup(context.Background(), proc.status.PID, exited, stopGracePeriod(proc.spec))
+	}
+	killCgroup(proc.status.Cgroup)
+	removeCgroup(proc.status.Cgroup)
+	return signal
+}
+
+// nativeEnv adds GPU visibility variables for Resources.GPUs unless the spec
+// sets them itself.
+func nativeEnv(spec ModuleSpec) map[string]string {
+	if len(spec.Resources.GPUs) == 0 {
+		return spec.Env
+	}
+	env := make(map[string]string, len(spec.Env)+2)
+	for key, value := range spec.Env {
+		env[key] = value
+	}
+	devices := spec.Resources.gpuList()
+	for _, key := range []string{"CUDA_VISIBLE_DEVICES", "ROCR_VISIBLE_DEVICES"} {
+		if _, ok := env[key]; !ok {
+			env[key] = devices
+		}
+	}
+	return env
+}
+
+func stopGracePeriod(spec ModuleSpec) time.Duration {
+	if spec.StopGracePeriod > 0 {
+		return spec.StopGracePeriod
+	}
+	return defaultStopGracePeriod
+}
+
+// terminateProcessGroup sends SIGTERM to the process group led by pid and
+// escalates to SIGKILL once grace has passed. done must be closed when the
+// leader has exited. It returns the name of the signal that ended the group.
+func terminateProcessGroup(ctx context.Context, pid int, done <-chan struct{}, grace time.Duration) (string, error) {
+	graceCtx, cancel := context.WithTimeout(ctx, grace)
+	defer cancel()
+	if err := signalProcessGroup(pid, syscall.SIGTERM); err == nil || errors.Is(err, os.ErrProcessDone) {
+		if waitProcessGroup(graceCtx, pid, done) {
+			return "SIGTERM", nil
+		}
+	}
+
+	if err := signalProcessGroup(pid, syscall.SIGKILL); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return "", i18n.Errorf("kill native process: %w", err)
+	}
+	select {
+	case <-done:
+		return "SIGKILL", nil
+	case <-ctx.Done():
+		return "SIGKILL", i18n.Errorf("timeout stopping native process")
+	}
+}
+
+// waitProcessGroup waits for the leader to exit and then for the rest of its
+// group, so that workers still shutting down are not killed prematurely.
+func waitProcessGroup(ctx context.Context, pid int, done <-chan struct{}) bool {
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return false
+	}
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for processGroupAlive(pid) {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// processStartTime returns the start time of pid in clock ticks since boot,
+// field 22 of /proc/<pid>/stat. Zombies are reported as not running.
+func processStartTime(pid int) (uint64, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	return parseProcStatStartTime(string(data))
+}
+
+func parseProcStatStartTime(stat string) (uint64, error) {
+	// The command name (field 2) may contain spaces and parentheses.
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, errors.New("malformed stat")
+	}
+	fields := strings.Fields(stat[end+1:])
+	// fields[0] is field 3 (state), so field 22 is fields[19].
+	if len(fields) < 20 {
+		return 0, errors.New("malformed stat")
+	}
+	if fields[0] == "Z" || fields[0] == "X" {
+		return 0, errors.New("process has exited")
+	}
+	return strconv.ParseUint(fields[19], 10, 64)
+}
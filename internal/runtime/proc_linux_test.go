@@ -0,0 +1,17 @@
+package runtime
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProcStatStartTime(t *testing.T) {
+	stat := "4242 (llama server) S 1 4242 4242 0 -1 4194560 1 0 0 0 0 0 0 0 20 0 1 0 987654 0 0"
+	got, err := parseProcStatStartTime(stat)
+	if err != nil || got != 987654 {
+		t.Fatalf("expected 987654, got %d (%v)", got, err)
+	}
+	if _, err := parseProcStatStartTime(strings.Replace(stat, ") S", ") Z", 1)); err == nil {
+		t.Fatalf("expected zombie to be reported as exited")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package runtime
+
+// processStartTime is only implemented on Linux; elsewhere re-adoption of
+// native processes is not attempted.
+func processStartTime(int) (uint64, error) {
+	return 0, errUnverifiable
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package runtime
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalProcessGroup signals the group led by pid. The bare PID is never
+// signalled: once the leader is gone it may belong to another process.
+func signalProcessGroup(pid int, sig syscall.Signal) error {
+	err := syscall.Kill(-pid, sig)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+	return err
+}
+
+func processGroupAlive(pid int) bool {
+	return syscall.Kill(-pid, 0) == nil
+}
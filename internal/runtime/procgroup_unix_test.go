@@ -0,0 +1,159 @@
+//go:build !windows
+
+package runtime
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func stopModule(t *testing.T, manager *Manager, name string) (Status, time.Duration) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	began := time.Now()
+	if err := manager.Stop(ctx, name); err != nil {
+		t.Fatalf("Stop returned error: %v", err)
+	}
+	status, _ := manager.Status(name)
+	return status, time.Since(began)
+}
+
+func TestStopNativeSendsSIGTERMFirst(t *testing.T) {
+	manager := newTestManager(t, t.TempDir())
+	if _, err := manager.Start(context.Background(), ModuleSpec{Name: "polite", Command: []string{"sleep", "30"}}); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	status, elapsed := stopModule(t, manager, "polite")
+	if status.State != StateStopped || status.StopSignal != "SIGTERM" {
+		t.Fatalf("expected SIGTERM stop, got %+v", status)
+	}
+	if elapsed > 5*time.Second {
+		t.Fatalf("SIGTERM stop should not wait for the grace period, took %s", elapsed)
+	}
+}
+
+func TestStopNativeEscalatesToSIGKILL(t *testing.T) {
+	manager := newTestManager(t, t.TempDir())
+	_, err := manager.Start(context.Background(), ModuleSpec{
+		Name:            "stubborn",
+		Command:         []string{"sh", "-c", "trap '' TERM; sleep 30"},
+		StopGracePeriod: 300 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	// Give the shell time to install its trap.
+	time.Sleep(100 * time.Millisecond)
+
+	status, elapsed := stopModule(t, manager, "stubborn")
+	if status.State != StateStopped || status.StopSignal != "SIGKILL" {
+		t.Fatalf("expected SIGKILL stop, got %+v", status)
+	}
+	if elapsed < 300*time.Millisecond {
+		t.Fatalf("SIGKILL was sent before the grace period elapsed (%s)", elapsed)
+	}
+	waitForPersistedState(t, manager, "stubborn", StateStopped)
+}
+
+func TestStopNativeAfterProcessExited(t *testing.T) {
+	manager := newTestManager(t, t.TempDir())
+	if _, err := manager.Start(context.Background(), ModuleSpec{Name: "brief", Command: []string{"true"}}); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	proc, _ := manager.getProcess("brief")
+	select {
+	case <-proc.done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("process never exited")
+	}
+
+	status, _ := stopModule(t, manager, "brief")
+	if status.State != StateStopped || status.StopSignal != "" {
+		t.Fatalf("expected an exited process to be stopped without signals, got %+v", status)
+	}
+	waitForPersistedState(t, manager, "brief", StateStopped)
+}
+
+func TestStopNativeKillsWholeProcessGroup(t *testing.T) {
+	if goruntime.GOOS != "linux" {
+		t.Skip("checks worker liveness through /proc")
+	}
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "worker.pid")
+	manager := newTestManager(t, dir)
+	_, err := manager.Start(context.Background(), ModuleSpec{
+		Name: "vllm",
+		// The worker ignores SIGTERM, like a GPU worker stuck in a kernel.
+		Command:         []string{"sh", "-c", "(trap '' TERM; exec sleep 30) & echo $! > " + pidFile + "; wait"},
+		StopGracePeriod: 300 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+
+	workerPID := readWorkerPID(t, pidFile)
+
+	status, _ := stopModule(t, manager, "vllm")
+	if status.StopSignal != "SIGKILL" {
+		t.Fatalf("expected the lingering worker to need SIGKILL, got %+v", status)
+	}
+	waitWorkerGone(t, workerPID, 2*time.Second)
+}
+
+func TestNativeExitKillsLeftoverWorkers(t *testing.T) {
+	if goruntime.GOOS != "linux" {
+		t.Skip("checks worker liveness through /proc")
+	}
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "worker.pid")
+	manager := newTestManager(t, dir)
+	_, err := manager.Start(context.Background(), ModuleSpec{
+		Name: "llama",
+		// The leader exits on its own and leaves a worker that ignores SIGTERM.
+		Command:         []string{"sh", "-c", "(trap '' TERM; exec sleep 30) & echo $! > " + pidFile},
+		StopGracePeriod: 300 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	workerPID := readWorkerPID(t, pidFile)
+	waitWorkerGone(t, workerPID, 5*time.Second)
+	waitForPersistedState(t, manager, "llama", StateStopped)
+}
+
+func readWorkerPID(t *testing.T, pidFile string) int {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if data, err := os.ReadFile(pidFile); err == nil {
+			if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && pid > 0 {
+				return pid
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("worker never started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func waitWorkerGone(t *testing.T, pid int, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, err := processStartTime(pid); err != nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("worker %d outlived its leader", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build windows
+
+package runtime
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func setProcessGroup(*exec.Cmd) {}
+
+// signalProcessGroup can only terminate the process itself on Windows, which
+// has no SIGTERM; callers fall through to SIGKILL.
+func signalProcessGroup(pid int, sig syscall.Signal) error {
+	if sig != syscall.SIGKILL {
+		return errors.New("signal not supported on windows")
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return os.ErrProcessDone
+	}
+	return process.Kill()
+}
+
+func processGroupAlive(int) bool {
+	return false
+}
@@ -0,0 +1,152 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+)
+
+const (
+	defaultRestartMaxRetries     = 5
+	defaultRestartInitialBackoff = time.Second
+	defaultRestartMaxBackoff     = time.Minute
+	defaultRestartMinUptime      = 10 * time.Second
+)
+
+func (r RestartSpec) withDefaults() RestartSpec {
+	if r.Policy == "" {
+		r.Policy = RestartNever
+	}
+	if r.MaxRetries <= 0 {
+		r.MaxRetries = defaultRestartMaxRetries
+	}
+	if r.InitialBackoff <= 0 {
+		r.InitialBackoff = defaultRestartInitialBackoff
+	}
+	if r.MaxBackoff <= 0 {
+		r.MaxBackoff = defaultRestartMaxBackoff
+	}
+	if r.MaxBackoff < r.InitialBackoff {
+		r.MaxBackoff = r.InitialBackoff
+	}
+	if r.MinUptime <= 0 {
+		r.MinUptime = defaultRestartMinUptime
+	}
+	return r
+}
+
+func validateRestartPolicy(policy RestartPolicy) error {
+	switch policy {
+	case "", RestartNever, RestartOnFailure, RestartAlways:
+		return nil
+	default:
+		return i18n.Errorf("invalid restart policy %q", policy)
+	}
+}
+
+// backoff returns the delay before the next restart after the given number of
+// consecutive fast failures.
+func (r RestartSpec) backoff(fastFailures int) time.Duration {
+	delay := r.InitialBackoff
+	for i := 1; i < fastFailures && delay < r.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.MaxBackoff {
+		delay = r.MaxBackoff
+	}
+	return delay
+}
+
+// exitCodeOf extracts the exit code from a cmd.Wait error.
+func exitCodeOf(err error) *int {
+	code := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil
+		}
+		code = exitErr.ExitCode()
+	}
+	return &code
+}
+
+// handleExit applies the restart policy after a process has exited on its
+// own and been marked stopped or failed. exitCode is nil when unknown.
+func (m *Manager) handleExit(proc *process, exitCode *int, exitErr error) {
+	m.mu.Lock()
+	if exitCode != nil {
+		code := *exitCode
+		proc.status.LastExitCode = &code
+	}
+	if proc.stopping || m.processes[proc.status.Name] != proc {
+		m.mu.Unlock()
+		m.saveState(proc)
+		return
+	}
+
+	policy := proc.spec.Restart.withDefaults()
+	failed := exitErr != nil
+	if policy.Policy == RestartNever || (policy.Policy == RestartOnFailure && !failed) {
+		m.mu.Unlock()
+		m.saveState(proc)
+		return
+	}
+
+	if time.Since(proc.status.StartedAt) < policy.MinUptime {
+		proc.fastFailures++
+	} else {
+		proc.fastFailures = 0
+	}
+	if proc.fastFailures >= policy.MaxRetries {
+		proc.status.State = StateCrashLoop
+		proc.status.Health = HealthUnhealthy
+		reason := i18n.T("gave up after %d consecutive fast exits", proc.fastFailures)
+		if proc.status.LastError != "" {
+			reason += ": " + proc.status.LastError
+		}
+		proc.status.LastError = reason
+		m.mu.Unlock()
+		log.Warn().Str("module", proc.status.Name).Msg(i18n.T("module is crash looping; not restarting"))
+		m.saveState(proc)
+		return
+	}
+
+	delay := policy.backoff(proc.fastFailures)
+	proc.status.State = StateRestarting
+	proc.restartTimer = time.AfterFunc(delay, func() { m.restart(proc) })
+	m.mu.Unlock()
+
+	log.Info().Str("module", proc.status.Name).Dur("backoff", delay).Msg(i18n.T("scheduling module restart"))
+	m.saveState(proc)
+}
+
+func (m *Manager) restart(prev *process) {
+	m.mu.Lock()
+	if prev.stopping || prev.status.State != StateRestarting || m.processes[prev.status.Name] != prev {
+		m.mu.Unlock()
+		return
+	}
+	spec := prev.spec
+	m.mu.Unlock()
+
+	if _, err := m.start(context.Background(), spec, prev); err != nil {
+		m.mu.Lock()
+		prev.status.State = StateFailed
+		prev.status.LastError = err.Error()
+		prev.status.StartedAt = time.Now()
+		m.mu.Unlock()
+		m.handleExit(prev, nil, err)
+	}
+}
+
+// cancelRestart stops a pending restart. It must be called with m.mu held.
+func (proc *process) cancelRestart() {
+	if proc.restartTimer != nil {
+		proc.restartTimer.Stop()
+		proc.restartTimer = nil
+	}
+}
@@ -0,0 +1,163 @@
+package runtime
+
+import (
+	"context"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRestartBackoff(t *testing.T) {
+	spec := RestartSpec{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}.withDefaults()
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{40, 10 * time.Second},
+	}
+	for _, tc := range cases {
+		if got := spec.backoff(tc.failures); got != tc.want {
+			t.Fatalf("backoff(%d) = %s, want %s", tc.failures, got, tc.want)
+		}
+	}
+}
+
+func TestRestartOnFailureRecoversProcess(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "started-once")
+	manager := newTestManager(t, dir)
+	_, err := manager.Start(context.Background(), ModuleSpec{
+		Name:    "flaky",
+		Command: []string{"sh", "-c", "if [ -f " + marker + " ]; then exec sleep 30; fi; touch " + marker + "; exit 3"},
+		Restart: RestartSpec{Policy: RestartOnFailure, InitialBackoff: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+
+	status := waitForRestartCount(t, manager, "flaky", 1)
+	if status.State != StateRunning || status.LastExitCode == nil || *status.LastExitCode != 3 {
+		t.Fatalf("expected running after one restart with exit code 3, got %+v", status)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := manager.Stop(ctx, "flaky"); err != nil {
+		t.Fatalf("Stop returned error: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if status, _ := manager.Status("flaky"); status.State != StateStopped || status.RestartCount != 1 {
+		t.Fatalf("expected stop not to trigger a restart, got %+v", status)
+	}
+}
+
+func TestRestartEntersCrashLoop(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	manager := newTestManager(t, t.TempDir())
+	_, err := manager.Start(context.Background(), ModuleSpec{
+		Name:    "broken",
+		Command: []string{"sh", "-c", "exit 7"},
+		Restart: RestartSpec{Policy: RestartAlways, MaxRetries: 3, InitialBackoff: time.Millisecond, MinUptime: time.Hour},
+	})
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+
+	status := waitForState(t, manager, "broken", StateCrashLoop)
+	if status.RestartCount != 2 || status.LastExitCode == nil || *status.LastExitCode != 7 {
+		t.Fatalf("expected two restarts before giving up, got %+v", status)
+	}
+	if !strings.Contains(status.LastError, "3 consecutive fast exits") {
+		t.Fatalf("expected crash-loop reason, got %q", status.LastError)
+	}
+	waitForPersistedState(t, manager, "broken", StateCrashLoop)
+
+	if err := manager.Stop(context.Background(), "broken"); err != nil {
+		t.Fatalf("Stop returned error: %v", err)
+	}
+	if status, _ := manager.Status("broken"); status.State != StateStopped {
+		t.Fatalf("expected crash-looping module to be stoppable, got %+v", status)
+	}
+}
+
+func TestRestartPolicies(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	manager := newTestManager(t, t.TempDir())
+	ctx := context.Background()
+
+	if _, err := manager.Start(ctx, ModuleSpec{Name: "clean", Command: []string{"true"}, Restart: RestartSpec{Policy: RestartOnFailure}}); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	waitForExitHandled(t, manager, "clean")
+	status := waitForPersistedState(t, manager, "clean", StateStopped).Status
+	if status.RestartCount != 0 || status.LastExitCode == nil || *status.LastExitCode != 0 {
+		t.Fatalf("on-failure must not restart a clean exit, got %+v", status)
+	}
+
+	if _, err := manager.Start(ctx, ModuleSpec{Name: "never", Command: []string{"false"}}); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	waitForExitHandled(t, manager, "never")
+	if status := waitForPersistedState(t, manager, "never", StateFailed).Status; status.RestartCount != 0 {
+		t.Fatalf("default policy must not restart, got %+v", status)
+	}
+
+	if _, err := manager.Start(ctx, ModuleSpec{Name: "pending", Command: []string{"true"}, Restart: RestartSpec{Policy: RestartAlways, InitialBackoff: time.Hour}}); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	waitForState(t, manager, "pending", StateRestarting)
+	if err := manager.Stop(ctx, "pending"); err != nil {
+		t.Fatalf("Stop returned error: %v", err)
+	}
+	if status, _ := manager.Status("pending"); status.State != StateStopped {
+		t.Fatalf("expected Stop to cancel the pending restart, got %+v", status)
+	}
+
+	if _, err := manager.Start(ctx, ModuleSpec{Name: "bad", Command: []string{"true"}, Restart: RestartSpec{Policy: "sometimes"}}); err == nil {
+		t.Fatalf("expected invalid restart policy to be rejected")
+	}
+}
+
+func waitForRestartCount(t *testing.T, manager *Manager, name string, count int) Status {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, ok := manager.Status(name)
+		if ok && status.RestartCount >= count && status.State == StateRunning {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("module %s never restarted %d times, last status %+v", name, count, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitForExitHandled blocks until the exit of name has been fully processed,
+// including the final state write.
+func waitForExitHandled(t *testing.T, manager *Manager, name string) {
+	t.Helper()
+	proc, ok := manager.getProcess(name)
+	if !ok {
+		t.Fatalf("module %s not found", name)
+	}
+	select {
+	case <-proc.done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("exit of %s was never handled", name)
+	}
+}
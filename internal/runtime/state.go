@@ -0,0 +1,259 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+)
+
+// stateRecord is the on-disk form of a supervised process. It carries enough
+// to find the process again after the server restarts.
+type stateRecord struct {
+	Spec         ModuleSpec `json:"spec"`
+	Status       Status     `json:"status"`
+	ContainerBin string     `json:"container_bin,omitempty"`
+	PIDStartTime uint64     `json:"pid_start_time,omitempty"`
+}
+
+func (m *Manager) stateDir() string {
+	return filepath.Join(m.baseDir, "state")
+}
+
+func (m *Manager) statePath(name string) string {
+	return filepath.Join(m.stateDir(), name+".json")
+}
+
+func (m *Manager) saveState(proc *process) {
+	m.mu.Lock()
+	record := stateRecord{
+		Spec:         proc.spec,
+		Status:       proc.status,
+		ContainerBin: proc.containerBin,
+		PIDStartTime: proc.pidStartTime,
+	}
+	m.mu.Unlock()
+
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	if err := writeStateRecord(m.statePath(record.Status.Name), record); err != nil {
+		log.Warn().Err(err).Str("module", record.Status.Name).Msg(i18n.T("failed to persist runtime state"))
+	}
+}
+
+func writeStateRecord(path string, record stateRecord) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func loadStateRecords(dir string) ([]stateRecord, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var records []stateRecord
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Warn().Err(err).Str("path", path).Msg(i18n.T("failed to read runtime state"))
+			continue
+		}
+		var record stateRecord
+		if err := json.Unmarshal(data, &record); err != nil || record.Status.Name == "" {
+			log.Warn().Err(err).Str("path", path).Msg(i18n.T("ignoring invalid runtime state"))
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Restore loads the persisted state of every known module. Processes that
+// were running when the previous server exited are re-adopted if they are
+// still alive, marked failed if they are gone, and left unknown when that
+// cannot be told.
+func (m *Manager) Restore(ctx context.Context) ([]Status, error) {
+	records, err := loadStateRecords(m.stateDir())
+	if err != nil {
+		return nil, i18n.Errorf("load runtime state: %w", err)
+	}
+
+	restored := make([]Status, 0, len(records))
+	for _, record := range records {
+		if _, ok := m.getProcess(record.Status.Name); ok {
+			continue
+		}
+		proc := &process{
+			spec:         record.Spec,
+			status:       record.Status,
+			containerBin: record.ContainerBin,
+			containerID:  record.Status.ContainerID,
+			healthCheck:  record.Spec.HealthCheck,
+			pidStartTime: record.PIDStartTime,
+		}
+
+		adopted := false
+		if record.Status.State == StateRunning || record.Status.State == StateStarting {
+			var reason error
+			switch record.Status.Mode {
+			case ModeNative:
+				reason = m.adoptNative(proc)
+			case ModeContainer:
+				reason = m.adoptContainer(ctx, proc)
+			default:
+				reason = i18n.Errorf("unsupported execution mode: %s", record.Status.Mode)
+			}
+			if errors.Is(reason, errUnverifiable) {
+				// It may still be running; restarting it could start a second copy.
+				proc.status.State = StateUnknown
+				proc.status.Health = HealthUnknown
+				proc.status.LastError = reason.Error()
+			} else if reason != nil {
+				finished := time.Now()
+				proc.status.State = StateFailed
+				proc.status.Health = HealthUnhealthy
+				proc.status.FinishedAt = &finished
+				proc.status.LastError = reason.Error()
+			} else {
+				adopted = true
+			}
+		}
+
+		m.mu.Lock()
+		m.processes[record.Status.Name] = proc
+		m.mu.Unlock()
+
+		if adopted {
+			if proc.status.Mode == ModeNative {
+				go m.watchAdopted(proc)
+			} else {
+				m.startContainerLogs(proc, time.Now())
+				go m.watchContainer(proc)
+			}
+			m.startHealthMonitor(proc)
+			log.Info().Str("module", proc.status.Name).Msg(i18n.T("re-adopted running module"))
+			m.saveState(proc)
+		} else if proc.status.State == StateFailed && record.Status.State != StateFailed {
+			log.Warn().Str("module", proc.status.Name).Str("reason", proc.status.LastError).Msg(i18n.T("module did not survive server restart"))
+			// The exit was never observed, so the restart policy applies now.
+			m.handleExit(proc, nil, errors.New(proc.status.LastError))
+		} else {
+			m.saveState(proc)
+		}
+		if status, ok := m.Status(proc.status.Name); ok {
+			restored = append(restored, status)
+		}
+	}
+	return restored, nil
+}
+
+// errUnverifiable means a recorded process cannot be identified, so it may
+// or may not still be running.
+var errUnverifiable = errors.New("process identity cannot be verified on this platform")
+
+func (m *Manager) adoptNative(proc *process) error {
+	pid := proc.status.PID
+	if pid <= 0 {
+		return i18n.Errorf("no process id recorded")
+	}
+	if proc.pidStartTime == 0 {
+		return i18n.Errorf("cannot verify process %d after server restart: %w", pid, errUnverifiable)
+	}
+	startTime, err := processStartTime(pid)
+	if errors.Is(err, errUnverifiable) {
+		return i18n.Errorf("cannot verify process %d after server restart: %w", pid, err)
+	}
+	if err != nil {
+		return i18n.Errorf("process %d is no longer running", pid)
+	}
+	if startTime != proc.pidStartTime {
+		return i18n.Errorf("process %d is no longer running (pid reused by another process)", pid)
+	}
+	proc.adopted = true
+	proc.done = make(chan struct{})
+	proc.status.State = StateRunning
+	return nil
+}
+
+func (m *Manager) adoptContainer(ctx context.Context, proc *process) error {
+	if proc.containerID == "" || proc.containerBin == "" {
+		return i18n.Errorf("no container recorded")
+	}
+	if _, err := exec.LookPath(proc.containerBin); err != nil {
+		return i18n.Errorf("container runtime %q not found", proc.containerBin)
+	}
+	inspectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(inspectCtx, proc.containerBin, "inspect", "--format", "{{.State.Running}}", proc.containerID).CombinedOutput()
+	if err != nil {
+		return i18n.Errorf("inspect container %s: %w (%s)", proc.containerID, err, strings.TrimSpace(string(output)))
+	}
+	if strings.TrimSpace(string(output)) != "true" {
+		return i18n.Errorf("container %s is no longer running", proc.containerID)
+	}
+
+	logFile, err := os.OpenFile(proc.status.LogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		var logPath string
+		logFile, logPath, err = m.createLogFile(proc.status.Name)
+		if err != nil {
+			return err
+		}
+		proc.status.LogPath = logPath
+	}
+	proc.logFile = logFile
+	proc.adopted = true
+	proc.done = make(chan struct{})
+	proc.status.State = StateRunning
+	return nil
+}
+
+// watchAdopted polls a re-adopted native process, which is not our child
+// and therefore cannot be waited on.
+func (m *Manager) watchAdopted(proc *process) {
+	ticker := time.NewTicker(m.adoptPollInterval)
+	defer ticker.Stop()
+	pid := proc.status.PID
+	for range ticker.C {
+		if startTime, err := processStartTime(pid); err == nil && startTime == proc.pidStartTime {
+			continue
+		}
+		m.mu.Lock()
+		stopping := proc.stopping
+		m.mu.Unlock()
+		var exitErr error
+		if !stopping {
+			reapNative(proc)
+			exitErr = i18n.Errorf("process %d exited; exit status is unavailable for re-adopted processes", pid)
+		}
+		m.markStopped(proc, exitErr)
+		m.stopHealthMonitor(proc)
+		m.handleExit(proc, nil, exitErr)
+		close(proc.done)
+		return
+	}
+}
@@ -0,0 +1,278 @@
+package runtime
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	goruntime "runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/config"
+)
+
+func newTestManager(t *testing.T, dir string) *Manager {
+	t.Helper()
+	manager := NewManager(config.RuntimeConfig{
+		NativeEnabled: true,
+		DefaultMode:   string(ModeNative),
+		LogDir:        dir,
+	})
+	manager.adoptPollInterval = 20 * time.Millisecond
+	return manager
+}
+
+func waitForState(t *testing.T, manager *Manager, name string, want ProcessState) Status {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, ok := manager.Status(name)
+		if ok && status.State == want {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("module %s never reached %s, last status %+v", name, want, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitForPersistedState waits until the state file catches up, so background
+// writes do not race with the test's temp dir cleanup.
+func waitForPersistedState(t *testing.T, manager *Manager, name string, want ProcessState) stateRecord {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		records, _ := loadStateRecords(manager.stateDir())
+		for _, record := range records {
+			if record.Status.Name == name && record.Status.State == want {
+				return record
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("state of %s was never persisted as %s", name, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRestoreReadoptsRunningNativeProcess(t *testing.T) {
+	if goruntime.GOOS != "linux" {
+		t.Skip("re-adoption relies on /proc")
+	}
+	dir := t.TempDir()
+	first := newTestManager(t, dir)
+	started, err := first.Start(context.Background(), ModuleSpec{Name: "sleeper", Command: []string{"sleep", "30"}, Labels: map[string]string{"role": "test"}})
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	pid := started.PID
+	defer func() {
+		if p, err := os.FindProcess(pid); err == nil {
+			_ = p.Kill()
+		}
+	}()
+
+	second := newTestManager(t, dir)
+	restored, err := second.Restore(context.Background())
+	if err != nil {
+		t.Fatalf("Restore returned error: %v", err)
+	}
+	if len(restored) != 1 || restored[0].State != StateRunning || restored[0].PID != pid {
+		t.Fatalf("expected sleeper to be re-adopted, got %+v", restored)
+	}
+	if restored[0].Labels["role"] != "test" {
+		t.Fatalf("expected labels to be restored, got %+v", restored[0].Labels)
+	}
+	if spec, ok := second.Spec("sleeper"); !ok || len(spec.Command) != 2 {
+		t.Fatalf("expected persisted spec, got %+v", spec)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := second.Stop(ctx, "sleeper"); err != nil {
+		t.Fatalf("Stop returned error: %v", err)
+	}
+	if status, _ := second.Status("sleeper"); status.State != StateStopped {
+		t.Fatalf("expected stopped after Stop, got %+v", status)
+	}
+}
+
+func TestRestoreMarksDeadProcessFailed(t *testing.T) {
+	if goruntime.GOOS != "linux" {
+		t.Skip("re-adoption relies on /proc")
+	}
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Fatalf("run true: %v", err)
+	}
+	selfStart, err := processStartTime(os.Getpid())
+	if err != nil {
+		t.Fatalf("processStartTime: %v", err)
+	}
+
+	dir := t.TempDir()
+	manager := newTestManager(t, dir)
+	records := []stateRecord{
+		{
+			Spec:         ModuleSpec{Name: "gone", Command: []string{"true"}},
+			Status:       Status{Name: "gone", Mode: ModeNative, PID: exited.Process.Pid, State: StateRunning},
+			PIDStartTime: 1,
+		},
+		{
+			Spec:         ModuleSpec{Name: "reused", Command: []string{"llama-server"}},
+			Status:       Status{Name: "reused", Mode: ModeNative, PID: os.Getpid(), State: StateRunning},
+			PIDStartTime: selfStart + 1,
+		},
+		{
+			Spec:   ModuleSpec{Name: "finished", Command: []string{"true"}},
+			Status: Status{Name: "finished", Mode: ModeNative, State: StateStopped},
+		},
+	}
+	for _, record := range records {
+		if err := writeStateRecord(manager.statePath(record.Status.Name), record); err != nil {
+			t.Fatalf("write state: %v", err)
+		}
+	}
+
+	if _, err := manager.Restore(context.Background()); err != nil {
+		t.Fatalf("Restore returned error: %v", err)
+	}
+	gone, _ := manager.Status("gone")
+	if gone.State != StateFailed || !strings.Contains(gone.LastError, "no longer running") || gone.FinishedAt == nil {
+		t.Fatalf("expected gone to be failed, got %+v", gone)
+	}
+	reused, _ := manager.Status("reused")
+	if reused.State != StateFailed || !strings.Contains(reused.LastError, "pid reused") {
+		t.Fatalf("expected reused pid to be detected, got %+v", reused)
+	}
+	finished, _ := manager.Status("finished")
+	if finished.State != StateStopped || finished.LastError != "" {
+		t.Fatalf("expected stopped record to be kept as is, got %+v", finished)
+	}
+
+	persisted, err := loadStateRecords(manager.stateDir())
+	if err != nil {
+		t.Fatalf("loadStateRecords: %v", err)
+	}
+	for _, record := range persisted {
+		if record.Status.Name == "gone" && record.Status.State != StateFailed {
+			t.Fatalf("expected failure to be persisted, got %+v", record.Status)
+		}
+	}
+}
+
+func TestRestoreLeavesUnverifiableProcessUnknown(t *testing.T) {
+	manager := newTestManager(t, t.TempDir())
+	spec := ModuleSpec{Name: "legacy", Command: []string{"sleep", "30"}, Restart: RestartSpec{Policy: RestartAlways}}
+	record := stateRecord{
+		Spec:   spec,
+		Status: Status{Name: "legacy", Mode: ModeNative, PID: os.Getpid(), State: StateRunning},
+	}
+	if err := writeStateRecord(manager.statePath("legacy"), record); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	if _, err := manager.Restore(context.Background()); err != nil {
+		t.Fatalf("Restore returned error: %v", err)
+	}
+	status, _ := manager.Status("legacy")
+	if status.State != StateUnknown || !strings.Contains(status.LastError, "cannot verify") {
+		t.Fatalf("expected an unverifiable process to be unknown, got %+v", status)
+	}
+	if _, err := manager.Start(context.Background(), spec); err == nil {
+		t.Fatalf("expected Start to refuse a module that may still be running")
+	}
+	if err := manager.Stop(context.Background(), "legacy"); err != nil {
+		t.Fatalf("Stop returned error: %v", err)
+	}
+	if status, _ := manager.Status("legacy"); status.State != StateStopped || status.PID != os.Getpid() {
+		t.Fatalf("expected Stop to forget the process, got %+v", status)
+	}
+}
+
+func TestReadoptedProcessExitIsDetected(t *testing.T) {
+	if goruntime.GOOS != "linux" {
+		t.Skip("re-adoption relies on /proc")
+	}
+	child := exec.Command("sleep", "30")
+	if err := child.Start(); err != nil {
+		t.Fatalf("start sleep: %v", err)
+	}
+	startTime, err := processStartTime(child.Process.Pid)
+	if err != nil {
+		t.Fatalf("processStartTime: %v", err)
+	}
+
+	manager := newTestManager(t, t.TempDir())
+	record := stateRecord{
+		Spec:         ModuleSpec{Name: "crashy", Command: []string{"sleep", "30"}},
+		Status:       Status{Name: "crashy", Mode: ModeNative, PID: child.Process.Pid, State: StateRunning},
+		PIDStartTime: startTime,
+	}
+	if err := writeStateRecord(manager.statePath("crashy"), record); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	if _, err := manager.Restore(context.Background()); err != nil {
+		t.Fatalf("Restore returned error: %v", err)
+	}
+	waitForState(t, manager, "crashy", StateRunning)
+
+	_ = child.Process.Kill()
+	_ = child.Wait()
+	status := waitForState(t, manager, "crashy", StateFailed)
+	if proc, ok := manager.getProcess("crashy"); ok {
+		// done is closed after the last state write.
+		<-proc.done
+	}
+	if !strings.Contains(status.LastError, "exited") {
+		t.Fatalf("expected exit reason, got %+v", status)
+	}
+}
+
+func TestRegisterKeepsSpecUntilStartedOrRemoved(t *testing.T) {
+	dir := t.TempDir()
+	manager := newTestManager(t, dir)
+	spec := ModuleSpec{Name: "lazy", Command: []string{"sleep", "30"}, Labels: map[string]string{"role": "test"}}
+	registered, err := manager.Register(spec)
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	if registered.State != StateStopped || registered.PID != 0 || registered.Labels["role"] != "test" {
+		t.Fatalf("unexpected registered status: %+v", registered)
+	}
+
+	restored := newTestManager(t, dir)
+	if _, err := restored.Restore(context.Background()); err != nil {
+		t.Fatalf("Restore returned error: %v", err)
+	}
+	saved, ok := restored.Spec("lazy")
+	if !ok || strings.Join(saved.Command, " ") != "sleep 30" {
+		t.Fatalf("expected the registered spec to be restored, got %+v", saved)
+	}
+	if status, _ := restored.Status("lazy"); status.State != StateStopped {
+		t.Fatalf("expected a registered module to stay stopped, got %s", status.State)
+	}
+
+	if _, err := restored.Start(context.Background(), saved); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	if _, err := restored.Register(spec); err == nil {
+		t.Fatalf("expected Register of a running module to fail")
+	}
+	if err := restored.Remove("lazy"); err == nil {
+		t.Fatalf("expected Remove of a running module to fail")
+	}
+	if err := restored.Stop(context.Background(), "lazy"); err != nil {
+		t.Fatalf("Stop returned error: %v", err)
+	}
+	if err := restored.Remove("lazy"); err != nil {
+		t.Fatalf("Remove returned error: %v", err)
+	}
+	if _, ok := restored.Status("lazy"); ok {
+		t.Fatalf("expected the module to be forgotten")
+	}
+	if records, _ := loadStateRecords(restored.stateDir()); len(records) != 0 {
+		t.Fatalf("expected the saved state to be removed, got %+v", records)
+	}
+}
@@ -18,6 +18,22 @@ const (
 	StateRunning  ProcessState = "running"
 	StateStopped  ProcessState = "stopped"
 	StateFailed   ProcessState = "failed"
+	// StateRestarting means the process exited and a restart is scheduled.
+	StateRestarting ProcessState = "restarting"
+	// StateCrashLoop means the process failed too often in a row and the
+	// manager gave up restarting it.
+	StateCrashLoop ProcessState = "crash-loop"
+	// StateUnknown means the server restarted and cannot tell whether the
+	// process it had started is still running.
+	StateUnknown ProcessState = "unknown"
+)
+
+type RestartPolicy string
+
+const (
+	RestartNever     RestartPolicy = "never"
+	RestartOnFailure RestartPolicy = "on-failure"
+	RestartAlways    RestartPolicy = "always"
 )
 
 const (
@@ -26,34 +42,133 @@ const (
 	HealthUnhealthy HealthState = "unhealthy"
 )
 
+// HealthCheck probes a running module. At most one of Command, HTTP and TCP
+// may be set; with none, a native process is healthy while it runs and a
+// container reports its own health status.
 type HealthCheck struct {
-	Command  []string
-	Interval time.Duration
-	Timeout  time.Duration
+	Command  []string         `json:"command,omitempty"`
+	HTTP     *HTTPHealthCheck `json:"http,omitempty"`
+	TCP      *TCPHealthCheck  `json:"tcp,omitempty"`
+	Interval time.Duration    `json:"interval,omitempty"`
+	Timeout  time.Duration    `json:"timeout,omitempty"`
+	// StartPeriod ignores failures after start until the first success.
+	StartPeriod time.Duration `json:"start_period,omitempty"`
+	// FailureThreshold is the number of consecutive failures before the
+	// module is reported unhealthy (default 3).
+	FailureThreshold int `json:"failure_threshold,omitempty"`
+}
+
+// HTTPHealthCheck issues a GET to URL. With ExpectedStatus unset any 2xx
+// response passes.
+type HTTPHealthCheck struct {
+	URL            string `json:"url"`
+	ExpectedStatus int    `json:"expected_status,omitempty"`
+	BodyContains   string `json:"body_contains,omitempty"`
+}
+
+// TCPHealthCheck passes when a TCP connection to Address succeeds.
+type TCPHealthCheck struct {
+	Address string `json:"address"`
+}
+
+// RestartSpec controls what happens when a process exits on its own. Runs
+// shorter than MinUptime count as fast failures; MaxRetries consecutive fast
+// failures put the module into StateCrashLoop. Zero values use defaults.
+type RestartSpec struct {
+	Policy         RestartPolicy `json:"policy,omitempty"`
+	MaxRetries     int           `json:"max_retries,omitempty"`
+	InitialBackoff time.Duration `json:"initial_backoff,omitempty"`
+	MaxBackoff     time.Duration `json:"max_backoff,omitempty"`
+	MinUptime      time.Duration `json:"min_uptime,omitempty"`
 }
 
+// Resources limits what a module may use; zero values mean unlimited. Native
+// modules are confined with a cgroup v2 group when the host allows it.
+type Resources struct {
+	// CPUs is a CPU quota in cores, e.g. 2.5.
+	CPUs      float64 `json:"cpus,omitempty"`
+	MemoryMax int64   `json:"memory_max,omitempty"`
+	PidsMax   int64   `json:"pids_max,omitempty"`
+	// GPUs lists the GPU indices the module may use. Native modules see
+	// every GPU when it is empty; containers only get the GPUs requested
+	// here or through AllGPUs.
+	GPUs    []int `json:"gpus,omitempty"`
+	AllGPUs bool  `json:"all_gpus,omitempty"`
+	// GPUVendor selects how GPUs are passed to containers: "nvidia"
+	// (default) or "amd".
+	GPUVendor string `json:"gpu_vendor,omitempty"`
+}
+
+// PortMapping publishes a container port on the host. HostPort 0 lets the
+// container runtime pick a free port.
+type PortMapping struct {
+	HostIP        string `json:"host_ip,omitempty"`
+	HostPort      int    `json:"host_port,omitempty"`
+	ContainerPort int    `json:"container_port"`
+	Protocol      string `json:"protocol,omitempty"`
+}
+
+// Mount bind-mounts a host path into a container, read-only unless
+// ReadWrite is set.
+type Mount struct {
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+	ReadWrite bool   `json:"read_write,omitempty"`
+}
+
+type PullPolicy string
+
+const (
+	PullAlways  PullPolicy = "always"
+	PullMissing PullPolicy = "missing"
+	PullNever   PullPolicy = "never"
+)
+
 type ModuleSpec struct {
-	Name             string
-	Mode             ExecutionMode
-	Image            string
-	Command          []string
-	Args             []string
-	Env              map[string]string
-	WorkDir          string
-	ContainerName    string
-	ContainerRuntime string
-	HealthCheck      HealthCheck
+	Name             string            `json:"name"`
+	Mode             ExecutionMode     `json:"mode,omitempty"`
+	Image            string            `json:"image,omitempty"`
+	Command          []string          `json:"command,omitempty"`
+	Args             []string          `json:"args,omitempty"`
+	Env              map[string]string `json:"env,omitempty"`
+	WorkDir          string            `json:"work_dir,omitempty"`
+	ContainerName    string            `json:"container_name,omitempty"`
+	ContainerRuntime string            `json:"container_runtime,omitempty"`
+	HealthCheck      HealthCheck       `json:"health_check"`
+	Restart          RestartSpec       `json:"restart,omitempty"`
+	// StopGracePeriod is how long Stop waits after SIGTERM before sending
+	// SIGKILL (default 10s).
+	StopGracePeriod time.Duration `json:"stop_grace_period,omitempty"`
+	Resources       Resources     `json:"resources,omitempty"`
+	// Labels are reported in Status for every mode and also applied to
+	// containers.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Container-only settings.
+	Ports      []PortMapping `json:"ports,omitempty"`
+	Mounts     []Mount       `json:"mounts,omitempty"`
+	User       string        `json:"user,omitempty"`
+	PullPolicy PullPolicy    `json:"pull_policy,omitempty"`
 }
 
 type Status struct {
-	Name        string
-	Mode        ExecutionMode
-	PID         int
-	ContainerID string
-	State       ProcessState
-	Health      HealthState
-	StartedAt   time.Time
-	FinishedAt  *time.Time
-	LogPath     string
-	LastError   string
+	Name        string        `json:"name"`
+	Mode        ExecutionMode `json:"mode"`
+	PID         int           `json:"pid,omitempty"`
+	ContainerID string        `json:"container_id,omitempty"`
+	State       ProcessState  `json:"state"`
+	Health      HealthState   `json:"health"`
+	StartedAt   time.Time     `json:"started_at"`
+	FinishedAt  *time.Time    `json:"finished_at,omitempty"`
+	LogPath     string        `json:"log_path"`
+	LastError   string        `json:"last_error,omitempty"`
+	// RestartCount is the number of automatic restarts since the last
+	// manual start.
+	RestartCount int  `json:"restart_count,omitempty"`
+	LastExitCode *int `json:"last_exit_code,omitempty"`
+	// StopSignal names the signal that ended the module on Stop, SIGTERM
+	// or SIGKILL.
+	StopSignal string `json:"stop_signal,omitempty"`
+	// Cgroup is the cgroup enforcing the module's resource limits, if any.
+	Cgroup string            `json:"cgroup,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
 }
@@ -13,6 +13,11 @@ type BaseInfoSummary struct {
 	MemoryKB int64
 	GPUName  string
 	GPUCount int
+	// GPUVendor, GPUVRAMMB and GPUComputeCapability are only known when the
+	// summary comes from a typed hardware profile.
+	GPUVendor            string
+	GPUVRAMMB            int64
+	GPUComputeCapability string
 }
 
 func LoadBaseInfoSummary(path string) (BaseInfoSummary, error) {
@@ -0,0 +1,172 @@
+package system
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+	"github.com/zhuangbiaowei/LocalAIStack/pkg/hardware"
+)
+
+// HardwareProfileSchemaVersion is bumped whenever the document layout changes
+// in a way older readers cannot handle.
+const HardwareProfileSchemaVersion = 1
+
+const hardwareProfileFileName = "hardware_profile.json"
+
+// HardwareProfileDocument is the typed hardware record written by
+// `las system detect` and read by the planners.
+type HardwareProfileDocument struct {
+	SchemaVersion int                      `json:"schema_version"`
+	CollectedAt   time.Time                `json:"collected_at"`
+	OS            string                   `json:"os"`
+	Hostname      string                   `json:"hostname,omitempty"`
+	Profile       hardware.HardwareProfile `json:"profile"`
+}
+
+func DetectHardwareProfile(detector hardware.Detector) (HardwareProfileDocument, error) {
+	if detector == nil {
+		detector = hardware.NewNativeDetector()
+	}
+	profile, err := detector.Detect()
+	if err != nil {
+		return HardwareProfileDocument{}, err
+	}
+	hostname, _ := os.Hostname()
+	return HardwareProfileDocument{
+		SchemaVersion: HardwareProfileSchemaVersion,
+		CollectedAt:   time.Now().UTC(),
+		OS:            runtime.GOOS,
+		Hostname:      hostname,
+		Profile:       *profile,
+	}, nil
+}
+
+func WriteHardwareProfile(path string, doc HardwareProfileDocument) error {
+	if path == "" {
+		resolved, err := resolveOutputPath(hardwareProfileFileName)
+		if err != nil {
+			return err
+		}
+		path = resolved
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return i18n.Errorf("create output directory: %w", err)
+	}
+	raw, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return i18n.Errorf("marshal json: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(raw, '\n'), 0o644); err != nil {
+		return i18n.Errorf("write output file: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func LoadHardwareProfile(path string) (HardwareProfileDocument, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return HardwareProfileDocument{}, err
+	}
+	var doc HardwareProfileDocument
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return HardwareProfileDocument{}, i18n.Errorf("parse hardware profile %s: %w", path, err)
+	}
+	if doc.SchemaVersion <= 0 || doc.SchemaVersion > HardwareProfileSchemaVersion {
+		return HardwareProfileDocument{}, i18n.Errorf("unsupported hardware profile schema version %d in %s", doc.SchemaVersion, path)
+	}
+	return doc, nil
+}
+
+// ResolveHardwareProfilePath returns ~/.localaistack/hardware_profile.json.
+func ResolveHardwareProfilePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".", hardwareProfileFileName)
+	}
+	return filepath.Join(home, ".localaistack", hardwareProfileFileName)
+}
+
+// ResolveBaseInfoPath returns the legacy base_info.json location, honouring
+// the historical ~/.localiastack misspelling.
+func ResolveBaseInfoPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".", "base_info.json")
+	}
+	primary := filepath.Join(home, ".localaistack", "base_info.json")
+	if _, err := os.Stat(primary); err == nil {
+		return primary
+	}
+	alternate := filepath.Join(home, ".localiastack", "base_info.json")
+	if _, err := os.Stat(alternate); err == nil {
+		return alternate
+	}
+	return primary
+}
+
+// LoadHardwareSummary reads the typed hardware profile and falls back to the
+// legacy base info file at baseInfoPath when no profile has been written yet.
+func LoadHardwareSummary(baseInfoPath string) (BaseInfoSummary, error) {
+	doc, err := LoadHardwareProfile(ResolveHardwareProfilePath())
+	if err == nil {
+		return doc.Summary(), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return BaseInfoSummary{}, err
+	}
+	if baseInfoPath == "" {
+		baseInfoPath = ResolveBaseInfoPath()
+	}
+	return LoadBaseInfoSummary(baseInfoPath)
+}
+
+// Summary reduces the profile to the facts the planners use. Integrated
+// GPUs without dedicated memory are not counted as accelerators.
+func (doc HardwareProfileDocument) Summary() BaseInfoSummary {
+	profile := doc.Profile
+	summary := BaseInfoSummary{
+		CPUCores: profile.CPU.Cores,
+		MemoryKB: int64(profile.Memory.Total / 1024),
+	}
+	for _, gpu := range profile.GPUs {
+		if gpu.VRAMTotal == 0 && gpu.Vendor != "NVIDIA" && gpu.Vendor != "AMD" {
+			continue
+		}
+		if summary.GPUCount == 0 {
+			summary.GPUName = gpu.Name
+			summary.GPUVendor = gpu.Vendor
+			summary.GPUComputeCapability = gpu.ComputeCapability
+		}
+		summary.GPUCount++
+		vramMB := int64(gpu.VRAMTotal / (1024 * 1024))
+		if vramMB > 0 && (summary.GPUVRAMMB == 0 || vramMB < summary.GPUVRAMMB) {
+			summary.GPUVRAMMB = vramMB
+		}
+	}
+	return summary
+}
+
+// VRAMGB returns the per-GPU VRAM in whole gigabytes, or 0 when no VRAM
+// figure was probed.
+func (s BaseInfoSummary) VRAMGB() int {
+	return int(math.Round(float64(s.GPUVRAMMB) / 1024))
+}
+
+// HasCUDAGPU reports whether the first accelerator is an NVIDIA device.
+func (s BaseInfoSummary) HasCUDAGPU() bool {
+	if strings.TrimSpace(s.GPUName) == "" {
+		return false
+	}
+	if s.GPUVendor != "" {
+		return s.GPUVendor == "NVIDIA"
+	}
+	return true
+}
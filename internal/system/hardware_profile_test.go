@@ -0,0 +1,96 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zhuangbiaowei/LocalAIStack/pkg/hardware"
+)
+
+func TestHardwareProfileRoundTripAndSummary(t *testing.T) {
+	doc := HardwareProfileDocument{
+		SchemaVersion: HardwareProfileSchemaVersion,
+		OS:            "linux",
+		Profile: hardware.HardwareProfile{
+			CPU:    hardware.CPU{Cores: 16, Threads: 32},
+			Memory: hardware.Memory{Total: 64 * 1024 * 1024 * 1024},
+			GPUs: []hardware.GPU{
+				{Index: 0, Name: "Intel UHD Graphics", Vendor: "Intel"},
+				{Index: 0, Name: "Tesla V100-SXM2", Vendor: "NVIDIA", VRAMTotal: 16160 << 20, ComputeCapability: "7.0"},
+				{Index: 1, Name: "Tesla V100-SXM2", Vendor: "NVIDIA", VRAMTotal: 32510 << 20, ComputeCapability: "7.0"},
+			},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "hardware_profile.json")
+	if err := WriteHardwareProfile(path, doc); err != nil {
+		t.Fatalf("WriteHardwareProfile returned error: %v", err)
+	}
+	loaded, err := LoadHardwareProfile(path)
+	if err != nil {
+		t.Fatalf("LoadHardwareProfile returned error: %v", err)
+	}
+
+	summary := loaded.Summary()
+	if summary.CPUCores != 16 || summary.MemoryKB != 64*1024*1024 {
+		t.Fatalf("unexpected cpu/memory summary: %+v", summary)
+	}
+	if summary.GPUCount != 2 || summary.GPUName != "Tesla V100-SXM2" || summary.GPUVendor != "NVIDIA" {
+		t.Fatalf("expected integrated GPU to be skipped, got %+v", summary)
+	}
+	if summary.GPUVRAMMB != 16160 || summary.VRAMGB() != 16 {
+		t.Fatalf("expected smallest per-GPU VRAM of 16GB, got %dMB / %dGB", summary.GPUVRAMMB, summary.VRAMGB())
+	}
+	if summary.GPUComputeCapability != "7.0" || !summary.HasCUDAGPU() {
+		t.Fatalf("unexpected cuda facts: %+v", summary)
+	}
+}
+
+func TestLoadHardwareProfileRejectsUnknownSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hardware_profile.json")
+	if err := os.WriteFile(path, []byte(`{"schema_version": 99}`), 0o644); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	if _, err := LoadHardwareProfile(path); err == nil {
+		t.Fatalf("expected schema version error")
+	}
+}
+
+func TestLoadHardwareSummaryPrefersTypedProfile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	baseInfoPath := filepath.Join(home, ".localaistack", "base_info.json")
+	if err := os.MkdirAll(filepath.Dir(baseInfoPath), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(baseInfoPath, []byte(`{"cpu":{"cores":4},"gpu":"NVIDIA RTX 3060 12GB","memory":"8 GB"}`), 0o644); err != nil {
+		t.Fatalf("write base info: %v", err)
+	}
+
+	legacy, err := LoadHardwareSummary(baseInfoPath)
+	if err != nil {
+		t.Fatalf("LoadHardwareSummary returned error: %v", err)
+	}
+	if legacy.CPUCores != 4 || legacy.VRAMGB() != 0 {
+		t.Fatalf("expected legacy fallback without a guessed VRAM figure, got %+v", legacy)
+	}
+
+	doc := HardwareProfileDocument{
+		SchemaVersion: HardwareProfileSchemaVersion,
+		Profile: hardware.HardwareProfile{
+			CPU:  hardware.CPU{Cores: 24},
+			GPUs: []hardware.GPU{{Name: "AMD Radeon PRO W7900", Vendor: "AMD", VRAMTotal: 48 << 30}},
+		},
+	}
+	if err := WriteHardwareProfile(ResolveHardwareProfilePath(), doc); err != nil {
+		t.Fatalf("WriteHardwareProfile returned error: %v", err)
+	}
+	typed, err := LoadHardwareSummary(baseInfoPath)
+	if err != nil {
+		t.Fatalf("LoadHardwareSummary returned error: %v", err)
+	}
+	if typed.CPUCores != 24 || typed.VRAMGB() != 48 || typed.HasCUDAGPU() {
+		t.Fatalf("expected typed profile summary, got %+v", typed)
+	}
+}
@@ -1,45 +1,55 @@
 package hardware
 
-import "github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+)
 
 type CPU struct {
-	Arch      string
-	Cores     int
-	Threads   int
-	ModelName string
-	Vendor    string
+	Arch      string `json:"arch"`
+	Cores     int    `json:"cores"`
+	Threads   int    `json:"threads"`
+	ModelName string `json:"model_name"`
+	Vendor    string `json:"vendor"`
 }
 
 type GPU struct {
-	Index         int
-	Name          string
-	Vendor        string
-	VRAMTotal     uint64
-	VRAMFree      uint64
-	CUDAVersion   string
-	DriverVersion string
-	MultiGPU      bool
-	NVLink        bool
+	Index     int    `json:"index"`
+	Name      string `json:"name"`
+	Vendor    string `json:"vendor"`
+	VRAMTotal uint64 `json:"vram_total"`
+	VRAMFree  uint64 `json:"vram_free"`
+	// ComputeCapability is the CUDA compute capability, e.g. "8.6".
+	ComputeCapability string `json:"compute_capability,omitempty"`
+	CUDAVersion       string `json:"cuda_version,omitempty"`
+	DriverVersion     string `json:"driver_version,omitempty"`
+	MultiGPU          bool   `json:"multi_gpu"`
+	NVLink            bool   `json:"nvlink"`
 }
 
 type Memory struct {
-	Total     uint64
-	Available uint64
-	Free      uint64
+	Total     uint64 `json:"total"`
+	Available uint64 `json:"available"`
+	Free      uint64 `json:"free"`
 }
 
 type Storage struct {
-	Path  string
-	Total uint64
-	Free  uint64
-	Type  string
+	Path  string `json:"path"`
+	Total uint64 `json:"total"`
+	Free  uint64 `json:"free"`
+	Type  string `json:"type"`
 }
 
 type HardwareProfile struct {
-	CPU     CPU
-	GPUs    []GPU
-	Memory  Memory
-	Storage []Storage
+	CPU     CPU       `json:"cpu"`
+	GPUs    []GPU     `json:"gpus"`
+	Memory  Memory    `json:"memory"`
+	Storage []Storage `json:"storage"`
 }
 
 type Detector interface {
@@ -50,43 +60,107 @@ type Detector interface {
 	Detect() (*HardwareProfile, error)
 }
 
-type NativeDetector struct{}
+// CommandRunner executes an external tool and returns its standard output.
+type CommandRunner func(name string, args ...string) ([]byte, error)
+
+// StatfsFunc reports the total and free bytes of the filesystem mounted at path.
+type StatfsFunc func(path string) (total uint64, free uint64, err error)
+
+// DetectorOptions makes the native detector testable against a fixture tree.
+type DetectorOptions struct {
+	// Root is prepended to /proc and /sys paths. Empty means the real root.
+	Root   string
+	Runner CommandRunner
+	Statfs StatfsFunc
+	Arch   string
+	// Probes overrides the registered GPU probes.
+	Probes []GPUProbe
+}
+
+type NativeDetector struct {
+	root   string
+	run    CommandRunner
+	statfs StatfsFunc
+	arch   string
+	probes []GPUProbe
+}
 
 func NewNativeDetector() Detector {
-	return &NativeDetector{}
+	return NewNativeDetectorWithOptions(DetectorOptions{})
 }
 
-func (d *NativeDetector) DetectCPU() (CPU, error) {
-	return CPU{
-		Arch:      "x86_64",
-		Cores:     8,
-		Threads:   16,
-		ModelName: "Placeholder",
-		Vendor:    "Unknown",
-	}, nil
+func NewNativeDetectorWithOptions(opts DetectorOptions) *NativeDetector {
+	d := &NativeDetector{
+		root:   opts.Root,
+		run:    opts.Runner,
+		statfs: opts.Statfs,
+		arch:   opts.Arch,
+		probes: opts.Probes,
+	}
+	if d.run == nil {
+		d.run = runCommand
+	}
+	if d.statfs == nil {
+		d.statfs = statfs
+	}
+	if d.arch == "" {
+		d.arch = normalizeArch(goruntime.GOARCH)
+	}
+	return d
 }
 
-func (d *NativeDetector) DetectGPUs() ([]GPU, error) {
-	return []GPU{}, nil
+func (d *NativeDetector) DetectCPU() (CPU, error) {
+	data, err := os.ReadFile(d.path("/proc/cpuinfo"))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return CPU{}, err
+		}
+		threads := goruntime.NumCPU()
+		return CPU{Arch: d.arch, Cores: threads, Threads: threads, ModelName: "Unknown", Vendor: "Unknown"}, nil
+	}
+	cpu := parseCPUInfo(data)
+	cpu.Arch = d.arch
+	return cpu, nil
 }
 
+// DetectMemory reads /proc/meminfo. Without procfs the memory is unknown
+// and reported as zero.
 func (d *NativeDetector) DetectMemory() (Memory, error) {
-	return Memory{
-		Total:     16 * 1024 * 1024 * 1024,
-		Available: 8 * 1024 * 1024 * 1024,
-		Free:      6 * 1024 * 1024 * 1024,
-	}, nil
+	data, err := os.ReadFile(d.path("/proc/meminfo"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Memory{}, nil
+		}
+		return Memory{}, err
+	}
+	return parseMemInfo(data)
 }
 
+// DetectStorage reports the mounts in /proc/mounts. Without procfs only the
+// root filesystem is reported, when statfs supports it.
 func (d *NativeDetector) DetectStorage() ([]Storage, error) {
-	return []Storage{
-		{
-			Path:  "/",
-			Total: 500 * 1024 * 1024 * 1024,
-			Free:  300 * 1024 * 1024 * 1024,
-			Type:  "ext4",
-		},
-	}, nil
+	mounts := []mountEntry{{Path: "/"}}
+	data, err := os.ReadFile(d.path("/proc/mounts"))
+	switch {
+	case err == nil:
+		mounts = parseMounts(data)
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+	storage := make([]Storage, 0)
+	for _, mount := range mounts {
+		total, free, err := d.statfs(d.path(mount.Path))
+		if err != nil || total == 0 {
+			continue
+		}
+		storage = append(storage, Storage{
+			Path:  mount.Path,
+			Total: total,
+			Free:  free,
+			Type:  mount.Type,
+		})
+	}
+	return storage, nil
 }
 
 func (d *NativeDetector) Detect() (*HardwareProfile, error) {
@@ -117,3 +191,10 @@ func (d *NativeDetector) Detect() (*HardwareProfile, error) {
 		Storage: storage,
 	}, nil
 }
+
+func (d *NativeDetector) path(name string) string {
+	if d.root == "" {
+		return name
+	}
+	return filepath.Join(d.root, name)
+}
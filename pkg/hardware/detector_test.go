@@ -0,0 +1,142 @@
+package hardware
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func fixtureRunner(t *testing.T, outputs map[string]string) CommandRunner {
+	t.Helper()
+	return func(name string, args ...string) ([]byte, error) {
+		file, ok := outputs[strings.Join(append([]string{name}, args...), " ")]
+		if !ok {
+			return nil, exec.ErrNotFound
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", "tools", file))
+		if err != nil {
+			t.Fatalf("read fixture %s: %v", file, err)
+		}
+		return data, nil
+	}
+}
+
+func fixtureStatfs(sizes map[string][2]uint64) StatfsFunc {
+	return func(path string) (uint64, uint64, error) {
+		for suffix, size := range sizes {
+			if strings.HasSuffix(filepath.ToSlash(path), suffix) {
+				return size[0], size[1], nil
+			}
+		}
+		return 0, 0, os.ErrNotExist
+	}
+}
+
+func TestNativeDetectorNvidiaFixture(t *testing.T) {
+	detector := NewNativeDetectorWithOptions(DetectorOptions{
+		Root: filepath.Join("testdata", "linux-nvidia"),
+		Runner: fixtureRunner(t, map[string]string{
+			"nvidia-smi --query-gpu=" + nvidiaQueryFields + " --format=csv,noheader,nounits": "nvidia-smi-query.csv",
+		}),
+		Statfs: fixtureStatfs(map[string][2]uint64{
+			"linux-nvidia":             {500 << 30, 200 << 30},
+			"linux-nvidia/boot/efi":    {512 << 20, 500 << 20},
+			"linux-nvidia/data models": {2 << 40, 1 << 40},
+		}),
+		Arch: "x86_64",
+	})
+
+	profile, err := detector.Detect()
+	if err != nil {
+		t.Fatalf("Detect returned error: %v", err)
+	}
+
+	cpu := profile.CPU
+	if cpu.ModelName != "Intel(R) Core(TM) i7-7700K CPU @ 4.20GHz" || cpu.Vendor != "Intel" || cpu.Arch != "x86_64" {
+		t.Fatalf("unexpected cpu identity: %+v", cpu)
+	}
+	if cpu.Cores != 4 || cpu.Threads != 8 {
+		t.Fatalf("expected 4 cores / 8 threads, got %d / %d", cpu.Cores, cpu.Threads)
+	}
+
+	if profile.Memory.Total != 32768000*1024 || profile.Memory.Available != 16384000*1024 || profile.Memory.Free != 2048000*1024 {
+		t.Fatalf("unexpected memory: %+v", profile.Memory)
+	}
+
+	if len(profile.Storage) != 3 {
+		t.Fatalf("expected 3 storage entries, got %+v", profile.Storage)
+	}
+	if profile.Storage[0].Path != "/" || profile.Storage[0].Type != "ext4" || profile.Storage[0].Total != 500<<30 {
+		t.Fatalf("unexpected root storage: %+v", profile.Storage[0])
+	}
+	if profile.Storage[2].Path != "/data models" || profile.Storage[2].Type != "xfs" {
+		t.Fatalf("expected escaped mount path to be decoded, got %+v", profile.Storage[2])
+	}
+
+	if len(profile.GPUs) != 2 {
+		t.Fatalf("expected NVIDIA and Intel GPUs, got %+v", profile.GPUs)
+	}
+	nvidia := profile.GPUs[0]
+	if nvidia.Name != "NVIDIA GeForce RTX 3090" || nvidia.Vendor != "NVIDIA" || nvidia.DriverVersion != "535.129.03" {
+		t.Fatalf("unexpected nvidia gpu: %+v", nvidia)
+	}
+	if nvidia.ComputeCapability != "8.6" || nvidia.NVLink {
+		t.Fatalf("unexpected nvidia capability: %+v", nvidia)
+	}
+	if nvidia.VRAMTotal != 24576<<20 || nvidia.VRAMFree != 23800<<20 || !nvidia.MultiGPU {
+		t.Fatalf("unexpected nvidia memory: %+v", nvidia)
+	}
+	if profile.GPUs[1].Vendor != "Intel" {
+		t.Fatalf("expected sysfs Intel GPU, got %+v", profile.GPUs[1])
+	}
+}
+
+func TestNativeDetectorAMDSysfsFallback(t *testing.T) {
+	detector := NewNativeDetectorWithOptions(DetectorOptions{
+		Root:   filepath.Join("testdata", "linux-amd"),
+		Runner: fixtureRunner(t, nil),
+		Statfs: fixtureStatfs(map[string][2]uint64{"linux-amd": {100 << 30, 40 << 30}}),
+		Arch:   "aarch64",
+	})
+
+	profile, err := detector.Detect()
+	if err != nil {
+		t.Fatalf("Detect returned error: %v", err)
+	}
+	if profile.CPU.Vendor != "ARM" || profile.CPU.Cores != 4 || profile.CPU.Threads != 4 {
+		t.Fatalf("unexpected cpu: %+v", profile.CPU)
+	}
+	if profile.Memory.Available != (4096000+1024000+10240000)*1024 {
+		t.Fatalf("expected MemAvailable fallback, got %+v", profile.Memory)
+	}
+	if len(profile.Storage) != 1 || profile.Storage[0].Type != "overlay" {
+		t.Fatalf("expected overlay root storage, got %+v", profile.Storage)
+	}
+	if len(profile.GPUs) != 1 {
+		t.Fatalf("expected one AMD gpu, got %+v", profile.GPUs)
+	}
+	gpu := profile.GPUs[0]
+	if gpu.Vendor != "AMD" || gpu.VRAMTotal != 17163091968 || gpu.VRAMFree != 17163091968-1073741824 || gpu.MultiGPU {
+		t.Fatalf("unexpected amd gpu: %+v", gpu)
+	}
+}
+
+func TestDetectWithoutProcfs(t *testing.T) {
+	detector := NewNativeDetectorWithOptions(DetectorOptions{
+		Root:   t.TempDir(),
+		Statfs: func(path string) (uint64, uint64, error) { return 100, 40, nil },
+		Probes: []GPUProbe{},
+	})
+	profile, err := detector.Detect()
+	if err != nil {
+		t.Fatalf("Detect returned error without procfs: %v", err)
+	}
+	if profile.Memory.Total != 0 {
+		t.Fatalf("expected unknown memory, got %+v", profile.Memory)
+	}
+	if len(profile.Storage) != 1 || profile.Storage[0].Path != "/" || profile.Storage[0].Free != 40 {
+		t.Fatalf("expected the root filesystem, got %+v", profile.Storage)
+	}
+}
@@ -0,0 +1,111 @@
+package hardware
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	pciVendorNVIDIA = "0x10de"
+	pciVendorAMD    = "0x1002"
+	pciVendorIntel  = "0x8086"
+)
+
+var drmCardPattern = regexp.MustCompile(`^card(\d+)$`)
+
+// DRMCard is a display device listed under /sys/class/drm.
+type DRMCard struct {
+	Card      int
+	VendorID  string
+	DeviceID  string
+	VRAMTotal uint64
+	VRAMUsed  uint64
+}
+
+func (d *NativeDetector) drmCards() []DRMCard {
+	entries, err := os.ReadDir(d.path("/sys/class/drm"))
+	if err != nil {
+		return nil
+	}
+	var cards []DRMCard
+	for _, entry := range entries {
+		match := drmCardPattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(match[1])
+		deviceDir := filepath.Join(d.path("/sys/class/drm"), entry.Name(), "device")
+		vendor := readSysfsString(filepath.Join(deviceDir, "vendor"))
+		if vendor == "" {
+			continue
+		}
+		cards = append(cards, DRMCard{
+			Card:      index,
+			VendorID:  strings.ToLower(vendor),
+			DeviceID:  strings.ToLower(readSysfsString(filepath.Join(deviceDir, "device"))),
+			VRAMTotal: readSysfsUint(filepath.Join(deviceDir, "mem_info_vram_total")),
+			VRAMUsed:  readSysfsUint(filepath.Join(deviceDir, "mem_info_vram_used")),
+		})
+	}
+	sort.Slice(cards, func(i, j int) bool { return cards[i].Card < cards[j].Card })
+	return cards
+}
+
+func sysfsGPUs(cards []DRMCard, vendorID string) []GPU {
+	var gpus []GPU
+	for _, card := range cards {
+		if card.VendorID != vendorID {
+			continue
+		}
+		vendor := gpuVendorName(card.VendorID)
+		gpu := GPU{
+			Index:     len(gpus),
+			Name:      strings.TrimSpace(vendor + " GPU " + card.DeviceID),
+			Vendor:    vendor,
+			VRAMTotal: card.VRAMTotal,
+		}
+		if card.VRAMTotal >= card.VRAMUsed {
+			gpu.VRAMFree = card.VRAMTotal - card.VRAMUsed
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus
+}
+
+func gpuVendorName(vendorID string) string {
+	switch vendorID {
+	case pciVendorNVIDIA:
+		return "NVIDIA"
+	case pciVendorAMD:
+		return "AMD"
+	case pciVendorIntel:
+		return "Intel"
+	default:
+		return vendorID
+	}
+}
+
+func parseMiB(value string) uint64 {
+	n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return uint64(n * 1024 * 1024)
+}
+
+func readSysfsString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func readSysfsUint(path string) uint64 {
+	n, _ := strconv.ParseUint(readSysfsString(path), 10, 64)
+	return n
+}
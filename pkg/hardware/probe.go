@@ -0,0 +1,64 @@
+package hardware
+
+import "sync"
+
+// GPUProbe discovers the GPUs of a single vendor. Probes prefer the vendor's
+// management tool and fall back to the sysfs view when the tool is missing.
+type GPUProbe interface {
+	Name() string
+	Probe(env ProbeEnv) ([]GPU, error)
+}
+
+// ProbeEnv is the host view handed to each probe.
+type ProbeEnv struct {
+	Run   CommandRunner
+	Cards []DRMCard
+}
+
+var (
+	gpuProbesMu sync.RWMutex
+	gpuProbes   = []GPUProbe{nvidiaProbe{}, amdProbe{}, intelProbe{}}
+)
+
+// RegisterGPUProbe adds a probe that runs after the built-in vendor probes.
+func RegisterGPUProbe(probe GPUProbe) {
+	if probe == nil {
+		return
+	}
+	gpuProbesMu.Lock()
+	defer gpuProbesMu.Unlock()
+	gpuProbes = append(gpuProbes, probe)
+}
+
+// GPUProbes returns the registered probes in execution order.
+func GPUProbes() []GPUProbe {
+	gpuProbesMu.RLock()
+	defer gpuProbesMu.RUnlock()
+	return append([]GPUProbe(nil), gpuProbes...)
+}
+
+// DetectGPUs runs every probe and merges their results. Hosts without a
+// supported accelerator get an empty list and are treated as CPU-only.
+func (d *NativeDetector) DetectGPUs() ([]GPU, error) {
+	env := ProbeEnv{Run: d.run, Cards: d.drmCards()}
+	probes := d.probes
+	if probes == nil {
+		probes = GPUProbes()
+	}
+
+	gpus := make([]GPU, 0)
+	for _, probe := range probes {
+		found, err := probe.Probe(env)
+		if err != nil {
+			continue
+		}
+		gpus = append(gpus, found...)
+	}
+
+	if len(gpus) > 1 {
+		for i := range gpus {
+			gpus[i].MultiGPU = true
+		}
+	}
+	return gpus, nil
+}
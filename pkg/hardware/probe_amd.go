@@ -0,0 +1,77 @@
+package hardware
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type amdProbe struct{}
+
+func (amdProbe) Name() string { return "amd" }
+
+func (amdProbe) Probe(env ProbeEnv) ([]GPU, error) {
+	out, err := env.Run("rocm-smi", "--showproductname", "--showmeminfo", "vram", "--showdriverversion", "--json")
+	if err != nil {
+		return sysfsGPUs(env.Cards, pciVendorAMD), nil
+	}
+	gpus, err := parseROCmSMIJSON(out)
+	if err != nil || len(gpus) == 0 {
+		return sysfsGPUs(env.Cards, pciVendorAMD), nil
+	}
+	return gpus, nil
+}
+
+// parseROCmSMIJSON parses `rocm-smi --showproductname --showmeminfo vram
+// --showdriverversion --json`, which reports one object per card keyed as
+// "card0", "card1", ... plus a "system" object carrying the driver version.
+func parseROCmSMIJSON(data []byte) ([]GPU, error) {
+	var payload map[string]map[string]string
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	driverVersion := ""
+	for key, fields := range payload {
+		if strings.EqualFold(key, "system") {
+			driverVersion = lowerKeys(fields)["driver version"]
+		}
+	}
+
+	var gpus []GPU
+	for key, fields := range payload {
+		match := drmCardPattern.FindStringSubmatch(strings.ToLower(key))
+		if match == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(match[1])
+		values := lowerKeys(fields)
+		name := values["card series"]
+		if name == "" {
+			name = values["card model"]
+		}
+		total, _ := strconv.ParseUint(values["vram total memory (b)"], 10, 64)
+		used, _ := strconv.ParseUint(values["vram total used memory (b)"], 10, 64)
+		gpu := GPU{
+			Index:         index,
+			Name:          name,
+			Vendor:        "AMD",
+			VRAMTotal:     total,
+			DriverVersion: driverVersion,
+		}
+		if total >= used {
+			gpu.VRAMFree = total - used
+		}
+		gpus = append(gpus, gpu)
+	}
+	sort.Slice(gpus, func(i, j int) bool { return gpus[i].Index < gpus[j].Index })
+	return gpus, nil
+}
+
+func lowerKeys(fields map[string]string) map[string]string {
+	values := make(map[string]string, len(fields))
+	for k, v := range fields {
+		values[strings.ToLower(k)] = strings.TrimSpace(v)
+	}
+	return values
+}
@@ -0,0 +1,76 @@
+package hardware
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+type intelProbe struct{}
+
+func (intelProbe) Name() string { return "intel" }
+
+func (intelProbe) Probe(env ProbeEnv) ([]GPU, error) {
+	out, err := env.Run("xpu-smi", "discovery", "--json")
+	if err != nil {
+		return sysfsGPUs(env.Cards, pciVendorIntel), nil
+	}
+	gpus, err := parseXPUSMIDiscovery(out)
+	if err != nil || len(gpus) == 0 {
+		return sysfsGPUs(env.Cards, pciVendorIntel), nil
+	}
+	for i := range gpus {
+		detail, err := env.Run("xpu-smi", "discovery", "-d", strconv.Itoa(gpus[i].Index), "--json")
+		if err != nil {
+			continue
+		}
+		applyXPUSMIDeviceDetail(&gpus[i], detail)
+	}
+	return gpus, nil
+}
+
+type xpuSMIDevice struct {
+	DeviceID           int    `json:"device_id"`
+	DeviceName         string `json:"device_name"`
+	DeviceType         string `json:"device_type"`
+	DriverVersion      string `json:"driver_version"`
+	MemoryPhysicalSize string `json:"memory_physical_size_byte"`
+	MemoryFreeSize     string `json:"memory_free_size_byte"`
+}
+
+// parseXPUSMIDiscovery parses `xpu-smi discovery --json`.
+func parseXPUSMIDiscovery(data []byte) ([]GPU, error) {
+	var payload struct {
+		DeviceList []xpuSMIDevice `json:"device_list"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	var gpus []GPU
+	for _, device := range payload.DeviceList {
+		if device.DeviceType != "" && !strings.EqualFold(device.DeviceType, "GPU") {
+			continue
+		}
+		gpus = append(gpus, GPU{
+			Index:  device.DeviceID,
+			Name:   device.DeviceName,
+			Vendor: "Intel",
+		})
+	}
+	return gpus, nil
+}
+
+// applyXPUSMIDeviceDetail fills memory and driver facts from
+// `xpu-smi discovery -d <id> --json`.
+func applyXPUSMIDeviceDetail(gpu *GPU, data []byte) {
+	var device xpuSMIDevice
+	if err := json.Unmarshal(data, &device); err != nil {
+		return
+	}
+	if device.DeviceName != "" {
+		gpu.Name = device.DeviceName
+	}
+	gpu.DriverVersion = device.DriverVersion
+	gpu.VRAMTotal, _ = strconv.ParseUint(strings.TrimSpace(device.MemoryPhysicalSize), 10, 64)
+	gpu.VRAMFree, _ = strconv.ParseUint(strings.TrimSpace(device.MemoryFreeSize), 10, 64)
+}
@@ -0,0 +1,135 @@
+package hardware
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	nvidiaQueryFields       = "index,name,memory.total,memory.free,driver_version,compute_cap"
+	nvidiaLegacyQueryFields = "index,name,memory.total,memory.free,driver_version"
+)
+
+var (
+	ansiEscapeRe        = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	nvidiaCUDAVersionRe = regexp.MustCompile(`CUDA Version:\s*([0-9][0-9.]*)`)
+	nvidiaTopoGPURe     = regexp.MustCompile(`^GPU(\d+)$`)
+	nvidiaTopoNVLinkRe  = regexp.MustCompile(`^NV\d+$`)
+)
+
+type nvidiaProbe struct{}
+
+func (nvidiaProbe) Name() string { return "nvidia" }
+
+func (nvidiaProbe) Probe(env ProbeEnv) ([]GPU, error) {
+	out, err := env.Run("nvidia-smi", "--query-gpu="+nvidiaQueryFields, "--format=csv,noheader,nounits")
+	if err != nil {
+		// compute_cap is only understood by drivers >= 510.
+		out, err = env.Run("nvidia-smi", "--query-gpu="+nvidiaLegacyQueryFields, "--format=csv,noheader,nounits")
+	}
+	if err != nil {
+		return sysfsGPUs(env.Cards, pciVendorNVIDIA), nil
+	}
+	gpus := parseNvidiaSMIQuery(out)
+	if len(gpus) == 0 {
+		return sysfsGPUs(env.Cards, pciVendorNVIDIA), nil
+	}
+
+	if header, err := env.Run("nvidia-smi"); err == nil {
+		if cudaVersion := parseNvidiaCUDAVersion(header); cudaVersion != "" {
+			for i := range gpus {
+				gpus[i].CUDAVersion = cudaVersion
+			}
+		}
+	}
+	if len(gpus) > 1 {
+		if topo, err := env.Run("nvidia-smi", "topo", "-m"); err == nil {
+			linked := parseNvidiaTopoNVLink(topo)
+			for i := range gpus {
+				gpus[i].NVLink = linked[gpus[i].Index]
+			}
+		}
+	}
+	return gpus, nil
+}
+
+// parseNvidiaSMIQuery parses `nvidia-smi --query-gpu=<nvidiaQueryFields>
+// --format=csv,noheader,nounits`. Memory is reported in MiB.
+func parseNvidiaSMIQuery(data []byte) []GPU {
+	var gpus []GPU
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) < 5 {
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		gpu := GPU{
+			Index:         index,
+			Name:          fields[1],
+			Vendor:        "NVIDIA",
+			VRAMTotal:     parseMiB(fields[2]),
+			VRAMFree:      parseMiB(fields[3]),
+			DriverVersion: fields[4],
+		}
+		if len(fields) > 5 && !strings.HasPrefix(fields[5], "[") {
+			gpu.ComputeCapability = fields[5]
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus
+}
+
+// parseNvidiaCUDAVersion reads the "CUDA Version" banner of plain `nvidia-smi`.
+func parseNvidiaCUDAVersion(data []byte) string {
+	match := nvidiaCUDAVersionRe.FindSubmatch(data)
+	if match == nil {
+		return ""
+	}
+	return string(match[1])
+}
+
+// parseNvidiaTopoNVLink reads the `nvidia-smi topo -m` matrix and reports the
+// GPU indices that have at least one NVLink (NV#) connection to a peer.
+func parseNvidiaTopoNVLink(data []byte) map[int]bool {
+	linked := make(map[int]bool)
+	var columns []int
+	for _, line := range strings.Split(ansiEscapeRe.ReplaceAllString(string(data), ""), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if columns == nil {
+			for _, field := range fields {
+				match := nvidiaTopoGPURe.FindStringSubmatch(field)
+				if match == nil {
+					break
+				}
+				index, _ := strconv.Atoi(match[1])
+				columns = append(columns, index)
+			}
+			continue
+		}
+		match := nvidiaTopoGPURe.FindStringSubmatch(fields[0])
+		if match == nil {
+			continue
+		}
+		row, _ := strconv.Atoi(match[1])
+		for i, cell := range fields[1:] {
+			if i >= len(columns) {
+				break
+			}
+			if nvidiaTopoNVLinkRe.MatchString(cell) {
+				linked[row] = true
+				linked[columns[i]] = true
+			}
+		}
+	}
+	return linked
+}
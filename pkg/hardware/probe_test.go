@@ -0,0 +1,136 @@
+package hardware
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNvidiaProbeDualNVLink(t *testing.T) {
+	env := ProbeEnv{Run: fixtureRunner(t, map[string]string{
+		"nvidia-smi --query-gpu=" + nvidiaQueryFields + " --format=csv,noheader,nounits": "nvidia-smi-query-dual.csv",
+		"nvidia-smi":         "nvidia-smi.txt",
+		"nvidia-smi topo -m": "nvidia-smi-topo.txt",
+	})}
+
+	gpus, err := nvidiaProbe{}.Probe(env)
+	if err != nil {
+		t.Fatalf("Probe returned error: %v", err)
+	}
+	if len(gpus) != 2 {
+		t.Fatalf("expected 2 gpus, got %+v", gpus)
+	}
+	for _, gpu := range gpus {
+		if gpu.Name != "Tesla V100-SXM2-16GB" || gpu.VRAMTotal != 16384<<20 {
+			t.Fatalf("unexpected gpu: %+v", gpu)
+		}
+		if gpu.CUDAVersion != "11.4" || gpu.DriverVersion != "470.182.03" || gpu.ComputeCapability != "7.0" {
+			t.Fatalf("unexpected versions: %+v", gpu)
+		}
+		if !gpu.NVLink {
+			t.Fatalf("expected NVLink on gpu %d", gpu.Index)
+		}
+	}
+}
+
+func TestNvidiaProbePCIeTopologyAndLegacyQuery(t *testing.T) {
+	env := ProbeEnv{Run: fixtureRunner(t, map[string]string{
+		"nvidia-smi --query-gpu=" + nvidiaLegacyQueryFields + " --format=csv,noheader,nounits": "nvidia-smi-query-legacy.csv",
+		"nvidia-smi topo -m": "nvidia-smi-topo-pcie.txt",
+	})}
+
+	gpus, err := nvidiaProbe{}.Probe(env)
+	if err != nil {
+		t.Fatalf("Probe returned error: %v", err)
+	}
+	if len(gpus) != 1 || gpus[0].Name != "Tesla P40" || gpus[0].ComputeCapability != "" || gpus[0].NVLink {
+		t.Fatalf("unexpected gpus: %+v", gpus)
+	}
+	if linked := parseNvidiaTopoNVLink([]byte("\tGPU0\tGPU1\nGPU0\t X \tPHB\nGPU1\tPHB\t X \n")); len(linked) != 0 {
+		t.Fatalf("expected no NVLink for PCIe topology, got %v", linked)
+	}
+}
+
+func TestNvidiaProbeFallsBackToSysfs(t *testing.T) {
+	env := ProbeEnv{
+		Run:   fixtureRunner(t, nil),
+		Cards: []DRMCard{{Card: 0, VendorID: pciVendorNVIDIA, DeviceID: "0x1db4"}, {Card: 1, VendorID: pciVendorIntel}},
+	}
+	gpus, err := nvidiaProbe{}.Probe(env)
+	if err != nil {
+		t.Fatalf("Probe returned error: %v", err)
+	}
+	if len(gpus) != 1 || gpus[0].Vendor != "NVIDIA" || !strings.Contains(gpus[0].Name, "0x1db4") {
+		t.Fatalf("unexpected sysfs fallback: %+v", gpus)
+	}
+}
+
+func TestAMDProbeROCmSMI(t *testing.T) {
+	env := ProbeEnv{Run: fixtureRunner(t, map[string]string{
+		"rocm-smi --showproductname --showmeminfo vram --showdriverversion --json": "rocm-smi.json",
+	})}
+	gpus, err := amdProbe{}.Probe(env)
+	if err != nil {
+		t.Fatalf("Probe returned error: %v", err)
+	}
+	if len(gpus) != 2 {
+		t.Fatalf("expected 2 gpus, got %+v", gpus)
+	}
+	if !strings.HasPrefix(gpus[0].Name, "Navi 21") || gpus[0].VRAMTotal != 17163091968 || gpus[0].VRAMFree != 17163091968-1073741824 {
+		t.Fatalf("unexpected card0: %+v", gpus[0])
+	}
+	if gpus[1].Index != 1 || gpus[1].DriverVersion != "6.7.0" {
+		t.Fatalf("unexpected card1: %+v", gpus[1])
+	}
+}
+
+func TestIntelProbeXPUSMI(t *testing.T) {
+	env := ProbeEnv{Run: fixtureRunner(t, map[string]string{
+		"xpu-smi discovery --json":      "xpu-smi-discovery.json",
+		"xpu-smi discovery -d 0 --json": "xpu-smi-device0.json",
+	})}
+	gpus, err := intelProbe{}.Probe(env)
+	if err != nil {
+		t.Fatalf("Probe returned error: %v", err)
+	}
+	if len(gpus) != 1 {
+		t.Fatalf("expected 1 gpu, got %+v", gpus)
+	}
+	gpu := gpus[0]
+	if gpu.Name != "Intel(R) Data Center GPU Flex 170" || gpu.VRAMTotal != 14193102848 || gpu.VRAMFree != 14000000000 {
+		t.Fatalf("unexpected gpu: %+v", gpu)
+	}
+	if gpu.DriverVersion != "I915_23.10.72_PSB_230612.12" {
+		t.Fatalf("unexpected driver version: %q", gpu.DriverVersion)
+	}
+}
+
+func TestDetectGPUsWithoutAcceleratorIsEmpty(t *testing.T) {
+	detector := NewNativeDetectorWithOptions(DetectorOptions{
+		Root:   t.TempDir(),
+		Runner: fixtureRunner(t, nil),
+	})
+	gpus, err := detector.DetectGPUs()
+	if err != nil {
+		t.Fatalf("DetectGPUs returned error: %v", err)
+	}
+	if gpus == nil || len(gpus) != 0 {
+		t.Fatalf("expected empty gpu list, got %#v", gpus)
+	}
+}
+
+type staticProbe struct{ gpus []GPU }
+
+func (staticProbe) Name() string { return "static" }
+
+func (p staticProbe) Probe(ProbeEnv) ([]GPU, error) { return p.gpus, nil }
+
+func TestDetectGPUsUsesProbeOverride(t *testing.T) {
+	detector := NewNativeDetectorWithOptions(DetectorOptions{
+		Root:   t.TempDir(),
+		Probes: []GPUProbe{staticProbe{gpus: []GPU{{Name: "a"}, {Name: "b"}}}},
+	})
+	gpus, _ := detector.DetectGPUs()
+	if len(gpus) != 2 || !gpus[0].MultiGPU || !gpus[1].MultiGPU {
+		t.Fatalf("expected merged multi-gpu result, got %+v", gpus)
+	}
+}
@@ -0,0 +1,227 @@
+package hardware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+)
+
+const commandTimeout = 10 * time.Second
+
+func runCommand(name string, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+func normalizeArch(goarch string) string {
+	switch goarch {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	case "386":
+		return "i686"
+	default:
+		return goarch
+	}
+}
+
+func parseCPUInfo(data []byte) CPU {
+	cpu := CPU{ModelName: "Unknown", Vendor: "Unknown"}
+	type coreKey struct {
+		physical string
+		core     string
+	}
+	cores := make(map[coreKey]struct{})
+	physical := ""
+	hasCoreIDs := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		switch key {
+		case "processor":
+			cpu.Threads++
+			physical = ""
+		case "physical id":
+			physical = value
+		case "core id":
+			hasCoreIDs = true
+			cores[coreKey{physical: physical, core: value}] = struct{}{}
+		case "model name", "Processor", "Hardware", "cpu model":
+			if cpu.ModelName == "Unknown" && value != "" {
+				cpu.ModelName = value
+			}
+		case "vendor_id", "CPU implementer":
+			if cpu.Vendor == "Unknown" && value != "" {
+				cpu.Vendor = cpuVendorName(value)
+			}
+		}
+	}
+
+	cpu.Cores = cpu.Threads
+	if hasCoreIDs && len(cores) > 0 {
+		cpu.Cores = len(cores)
+	}
+	return cpu
+}
+
+func cpuVendorName(value string) string {
+	switch strings.ToLower(value) {
+	case "genuineintel":
+		return "Intel"
+	case "authenticamd":
+		return "AMD"
+	case "hygongenuine":
+		return "Hygon"
+	case "centaurhauls":
+		return "Centaur"
+	case "0x41":
+		return "ARM"
+	case "0x48":
+		return "HiSilicon"
+	case "0x4e":
+		return "NVIDIA"
+	case "0x51":
+		return "Qualcomm"
+	case "0x61":
+		return "Apple"
+	case "0xc0":
+		return "Ampere"
+	default:
+		return value
+	}
+}
+
+func parseMemInfo(data []byte) (Memory, error) {
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 1 && strings.EqualFold(fields[1], "kB") {
+			n *= 1024
+		}
+		values[strings.TrimSpace(key)] = n
+	}
+
+	total, ok := values["MemTotal"]
+	if !ok {
+		return Memory{}, i18n.Errorf("MemTotal not found in meminfo")
+	}
+	memory := Memory{
+		Total: total,
+		Free:  values["MemFree"],
+	}
+	if available, ok := values["MemAvailable"]; ok {
+		memory.Available = available
+	} else {
+		memory.Available = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+	return memory, nil
+}
+
+type mountEntry struct {
+	Device string
+	Path   string
+	Type   string
+}
+
+var storageFilesystems = map[string]bool{
+	"ext2":     true,
+	"ext3":     true,
+	"ext4":     true,
+	"xfs":      true,
+	"btrfs":    true,
+	"zfs":      true,
+	"f2fs":     true,
+	"jfs":      true,
+	"reiserfs": true,
+	"bcachefs": true,
+	"vfat":     true,
+	"exfat":    true,
+	"ntfs":     true,
+	"ntfs3":    true,
+	"fuseblk":  true,
+	"nfs":      true,
+	"nfs4":     true,
+	"cifs":     true,
+	"smb3":     true,
+}
+
+// parseMounts returns the block-backed and network filesystems listed in
+// /proc/mounts, skipping pseudo filesystems and repeated bind mounts.
+func parseMounts(data []byte) []mountEntry {
+	var mounts []mountEntry
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		entry := mountEntry{
+			Device: unescapeMountField(fields[0]),
+			Path:   unescapeMountField(fields[1]),
+			Type:   fields[2],
+		}
+		if !storageFilesystems[entry.Type] && !(entry.Type == "overlay" && entry.Path == "/") {
+			continue
+		}
+		key := entry.Device
+		if entry.Type == "overlay" {
+			key = entry.Path
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		mounts = append(mounts, entry)
+	}
+	return mounts
+}
+
+// unescapeMountField decodes the octal escapes (\040 for space, etc.) used by
+// the kernel in /proc/mounts.
+func unescapeMountField(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+3 < len(value) {
+			if n, err := strconv.ParseUint(value[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
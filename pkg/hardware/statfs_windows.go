@@ -0,0 +1,9 @@
+//go:build windows
+
+package hardware
+
+import "github.com/zhuangbiaowei/LocalAIStack/internal/i18n"
+
+func statfs(path string) (uint64, uint64, error) {
+	return 0, 0, i18n.Errorf("statfs is not supported on windows: %s", path)
+}